@@ -0,0 +1,262 @@
+// Package aml screens accounts for money laundering risk and raises
+// suspicious activity cases for compliance officers.
+//
+// A Monitor runs two checks. Watchlist screening compares user, merchant and
+// shop names with the sanctions and PEP lists in a local file; the first run
+// after start screens every name, since the file may have changed, and later
+// runs only names added or changed since. Structuring detection looks for
+// customers breaking a large amount into many payments or transfers that each
+// stay under the reporting threshold, over calendar days in Location.
+//
+// Checks only raise cases. Suspending an account or filing a report is left
+// to an officer working the case.
+package aml
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+const (
+	// Interval is how often Run checks.
+	Interval = time.Hour
+	// screeningOverlap rescreens names changed just before the previous
+	// run started, covering clock skew between the app and the database.
+	screeningOverlap = time.Minute
+)
+
+// Location is East Africa Time, which has no daylight saving.
+var Location = time.FixedZone("EAT", 3*60*60)
+
+// StructuringRule flags a customer who makes at least MinCount payments in a
+// day, each of at least MinAmount but under Threshold, that add up to
+// Threshold or more. MinAmount keeps everyday small purchases out.
+type StructuringRule struct {
+	Threshold money.Amount
+	MinAmount money.Amount
+	MinCount  int
+}
+
+// DefaultStructuringRule flags five or more payments of KES 5,000 up to
+// KES 100,000 in a day that add up to KES 100,000 or more.
+var DefaultStructuringRule = StructuringRule{Threshold: 10000000, MinAmount: 500000, MinCount: 5}
+
+// ScreeningEvidence is the evidence of a sanctions or PEP case. ShopID is set
+// when the name that matched is one of a merchant's shops.
+type ScreeningEvidence struct {
+	List        string   `json:"list"`
+	EntryName   string   `json:"entry_name"`
+	Aliases     []string `json:"aliases"`
+	Reference   string   `json:"reference"`
+	MatchedName string   `json:"matched_name"`
+	ShopID      string   `json:"shop_id,omitempty"`
+}
+
+// StructuringEvidence is the evidence of a structuring case: the payments
+// and transfers, oldest first, and the rule they tripped.
+type StructuringEvidence struct {
+	Day            string       `json:"day"`
+	Count          int          `json:"count"`
+	Total          money.Amount `json:"total"`
+	Threshold      money.Amount `json:"threshold"`
+	MinAmount      money.Amount `json:"min_amount"`
+	TransactionIDs []string     `json:"transaction_ids"`
+	TransferIDs    []string     `json:"transfer_ids"`
+	FirstAt        time.Time    `json:"first_at"`
+	LastAt         time.Time    `json:"last_at"`
+}
+
+type Monitor struct {
+	Store     store.AMLStore
+	Watchlist *Watchlist
+	Rule      StructuringRule
+	Logger    *log.Logger
+	now       func() time.Time
+	// screened is when the last successful screening started. The zero time
+	// screens every name.
+	screened time.Time
+}
+
+func NewMonitor(amlStore store.AMLStore, watchlist *Watchlist, logger *log.Logger) *Monitor {
+	return &Monitor{
+		Store:     amlStore,
+		Watchlist: watchlist,
+		Rule:      DefaultStructuringRule,
+		Logger:    logger,
+		now:       time.Now,
+	}
+}
+
+// Run checks once at start and then every Interval until ctx is cancelled.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(Interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := m.RunOnce()
+		if err != nil {
+			m.Logger.Printf("ERROR: error running AML checks at RunOnce: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce screens names and looks for structuring. It returns how many cases
+// it opened.
+func (m *Monitor) RunOnce() (int, error) {
+	screened, err := m.Screen()
+	if err != nil {
+		return screened, err
+	}
+	structured, err := m.DetectStructuring()
+	return screened + structured, err
+}
+
+// Screen checks the names added or changed since the last screening against
+// the watchlist and returns how many cases it opened.
+func (m *Monitor) Screen() (int, error) {
+	if m.Watchlist.Len() == 0 {
+		return 0, nil
+	}
+	started := m.now()
+	subjects, err := m.Store.GetScreeningSubjects(m.screened)
+	if err != nil {
+		return 0, err
+	}
+
+	opened := 0
+	for _, subject := range subjects {
+		for _, match := range m.Watchlist.Screen(subject.Name) {
+			created, err := m.raise(screeningCase(subject, match))
+			if err != nil {
+				return opened, err
+			}
+			if created {
+				opened++
+			}
+		}
+	}
+	m.screened = started.Add(-screeningOverlap)
+	return opened, nil
+}
+
+// DetectStructuring checks yesterday and today so payments made after the
+// last run before midnight are not missed, and returns how many cases it
+// opened.
+func (m *Monitor) DetectStructuring() (int, error) {
+	local := m.now().In(Location)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, Location)
+
+	opened := 0
+	for _, day := range []time.Time{today.AddDate(0, 0, -1), today} {
+		activities, err := m.Store.FindStructuring(day, day.AddDate(0, 0, 1), m.Rule.MinAmount, m.Rule.Threshold, m.Rule.MinCount)
+		if err != nil {
+			return opened, err
+		}
+		for _, activity := range activities {
+			created, err := m.raise(structuringCase(activity, day, m.Rule))
+			if err != nil {
+				return opened, err
+			}
+			if created {
+				opened++
+			}
+		}
+	}
+	return opened, nil
+}
+
+func (m *Monitor) raise(amlCase *store.AMLCase) (bool, error) {
+	raised, created, err := m.Store.RaiseAMLCase(amlCase)
+	if err != nil {
+		return false, fmt.Errorf("raising %s case for %s %s: %w", amlCase.Kind, amlCase.SubjectType, amlCase.SubjectID, err)
+	}
+	if created {
+		m.Logger.Printf("WARNING: opened %s case %s for %s %s", raised.Kind, raised.ID, raised.SubjectType, raised.SubjectID)
+	}
+	return created, nil
+}
+
+// screeningCase builds the case for subject matching a watchlist entry. The
+// fingerprint covers the entry and the name as screened, so a dismissed match
+// is raised again only if the subject changes its name.
+func screeningCase(subject *store.AMLSubject, match *Match) *store.AMLCase {
+	entry := match.Entry
+	entryKey := entry.Reference
+	if entryKey == "" {
+		entryKey = strings.Join(tokenize(entry.Name), " ")
+	}
+	fingerprint := sha256.Sum256([]byte(entry.List + "\x00" + entryKey + "\x00" + strings.Join(tokenize(subject.Name), " ")))
+
+	what := "Name"
+	if subject.ShopID != "" {
+		what = "Shop name"
+	}
+	summary := fmt.Sprintf("%s %q matches %q on the %s list", what, subject.Name, match.MatchedName, entry.List)
+	if match.MatchedName != entry.Name {
+		summary += fmt.Sprintf(", an alias of %q", entry.Name)
+	}
+	if entry.Reference != "" {
+		summary += fmt.Sprintf(" (reference %s)", entry.Reference)
+	}
+
+	evidence, _ := json.Marshal(ScreeningEvidence{
+		List:        entry.List,
+		EntryName:   entry.Name,
+		Aliases:     entry.Aliases,
+		Reference:   entry.Reference,
+		MatchedName: match.MatchedName,
+		ShopID:      subject.ShopID,
+	})
+	return &store.AMLCase{
+		SubjectType: subject.Type,
+		SubjectID:   subject.ID,
+		SubjectName: subject.Name,
+		Kind:        entry.List,
+		Fingerprint: hex.EncodeToString(fingerprint[:]),
+		Summary:     summary,
+		Evidence:    evidence,
+	}
+}
+
+// structuringCase builds the case for a user's payments on day. There is at
+// most one structuring case per user per day; later payments that day update
+// it while it is open.
+func structuringCase(activity *store.StructuringActivity, day time.Time, rule StructuringRule) *store.AMLCase {
+	date := day.Format(time.DateOnly)
+	evidence, _ := json.Marshal(StructuringEvidence{
+		Day:            date,
+		Count:          activity.Count,
+		Total:          activity.Total,
+		Threshold:      rule.Threshold,
+		MinAmount:      rule.MinAmount,
+		TransactionIDs: activity.TransactionIDs,
+		TransferIDs:    activity.TransferIDs,
+		FirstAt:        activity.FirstAt,
+		LastAt:         activity.LastAt,
+	})
+	return &store.AMLCase{
+		SubjectType: store.AMLSubjectUser,
+		SubjectID:   activity.UserID,
+		SubjectName: activity.Username,
+		Kind:        store.AMLCaseStructuring,
+		Fingerprint: date,
+		Summary: fmt.Sprintf("%d payments on %s totalling KES %s, each between KES %s and the KES %s threshold",
+			activity.Count, date, money.KES.Format(activity.Total), money.KES.Format(rule.MinAmount), money.KES.Format(rule.Threshold)),
+		Evidence: evidence,
+	}
+}
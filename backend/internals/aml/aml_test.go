@@ -0,0 +1,195 @@
+package aml
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+const testWatchlist = `# Test list
+list,name,aliases,reference
+sanctions,John Kamau Doe,Johnny Doe;J. K. Doe,UN-001
+pep,José Mwangi,,KE-PEP-7
+sanctions,Acme,,
+`
+
+func loadTestWatchlist(t *testing.T) *Watchlist {
+	t.Helper()
+	wl, err := LoadWatchlist(strings.NewReader(testWatchlist))
+	require.NoError(t, err)
+	return wl
+}
+
+func TestLoadWatchlist(t *testing.T) {
+	wl := loadTestWatchlist(t)
+	assert.Equal(t, 3, wl.Len())
+	assert.Equal(t, []string{"Johnny Doe", "J. K. Doe"}, wl.entries[0].Aliases)
+
+	_, err := LoadWatchlist(strings.NewReader("name,list\nJohn,sanctions\n"))
+	assert.Error(t, err, "wrong header")
+	_, err = LoadWatchlist(strings.NewReader("list,name,aliases,reference\nwanted,John Doe,,\n"))
+	assert.Error(t, err, "unknown list")
+	_, err = LoadWatchlist(strings.NewReader("list,name,aliases,reference\npep,--,,\n"))
+	assert.Error(t, err, "no name")
+	_, err = LoadWatchlist(strings.NewReader(""))
+	assert.Error(t, err, "empty")
+}
+
+func TestScreen(t *testing.T) {
+	wl := loadTestWatchlist(t)
+
+	for name, want := range map[string]string{
+		"John Kamau Doe":     "John Kamau Doe",
+		"doe_john_kamau_254": "John Kamau Doe",
+		"JohnnyDoe":          "Johnny Doe",
+		"jose mwangi":        "José Mwangi",
+		"MWANGI, José":       "José Mwangi",
+		"acme":               "Acme",
+	} {
+		matches := wl.Screen(name)
+		require.Len(t, matches, 1, name)
+		assert.Equal(t, want, matches[0].MatchedName, name)
+	}
+
+	for _, name := range []string{"John Doe", "Mwangi", "acme_supplies", "", "Jane Wanjiru"} {
+		assert.Empty(t, wl.Screen(name), name)
+	}
+
+	assert.Empty(t, (&Watchlist{}).Screen("John Kamau Doe"))
+}
+
+func TestScreeningCaseFingerprint(t *testing.T) {
+	wl := loadTestWatchlist(t)
+	subject := &store.AMLSubject{Type: store.AMLSubjectMerchant, ID: "merchant-1", Name: "Johnny Doe", ShopID: "shop-1"}
+
+	first := screeningCase(subject, wl.Screen(subject.Name)[0])
+	assert.Equal(t, store.AMLCaseSanctions, first.Kind)
+	assert.Equal(t, `Shop name "Johnny Doe" matches "Johnny Doe" on the sanctions list, an alias of "John Kamau Doe" (reference UN-001)`, first.Summary)
+
+	var evidence ScreeningEvidence
+	require.NoError(t, json.Unmarshal(first.Evidence, &evidence))
+	assert.Equal(t, "shop-1", evidence.ShopID)
+
+	subject.Name = "johnny-doe"
+	assert.Equal(t, first.Fingerprint, screeningCase(subject, wl.Screen(subject.Name)[0]).Fingerprint, "the same name spelt differently")
+	subject.Name = "Johnny Doe Ltd"
+	assert.NotEqual(t, first.Fingerprint, screeningCase(subject, wl.Screen(subject.Name)[0]).Fingerprint, "a new name")
+}
+
+type fakeAMLStore struct {
+	store.AMLStore
+	subjects      []*store.AMLSubject
+	subjectsSince []time.Time
+	activities    map[string][]*store.StructuringActivity
+	cases         map[string]*store.AMLCase
+}
+
+func (fs *fakeAMLStore) GetScreeningSubjects(since time.Time) ([]*store.AMLSubject, error) {
+	fs.subjectsSince = append(fs.subjectsSince, since)
+	return fs.subjects, nil
+}
+
+func (fs *fakeAMLStore) FindStructuring(from time.Time, to time.Time, minAmount money.Amount, threshold money.Amount, minCount int) ([]*store.StructuringActivity, error) {
+	return fs.activities[from.UTC().Format(time.RFC3339)], nil
+}
+
+func (fs *fakeAMLStore) RaiseAMLCase(amlCase *store.AMLCase) (*store.AMLCase, bool, error) {
+	key := amlCase.SubjectType + amlCase.SubjectID + amlCase.Kind + amlCase.Fingerprint
+	if _, ok := fs.cases[key]; ok {
+		return nil, false, nil
+	}
+	fs.cases[key] = amlCase
+	return amlCase, true, nil
+}
+
+var now = time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+func newTestMonitor(amlStore *fakeAMLStore, wl *Watchlist) *Monitor {
+	m := NewMonitor(amlStore, wl, log.New(io.Discard, "", 0))
+	m.now = func() time.Time { return now }
+	return m
+}
+
+func TestMonitorScreen(t *testing.T) {
+	amlStore := &fakeAMLStore{
+		subjects: []*store.AMLSubject{
+			{Type: store.AMLSubjectUser, ID: "user-1", Name: "jose_mwangi"},
+			{Type: store.AMLSubjectUser, ID: "user-2", Name: "wanjiru"},
+		},
+		cases: map[string]*store.AMLCase{},
+	}
+	m := newTestMonitor(amlStore, loadTestWatchlist(t))
+
+	opened, err := m.Screen()
+	require.NoError(t, err)
+	assert.Equal(t, 1, opened)
+
+	opened, err = m.Screen()
+	require.NoError(t, err)
+	assert.Zero(t, opened, "an existing case is not opened again")
+
+	// The first screening covers every name, later ones only recent changes.
+	assert.Equal(t, []time.Time{{}, now.Add(-screeningOverlap)}, amlStore.subjectsSince)
+}
+
+func TestMonitorDetectStructuring(t *testing.T) {
+	// 09:00 UTC is 12:00 in Nairobi, so today starts at 21:00 UTC yesterday.
+	amlStore := &fakeAMLStore{
+		activities: map[string][]*store.StructuringActivity{
+			"2026-10-18T21:00:00Z": {{UserID: "user-1", Username: "kamau", Count: 6, Total: 54000000, TransactionIDs: []string{"t1", "t2"}, TransferIDs: []string{"p1"}}},
+		},
+		cases: map[string]*store.AMLCase{},
+	}
+	m := newTestMonitor(amlStore, &Watchlist{})
+
+	opened, err := m.RunOnce()
+	require.NoError(t, err)
+	require.Equal(t, 1, opened)
+
+	var raised *store.AMLCase
+	for _, amlCase := range amlStore.cases {
+		raised = amlCase
+	}
+	assert.Equal(t, store.AMLCaseStructuring, raised.Kind)
+	assert.Equal(t, "2026-10-19", raised.Fingerprint)
+	assert.Equal(t, "6 payments on 2026-10-19 totalling KES 540000.00, each between KES 5000.00 and the KES 100000.00 threshold", raised.Summary)
+
+	var evidence StructuringEvidence
+	require.NoError(t, json.Unmarshal(raised.Evidence, &evidence))
+	assert.Equal(t, []string{"t1", "t2"}, evidence.TransactionIDs)
+	assert.Equal(t, []string{"p1"}, evidence.TransferIDs)
+}
+
+func TestWriteCSV(t *testing.T) {
+	resolvedAt := time.Date(2026, 10, 19, 10, 30, 0, 0, time.UTC)
+	cases := []*store.AMLCase{
+		{
+			ID: "case-1", Kind: store.AMLCasePEP, Status: store.AMLCaseStatusReported,
+			SubjectType: store.AMLSubjectUser, SubjectID: "user-1", SubjectName: "=HYPERLINK(\"x\")",
+			Summary: "Name matches", Evidence: json.RawMessage(`{"list":"pep"}`),
+			ReportReference: "FRC-12", ResolvedBy: "officer", ResolvedAt: &resolvedAt, CreatedAt: resolvedAt.Add(-time.Hour),
+		},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, cases))
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, ExportHeader, rows[0])
+	assert.Equal(t, []string{
+		"case-1", "2026-10-19T12:30:00+03:00", "pep", "reported", "user", "user-1", "'=HYPERLINK(\"x\")",
+		"Name matches", `{"list":"pep"}`, "FRC-12", "", "officer", "2026-10-19T13:30:00+03:00",
+	}, rows[1])
+}
@@ -0,0 +1,66 @@
+package aml
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+// ExportHeader is the header row of a case export.
+var ExportHeader = []string{
+	"case_id", "opened_at", "kind", "status", "subject_type", "subject_id", "subject_name",
+	"summary", "evidence", "report_reference", "resolution_note", "resolved_by", "resolved_at",
+}
+
+// WriteCSV writes cases in the export format compliance officers download:
+// one row per case, times in RFC 3339 in Location, and the evidence as JSON
+// in a single column.
+func WriteCSV(w io.Writer, cases []*store.AMLCase) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write(ExportHeader)
+	if err != nil {
+		return err
+	}
+	for _, amlCase := range cases {
+		resolvedAt := ""
+		if amlCase.ResolvedAt != nil {
+			resolvedAt = formatTime(*amlCase.ResolvedAt)
+		}
+		err = writer.Write([]string{
+			amlCase.ID,
+			formatTime(amlCase.CreatedAt),
+			amlCase.Kind,
+			amlCase.Status,
+			amlCase.SubjectType,
+			amlCase.SubjectID,
+			cell(amlCase.SubjectName),
+			cell(amlCase.Summary),
+			string(amlCase.Evidence),
+			cell(amlCase.ReportReference),
+			cell(amlCase.ResolutionNote),
+			cell(amlCase.ResolvedBy),
+			resolvedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatTime(t time.Time) string {
+	return t.In(Location).Format(time.RFC3339)
+}
+
+// cell stops free text, such as a username, from being read as a formula
+// when the export is opened in a spreadsheet.
+func cell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,228 @@
+package aml
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+// watchlistHeader is the header row a watchlist file must start with.
+// Aliases are separated by semicolons. Lines starting with # are comments.
+var watchlistHeader = []string{"list", "name", "aliases", "reference"}
+
+// Entry is a sanctioned person or organisation, or a politically exposed
+// person. List is store.AMLCaseSanctions or store.AMLCasePEP.
+type Entry struct {
+	List      string   `json:"list"`
+	Name      string   `json:"name"`
+	Aliases   []string `json:"aliases"`
+	Reference string   `json:"reference"`
+}
+
+// Match is a screened name that matched an entry. MatchedName is the entry's
+// name or alias that it matched.
+type Match struct {
+	Entry       *Entry
+	MatchedName string
+}
+
+// variant is one name or alias of an entry, normalised for matching.
+type variant struct {
+	index  int
+	entry  *Entry
+	name   string
+	tokens []string
+	joined string
+}
+
+// Watchlist matches names against the sanctions and PEP entries loaded from
+// a file. A name matches when it is the entry's name or alias once case,
+// accents, punctuation and spacing are ignored, or when it contains every
+// word of a name or alias of two or more words in any order. The second
+// rule catches usernames like "doe_john_254" at the cost of some false
+// positives, which an officer dismisses.
+type Watchlist struct {
+	entries  []*Entry
+	byJoined map[string][]*variant
+	byToken  map[string][]*variant
+}
+
+// LoadWatchlist reads a watchlist in CSV form.
+func LoadWatchlist(r io.Reader) (*Watchlist, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = len(watchlistHeader)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("watchlist is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, column := range watchlistHeader {
+		if strings.ToLower(strings.TrimSpace(header[i])) != column {
+			return nil, fmt.Errorf("watchlist header must be %s", strings.Join(watchlistHeader, ","))
+		}
+	}
+
+	wl := &Watchlist{byJoined: map[string][]*variant{}, byToken: map[string][]*variant{}}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		entry := &Entry{
+			List:      strings.ToLower(strings.TrimSpace(record[0])),
+			Name:      strings.TrimSpace(record[1]),
+			Reference: strings.TrimSpace(record[3]),
+			Aliases:   []string{},
+		}
+		if entry.List != store.AMLCaseSanctions && entry.List != store.AMLCasePEP {
+			return nil, fmt.Errorf("watchlist line %d: list must be %s or %s", line, store.AMLCaseSanctions, store.AMLCasePEP)
+		}
+		for _, alias := range strings.Split(record[2], ";") {
+			if alias = strings.TrimSpace(alias); alias != "" {
+				entry.Aliases = append(entry.Aliases, alias)
+			}
+		}
+		if len(tokenize(entry.Name)) == 0 {
+			return nil, fmt.Errorf("watchlist line %d: name is required", line)
+		}
+		wl.add(entry)
+	}
+	return wl, nil
+}
+
+// LoadWatchlistFile reads the watchlist at path.
+func LoadWatchlistFile(path string) (*Watchlist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	wl, err := LoadWatchlist(file)
+	if err != nil {
+		return nil, fmt.Errorf("loading watchlist %s: %w", path, err)
+	}
+	return wl, nil
+}
+
+// NewWatchlistFromEnv loads the file at AML_WATCHLIST_PATH. Screening is off
+// while it is unset, but a set path that cannot be loaded is an error so a
+// broken list is not mistaken for a clean one.
+func NewWatchlistFromEnv(logger *log.Logger) (*Watchlist, error) {
+	path := os.Getenv("AML_WATCHLIST_PATH")
+	if path == "" {
+		logger.Println("WARNING: AML_WATCHLIST_PATH is not set, watchlist screening is disabled")
+		return &Watchlist{}, nil
+	}
+	wl, err := LoadWatchlistFile(path)
+	if err != nil {
+		return nil, err
+	}
+	logger.Printf("loaded %d watchlist entries from %s", wl.Len(), path)
+	return wl, nil
+}
+
+// Len returns how many entries the watchlist holds.
+func (wl *Watchlist) Len() int {
+	return len(wl.entries)
+}
+
+func (wl *Watchlist) add(entry *Entry) {
+	index := len(wl.entries)
+	wl.entries = append(wl.entries, entry)
+	for _, name := range append([]string{entry.Name}, entry.Aliases...) {
+		tokens := tokenize(name)
+		if len(tokens) == 0 {
+			continue
+		}
+		v := &variant{index: index, entry: entry, name: name, tokens: tokens, joined: strings.Join(tokens, "")}
+		wl.byJoined[v.joined] = append(wl.byJoined[v.joined], v)
+		if len(tokens) > 1 {
+			wl.byToken[tokens[0]] = append(wl.byToken[tokens[0]], v)
+		}
+	}
+}
+
+// Screen returns the entries name matches, at most one match per entry, in
+// the order the entries appear in the file.
+func (wl *Watchlist) Screen(name string) []*Match {
+	tokens := tokenize(name)
+	if len(tokens) == 0 || len(wl.entries) == 0 {
+		return nil
+	}
+	present := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		present[token] = true
+	}
+
+	found := map[int]*variant{}
+	for _, v := range wl.byJoined[strings.Join(tokens, "")] {
+		if _, ok := found[v.index]; !ok {
+			found[v.index] = v
+		}
+	}
+	for token := range present {
+		for _, v := range wl.byToken[token] {
+			if _, ok := found[v.index]; ok || !containsAll(present, v.tokens) {
+				continue
+			}
+			found[v.index] = v
+		}
+	}
+
+	variants := make([]*variant, 0, len(found))
+	for _, v := range found {
+		variants = append(variants, v)
+	}
+	sort.Slice(variants, func(i, j int) bool { return variants[i].index < variants[j].index })
+
+	matches := make([]*Match, len(variants))
+	for i, v := range variants {
+		matches[i] = &Match{Entry: v.entry, MatchedName: v.name}
+	}
+	return matches
+}
+
+func containsAll(present map[string]bool, tokens []string) bool {
+	for _, token := range tokens {
+		if !present[token] {
+			return false
+		}
+	}
+	return true
+}
+
+// tokenize lowercases name, strips accents and splits it into words of
+// letters and digits.
+func tokenize(name string) []string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(name) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return strings.FieldsFunc(b.String(), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,218 @@
+// Package anchoring proves that transaction history has not been altered.
+//
+// An Anchorer periodically gathers transactions and audit events that are
+// not yet anchored, builds a Merkle tree over their leaf hashes and submits
+// the root to a dedicated HCS topic. Anyone holding a record, its inclusion
+// proof and the consensus timestamp of the topic message can then show the
+// record existed in that form when the root was submitted.
+package anchoring
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	hiero "github.com/hiero-ledger/hiero-sdk-go/v2/sdk"
+
+	"github.com/divin3circle/orcus/backend/internals/notifications"
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+const (
+	// Interval is how often Run anchors.
+	Interval = time.Hour
+	// MaxLeaves caps one anchor's tree; a backlog is spread over several
+	// anchors in one run.
+	MaxLeaves = 10000
+	// maxAnchorsPerRun bounds the topic messages sent in one run.
+	maxAnchorsPerRun = 10
+
+	// MessageType and MessageVersion identify anchor messages on the topic.
+	MessageType    = "orcus.merkle_root"
+	MessageVersion = 1
+)
+
+// Message is the JSON document submitted to the anchoring topic. Root is hex.
+type Message struct {
+	Type      string    `json:"type"`
+	Version   int       `json:"version"`
+	AnchorID  string    `json:"anchor_id"`
+	Root      string    `json:"root"`
+	LeafCount int       `json:"leaf_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Submitter sends a message to an HCS topic.
+type Submitter interface {
+	Submit(topicID string, message []byte) (*notifications.TopicReceipt, error)
+}
+
+// HederaSubmitter submits through the operator's client.
+type HederaSubmitter struct {
+	Client *hiero.Client
+}
+
+func (hs *HederaSubmitter) Submit(topicID string, message []byte) (*notifications.TopicReceipt, error) {
+	return notifications.SubmitTopicMessage(hs.Client, topicID, message)
+}
+
+type Anchorer struct {
+	Store     store.AnchorStore
+	Submitter Submitter
+	TopicID   string
+	Logger    *log.Logger
+}
+
+func NewAnchorer(anchorStore store.AnchorStore, submitter Submitter, topicID string, logger *log.Logger) *Anchorer {
+	return &Anchorer{Store: anchorStore, Submitter: submitter, TopicID: topicID, Logger: logger}
+}
+
+// NewAnchorerFromEnv anchors to ANCHOR_TOPIC_ID. Anchoring is off while it is
+// unset.
+func NewAnchorerFromEnv(anchorStore store.AnchorStore, client *hiero.Client, logger *log.Logger) *Anchorer {
+	return NewAnchorer(anchorStore, &HederaSubmitter{Client: client}, os.Getenv("ANCHOR_TOPIC_ID"), logger)
+}
+
+// Enabled reports whether a topic is configured.
+func (a *Anchorer) Enabled() bool {
+	return a.TopicID != ""
+}
+
+// Run anchors every Interval until ctx is cancelled.
+func (a *Anchorer) Run(ctx context.Context) {
+	if !a.Enabled() {
+		a.Logger.Println("WARNING: ANCHOR_TOPIC_ID is not set, anchoring is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := a.RunOnce()
+			if err != nil {
+				a.Logger.Printf("ERROR: error anchoring records at RunOnce: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce retries anchors left pending by earlier runs, then anchors new
+// records. It returns how many anchors it submitted.
+func (a *Anchorer) RunOnce() (int, error) {
+	pending, err := a.Store.GetPendingAnchors(maxAnchorsPerRun)
+	if err != nil {
+		return 0, err
+	}
+
+	submitted := 0
+	for _, anchor := range pending {
+		if a.submit(anchor) {
+			submitted++
+		}
+	}
+
+	for i := len(pending); i < maxAnchorsPerRun; i++ {
+		anchor, err := a.createAnchor()
+		if err != nil {
+			return submitted, err
+		}
+		if anchor == nil {
+			break
+		}
+		if a.submit(anchor) {
+			submitted++
+		}
+	}
+	return submitted, nil
+}
+
+// createAnchor builds the next anchor from unanchored records: transactions
+// oldest first, then audit events in chain order. It returns nil when there
+// is nothing left to anchor.
+func (a *Anchorer) createAnchor() (*store.Anchor, error) {
+	transactions, err := a.Store.GetUnanchoredTransactions(MaxLeaves)
+	if err != nil {
+		return nil, err
+	}
+	events, err := a.Store.GetUnanchoredAuditEvents(MaxLeaves - len(transactions))
+	if err != nil {
+		return nil, err
+	}
+	if len(transactions)+len(events) == 0 {
+		return nil, nil
+	}
+
+	leaves := make([]*store.AnchorLeaf, 0, len(transactions)+len(events))
+	for _, transaction := range transactions {
+		leaves = append(leaves, &store.AnchorLeaf{
+			Position:    len(leaves),
+			SubjectType: store.AnchorSubjectTransaction,
+			SubjectID:   transaction.ID,
+			LeafHash:    TransactionLeaf(transaction),
+		})
+	}
+	for _, event := range events {
+		leaves = append(leaves, &store.AnchorLeaf{
+			Position:    len(leaves),
+			SubjectType: store.AnchorSubjectAuditEvent,
+			SubjectID:   event.ID,
+			LeafHash:    AuditEventLeaf(event),
+		})
+	}
+
+	hashes := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		hashes[i] = leaf.LeafHash
+	}
+
+	anchor, err := a.Store.CreateAnchor(&store.Anchor{Root: Root(hashes)}, leaves)
+	if err != nil {
+		return nil, err
+	}
+	if anchor == nil {
+		a.Logger.Println("WARNING: records were anchored by another run, skipping")
+	}
+	return anchor, nil
+}
+
+// submit sends anchor's root to the topic and reports whether it went
+// through. A failure leaves the anchor pending for the next run.
+func (a *Anchorer) submit(anchor *store.Anchor) bool {
+	message, err := json.Marshal(&Message{
+		Type:      MessageType,
+		Version:   MessageVersion,
+		AnchorID:  anchor.ID,
+		Root:      hex.EncodeToString(anchor.Root),
+		LeafCount: anchor.LeafCount,
+		CreatedAt: anchor.CreatedAt.UTC(),
+	})
+	if err != nil {
+		a.Logger.Printf("ERROR: error encoding anchor %s at Marshal: %v", anchor.ID, err)
+		return false
+	}
+
+	receipt, err := a.Submitter.Submit(a.TopicID, message)
+	if err != nil {
+		a.Logger.Printf("ERROR: error submitting anchor %s at Submit: %v", anchor.ID, err)
+		err = a.Store.MarkAnchorFailed(anchor.ID, err.Error())
+		if err != nil {
+			a.Logger.Printf("ERROR: error recording anchor failure at MarkAnchorFailed: %v", err)
+		}
+		return false
+	}
+
+	err = a.Store.MarkAnchorSubmitted(anchor.ID, receipt.TopicID, int64(receipt.SequenceNumber), receipt.HederaTransactionID)
+	if err != nil {
+		a.Logger.Printf("ERROR: error recording anchor submission at MarkAnchorSubmitted: %v", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,166 @@
+package anchoring
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/divin3circle/orcus/backend/internals/notifications"
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+type fakeAnchorStore struct {
+	store.AnchorStore
+	transactions []*store.Transaction
+	events       []*store.AuditEvent
+	anchors      []*store.Anchor
+	leaves       map[string][]*store.AnchorLeaf
+}
+
+func (fs *fakeAnchorStore) GetUnanchoredTransactions(limit int) ([]*store.Transaction, error) {
+	transactions := fs.transactions[:min(limit, len(fs.transactions))]
+	fs.transactions = fs.transactions[len(transactions):]
+	return transactions, nil
+}
+
+func (fs *fakeAnchorStore) GetUnanchoredAuditEvents(limit int) ([]*store.AuditEvent, error) {
+	events := fs.events[:min(limit, len(fs.events))]
+	fs.events = fs.events[len(events):]
+	return events, nil
+}
+
+func (fs *fakeAnchorStore) CreateAnchor(anchor *store.Anchor, leaves []*store.AnchorLeaf) (*store.Anchor, error) {
+	anchor.ID = "anchor-" + string(rune('a'+len(fs.anchors)))
+	anchor.LeafCount = len(leaves)
+	anchor.Status = store.AnchorStatusPending
+	fs.anchors = append(fs.anchors, anchor)
+	fs.leaves[anchor.ID] = leaves
+	return anchor, nil
+}
+
+func (fs *fakeAnchorStore) GetPendingAnchors(limit int) ([]*store.Anchor, error) {
+	var pending []*store.Anchor
+	for _, anchor := range fs.anchors {
+		if anchor.Status == store.AnchorStatusPending {
+			pending = append(pending, anchor)
+		}
+	}
+	return pending, nil
+}
+
+func (fs *fakeAnchorStore) MarkAnchorSubmitted(id string, topicID string, sequenceNumber int64, hederaTransactionID string) error {
+	for _, anchor := range fs.anchors {
+		if anchor.ID == id {
+			anchor.Status = store.AnchorStatusSubmitted
+			anchor.TopicSequenceNumber = sequenceNumber
+		}
+	}
+	return nil
+}
+
+func (fs *fakeAnchorStore) MarkAnchorFailed(id string, message string) error {
+	for _, anchor := range fs.anchors {
+		if anchor.ID == id {
+			anchor.LastError = message
+		}
+	}
+	return nil
+}
+
+type fakeSubmitter struct {
+	err      error
+	messages []*Message
+}
+
+func (fs *fakeSubmitter) Submit(topicID string, message []byte) (*notifications.TopicReceipt, error) {
+	if fs.err != nil {
+		return nil, fs.err
+	}
+	decoded := &Message{}
+	if err := json.Unmarshal(message, decoded); err != nil {
+		return nil, err
+	}
+	fs.messages = append(fs.messages, decoded)
+	return &notifications.TopicReceipt{TopicID: topicID, SequenceNumber: uint64(len(fs.messages))}, nil
+}
+
+func newTestAnchorer(anchorStore *fakeAnchorStore, submitter *fakeSubmitter) *Anchorer {
+	return NewAnchorer(anchorStore, submitter, "0.0.9001", log.New(io.Discard, "", 0))
+}
+
+func TestRunOnceAnchorsTransactionsAndAuditEvents(t *testing.T) {
+	createdAt := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	anchorStore := &fakeAnchorStore{
+		transactions: []*store.Transaction{
+			{ID: "txn-1", ShopID: "shop-1", Amount: 5000, Fee: 50, Kind: store.TransactionKindPayment, CreatedAt: createdAt},
+			{ID: "txn-2", ShopID: "shop-1", Amount: 2500, Fee: 25, Kind: store.TransactionKindPayment, CreatedAt: createdAt.Add(time.Minute)},
+		},
+		events: []*store.AuditEvent{{ID: "event-1", Seq: 1, Hash: []byte("hash")}},
+		leaves: map[string][]*store.AnchorLeaf{},
+	}
+	submitter := &fakeSubmitter{}
+
+	submitted, err := newTestAnchorer(anchorStore, submitter).RunOnce()
+	require.NoError(t, err)
+	assert.Equal(t, 1, submitted)
+
+	require.Len(t, anchorStore.anchors, 1)
+	anchor := anchorStore.anchors[0]
+	assert.Equal(t, store.AnchorStatusSubmitted, anchor.Status)
+	leaves := anchorStore.leaves[anchor.ID]
+	require.Len(t, leaves, 3)
+	assert.Equal(t, store.AnchorSubjectAuditEvent, leaves[2].SubjectType)
+
+	require.Len(t, submitter.messages, 1)
+	assert.Equal(t, MessageType, submitter.messages[0].Type)
+	assert.Equal(t, hex.EncodeToString(anchor.Root), submitter.messages[0].Root)
+	assert.Equal(t, 3, submitter.messages[0].LeafCount)
+
+	// Each leaf's proof leads back to the submitted root.
+	hashes := [][]byte{leaves[0].LeafHash, leaves[1].LeafHash, leaves[2].LeafHash}
+	proof, err := Proof(hashes, 1)
+	require.NoError(t, err)
+	assert.True(t, VerifyProof(TransactionLeaf(&store.Transaction{ID: "txn-2", ShopID: "shop-1", Amount: 2500, Fee: 25, Kind: store.TransactionKindPayment, CreatedAt: createdAt.Add(time.Minute)}), proof, anchor.Root))
+}
+
+func TestRunOnceRetriesFailedSubmissions(t *testing.T) {
+	anchorStore := &fakeAnchorStore{
+		transactions: []*store.Transaction{{ID: "txn-1"}},
+		leaves:       map[string][]*store.AnchorLeaf{},
+	}
+	submitter := &fakeSubmitter{err: errors.New("network unavailable")}
+	anchorer := newTestAnchorer(anchorStore, submitter)
+
+	submitted, err := anchorer.RunOnce()
+	require.NoError(t, err)
+	assert.Equal(t, 0, submitted)
+	require.Len(t, anchorStore.anchors, 1)
+	assert.Equal(t, store.AnchorStatusPending, anchorStore.anchors[0].Status)
+	assert.Equal(t, "network unavailable", anchorStore.anchors[0].LastError)
+
+	submitter.err = nil
+	submitted, err = anchorer.RunOnce()
+	require.NoError(t, err)
+	assert.Equal(t, 1, submitted)
+	assert.Len(t, anchorStore.anchors, 1)
+	assert.Equal(t, store.AnchorStatusSubmitted, anchorStore.anchors[0].Status)
+}
+
+func TestTransactionLeafIgnoresStatus(t *testing.T) {
+	transaction := &store.Transaction{ID: "txn-1", Amount: 5000, Status: store.TransactionStatusCompleted, CreatedAt: time.Date(2026, 10, 19, 9, 0, 0, 123456789, time.UTC)}
+	leaf := TransactionLeaf(transaction)
+
+	transaction.Status = store.TransactionStatusRefunded
+	transaction.CreatedAt = transaction.CreatedAt.Truncate(time.Microsecond).In(time.FixedZone("EAT", 3*60*60))
+	assert.Equal(t, leaf, TransactionLeaf(transaction))
+
+	transaction.Amount = 500
+	assert.NotEqual(t, leaf, TransactionLeaf(transaction))
+}
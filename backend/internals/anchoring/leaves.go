@@ -0,0 +1,41 @@
+package anchoring
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+// TransactionLeaf returns the leaf hash of a transaction. It covers the
+// fields fixed when the payment is recorded; status is left out because
+// refunds change it.
+func TransactionLeaf(transaction *store.Transaction) []byte {
+	canonical, _ := json.Marshal([]any{
+		store.AnchorSubjectTransaction,
+		transaction.ID,
+		transaction.ShopID,
+		transaction.UserID,
+		transaction.MerchantID,
+		int64(transaction.Amount),
+		int64(transaction.Fee),
+		transaction.HederaTransactionID,
+		transaction.HederaFeeTransactionID,
+		transaction.Kind,
+		transaction.ParentID,
+		transaction.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+	})
+	return HashLeaf(canonical)
+}
+
+// AuditEventLeaf returns the leaf hash of an audit event. The event's own
+// hash already covers its fields and everything before it in the chain.
+func AuditEventLeaf(event *store.AuditEvent) []byte {
+	canonical, _ := json.Marshal([]any{
+		store.AnchorSubjectAuditEvent,
+		event.ID,
+		event.Seq,
+		event.Hash,
+	})
+	return HashLeaf(canonical)
+}
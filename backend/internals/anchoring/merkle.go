@@ -0,0 +1,111 @@
+package anchoring
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+)
+
+// Leaves and interior nodes are hashed with different prefixes, as in
+// RFC 6962, so an interior node can never be passed off as a leaf.
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+const (
+	SideLeft  = "left"
+	SideRight = "right"
+)
+
+var ErrLeafOutOfRange = errors.New("leaf index is out of range")
+
+// ProofStep is one sibling on the path from a leaf to the root. Side says
+// which side of the running hash the sibling goes on.
+type ProofStep struct {
+	Hash []byte `json:"hash"`
+	Side string `json:"side"`
+}
+
+// HashLeaf returns the leaf hash of data.
+func HashLeaf(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{leafPrefix})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func hashNode(left []byte, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{nodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// Root returns the Merkle root of leaf hashes, built as in RFC 6962: the
+// left subtree holds the largest power of two below the leaf count. There is
+// no padding, so an odd leaf is never duplicated. The root of no leaves is
+// the hash of nothing.
+func Root(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	case 1:
+		return leaves[0]
+	}
+	k := split(len(leaves))
+	return hashNode(Root(leaves[:k]), Root(leaves[k:]))
+}
+
+// Proof returns the siblings needed to recompute the root from the leaf at
+// index, nearest the leaf first.
+func Proof(leaves [][]byte, index int) ([]ProofStep, error) {
+	if index < 0 || index >= len(leaves) {
+		return nil, ErrLeafOutOfRange
+	}
+	steps := []ProofStep{}
+	for len(leaves) > 1 {
+		k := split(len(leaves))
+		if index < k {
+			steps = append(steps, ProofStep{Hash: Root(leaves[k:]), Side: SideRight})
+			leaves = leaves[:k]
+		} else {
+			steps = append(steps, ProofStep{Hash: Root(leaves[:k]), Side: SideLeft})
+			leaves = leaves[k:]
+			index -= k
+		}
+	}
+
+	// Steps were collected root first.
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return steps, nil
+}
+
+// VerifyProof reports whether leaf and proof hash up to root.
+func VerifyProof(leaf []byte, proof []ProofStep, root []byte) bool {
+	running := leaf
+	for _, step := range proof {
+		switch step.Side {
+		case SideLeft:
+			running = hashNode(step.Hash, running)
+		case SideRight:
+			running = hashNode(running, step.Hash)
+		default:
+			return false
+		}
+	}
+	return bytes.Equal(running, root)
+}
+
+// split returns the largest power of two below n, for n > 1.
+func split(n int) int {
+	k := 1
+	for k*2 < n {
+		k *= 2
+	}
+	return k
+}
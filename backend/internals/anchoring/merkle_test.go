@@ -0,0 +1,69 @@
+package anchoring
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfc6962Leaves are the inputs of the certificate transparency reference
+// tree.
+var rfc6962Leaves = []string{"", "00", "10", "2021", "3031", "40414243", "5051525354555657", "606162636465666768696a6b6c6d6e6f"}
+
+func leafHashes(t *testing.T, inputs []string) [][]byte {
+	t.Helper()
+	hashes := make([][]byte, len(inputs))
+	for i, input := range inputs {
+		data, err := hex.DecodeString(input)
+		require.NoError(t, err)
+		hashes[i] = HashLeaf(data)
+	}
+	return hashes
+}
+
+func TestRootMatchesRFC6962(t *testing.T) {
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", hex.EncodeToString(Root(nil)))
+	assert.Equal(t, "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d", hex.EncodeToString(Root(leafHashes(t, rfc6962Leaves[:1]))))
+	assert.Equal(t, "5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328", hex.EncodeToString(Root(leafHashes(t, rfc6962Leaves))))
+}
+
+func TestProofVerifiesEveryLeaf(t *testing.T) {
+	for n := 1; n <= 17; n++ {
+		leaves := make([][]byte, n)
+		for i := range leaves {
+			leaves[i] = HashLeaf([]byte{byte(i)})
+		}
+		root := Root(leaves)
+
+		for i := range leaves {
+			proof, err := Proof(leaves, i)
+			require.NoError(t, err)
+			assert.True(t, VerifyProof(leaves[i], proof, root), "leaf %d of %d", i, n)
+
+			if n > 1 {
+				other := leaves[(i+1)%n]
+				assert.False(t, VerifyProof(other, proof, root), "wrong leaf %d of %d", i, n)
+			}
+		}
+	}
+}
+
+func TestProofRejectsTampering(t *testing.T) {
+	leaves := leafHashes(t, rfc6962Leaves)
+	root := Root(leaves)
+
+	proof, err := Proof(leaves, 5)
+	require.NoError(t, err)
+	proof[1].Hash = HashLeaf([]byte("forged"))
+	assert.False(t, VerifyProof(leaves[5], proof, root))
+
+	proof, err = Proof(leaves, 5)
+	require.NoError(t, err)
+	proof[0].Side = SideRight
+	assert.False(t, VerifyProof(leaves[5], proof, root))
+
+	_, err = Proof(leaves, len(leaves))
+	assert.ErrorIs(t, err, ErrLeafOutOfRange)
+}
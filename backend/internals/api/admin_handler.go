@@ -0,0 +1,575 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+)
+
+// DefaultStatsWindow is how far back platform stats reach when the request
+// gives no from.
+const DefaultStatsWindow = 30 * 24 * time.Hour
+
+type CreateAdminRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type SuspendRequest struct {
+	Reason string `json:"reason"`
+}
+
+type AdminHandler struct {
+	AdminStore    store.AdminStore
+	MerchantStore store.MerchantStore
+	UserStore     store.UserStore
+	ShopStore     store.ShopStore
+	Audit         *AuditRecorder
+	Logger        *log.Logger
+}
+
+func NewAdminHandler(adminStore store.AdminStore, merchantStore store.MerchantStore, userStore store.UserStore, shopStore store.ShopStore, audit *AuditRecorder, logger *log.Logger) *AdminHandler {
+	return &AdminHandler{AdminStore: adminStore, MerchantStore: merchantStore, UserStore: userStore, ShopStore: shopStore, Audit: audit, Logger: logger}
+}
+
+func (ah *AdminHandler) HandleGetCurrentAdmin(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"admin": middleware.GetAdmin(r)})
+}
+
+func (ah *AdminHandler) HandleGetAdmins(w http.ResponseWriter, r *http.Request) {
+	admins, err := ah.AdminStore.GetAdmins()
+	if err != nil {
+		ah.Logger.Printf("ERROR: error getting admins at GetAdmins: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"admins": admins})
+}
+
+func (ah *AdminHandler) HandleCreateAdmin(w http.ResponseWriter, r *http.Request) {
+	var req CreateAdminRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error decoding create admin request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	if len(req.Username) < 3 || len(req.Username) > 50 {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "username must be between 3 and 50 characters long"})
+		return
+	}
+	if strings.EqualFold(req.Username, middleware.BootstrapAdminUsername) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "username is reserved"})
+		return
+	}
+	if len(req.Password) < 12 {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "password must be at least 12 characters long"})
+		return
+	}
+
+	admin := &store.Admin{Username: req.Username}
+	err = admin.PasswordHash.Set(req.Password)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error hashing password at Set: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	created, err := ah.AdminStore.CreateAdmin(admin)
+	if errors.Is(err, store.ErrAdminUsernameTaken) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ah.Logger.Printf("ERROR: error creating admin at CreateAdmin: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	ah.recordAction(r, "admin.create", "admin", created.ID, map[string]string{"username": created.Username})
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"admin": created})
+}
+
+// HandleDisableAdmin stops an admin from signing in and ends their sessions.
+// Admins cannot disable themselves, so there is always someone left who can
+// undo a mistake.
+func (ah *AdminHandler) HandleDisableAdmin(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := ah.readUUIDParam(w, r, "admin not found")
+	if !ok {
+		return
+	}
+	if adminID == middleware.GetAdmin(r).ID {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "you cannot disable yourself"})
+		return
+	}
+
+	disabled, err := ah.AdminStore.DisableAdmin(adminID)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error disabling admin at DisableAdmin: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if disabled == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "admin not found"})
+		return
+	}
+
+	ah.recordAction(r, "admin.disable", "admin", disabled.ID, nil)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"admin": disabled})
+}
+
+// HandleSearchMerchants lists merchants matching ?q= and ?status=active or
+// suspended, paged with limit and offset.
+func (ah *AdminHandler) HandleSearchMerchants(w http.ResponseWriter, r *http.Request) {
+	search, ok := ah.readSearch(w, r)
+	if !ok {
+		return
+	}
+
+	merchants, err := ah.AdminStore.SearchMerchants(search)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error searching merchants at SearchMerchants: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"merchants": merchants})
+}
+
+// HandleGetMerchant returns a merchant with their shops.
+func (ah *AdminHandler) HandleGetMerchant(w http.ResponseWriter, r *http.Request) {
+	merchantID, ok := ah.readUUIDParam(w, r, "merchant not found")
+	if !ok {
+		return
+	}
+
+	merchant, err := ah.MerchantStore.GetMerchantByID(merchantID)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error getting merchant at GetMerchantByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if merchant == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "merchant not found"})
+		return
+	}
+
+	shops, err := ah.ShopStore.GetShopsByMerchantID(merchant.ID)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error getting shops at GetShopsByMerchantID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if shops == nil {
+		shops = []*store.Shop{}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"merchant": merchant, "shops": shops})
+}
+
+func (ah *AdminHandler) HandleSearchUsers(w http.ResponseWriter, r *http.Request) {
+	search, ok := ah.readSearch(w, r)
+	if !ok {
+		return
+	}
+
+	users, err := ah.AdminStore.SearchUsers(search)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error searching users at SearchUsers: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"users": users})
+}
+
+func (ah *AdminHandler) HandleGetUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := ah.readUUIDParam(w, r, "user not found")
+	if !ok {
+		return
+	}
+
+	user, err := ah.UserStore.GetUserByID(userID)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error getting user at GetUserByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if user == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "user not found"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"user": user})
+}
+
+func (ah *AdminHandler) HandleSearchShops(w http.ResponseWriter, r *http.Request) {
+	search, ok := ah.readSearch(w, r)
+	if !ok {
+		return
+	}
+
+	shops, err := ah.AdminStore.SearchShops(search)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error searching shops at SearchShops: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"shops": shops})
+}
+
+func (ah *AdminHandler) HandleGetShop(w http.ResponseWriter, r *http.Request) {
+	shopID, ok := ah.readUUIDParam(w, r, "shop not found")
+	if !ok {
+		return
+	}
+
+	shop, err := ah.ShopStore.GetShopByID(shopID)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error getting shop at GetShopByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if shop == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "shop not found"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"shop": shop})
+}
+
+// HandleSuspend returns a handler that suspends the subjectType in the URL.
+// Suspended merchants and users are signed out and cannot sign back in;
+// suspended shops stop taking payments.
+func (ah *AdminHandler) HandleSuspend(subjectType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ah.setSuspended(w, r, subjectType, true)
+	}
+}
+
+func (ah *AdminHandler) HandleUnsuspend(subjectType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ah.setSuspended(w, r, subjectType, false)
+	}
+}
+
+func (ah *AdminHandler) setSuspended(w http.ResponseWriter, r *http.Request, subjectType string, suspended bool) {
+	subjectID, ok := ah.readUUIDParam(w, r, subjectType+" not found")
+	if !ok {
+		return
+	}
+
+	var req SuspendRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error decoding suspend request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" || len(req.Reason) > 500 {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "reason must be between 1 and 500 characters long"})
+		return
+	}
+
+	changed, err := ah.AdminStore.SetSuspended(subjectType, subjectID, suspended)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error updating suspension at SetSuspended: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	subject, err := ah.getSubject(subjectType, subjectID)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error getting %s at getSubject: %v", subjectType, err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if subject == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": subjectType + " not found"})
+		return
+	}
+	if !changed {
+		state := "suspended"
+		if !suspended {
+			state = "not suspended"
+		}
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": subjectType + " is already " + state})
+		return
+	}
+
+	action := subjectType + ".suspend"
+	if !suspended {
+		action = subjectType + ".unsuspend"
+	}
+	ah.recordAction(r, action, subjectType, subjectID, map[string]string{"reason": req.Reason})
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{subjectType: subject})
+}
+
+// getSubject loads a merchant, user or shop. It returns nil, nil when it
+// does not exist.
+func (ah *AdminHandler) getSubject(subjectType string, id string) (any, error) {
+	switch subjectType {
+	case store.AdminSubjectMerchant:
+		merchant, err := ah.MerchantStore.GetMerchantByID(id)
+		if err != nil || merchant == nil {
+			return nil, err
+		}
+		return merchant, nil
+	case store.AdminSubjectUser:
+		user, err := ah.UserStore.GetUserByID(id)
+		if err != nil || user == nil {
+			return nil, err
+		}
+		return user, nil
+	case store.AdminSubjectShop:
+		shop, err := ah.ShopStore.GetShopByID(id)
+		if err != nil || shop == nil {
+			return nil, err
+		}
+		return shop, nil
+	}
+	return nil, errors.New("unknown subject type " + subjectType)
+}
+
+// HandleSearchTransactions lists payments across all merchants. It accepts
+// merchant_id, shop_id, user_id, status, kind, from and to (RFC 3339) along
+// with limit and offset.
+func (ah *AdminHandler) HandleSearchTransactions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, offset, ok := readPage(w, r)
+	if !ok {
+		return
+	}
+	filter := store.AdminTransactionFilter{
+		MerchantID: query.Get("merchant_id"),
+		ShopID:     query.Get("shop_id"),
+		UserID:     query.Get("user_id"),
+		Status:     query.Get("status"),
+		Kind:       query.Get("kind"),
+		Limit:      limit,
+		Offset:     offset,
+	}
+	for _, param := range []struct {
+		name string
+		dest **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		raw := query.Get(param.name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": param.name + " must be an RFC 3339 timestamp"})
+			return
+		}
+		*param.dest = &parsed
+	}
+
+	transactions, err := ah.AdminStore.SearchTransactions(filter)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error searching transactions at SearchTransactions: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"transactions": transactions})
+}
+
+// HandleGetFailures returns what needs an operator's attention: failed
+// withdrawals and open reconciliation issues, which include payments that
+// failed on the ledger.
+func (ah *AdminHandler) HandleGetFailures(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := readPage(w, r)
+	if !ok {
+		return
+	}
+
+	withdrawals, err := ah.AdminStore.GetFailedWithdrawals(limit, offset)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error getting failed withdrawals at GetFailedWithdrawals: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	issues, err := ah.AdminStore.GetOpenReconciliationIssues(limit, offset)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error getting reconciliation issues at GetOpenReconciliationIssues: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"failed_withdrawals": withdrawals, "reconciliation_issues": issues})
+}
+
+// HandleGetStats returns platform volume and fee revenue between from and to
+// (RFC 3339), the last 30 days by default.
+func (ah *AdminHandler) HandleGetStats(w http.ResponseWriter, r *http.Request) {
+	to := time.Now()
+	from := to.Add(-DefaultStatsWindow)
+	if raw := r.URL.Query().Get("to"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "to must be an RFC 3339 timestamp"})
+			return
+		}
+		to = parsed
+		from = to.Add(-DefaultStatsWindow)
+	}
+	if raw := r.URL.Query().Get("from"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "from must be an RFC 3339 timestamp"})
+			return
+		}
+		from = parsed
+	}
+	if !from.Before(to) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "from must be before to"})
+		return
+	}
+
+	stats, err := ah.AdminStore.GetPlatformStats(from, to)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error getting platform stats at GetPlatformStats: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"stats": stats})
+}
+
+// HandleGetActions returns the admin audit trail, filtered by admin_id,
+// subject_type and subject_id.
+func (ah *AdminHandler) HandleGetActions(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := readPage(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	filter := store.AdminActionFilter{
+		AdminID:     query.Get("admin_id"),
+		SubjectType: query.Get("subject_type"),
+		SubjectID:   query.Get("subject_id"),
+		Limit:       limit,
+		Offset:      offset,
+	}
+
+	actions, err := ah.AdminStore.GetAdminActions(filter)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error getting admin actions at GetAdminActions: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"actions": actions})
+}
+
+// recordAction adds to the admin audit trail. The change has already been
+// made, so a failure is logged rather than returned.
+func (ah *AdminHandler) recordAction(r *http.Request, action string, subjectType string, subjectID string, details any) {
+	recordAdminAction(ah.AdminStore, ah.Audit, ah.Logger, r, action, subjectType, subjectID, details)
+}
+
+func recordAdminAction(adminStore store.AdminStore, audit *AuditRecorder, logger *log.Logger, r *http.Request, action string, subjectType string, subjectID string, details any) {
+	admin := middleware.GetAdmin(r)
+	entry := &store.AdminAction{
+		AdminID:     admin.ID,
+		Actor:       admin.Username,
+		Action:      action,
+		SubjectType: subjectType,
+		SubjectID:   subjectID,
+		IPAddress:   middleware.ClientIP(r),
+	}
+	if details != nil {
+		encoded, err := json.Marshal(details)
+		if err != nil {
+			logger.Printf("ERROR: error encoding admin action details at Marshal: %v", err)
+		}
+		entry.Details = encoded
+	}
+
+	err := adminStore.RecordAdminAction(entry)
+	if err != nil {
+		logger.Printf("ERROR: error recording admin action %s at RecordAdminAction: %v", action, err)
+	}
+
+	// Actions on a merchant also show up in that merchant's audit feed.
+	auditEntry := AuditEntry{Action: action, TargetType: subjectType, TargetID: subjectID, Details: details}
+	if subjectType == store.AdminSubjectMerchant {
+		auditEntry.MerchantID = subjectID
+	}
+	audit.RecordEntry(r, auditEntry)
+}
+
+func (ah *AdminHandler) readSearch(w http.ResponseWriter, r *http.Request) (store.AdminSearch, bool) {
+	limit, offset, ok := readPage(w, r)
+	if !ok {
+		return store.AdminSearch{}, false
+	}
+
+	status := r.URL.Query().Get("status")
+	if status != "" && status != store.AccountStatusActive && status != store.AccountStatusSuspended {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "status must be active or suspended"})
+		return store.AdminSearch{}, false
+	}
+
+	search := store.AdminSearch{
+		Query:  strings.TrimSpace(r.URL.Query().Get("q")),
+		Status: status,
+		Limit:  limit,
+		Offset: offset,
+	}
+	return search, true
+}
+
+func (ah *AdminHandler) readUUIDParam(w http.ResponseWriter, r *http.Request, notFound string) (string, bool) {
+	id, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		ah.Logger.Printf("ERROR: error reading id at ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return "", false
+	}
+	if _, err = uuid.Parse(id); err != nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": notFound})
+		return "", false
+	}
+	return id, true
+}
+
+// readPage reads the limit and offset query parameters. Limits above
+// store.MaxAdminPageSize are clamped by the store.
+func readPage(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	var limit, offset int
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "limit must be a positive number"})
+			return 0, 0, false
+		}
+		limit = parsed
+	}
+	if raw := r.URL.Query().Get("offset"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "offset must not be negative"})
+			return 0, 0, false
+		}
+		offset = parsed
+	}
+	return limit, offset, true
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/divin3circle/orcus/backend/internals/aml"
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+)
+
+// amlCaseSubject is the admin action subject for case work. Cases are kept
+// out of the merchant audit feed so a merchant under investigation is not
+// tipped off.
+const amlCaseSubject = "aml_case"
+
+type ResolveAMLCaseRequest struct {
+	Reference string `json:"reference"`
+	Note      string `json:"note"`
+}
+
+type AMLHandler struct {
+	AMLStore   store.AMLStore
+	AdminStore store.AdminStore
+	Audit      *AuditRecorder
+	Logger     *log.Logger
+}
+
+func NewAMLHandler(amlStore store.AMLStore, adminStore store.AdminStore, audit *AuditRecorder, logger *log.Logger) *AMLHandler {
+	return &AMLHandler{AMLStore: amlStore, AdminStore: adminStore, Audit: audit, Logger: logger}
+}
+
+// HandleGetCases lists cases newest first, filtered by status, kind,
+// subject_type, subject_id and from and to (RFC 3339) on when they opened.
+func (ah *AMLHandler) HandleGetCases(w http.ResponseWriter, r *http.Request) {
+	filter, ok := ah.readCaseFilter(w, r)
+	if !ok {
+		return
+	}
+	filter.Limit, filter.Offset, ok = readPage(w, r)
+	if !ok {
+		return
+	}
+
+	cases, err := ah.AMLStore.SearchAMLCases(filter)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error searching aml cases at SearchAMLCases: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"cases": cases})
+}
+
+// HandleExportCases downloads every case matching the same filters as
+// HandleGetCases as CSV; see aml.WriteCSV for the columns.
+func (ah *AMLHandler) HandleExportCases(w http.ResponseWriter, r *http.Request) {
+	filter, ok := ah.readCaseFilter(w, r)
+	if !ok {
+		return
+	}
+
+	cases := []*store.AMLCase{}
+	filter.Limit = store.MaxAdminPageSize
+	for {
+		page, err := ah.AMLStore.SearchAMLCases(filter)
+		if err != nil {
+			ah.Logger.Printf("ERROR: error searching aml cases at SearchAMLCases: %v", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+			return
+		}
+		cases = append(cases, page...)
+		if len(page) < filter.Limit {
+			break
+		}
+		filter.Offset += len(page)
+	}
+
+	var export bytes.Buffer
+	err := aml.WriteCSV(&export, cases)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error writing aml case export at WriteCSV: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	recordAdminAction(ah.AdminStore, ah.Audit, ah.Logger, r, "aml_case.export", amlCaseSubject, "", map[string]any{"query": r.URL.RawQuery, "cases": len(cases)})
+	filename := "aml-cases-" + time.Now().In(aml.Location).Format("20060102-150405") + ".csv"
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(export.Bytes())
+}
+
+func (ah *AMLHandler) HandleGetCase(w http.ResponseWriter, r *http.Request) {
+	amlCase, ok := ah.readCase(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"case": amlCase})
+}
+
+// HandleReportCase closes a case as reported to the regulator. reference is
+// the reference the report was filed under.
+func (ah *AMLHandler) HandleReportCase(w http.ResponseWriter, r *http.Request) {
+	ah.resolveCase(w, r, store.AMLCaseStatusReported)
+}
+
+// HandleDismissCase closes a case as not suspicious, such as a watchlist
+// match on a different person. The note must say why.
+func (ah *AMLHandler) HandleDismissCase(w http.ResponseWriter, r *http.Request) {
+	ah.resolveCase(w, r, store.AMLCaseStatusDismissed)
+}
+
+func (ah *AMLHandler) resolveCase(w http.ResponseWriter, r *http.Request, status string) {
+	amlCase, ok := ah.readCase(w, r)
+	if !ok {
+		return
+	}
+
+	var req ResolveAMLCaseRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error decoding resolve aml case request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	req.Reference = strings.TrimSpace(req.Reference)
+	req.Note = strings.TrimSpace(req.Note)
+	if status == store.AMLCaseStatusReported && req.Reference == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "reference is required"})
+		return
+	}
+	if status == store.AMLCaseStatusDismissed && req.Note == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "note is required"})
+		return
+	}
+	if len(req.Reference) > 100 {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "reference must be at most 100 characters"})
+		return
+	}
+
+	resolved, err := ah.AMLStore.ResolveAMLCase(amlCase.ID, status, req.Reference, req.Note, middleware.GetAdmin(r).Username)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error resolving aml case at ResolveAMLCase: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if resolved == nil {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": "case is already " + amlCase.Status})
+		return
+	}
+
+	recordAdminAction(ah.AdminStore, ah.Audit, ah.Logger, r, "aml_case."+status, amlCaseSubject, resolved.ID, req)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"case": resolved})
+}
+
+func (ah *AMLHandler) readCase(w http.ResponseWriter, r *http.Request) (*store.AMLCase, bool) {
+	caseID, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		ah.Logger.Printf("ERROR: error reading aml case id at ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if _, err = uuid.Parse(caseID); err != nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "case not found"})
+		return nil, false
+	}
+
+	amlCase, err := ah.AMLStore.GetAMLCaseByID(caseID)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error getting aml case at GetAMLCaseByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if amlCase == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "case not found"})
+		return nil, false
+	}
+	return amlCase, true
+}
+
+func (ah *AMLHandler) readCaseFilter(w http.ResponseWriter, r *http.Request) (store.AMLCaseFilter, bool) {
+	query := r.URL.Query()
+	filter := store.AMLCaseFilter{
+		Status:      query.Get("status"),
+		Kind:        query.Get("kind"),
+		SubjectType: query.Get("subject_type"),
+		SubjectID:   query.Get("subject_id"),
+	}
+	switch filter.Status {
+	case "", store.AMLCaseStatusOpen, store.AMLCaseStatusReported, store.AMLCaseStatusDismissed:
+	default:
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "status must be open, reported or dismissed"})
+		return filter, false
+	}
+	switch filter.Kind {
+	case "", store.AMLCaseSanctions, store.AMLCasePEP, store.AMLCaseStructuring:
+	default:
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "kind must be sanctions, pep or structuring"})
+		return filter, false
+	}
+
+	for _, param := range []struct {
+		name string
+		dest **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		raw := query.Get(param.name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": param.name + " must be an RFC 3339 timestamp"})
+			return filter, false
+		}
+		*param.dest = &parsed
+	}
+	return filter, true
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/divin3circle/orcus/backend/internals/anchoring"
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+)
+
+// ProofStepResponse is a ProofStep with the hash in hex.
+type ProofStepResponse struct {
+	Hash string `json:"hash"`
+	Side string `json:"side"`
+}
+
+// InclusionProof shows a transaction is one of the leaves under an anchored
+// root. Hash LeafHash with each step's hash in order, the step on the side
+// it names and with the 0x01 node prefix, and the result is Root.
+// RecordMatches reports whether the transaction as stored today still
+// hashes to LeafHash.
+type InclusionProof struct {
+	TransactionID string              `json:"transaction_id"`
+	LeafHash      string              `json:"leaf_hash"`
+	RecordMatches bool                `json:"record_matches"`
+	Position      int                 `json:"position"`
+	Steps         []ProofStepResponse `json:"steps"`
+	Root          string              `json:"root"`
+	Anchor        *store.Anchor       `json:"anchor"`
+}
+
+type AnchorHandler struct {
+	AnchorStore      store.AnchorStore
+	TransactionStore store.TransactionStore
+	Logger           *log.Logger
+}
+
+func NewAnchorHandler(anchorStore store.AnchorStore, transactionStore store.TransactionStore, logger *log.Logger) *AnchorHandler {
+	return &AnchorHandler{AnchorStore: anchorStore, TransactionStore: transactionStore, Logger: logger}
+}
+
+// HandleGetTransactionProof returns the inclusion proof for one of the
+// merchant's transactions.
+func (ah *AnchorHandler) HandleGetTransactionProof(w http.ResponseWriter, r *http.Request) {
+	transaction, ok := ah.readTransaction(w, r)
+	if !ok {
+		return
+	}
+	if transaction.MerchantID != middleware.GetMerchant(r).ID || !canAccessShop(r, transaction.ShopID) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "transaction not found"})
+		return
+	}
+
+	ah.writeProof(w, transaction)
+}
+
+// HandleGetAdminTransactionProof returns the inclusion proof for any
+// transaction.
+func (ah *AnchorHandler) HandleGetAdminTransactionProof(w http.ResponseWriter, r *http.Request) {
+	transaction, ok := ah.readTransaction(w, r)
+	if !ok {
+		return
+	}
+
+	ah.writeProof(w, transaction)
+}
+
+// HandleGetAnchors lists anchors newest first, paged with limit and offset.
+func (ah *AnchorHandler) HandleGetAnchors(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := readPage(w, r)
+	if !ok {
+		return
+	}
+
+	anchors, err := ah.AnchorStore.GetAnchors(limit, offset)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error getting anchors at GetAnchors: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"anchors": anchors})
+}
+
+func (ah *AnchorHandler) readTransaction(w http.ResponseWriter, r *http.Request) (*store.Transaction, bool) {
+	transactionID, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		ah.Logger.Printf("ERROR: error reading transaction id at ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if _, err := uuid.Parse(transactionID); err != nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "transaction not found"})
+		return nil, false
+	}
+
+	transaction, err := ah.TransactionStore.GetTransactionByID(transactionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "transaction not found"})
+		return nil, false
+	}
+	if err != nil {
+		ah.Logger.Printf("ERROR: error getting transaction at GetTransactionByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	return transaction, true
+}
+
+func (ah *AnchorHandler) writeProof(w http.ResponseWriter, transaction *store.Transaction) {
+	leaf, err := ah.AnchorStore.GetAnchorLeaf(store.AnchorSubjectTransaction, transaction.ID)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error getting anchor leaf at GetAnchorLeaf: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if leaf == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "transaction has not been anchored yet"})
+		return
+	}
+
+	anchor, err := ah.AnchorStore.GetAnchorByID(leaf.AnchorID)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error getting anchor at GetAnchorByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	hashes, err := ah.AnchorStore.GetAnchorLeafHashes(leaf.AnchorID)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error getting anchor leaves at GetAnchorLeafHashes: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	steps, err := anchoring.Proof(hashes, leaf.Position)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error building inclusion proof at Proof: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if !anchoring.VerifyProof(leaf.LeafHash, steps, anchor.Root) {
+		ah.Logger.Printf("ERROR: anchor %s leaves no longer hash to its root", anchor.ID)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "anchor leaves do not match the anchored root"})
+		return
+	}
+
+	proof := &InclusionProof{
+		TransactionID: transaction.ID,
+		LeafHash:      hex.EncodeToString(leaf.LeafHash),
+		RecordMatches: bytes.Equal(anchoring.TransactionLeaf(transaction), leaf.LeafHash),
+		Position:      leaf.Position,
+		Steps:         make([]ProofStepResponse, len(steps)),
+		Root:          anchor.RootHex,
+		Anchor:        anchor,
+	}
+	for i, step := range steps {
+		proof.Steps[i] = ProofStepResponse{Hash: hex.EncodeToString(step.Hash), Side: step.Side}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"proof": proof})
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/tokens"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+)
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+type APIKeyHandler struct {
+	APIKeyStore store.APIKeyStore
+	Audit       *AuditRecorder
+	Logger      *log.Logger
+}
+
+func NewAPIKeyHandler(apiKeyStore store.APIKeyStore, audit *AuditRecorder, logger *log.Logger) *APIKeyHandler {
+	return &APIKeyHandler{APIKeyStore: apiKeyStore, Audit: audit, Logger: logger}
+}
+
+func (ah *APIKeyHandler) HandleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req CreateAPIKeyRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error decoding create api key request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = ah.validateCreateAPIKeyRequest(&req)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	merchant := middleware.GetMerchant(r)
+	key, generated, err := ah.APIKeyStore.CreateAPIKey(merchant.ID, req.Name, req.Scopes)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error creating api key at CreateAPIKey: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	ah.Audit.Record(r, "api_key.create", "api_key", key.ID, map[string]any{"name": key.Name, "scopes": key.Scopes})
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"api_key": key, "key": generated.Plaintext})
+}
+
+func (ah *APIKeyHandler) HandleGetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	keys, err := ah.APIKeyStore.GetAPIKeysByMerchantID(merchant.ID)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error getting api keys at GetAPIKeysByMerchantID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"api_keys": keys})
+}
+
+// HandleRotateAPIKey revokes the key immediately and returns its replacement.
+func (ah *APIKeyHandler) HandleRotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	keyID, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		ah.Logger.Printf("ERROR: error reading api key id at ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	merchant := middleware.GetMerchant(r)
+	key, generated, err := ah.APIKeyStore.RotateAPIKey(merchant.ID, keyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "api key not found"})
+		return
+	}
+	if err != nil {
+		ah.Logger.Printf("ERROR: error rotating api key at RotateAPIKey: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	ah.Audit.Record(r, "api_key.rotate", "api_key", keyID, map[string]string{"replacement_id": key.ID})
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"api_key": key, "key": generated.Plaintext})
+}
+
+func (ah *APIKeyHandler) HandleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	keyID, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		ah.Logger.Printf("ERROR: error reading api key id at ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	merchant := middleware.GetMerchant(r)
+	err = ah.APIKeyStore.RevokeAPIKey(merchant.ID, keyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "api key not found"})
+		return
+	}
+	if err != nil {
+		ah.Logger.Printf("ERROR: error revoking api key at RevokeAPIKey: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	ah.Audit.Record(r, "api_key.revoke", "api_key", keyID, nil)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "API key revoked"})
+}
+
+func (ah *APIKeyHandler) validateCreateAPIKeyRequest(req *CreateAPIKeyRequest) error {
+	if req.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(req.Name) > 100 {
+		return errors.New("name must be at most 100 characters long")
+	}
+	if len(req.Scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+	for _, scope := range req.Scopes {
+		if !tokens.IsAPIKeyScope(scope) {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+// maxUserAgentLength keeps oversized headers out of the audit log.
+const maxUserAgentLength = 256
+
+// AuditEntry describes an action for AuditRecorder. The actor and merchant
+// are taken from the request unless set here, which login handlers do since
+// nobody is signed in yet.
+type AuditEntry struct {
+	Action     string
+	TargetType string
+	TargetID   string
+	Outcome    string
+	Details    any
+	ActorType  string
+	ActorID    string
+	MerchantID string
+}
+
+// AuditRecorder writes security-sensitive actions to the audit log. The
+// action has already happened by the time it is recorded, so failures are
+// logged rather than returned. A nil recorder records nothing.
+type AuditRecorder struct {
+	Store  store.AuditStore
+	Logger *log.Logger
+}
+
+func NewAuditRecorder(auditStore store.AuditStore, logger *log.Logger) *AuditRecorder {
+	return &AuditRecorder{Store: auditStore, Logger: logger}
+}
+
+// Record logs a successful action on target by whoever made the request.
+func (ar *AuditRecorder) Record(r *http.Request, action string, targetType string, targetID string, details any) {
+	ar.RecordEntry(r, AuditEntry{Action: action, TargetType: targetType, TargetID: targetID, Details: details})
+}
+
+func (ar *AuditRecorder) RecordEntry(r *http.Request, entry AuditEntry) {
+	if ar == nil {
+		return
+	}
+
+	event := &store.AuditEvent{
+		OccurredAt: time.Now(),
+		ActorType:  entry.ActorType,
+		ActorID:    entry.ActorID,
+		MerchantID: entry.MerchantID,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		Outcome:    entry.Outcome,
+		IPAddress:  middleware.ClientIP(r),
+		UserAgent:  r.UserAgent(),
+	}
+	if len(event.UserAgent) > maxUserAgentLength {
+		event.UserAgent = event.UserAgent[:maxUserAgentLength]
+	}
+	if event.Outcome == "" {
+		event.Outcome = store.AuditOutcomeSuccess
+	}
+	if event.ActorType == "" {
+		event.ActorType, event.ActorID = auditActor(r)
+	}
+	if event.MerchantID == "" {
+		if merchant, ok := r.Context().Value(middleware.MerchantContextKey).(*store.Merchant); ok && !merchant.IsAnonymous() {
+			event.MerchantID = merchant.ID
+		}
+	}
+	if entry.Details != nil {
+		details, err := json.Marshal(entry.Details)
+		if err != nil {
+			ar.Logger.Printf("ERROR: error encoding audit details for %s at Marshal: %v", entry.Action, err)
+		}
+		event.Details = details
+	}
+
+	_, err := ar.Store.AppendAuditEvent(event)
+	if err != nil {
+		ar.Logger.Printf("ERROR: error recording audit event %s at AppendAuditEvent: %v", entry.Action, err)
+	}
+}
+
+// auditActor names the principal the request was authenticated as, most
+// specific first: a till or API key acts for a merchant, and so does a team
+// member.
+func auditActor(r *http.Request) (string, string) {
+	if device := middleware.GetPOSDevice(r); device != nil {
+		return store.AuditActorPOSDevice, device.ID
+	}
+	if key := middleware.GetAPIKey(r); key != nil {
+		return store.AuditActorAPIKey, key.ID
+	}
+	if member := middleware.GetMember(r); member != nil {
+		return store.AuditActorMember, member.ID
+	}
+	if merchant, ok := r.Context().Value(middleware.MerchantContextKey).(*store.Merchant); ok && !merchant.IsAnonymous() {
+		return store.AuditActorMerchant, merchant.ID
+	}
+	if user, ok := r.Context().Value(middleware.UserContextKey).(*store.User); ok && !user.IsAnonymous() {
+		return store.AuditActorUser, user.ID
+	}
+	if admin, ok := r.Context().Value(middleware.AdminContextKey).(*store.Admin); ok {
+		if admin.ID == "" {
+			return store.AuditActorAdmin, admin.Username
+		}
+		return store.AuditActorAdmin, admin.ID
+	}
+	return store.AuditActorAnonymous, ""
+}
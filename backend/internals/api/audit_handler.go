@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+)
+
+// auditVerifyBatch is how many events HandleVerifyAuditChain reads at a time.
+const auditVerifyBatch = 500
+
+type AuditHandler struct {
+	AuditStore store.AuditStore
+	Logger     *log.Logger
+}
+
+func NewAuditHandler(auditStore store.AuditStore, logger *log.Logger) *AuditHandler {
+	return &AuditHandler{AuditStore: auditStore, Logger: logger}
+}
+
+// HandleGetMerchantAuditEvents returns the merchant's audit feed, newest
+// first, filtered by action and actor_type.
+func (ah *AuditHandler) HandleGetMerchantAuditEvents(w http.ResponseWriter, r *http.Request) {
+	filter, ok := readAuditFilter(w, r)
+	if !ok {
+		return
+	}
+	filter.MerchantID = middleware.GetMerchant(r).ID
+
+	ah.writeAuditEvents(w, filter)
+}
+
+// HandleGetAuditEvents returns the platform-wide audit log, optionally for a
+// single merchant_id.
+func (ah *AuditHandler) HandleGetAuditEvents(w http.ResponseWriter, r *http.Request) {
+	filter, ok := readAuditFilter(w, r)
+	if !ok {
+		return
+	}
+	filter.MerchantID = r.URL.Query().Get("merchant_id")
+	if filter.MerchantID != "" {
+		if _, err := uuid.Parse(filter.MerchantID); err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "merchant_id must be a valid id"})
+			return
+		}
+	}
+
+	ah.writeAuditEvents(w, filter)
+}
+
+// HandleVerifyAuditChain walks the whole log from the first event and checks
+// every link. A broken chain is reported with 200 so the caller can tell it
+// apart from the check itself failing.
+func (ah *AuditHandler) HandleVerifyAuditChain(w http.ResponseWriter, r *http.Request) {
+	var seq, verified int64
+	prevHash := []byte{}
+	for {
+		events, err := ah.AuditStore.GetAuditEventsAfter(seq, auditVerifyBatch)
+		if err != nil {
+			ah.Logger.Printf("ERROR: error getting audit events at GetAuditEventsAfter: %v", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+			return
+		}
+		if len(events) == 0 {
+			break
+		}
+
+		err = store.VerifyAuditChain(prevHash, events)
+		if errors.Is(err, store.ErrAuditChainBroken) {
+			ah.Logger.Printf("ERROR: audit chain verification failed at VerifyAuditChain: %v", err)
+			utils.WriteJSON(w, http.StatusOK, utils.Envelope{"valid": false, "verified": verified, "error": err.Error()})
+			return
+		}
+
+		last := events[len(events)-1]
+		seq, prevHash = last.Seq, last.Hash
+		verified += int64(len(events))
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"valid": true, "verified": verified, "head_seq": seq, "head_hash": hex.EncodeToString(prevHash)})
+}
+
+func (ah *AuditHandler) writeAuditEvents(w http.ResponseWriter, filter store.AuditEventFilter) {
+	events, err := ah.AuditStore.GetAuditEvents(filter)
+	if err != nil {
+		ah.Logger.Printf("ERROR: error getting audit events at GetAuditEvents: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"events": events})
+}
+
+func readAuditFilter(w http.ResponseWriter, r *http.Request) (store.AuditEventFilter, bool) {
+	limit, offset, ok := readPage(w, r)
+	if !ok {
+		return store.AuditEventFilter{}, false
+	}
+	query := r.URL.Query()
+	return store.AuditEventFilter{
+		Action:    query.Get("action"),
+		ActorType: query.Get("actor_type"),
+		Limit:     limit,
+		Offset:    offset,
+	}, true
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/balances"
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+)
+
+// TokenBalance is a balance in the token's minor unit, cents for KES.
+type TokenBalance struct {
+	TokenID      string       `json:"token_id"`
+	Balance      money.Amount `json:"balance"`
+	CampaignID   string       `json:"campaign_id,omitempty"`
+	CampaignName string       `json:"campaign_name,omitempty"`
+	ShopID       string       `json:"shop_id,omitempty"`
+}
+
+type BalancesResponse struct {
+	AccountID      string          `json:"account_id"`
+	KES            TokenBalance    `json:"kes"`
+	CampaignTokens []*TokenBalance `json:"campaign_tokens"`
+	FetchedAt      time.Time       `json:"fetched_at"`
+}
+
+type BalanceHandler struct {
+	Balances  *balances.Cache
+	ShopStore store.ShopStore
+	Logger    *log.Logger
+}
+
+func NewBalanceHandler(balanceCache *balances.Cache, shopStore store.ShopStore, logger *log.Logger) *BalanceHandler {
+	return &BalanceHandler{Balances: balanceCache, ShopStore: shopStore, Logger: logger}
+}
+
+func (bh *BalanceHandler) HandleGetMerchantBalances(w http.ResponseWriter, r *http.Request) {
+	bh.writeBalances(w, middleware.GetMerchant(r).AccountID)
+}
+
+func (bh *BalanceHandler) HandleGetUserBalances(w http.ResponseWriter, r *http.Request) {
+	bh.writeBalances(w, middleware.GetUser(r).AccountID)
+}
+
+// writeBalances responds with the account's KES balance and any campaign
+// tokens it holds. fetched_at is when the figures were read from the ledger,
+// which may be up to balances.TTL ago.
+func (bh *BalanceHandler) writeBalances(w http.ResponseWriter, accountID string) {
+	balance, err := bh.Balances.Get(accountID)
+	if err != nil {
+		bh.Logger.Printf("ERROR: error getting balances at Get: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	kshTokenID := os.Getenv("KSH_TOKEN_ID")
+	response := &BalancesResponse{
+		AccountID:      accountID,
+		KES:            TokenBalance{TokenID: kshTokenID, Balance: balance.Token(kshTokenID)},
+		CampaignTokens: []*TokenBalance{},
+		FetchedAt:      balance.FetchedAt,
+	}
+
+	tokenIDs := make([]string, 0, len(balance.Tokens))
+	for tokenID := range balance.Tokens {
+		if tokenID != kshTokenID {
+			tokenIDs = append(tokenIDs, tokenID)
+		}
+	}
+	if len(tokenIDs) > 0 {
+		campaigns, err := bh.ShopStore.GetCampaignsByTokenIDs(tokenIDs)
+		if err != nil {
+			bh.Logger.Printf("ERROR: error getting campaigns at GetCampaignsByTokenIDs: %v", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+			return
+		}
+		for _, campaign := range campaigns {
+			response.CampaignTokens = append(response.CampaignTokens, &TokenBalance{
+				TokenID:      campaign.TokenID,
+				Balance:      balance.Token(campaign.TokenID),
+				CampaignID:   campaign.ID,
+				CampaignName: campaign.Name,
+				ShopID:       campaign.ShopID,
+			})
+		}
+		sort.Slice(response.CampaignTokens, func(i, j int) bool {
+			return response.CampaignTokens[i].CampaignName < response.CampaignTokens[j].CampaignName
+		})
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"balances": response})
+}
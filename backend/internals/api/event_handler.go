@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/events"
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+)
+
+// streamHeartbeat keeps proxies from closing idle streams.
+const streamHeartbeat = 25 * time.Second
+
+type EventHandler struct {
+	Hub    *events.Hub
+	Logger *log.Logger
+}
+
+func NewEventHandler(hub *events.Hub, logger *log.Logger) *EventHandler {
+	return &EventHandler{Hub: hub, Logger: logger}
+}
+
+func (eh *EventHandler) HandleMerchantStream(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	eh.stream(w, r, events.MerchantTopic(merchant.ID))
+}
+
+func (eh *EventHandler) HandleUserStream(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	eh.stream(w, r, events.UserTopic(user.ID))
+}
+
+// stream writes hub events for topic as Server-Sent Events until the client
+// disconnects.
+func (eh *EventHandler) stream(w http.ResponseWriter, r *http.Request, topic string) {
+	rc := http.NewResponseController(w)
+	// The server's WriteTimeout would otherwise cut every stream off.
+	err := rc.SetWriteDeadline(time.Time{})
+	if err != nil {
+		eh.Logger.Printf("ERROR: error clearing write deadline for event stream at SetWriteDeadline: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	subscription, unsubscribe := eh.Hub.Subscribe(topic)
+	defer unsubscribe()
+
+	fmt.Fprint(w, ": connected\n\n")
+	err = rc.Flush()
+	if err != nil {
+		eh.Logger.Printf("ERROR: error flushing event stream at Flush: %v", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case event := <-subscription:
+			data, err := json.Marshal(event)
+			if err != nil {
+				eh.Logger.Printf("ERROR: error marshalling stream event at Marshal: %v", err)
+				continue
+			}
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+		}
+		err = rc.Flush()
+		if err != nil {
+			return
+		}
+	}
+}
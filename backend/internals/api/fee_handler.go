@@ -0,0 +1,192 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/divin3circle/orcus/backend/internals/fees"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+)
+
+type FeeHandler struct {
+	FeeStore      store.FeeStore
+	MerchantStore store.MerchantStore
+	AdminStore    store.AdminStore
+	Audit         *AuditRecorder
+	Logger        *log.Logger
+}
+
+func NewFeeHandler(feeStore store.FeeStore, merchantStore store.MerchantStore, adminStore store.AdminStore, audit *AuditRecorder, logger *log.Logger) *FeeHandler {
+	return &FeeHandler{FeeStore: feeStore, MerchantStore: merchantStore, AdminStore: adminStore, Audit: audit, Logger: logger}
+}
+
+// HandleCreateFeeRule adds a rule. Rules are active unless the body says
+// otherwise and round down unless a rounding mode is given.
+func (fh *FeeHandler) HandleCreateFeeRule(w http.ResponseWriter, r *http.Request) {
+	rule := &store.FeeRule{Active: true, Rounding: "down"}
+	err := json.NewDecoder(r.Body).Decode(rule)
+	if err != nil {
+		fh.Logger.Printf("ERROR: error decoding create fee rule request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if !fh.validateFeeRule(w, rule) {
+		return
+	}
+
+	created, err := fh.FeeStore.CreateFeeRule(rule)
+	if err != nil {
+		fh.Logger.Printf("ERROR: error creating fee rule at CreateFeeRule: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	recordAdminAction(fh.AdminStore, fh.Audit, fh.Logger, r, "fee_rule.create", "fee_rule", created.ID, created)
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"fee_rule": created})
+}
+
+func (fh *FeeHandler) HandleGetFeeRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := fh.FeeStore.GetFeeRules()
+	if err != nil {
+		fh.Logger.Printf("ERROR: error getting fee rules at GetFeeRules: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"fee_rules": rules})
+}
+
+func (fh *FeeHandler) HandleGetFeeRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := fh.readFeeRule(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"fee_rule": rule})
+}
+
+// HandleUpdateFeeRule applies the fields present in the body to the rule and
+// leaves the rest as they were.
+func (fh *FeeHandler) HandleUpdateFeeRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := fh.readFeeRule(w, r)
+	if !ok {
+		return
+	}
+	ruleID := rule.ID
+
+	err := json.NewDecoder(r.Body).Decode(rule)
+	if err != nil {
+		fh.Logger.Printf("ERROR: error decoding update fee rule request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	rule.ID = ruleID
+
+	if !fh.validateFeeRule(w, rule) {
+		return
+	}
+
+	updated, err := fh.FeeStore.UpdateFeeRule(rule)
+	if err != nil {
+		fh.Logger.Printf("ERROR: error updating fee rule at UpdateFeeRule: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if updated == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "fee rule not found"})
+		return
+	}
+
+	recordAdminAction(fh.AdminStore, fh.Audit, fh.Logger, r, "fee_rule.update", "fee_rule", updated.ID, updated)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"fee_rule": updated})
+}
+
+// HandleDeleteFeeRule deactivates the rule. Transactions it priced keep
+// their reference to it.
+func (fh *FeeHandler) HandleDeleteFeeRule(w http.ResponseWriter, r *http.Request) {
+	ruleID, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		fh.Logger.Printf("ERROR: error reading fee rule id at ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if _, err = uuid.Parse(ruleID); err != nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "fee rule not found"})
+		return
+	}
+
+	err = fh.FeeStore.DeactivateFeeRule(ruleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "fee rule not found"})
+		return
+	}
+	if err != nil {
+		fh.Logger.Printf("ERROR: error deactivating fee rule at DeactivateFeeRule: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	recordAdminAction(fh.AdminStore, fh.Audit, fh.Logger, r, "fee_rule.deactivate", "fee_rule", ruleID, nil)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "Fee rule deactivated"})
+}
+
+func (fh *FeeHandler) readFeeRule(w http.ResponseWriter, r *http.Request) (*store.FeeRule, bool) {
+	ruleID, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		fh.Logger.Printf("ERROR: error reading fee rule id at ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if _, err = uuid.Parse(ruleID); err != nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "fee rule not found"})
+		return nil, false
+	}
+
+	rule, err := fh.FeeStore.GetFeeRuleByID(ruleID)
+	if err != nil {
+		fh.Logger.Printf("ERROR: error getting fee rule at GetFeeRuleByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if rule == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "fee rule not found"})
+		return nil, false
+	}
+	return rule, true
+}
+
+// validateFeeRule writes a 400 and returns false when the rule is invalid or
+// overrides a merchant that does not exist.
+func (fh *FeeHandler) validateFeeRule(w http.ResponseWriter, rule *store.FeeRule) bool {
+	err := fees.Validate(rule)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return false
+	}
+	if rule.MerchantID == "" {
+		return true
+	}
+
+	if _, err = uuid.Parse(rule.MerchantID); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "merchant not found"})
+		return false
+	}
+	merchant, err := fh.MerchantStore.GetMerchantByID(rule.MerchantID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		fh.Logger.Printf("ERROR: error getting fee rule merchant at GetMerchantByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return false
+	}
+	if merchant == nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "merchant not found"})
+		return false
+	}
+	return true
+}
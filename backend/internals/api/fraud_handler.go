@@ -0,0 +1,360 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/divin3circle/orcus/backend/internals/fraud"
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/sms"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/tokens"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+)
+
+var (
+	errPaymentDeclined   = errors.New("payment was declined")
+	errPaymentChallenged = errors.New("confirm this payment with the code sent to your mobile number")
+	errInvalidChallenge  = errors.New("invalid or expired challenge code")
+)
+
+// FraudChallengeAnswer confirms a challenged payment. The client repeats the
+// payment with the challenge id from the 428 response and the code the
+// customer received by SMS.
+type FraudChallengeAnswer struct {
+	ChallengeID   string `json:"challenge_id"`
+	ChallengeCode string `json:"challenge_code"`
+}
+
+type FraudReviewRequest struct {
+	Outcome string `json:"outcome"`
+	Note    string `json:"note"`
+}
+
+type FraudHandler struct {
+	FraudStore     store.FraudStore
+	Engine         *fraud.Engine
+	UserTokenStore store.UserTokenStore
+	Sender         sms.Sender
+	AdminStore     store.AdminStore
+	Audit          *AuditRecorder
+	Logger         *log.Logger
+}
+
+func NewFraudHandler(fraudStore store.FraudStore, engine *fraud.Engine, userTokenStore store.UserTokenStore, sender sms.Sender, adminStore store.AdminStore, audit *AuditRecorder, logger *log.Logger) *FraudHandler {
+	return &FraudHandler{FraudStore: fraudStore, Engine: engine, UserTokenStore: userTokenStore, Sender: sender, AdminStore: adminStore, Audit: audit, Logger: logger}
+}
+
+func (fh *FraudHandler) HandleGetRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := fh.FraudStore.GetFraudRules()
+	if err != nil {
+		fh.Logger.Printf("ERROR: error getting fraud rules at GetFraudRules: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"fraud_rules": rules})
+}
+
+// HandleCreateRule adds a rule, active unless the body says otherwise.
+func (fh *FraudHandler) HandleCreateRule(w http.ResponseWriter, r *http.Request) {
+	rule := &store.FraudRule{Active: true}
+	err := json.NewDecoder(r.Body).Decode(rule)
+	if err != nil {
+		fh.Logger.Printf("ERROR: error decoding create fraud rule request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	err = fraud.Validate(rule)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	created, err := fh.FraudStore.CreateFraudRule(rule)
+	if err != nil {
+		fh.Logger.Printf("ERROR: error creating fraud rule at CreateFraudRule: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	recordAdminAction(fh.AdminStore, fh.Audit, fh.Logger, r, "fraud_rule.create", "fraud_rule", created.ID, created)
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"fraud_rule": created})
+}
+
+// HandleUpdateRule applies the fields present in the body to the rule and
+// leaves the rest as they were. Set active to false to retire a rule; rules
+// are kept so past hits still point at them.
+func (fh *FraudHandler) HandleUpdateRule(w http.ResponseWriter, r *http.Request) {
+	ruleID, ok := fh.readUUIDParam(w, r, "fraud rule not found")
+	if !ok {
+		return
+	}
+	rule, err := fh.FraudStore.GetFraudRuleByID(ruleID)
+	if err != nil {
+		fh.Logger.Printf("ERROR: error getting fraud rule at GetFraudRuleByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if rule == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "fraud rule not found"})
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(rule)
+	if err != nil {
+		fh.Logger.Printf("ERROR: error decoding update fraud rule request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	rule.ID = ruleID
+	err = fraud.Validate(rule)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	updated, err := fh.FraudStore.UpdateFraudRule(rule)
+	if err != nil {
+		fh.Logger.Printf("ERROR: error updating fraud rule at UpdateFraudRule: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if updated == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "fraud rule not found"})
+		return
+	}
+
+	recordAdminAction(fh.AdminStore, fh.Audit, fh.Logger, r, "fraud_rule.update", "fraud_rule", updated.ID, updated)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"fraud_rule": updated})
+}
+
+// HandleGetDecisions lists screened payments that hit a rule, newest first,
+// filtered by user_id, outcome and unreviewed=true.
+func (fh *FraudHandler) HandleGetDecisions(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := readPage(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	filter := store.FraudDecisionFilter{
+		UserID:     query.Get("user_id"),
+		Outcome:    query.Get("outcome"),
+		Unreviewed: query.Get("unreviewed") == "true",
+		Limit:      limit,
+		Offset:     offset,
+	}
+	if filter.Outcome != "" && filter.Outcome != store.FraudOutcomeAllow && filter.Outcome != store.FraudOutcomeChallenge && filter.Outcome != store.FraudOutcomeBlock {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "outcome must be allow, challenge or block"})
+		return
+	}
+
+	decisions, err := fh.FraudStore.SearchFraudDecisions(filter)
+	if err != nil {
+		fh.Logger.Printf("ERROR: error searching fraud decisions at SearchFraudDecisions: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"decisions": decisions})
+}
+
+func (fh *FraudHandler) HandleGetDecision(w http.ResponseWriter, r *http.Request) {
+	decisionID, ok := fh.readUUIDParam(w, r, "fraud decision not found")
+	if !ok {
+		return
+	}
+
+	decision, err := fh.FraudStore.GetFraudDecisionByID(decisionID)
+	if err != nil {
+		fh.Logger.Printf("ERROR: error getting fraud decision at GetFraudDecisionByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if decision == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "fraud decision not found"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"decision": decision})
+}
+
+// HandleReviewDecision records whether a flagged payment was fraud. The
+// verdict is for tuning rules and follow-up; it does not reverse payments.
+func (fh *FraudHandler) HandleReviewDecision(w http.ResponseWriter, r *http.Request) {
+	decisionID, ok := fh.readUUIDParam(w, r, "fraud decision not found")
+	if !ok {
+		return
+	}
+
+	var req FraudReviewRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		fh.Logger.Printf("ERROR: error decoding fraud review request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if req.Outcome != store.FraudReviewFraud && req.Outcome != store.FraudReviewLegitimate {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "outcome must be fraud or legitimate"})
+		return
+	}
+
+	reviewed, err := fh.FraudStore.ReviewFraudDecision(decisionID, req.Outcome, strings.TrimSpace(req.Note), middleware.GetAdmin(r).Username)
+	if err != nil {
+		fh.Logger.Printf("ERROR: error reviewing fraud decision at ReviewFraudDecision: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if reviewed == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "fraud decision not found"})
+		return
+	}
+
+	recordAdminAction(fh.AdminStore, fh.Audit, fh.Logger, r, "fraud_decision.review", store.AdminSubjectUser, reviewed.UserID, map[string]string{"decision_id": reviewed.ID, "outcome": reviewed.ReviewOutcome, "note": reviewed.ReviewNote})
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"decision": reviewed})
+}
+
+// screen runs a payment through the fraud rules before any money moves. It
+// returns the decision to link the payment to, or nil when no rule was hit.
+// When the payment may not go ahead the response has been written and an
+// error is returned. A nil answer means the customer is not there to confirm,
+// as with mandate charges, so a challenge declines the payment.
+func (fh *FraudHandler) screen(w http.ResponseWriter, payment fraud.Payment, answer *FraudChallengeAnswer) (*store.FraudDecision, error) {
+	result, err := fh.Engine.Evaluate(payment)
+	if err != nil {
+		fh.Logger.Printf("ERROR: error screening payment at Evaluate: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return nil, err
+	}
+
+	if result.Outcome == store.FraudOutcomeChallenge && answer != nil && answer.ChallengeID != "" {
+		decision, err := fh.confirm(payment, answer)
+		if err != nil {
+			fh.Logger.Printf("ERROR: error confirming payment challenge at confirm: %v", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+			return nil, err
+		}
+		if decision == nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": errInvalidChallenge.Error()})
+			return nil, errInvalidChallenge
+		}
+		return decision, nil
+	}
+	if len(result.Hits) == 0 {
+		return nil, nil
+	}
+
+	challenge := result.Outcome == store.FraudOutcomeChallenge && answer != nil
+	decision := &store.FraudDecision{UserID: payment.User.ID, ShopID: payment.ShopID, Amount: payment.Amount, Outcome: result.Outcome, Hits: result.Hits}
+	if challenge {
+		decision.ChallengeStatus = store.FraudChallengePending
+	}
+	decision, err = fh.FraudStore.CreateFraudDecision(decision)
+	if err != nil {
+		fh.Logger.Printf("ERROR: error recording fraud decision at CreateFraudDecision: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return nil, err
+	}
+
+	switch {
+	case result.Outcome == store.FraudOutcomeAllow:
+		return decision, nil
+	case challenge:
+		err = fh.sendChallenge(payment)
+		if err != nil {
+			fh.Logger.Printf("ERROR: error sending payment challenge: %v", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to send verification code"})
+			return nil, err
+		}
+		utils.WriteJSON(w, http.StatusPreconditionRequired, utils.Envelope{
+			"error":     errPaymentChallenged.Error(),
+			"challenge": utils.Envelope{"id": decision.ID, "expires_at": decision.CreatedAt.Add(OTPTTL)},
+		})
+		return nil, errPaymentChallenged
+	default:
+		// Customers are not told which rule stopped them.
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": errPaymentDeclined.Error(), "decision_id": decision.ID})
+		return nil, errPaymentDeclined
+	}
+}
+
+// sendChallenge texts the customer a code. Codes are per user rather than
+// per challenge, so a code sent in the last minute is left to answer this
+// challenge too instead of sending another.
+func (fh *FraudHandler) sendChallenge(payment fraud.Payment) error {
+	recent, err := fh.UserTokenStore.HasRecentOTP(payment.User.ID, tokens.ScopePaymentChallenge, time.Now().Add(-OTPCooldown))
+	if err != nil || recent {
+		return err
+	}
+	otp, err := fh.UserTokenStore.CreateOTP(payment.User.ID, OTPTTL, tokens.ScopePaymentChallenge)
+	if err != nil {
+		return err
+	}
+	return fh.Sender.Send(payment.User.MobileNumber, paymentChallengeMessage(payment.Amount, otp.Plaintext))
+}
+
+// confirm checks answer against the challenge it names, which must be for the
+// same customer, shop and amount and still pending. It returns nil, nil when
+// the answer does not hold.
+func (fh *FraudHandler) confirm(payment fraud.Payment, answer *FraudChallengeAnswer) (*store.FraudDecision, error) {
+	if _, err := uuid.Parse(answer.ChallengeID); err != nil {
+		return nil, nil
+	}
+	decision, err := fh.FraudStore.GetFraudDecisionByID(answer.ChallengeID)
+	if err != nil || decision == nil {
+		return nil, err
+	}
+	if decision.UserID != payment.User.ID || decision.ShopID != payment.ShopID || decision.Amount != payment.Amount ||
+		decision.ChallengeStatus != store.FraudChallengePending || time.Since(decision.CreatedAt) > OTPTTL {
+		return nil, nil
+	}
+
+	ok, err := fh.UserTokenStore.MatchOTP(payment.User.ID, tokens.ScopePaymentChallenge, answer.ChallengeCode)
+	if err != nil || !ok {
+		return nil, err
+	}
+	passed, err := fh.FraudStore.PassFraudChallenge(decision.ID)
+	if err != nil || !passed {
+		return nil, err
+	}
+	decision.ChallengeStatus = store.FraudChallengePassed
+	return decision, nil
+}
+
+// linkTransaction ties a decision to the payment it let through. The payment
+// has already been made, so a failure is logged rather than returned.
+func (fh *FraudHandler) linkTransaction(decision *store.FraudDecision, transactionID string) {
+	if decision == nil {
+		return
+	}
+	err := fh.FraudStore.SetFraudDecisionTransaction(decision.ID, transactionID)
+	if err != nil {
+		fh.Logger.Printf("ERROR: error linking fraud decision %s to transaction %s at SetFraudDecisionTransaction: %v", decision.ID, transactionID, err)
+	}
+}
+
+func (fh *FraudHandler) readUUIDParam(w http.ResponseWriter, r *http.Request, notFound string) (string, bool) {
+	id, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		fh.Logger.Printf("ERROR: error reading id at ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return "", false
+	}
+	if _, err = uuid.Parse(id); err != nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": notFound})
+		return "", false
+	}
+	return id, true
+}
+
+func paymentChallengeMessage(amount money.Amount, code string) string {
+	return fmt.Sprintf("Your Orcus code to confirm a payment of KES %s is %s. It expires in %d minutes. Do not share it.", money.KES.Format(amount), code, int(OTPTTL.Minutes()))
+}
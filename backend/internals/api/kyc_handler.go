@@ -0,0 +1,305 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/divin3circle/orcus/backend/internals/kyc"
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+)
+
+// maxKYCDocuments caps how many document images one submission may link.
+const maxKYCDocuments = 5
+
+type KYCSubmissionRequest struct {
+	Level          string   `json:"level"`
+	DocumentType   string   `json:"document_type"`
+	DocumentNumber string   `json:"document_number"`
+	DocumentURLs   []string `json:"document_urls"`
+}
+
+type KYCDecisionRequest struct {
+	Reason string `json:"reason"`
+}
+
+type KYCHandler struct {
+	KYCStore   store.KYCStore
+	Provider   kyc.Provider
+	Limits     *kyc.Limiter
+	AdminStore store.AdminStore
+	Audit      *AuditRecorder
+	Logger     *log.Logger
+}
+
+func NewKYCHandler(kycStore store.KYCStore, provider kyc.Provider, limits *kyc.Limiter, adminStore store.AdminStore, audit *AuditRecorder, logger *log.Logger) *KYCHandler {
+	return &KYCHandler{KYCStore: kycStore, Provider: provider, Limits: limits, AdminStore: adminStore, Audit: audit, Logger: logger}
+}
+
+// HandleGetUserKYC returns the user's level, what it allows them to pay and
+// buy, and their submissions.
+func (kh *KYCHandler) HandleGetUserKYC(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	kh.writeStatus(w, store.KYCSubjectUser, user.ID, user.KYCLevel, store.KYCOperationPayment, store.KYCOperationPurchase)
+}
+
+func (kh *KYCHandler) HandleCreateUserSubmission(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	kh.submit(w, r, store.KYCSubjectUser, user.ID, user.KYCLevel)
+}
+
+// HandleGetMerchantKYC returns the merchant's level, what it allows them to
+// withdraw, and their submissions.
+func (kh *KYCHandler) HandleGetMerchantKYC(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	kh.writeStatus(w, store.KYCSubjectMerchant, merchant.ID, merchant.KYCLevel, store.KYCOperationWithdrawal)
+}
+
+func (kh *KYCHandler) HandleCreateMerchantSubmission(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	kh.submit(w, r, store.KYCSubjectMerchant, merchant.ID, merchant.KYCLevel)
+}
+
+// HandleGetSubmissions lists submissions for review, filtered by ?status=
+// and paged with limit and offset.
+func (kh *KYCHandler) HandleGetSubmissions(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := readPage(w, r)
+	if !ok {
+		return
+	}
+	status := r.URL.Query().Get("status")
+	if status != "" && status != store.KYCStatusPending && status != store.KYCStatusApproved && status != store.KYCStatusRejected {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "status must be pending, approved or rejected"})
+		return
+	}
+
+	submissions, err := kh.KYCStore.SearchKYCSubmissions(status, limit, offset)
+	if err != nil {
+		kh.Logger.Printf("ERROR: error searching kyc submissions at SearchKYCSubmissions: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"submissions": submissions})
+}
+
+// HandleApproveSubmission approves a pending submission, raising the
+// account to the level it asked for.
+func (kh *KYCHandler) HandleApproveSubmission(w http.ResponseWriter, r *http.Request) {
+	kh.decide(w, r, store.KYCStatusApproved)
+}
+
+// HandleRejectSubmission rejects a pending submission. The reason is shown
+// to the account holder.
+func (kh *KYCHandler) HandleRejectSubmission(w http.ResponseWriter, r *http.Request) {
+	kh.decide(w, r, store.KYCStatusRejected)
+}
+
+func (kh *KYCHandler) HandleGetLimits(w http.ResponseWriter, r *http.Request) {
+	limits, err := kh.KYCStore.GetKYCLimits()
+	if err != nil {
+		kh.Logger.Printf("ERROR: error getting kyc limits at GetKYCLimits: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"limits": limits})
+}
+
+// HandleUpdateLimit sets the daily and monthly limits, in cents, for one
+// level and operation.
+func (kh *KYCHandler) HandleUpdateLimit(w http.ResponseWriter, r *http.Request) {
+	limit := &store.KYCLimit{}
+	err := json.NewDecoder(r.Body).Decode(limit)
+	if err != nil {
+		kh.Logger.Printf("ERROR: error decoding kyc limit request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	switch {
+	case store.KYCRank(limit.Level) < 0:
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "level must be one of " + strings.Join(store.KYCLevels, ", ")})
+		return
+	case limit.Operation != store.KYCOperationPayment && limit.Operation != store.KYCOperationPurchase && limit.Operation != store.KYCOperationWithdrawal:
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "operation must be payment, purchase or withdrawal"})
+		return
+	case limit.DailyLimit < 0 || limit.MonthlyLimit < 0:
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "limits must not be negative"})
+		return
+	case limit.DailyLimit > limit.MonthlyLimit:
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "daily limit must not exceed monthly limit"})
+		return
+	}
+
+	updated, err := kh.KYCStore.UpsertKYCLimit(limit)
+	if err != nil {
+		kh.Logger.Printf("ERROR: error updating kyc limit at UpsertKYCLimit: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	recordAdminAction(kh.AdminStore, kh.Audit, kh.Logger, r, "kyc_limit.update", "kyc_limit", updated.Level+"/"+updated.Operation, updated)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"limit": updated})
+}
+
+func (kh *KYCHandler) writeStatus(w http.ResponseWriter, subjectType string, subjectID string, level string, operations ...string) {
+	limits, err := kh.Limits.Statuses(subjectID, level, operations...)
+	if err != nil {
+		kh.Logger.Printf("ERROR: error getting kyc limits at Statuses: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	submissions, err := kh.KYCStore.GetKYCSubmissions(subjectType, subjectID)
+	if err != nil {
+		kh.Logger.Printf("ERROR: error getting kyc submissions at GetKYCSubmissions: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"kyc_level": level, "limits": limits, "submissions": submissions})
+}
+
+// submit records a submission and hands it to the provider. Providers that
+// answer straight away settle it here; otherwise it stays pending until the
+// provider or an admin decides.
+func (kh *KYCHandler) submit(w http.ResponseWriter, r *http.Request, subjectType string, subjectID string, currentLevel string) {
+	var req KYCSubmissionRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		kh.Logger.Printf("ERROR: error decoding kyc submission request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	req.DocumentNumber = strings.TrimSpace(req.DocumentNumber)
+	if req.DocumentNumber == "" || len(req.DocumentNumber) > 50 {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "document number must be between 1 and 50 characters long"})
+		return
+	}
+	if len(req.DocumentURLs) == 0 || len(req.DocumentURLs) > maxKYCDocuments || slices.Contains(req.DocumentURLs, "") {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "between 1 and 5 document urls are required"})
+		return
+	}
+	err = kyc.ValidateSubmission(subjectType, currentLevel, req.Level, req.DocumentType)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	submission, err := kh.KYCStore.CreateKYCSubmission(&store.KYCSubmission{
+		SubjectType:    subjectType,
+		SubjectID:      subjectID,
+		Level:          req.Level,
+		DocumentType:   req.DocumentType,
+		DocumentNumber: req.DocumentNumber,
+		DocumentURLs:   req.DocumentURLs,
+		Provider:       kh.Provider.Name(),
+	})
+	if errors.Is(err, store.ErrKYCSubmissionPending) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		kh.Logger.Printf("ERROR: error creating kyc submission at CreateKYCSubmission: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	kh.Audit.Record(r, "kyc.submit", "kyc_submission", submission.ID, map[string]string{"level": submission.Level, "document_type": submission.DocumentType})
+
+	// The submission is saved, so a provider failure leaves it pending for
+	// an admin rather than failing the request.
+	result, err := kh.Provider.Verify(r.Context(), submission)
+	if err != nil {
+		kh.Logger.Printf("ERROR: error verifying kyc submission %s at Verify: %v", submission.ID, err)
+		utils.WriteJSON(w, http.StatusAccepted, utils.Envelope{"submission": submission})
+		return
+	}
+	if result.Reference != "" {
+		err = kh.KYCStore.SetKYCProviderReference(submission.ID, result.Reference)
+		if err != nil {
+			kh.Logger.Printf("ERROR: error saving kyc provider reference at SetKYCProviderReference: %v", err)
+		}
+		submission.ProviderReference = result.Reference
+	}
+	if result.Status == store.KYCStatusPending {
+		utils.WriteJSON(w, http.StatusAccepted, utils.Envelope{"submission": submission})
+		return
+	}
+
+	decided, err := kh.KYCStore.DecideKYCSubmission(submission.ID, result.Status, result.Reason, kh.Provider.Name())
+	if err != nil {
+		kh.Logger.Printf("ERROR: error deciding kyc submission at DecideKYCSubmission: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	kh.Audit.Record(r, "kyc."+result.Status, "kyc_submission", decided.ID, map[string]string{"level": decided.Level, "provider": kh.Provider.Name()})
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"submission": decided})
+}
+
+func (kh *KYCHandler) decide(w http.ResponseWriter, r *http.Request, status string) {
+	submissionID, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		kh.Logger.Printf("ERROR: error reading kyc submission id at ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if _, err = uuid.Parse(submissionID); err != nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "kyc submission not found"})
+		return
+	}
+
+	var req KYCDecisionRequest
+	if r.ContentLength != 0 {
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			kh.Logger.Printf("ERROR: error decoding kyc decision request body at Decode: %v", err)
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if status == store.KYCStatusRejected && req.Reason == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "a reason is required to reject a submission"})
+		return
+	}
+
+	decided, err := kh.KYCStore.DecideKYCSubmission(submissionID, status, req.Reason, middleware.GetAdmin(r).Username)
+	if err != nil {
+		kh.Logger.Printf("ERROR: error deciding kyc submission at DecideKYCSubmission: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if decided == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "no pending kyc submission with that id"})
+		return
+	}
+
+	recordAdminAction(kh.AdminStore, kh.Audit, kh.Logger, r, "kyc."+status, decided.SubjectType, decided.SubjectID, map[string]string{"submission_id": decided.ID, "level": decided.Level, "reason": decided.Reason})
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"submission": decided})
+}
+
+// checkKYCLimit writes a 403 naming the exceeded limit, or a 500, and
+// returns the error when the account may not move amount through operation.
+func checkKYCLimit(w http.ResponseWriter, limits *kyc.Limiter, logger *log.Logger, subjectID string, level string, operation string, amount money.Amount) error {
+	err := limits.Check(subjectID, level, operation, amount)
+	var limitErr *kyc.LimitError
+	if errors.As(err, &limitErr) {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": limitErr.Error(), "limit": limitErr})
+		return err
+	}
+	if err != nil {
+		logger.Printf("ERROR: error checking kyc limit at Check: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/divin3circle/orcus/backend/internals/mandates"
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+)
+
+const MaxMandateIntervalCount = 365
+
+type MandateRequest struct {
+	ShopID string `json:"shop_id"`
+	// Amount is in whole KES.
+	Amount int64 `json:"amount"`
+	// IntervalUnit is day, week or month. IntervalCount defaults to 1, so
+	// school fees each term would be month and 3.
+	IntervalUnit  string `json:"interval_unit"`
+	IntervalCount int    `json:"interval_count"`
+	Reference     string `json:"reference"`
+	// StartsAt is when the first payment is taken, now when left out.
+	StartsAt    *time.Time `json:"starts_at"`
+	EndsAt      *time.Time `json:"ends_at"`
+	MaxPayments int        `json:"max_payments"`
+}
+
+// MandateHandler lets users set up recurring payments to shops and merchants
+// see the mandates paying them. Payments are taken by the mandates
+// scheduler, not by these handlers.
+type MandateHandler struct {
+	MandateStore store.MandateStore
+	ShopStore    store.ShopStore
+	Logger       *log.Logger
+}
+
+func NewMandateHandler(mandateStore store.MandateStore, shopStore store.ShopStore, logger *log.Logger) *MandateHandler {
+	return &MandateHandler{MandateStore: mandateStore, ShopStore: shopStore, Logger: logger}
+}
+
+func (mh *MandateHandler) HandleCreateMandate(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	var req MandateRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		mh.Logger.Printf("ERROR: error decoding mandate request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	mandate, err := mh.validateMandateRequest(&req)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if !user.PhoneVerified {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": errPhoneNotVerified.Error()})
+		return
+	}
+
+	shop, err := mh.ShopStore.GetShopByID(req.ShopID)
+	if err != nil {
+		mh.Logger.Printf("ERROR: error getting shop at GetShopByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if shop == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "shop not found"})
+		return
+	}
+	mandate.UserID = user.ID
+	mandate.ShopID = shop.ID
+	mandate.MerchantID = shop.MerchantID
+
+	created, err := mh.MandateStore.CreateMandate(mandate)
+	if err != nil {
+		mh.Logger.Printf("ERROR: error creating mandate at CreateMandate: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"mandate": created})
+}
+
+func (mh *MandateHandler) HandleGetUserMandates(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	mandateList, err := mh.MandateStore.GetMandatesByUserID(user.ID)
+	if err != nil {
+		mh.Logger.Printf("ERROR: error getting mandates at GetMandatesByUserID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"mandates": mandateList})
+}
+
+// HandleGetUserMandate returns the mandate with its payment attempts.
+func (mh *MandateHandler) HandleGetUserMandate(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	mandate, ok := mh.readMandate(w, r, func(mandate *store.Mandate) bool {
+		return mandate.UserID == user.ID
+	})
+	if !ok {
+		return
+	}
+
+	mh.writeMandateWithRuns(w, mandate)
+}
+
+func (mh *MandateHandler) HandlePauseMandate(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	mandate, ok := mh.readMandate(w, r, func(mandate *store.Mandate) bool {
+		return mandate.UserID == user.ID
+	})
+	if !ok {
+		return
+	}
+
+	paused, err := mh.MandateStore.PauseMandate(mandate.ID)
+	if err != nil {
+		mh.Logger.Printf("ERROR: error pausing mandate at PauseMandate: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if paused == nil {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": "only active mandates can be paused"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"mandate": paused})
+}
+
+// HandleResumeMandate reactivates a paused mandate. Payments that fell due
+// while it was paused are skipped, not collected.
+func (mh *MandateHandler) HandleResumeMandate(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	mandate, ok := mh.readMandate(w, r, func(mandate *store.Mandate) bool {
+		return mandate.UserID == user.ID
+	})
+	if !ok {
+		return
+	}
+	if mandate.Status != store.MandateStatusPaused {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": "only paused mandates can be resumed"})
+		return
+	}
+
+	mandates.Resume(mandate, time.Now())
+	resumed, err := mh.MandateStore.ResumeMandate(mandate)
+	if err != nil {
+		mh.Logger.Printf("ERROR: error resuming mandate at ResumeMandate: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if resumed == nil {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": "only paused mandates can be resumed"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"mandate": resumed})
+}
+
+func (mh *MandateHandler) HandleCancelUserMandate(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	mh.cancelMandate(w, r, func(mandate *store.Mandate) bool {
+		return mandate.UserID == user.ID
+	})
+}
+
+// HandleGetMandates lists the mandates paying the merchant's shops,
+// filtered by the status query parameter when given.
+func (mh *MandateHandler) HandleGetMandates(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	mandateList, err := mh.MandateStore.GetMandatesByMerchantID(merchant.ID, r.URL.Query().Get("status"))
+	if err != nil {
+		mh.Logger.Printf("ERROR: error getting mandates at GetMandatesByMerchantID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	visible := []*store.Mandate{}
+	for _, mandate := range mandateList {
+		if canAccessShop(r, mandate.ShopID) {
+			visible = append(visible, mandate)
+		}
+	}
+	mandateList = visible
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"mandates": mandateList})
+}
+
+func (mh *MandateHandler) HandleGetMandate(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	mandate, ok := mh.readMandate(w, r, func(mandate *store.Mandate) bool {
+		return mandate.MerchantID == merchant.ID && canAccessShop(r, mandate.ShopID)
+	})
+	if !ok {
+		return
+	}
+
+	mh.writeMandateWithRuns(w, mandate)
+}
+
+// HandleCancelMandate lets a merchant stop collecting a mandate, for example
+// when a subscription ends early.
+func (mh *MandateHandler) HandleCancelMandate(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	mh.cancelMandate(w, r, func(mandate *store.Mandate) bool {
+		return mandate.MerchantID == merchant.ID && canAccessShop(r, mandate.ShopID)
+	})
+}
+
+func (mh *MandateHandler) cancelMandate(w http.ResponseWriter, r *http.Request, visible func(*store.Mandate) bool) {
+	mandate, ok := mh.readMandate(w, r, visible)
+	if !ok {
+		return
+	}
+
+	cancelled, err := mh.MandateStore.CancelMandate(mandate.ID)
+	if err != nil {
+		mh.Logger.Printf("ERROR: error cancelling mandate at CancelMandate: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if cancelled == nil {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": "mandate is already " + mandate.Status})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"mandate": cancelled})
+}
+
+func (mh *MandateHandler) writeMandateWithRuns(w http.ResponseWriter, mandate *store.Mandate) {
+	runs, err := mh.MandateStore.GetMandateRuns(mandate.ID)
+	if err != nil {
+		mh.Logger.Printf("ERROR: error getting mandate runs at GetMandateRuns: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"mandate": mandate, "runs": runs})
+}
+
+// readMandate loads the mandate named in the URL. Mandates the caller may
+// not see are reported as not found.
+func (mh *MandateHandler) readMandate(w http.ResponseWriter, r *http.Request, visible func(*store.Mandate) bool) (*store.Mandate, bool) {
+	mandateID, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		mh.Logger.Printf("ERROR: error reading mandate id at ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if _, err = uuid.Parse(mandateID); err != nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "mandate not found"})
+		return nil, false
+	}
+
+	mandate, err := mh.MandateStore.GetMandateByID(mandateID)
+	if err != nil {
+		mh.Logger.Printf("ERROR: error getting mandate at GetMandateByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if mandate == nil || !visible(mandate) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "mandate not found"})
+		return nil, false
+	}
+	return mandate, true
+}
+
+func (mh *MandateHandler) validateMandateRequest(req *MandateRequest) (*store.Mandate, error) {
+	if req.ShopID == "" {
+		return nil, errors.New("shop id is required")
+	}
+	if _, err := uuid.Parse(req.ShopID); err != nil {
+		return nil, errors.New("shop id is invalid")
+	}
+	if req.Amount <= 0 {
+		return nil, errors.New("amount is required")
+	}
+	amount, err := money.KES.FromMajor(req.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.IntervalUnit {
+	case store.MandateIntervalDay, store.MandateIntervalWeek, store.MandateIntervalMonth:
+	default:
+		return nil, errors.New("interval_unit must be day, week or month")
+	}
+	intervalCount := req.IntervalCount
+	if intervalCount == 0 {
+		intervalCount = 1
+	}
+	if intervalCount < 0 || intervalCount > MaxMandateIntervalCount {
+		return nil, errors.New("interval_count must be between 1 and 365")
+	}
+	if req.MaxPayments < 0 {
+		return nil, errors.New("max_payments must not be negative")
+	}
+
+	now := time.Now()
+	startsAt := now
+	if req.StartsAt != nil {
+		startsAt = *req.StartsAt
+	}
+	if startsAt.Before(now.Add(-time.Minute)) {
+		return nil, errors.New("starts_at must not be in the past")
+	}
+	if req.EndsAt != nil && !req.EndsAt.After(startsAt) {
+		return nil, errors.New("ends_at must be after starts_at")
+	}
+
+	return &store.Mandate{
+		Amount:        amount,
+		IntervalUnit:  req.IntervalUnit,
+		IntervalCount: intervalCount,
+		Reference:     req.Reference,
+		StartsAt:      startsAt,
+		EndsAt:        req.EndsAt,
+		MaxPayments:   req.MaxPayments,
+	}, nil
+}
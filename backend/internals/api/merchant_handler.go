@@ -6,11 +6,16 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"time"
 
+	"github.com/divin3circle/orcus/backend/internals/events"
+	"github.com/divin3circle/orcus/backend/internals/fees"
+	"github.com/divin3circle/orcus/backend/internals/kyc"
 	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/notifications"
 	"github.com/divin3circle/orcus/backend/internals/store"
 	"github.com/divin3circle/orcus/backend/internals/utils"
+	"github.com/divin3circle/orcus/backend/internals/webhooks"
 	"github.com/go-chi/chi/v5"
 	hiero "github.com/hiero-ledger/hiero-sdk-go/v2/sdk"
 )
@@ -30,21 +35,27 @@ type WithdrawRequest struct {
 	Receiver string `json:"receiver"`
 }
 
-type NotificationMessage struct {
-	Type string `json:"type"`
-	MessageContent string `json:"message_content"`
-	Timestamp int64 `json:"timestamp"`
-}
-
 type MerchantHandler struct{
 	MerchantStore store.MerchantStore
+	Fees *fees.Engine
+	Webhooks webhooks.Publisher
+	Events *events.Hub
+	Approvals *WithdrawalApprovalHandler
+	Audit *AuditRecorder
+	Limits *kyc.Limiter
 	Logger *log.Logger 
 	Client *hiero.Client
 }
 
-func NewMerchantHandler(merchantStore store.MerchantStore, logger *log.Logger, client *hiero.Client) *MerchantHandler {
+func NewMerchantHandler(merchantStore store.MerchantStore, feeEngine *fees.Engine, publisher webhooks.Publisher, hub *events.Hub, approvals *WithdrawalApprovalHandler, audit *AuditRecorder, limits *kyc.Limiter, logger *log.Logger, client *hiero.Client) *MerchantHandler {
 	return &MerchantHandler{
 		MerchantStore: merchantStore,
+		Fees: feeEngine,
+		Webhooks: publisher,
+		Events: hub,
+		Approvals: approvals,
+		Audit: audit,
+		Limits: limits,
 		Logger: logger,
 		Client: client,
 	}
@@ -148,15 +159,49 @@ func (mh *MerchantHandler) HandleWithdraw(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	amount, err := money.KES.FromMajor(req.Amount)
+	if err != nil {
+		mh.Logger.Printf("ERROR: error while converting withdraw amount at FromMajor, %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
 	merchant := middleware.GetMerchant(r)
-	withdrawal, err := mh.MerchantStore.Withdraw(merchant, req.Amount, req.Receiver)
+	if checkKYCLimit(w, mh.Limits, mh.Logger, merchant.ID, merchant.KYCLevel, store.KYCOperationWithdrawal, amount) != nil {
+		return
+	}
+	quote, err := mh.Fees.Quote(store.FeeTransactionWithdrawal, merchant.ID, amount)
+	if err != nil {
+		mh.Logger.Printf("ERROR: error while pricing withdrawal at Quote, %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	requiresApproval, err := mh.Approvals.Requires(merchant.ID, amount)
+	if err != nil {
+		mh.Logger.Printf("ERROR: error while checking withdrawal approval at Requires, %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if requiresApproval {
+		mh.Approvals.scheduleWithdrawal(w, r, merchant, amount, quote, req.Receiver)
+		return
+	}
+	withdrawal, err := mh.MerchantStore.Withdraw(merchant, amount, quote.Fee, quote.Rule, req.Receiver)
 	if err != nil {
 		mh.Logger.Printf("ERROR: error while withdrawing at Withdraw, %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
 		return
 	}
 
-	NotifyMerchant(w, merchant.TopicID, "withdrawal", mh.Client)
+	NotifyMerchant(mh.Logger, merchant.TopicID, notifications.TypeWithdrawal, &notifications.Payload{
+		TransactionID: withdrawal.ID,
+		Amount:        withdrawal.Amount,
+		Fee:           withdrawal.Fee,
+		Receiver:      withdrawal.Receiver,
+	}, mh.Client)
+	mh.Webhooks.Publish(merchant.ID, webhooks.EventWithdrawalCompleted, withdrawal)
+	mh.Events.PublishMerchant(merchant.ID, webhooks.EventWithdrawalCompleted, withdrawal)
+	mh.Audit.Record(r, "withdrawal.create", "withdrawal", withdrawal.ID, map[string]any{"amount": withdrawal.Amount, "fee": withdrawal.Fee, "receiver": withdrawal.Receiver})
 	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"withdrawal": withdrawal})
 }
 
@@ -211,61 +256,28 @@ func (mh *MerchantHandler) generateTopicID(w http.ResponseWriter, merchant *stor
 	return topicID.String()
 }
 
-func NotifyMerchant(w http.ResponseWriter, topicID string, messageType string, client *hiero.Client) {
+func NotifyMerchant(logger *log.Logger, topicID string, messageType string, data *notifications.Payload, client *hiero.Client) {
 	var messageContent string
 	switch messageType {
-	case "transaction":
+	case notifications.TypeTransaction:
 		messageContent = "Payment received"
-	case "airdrop":
+	case notifications.TypeAirdrop:
 		messageContent = "KSH Token airdropped successfully"
-	case "withdrawal":
+	case notifications.TypeWithdrawal:
 		messageContent = "Withdrawal completed"
-	case "shop_created":
+	case notifications.TypeWithdrawalApproval:
+		messageContent = "Withdrawal awaiting approval"
+	case notifications.TypeShopCreated:
 		messageContent = "Shop created"
-	case "campaign_created":
+	case notifications.TypeCampaignCreated:
 		messageContent = "Campaign created"
-	case "joined_campaign":
+	case notifications.TypeJoinedCampaign:
 		messageContent = "A user joined your campaign"
+	case notifications.TypeRefund:
+		messageContent = "Refund sent"
 	default:
 		messageContent = "Unknown message type"
 	}
 
-	topicIDObj, err := hiero.TopicIDFromString(topicID)
-	if err != nil {
-		fmt.Printf("ERROR: error getting topic id: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
-	}
-
-	topicMessage := NotificationMessage{
-		Type: messageType,
-		MessageContent: messageContent,
-		Timestamp: time.Now().Unix(),
-	}
-
-	marshalledMessage, err := json.Marshal(topicMessage)
-	if err != nil {
-		fmt.Printf("ERROR: error marshalling message: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
-	}
-
-	submitMessage, err := hiero.NewTopicMessageSubmitTransaction().
-		SetMessage(marshalledMessage).
-		SetTopicID(topicIDObj).
-		Execute(client)
-	if err != nil {
-		fmt.Printf("ERROR: error freezing transaction: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
-	}
-
-	receipt, err := submitMessage.GetReceipt(client)
-	if err != nil {
-		fmt.Printf("ERROR: error getting receipt response for topic message submit transaction: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
-	}
-
-	fmt.Printf("Topic message submitted successfully: %v", receipt.TopicID)
+	submitNotification(logger, topicID, messageType, messageContent, data, client)
 }
\ No newline at end of file
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/notifications"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+	hiero "github.com/hiero-ledger/hiero-sdk-go/v2/sdk"
+)
+
+type NotificationHandler struct {
+	Logger *log.Logger
+}
+
+func NewNotificationHandler(logger *log.Logger) *NotificationHandler {
+	return &NotificationHandler{Logger: logger}
+}
+
+func (nh *NotificationHandler) HandleGetSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(notifications.Schema)
+}
+
+func (nh *NotificationHandler) HandleGetMerchantNotificationKey(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	nh.writeTopicKey(w, merchant.TopicID)
+}
+
+func (nh *NotificationHandler) HandleGetUserNotificationKey(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	nh.writeTopicKey(w, user.TopicID)
+}
+
+// writeTopicKey returns the key a topic owner needs to open encrypted
+// notification payloads, or encrypted=false when encryption is off. An
+// account without a topic has no key to hand out.
+func (nh *NotificationHandler) writeTopicKey(w http.ResponseWriter, topicID string) {
+	if topicID == "" {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "account has no notification topic"})
+		return
+	}
+
+	masterKey, err := notifications.MasterKeyFromEnv()
+	if err != nil {
+		nh.Logger.Printf("ERROR: error reading notification key at MasterKeyFromEnv: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "notification encryption is misconfigured"})
+		return
+	}
+	if masterKey == nil {
+		utils.WriteJSON(w, http.StatusOK, utils.Envelope{"encrypted": false})
+		return
+	}
+
+	key := notifications.TopicKey(masterKey, topicID)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{
+		"encrypted": true,
+		"alg":       notifications.EncryptionAlgorithm,
+		"topic_id":  topicID,
+		"key":       base64.StdEncoding.EncodeToString(key),
+	})
+}
+
+// submitNotification posts a message to topicID. It runs after the action it
+// reports has already happened, so failures are only logged and never reach
+// the response.
+func submitNotification(logger *log.Logger, topicID string, messageType string, messageContent string, data *notifications.Payload, client *hiero.Client) {
+	masterKey, err := notifications.MasterKeyFromEnv()
+	if err != nil {
+		logger.Printf("ERROR: error reading notification key at MasterKeyFromEnv: %v", err)
+		return
+	}
+
+	topicMessage, err := notifications.NewMessage(messageType, messageContent, data, topicID, masterKey)
+	if err != nil {
+		logger.Printf("ERROR: error building %s notification at NewMessage: %v", messageType, err)
+		return
+	}
+
+	marshalledMessage, err := json.Marshal(topicMessage)
+	if err != nil {
+		logger.Printf("ERROR: error marshalling %s notification at Marshal: %v", messageType, err)
+		return
+	}
+
+	receipt, err := notifications.SubmitTopicMessage(client, topicID, marshalledMessage)
+	if err != nil {
+		logger.Printf("ERROR: error submitting %s notification to topic %s at SubmitTopicMessage: %v", messageType, topicID, err)
+		return
+	}
+
+	logger.Printf("Topic message submitted successfully: %v", receipt.TopicID)
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/notifications"
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+func TestUserNotificationKeyOpensMessages(t *testing.T) {
+	masterKey := make([]byte, 32)
+	for i := range masterKey {
+		masterKey[i] = byte(i)
+	}
+	t.Setenv("NOTIFICATION_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(masterKey))
+	nh := NewNotificationHandler(log.New(io.Discard, "", 0))
+	user := &store.User{ID: "user-1", Username: "wanjiru", TopicID: "0.0.4821"}
+
+	message, err := notifications.NewMessage(notifications.TypeAirdrop, "You got tokens", &notifications.Payload{CampaignID: "campaign-a", Amount: 2500}, user.TopicID, masterKey)
+	require.NoError(t, err)
+	require.NotNil(t, message.Encrypted)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/notification-key", nil)
+	rec := httptest.NewRecorder()
+	nh.HandleGetUserNotificationKey(rec, middleware.SetUser(req, user))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var body struct {
+		TopicID string `json:"topic_id"`
+		Key     string `json:"key"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, user.TopicID, body.TopicID)
+	key, err := base64.StdEncoding.DecodeString(body.Key)
+	require.NoError(t, err)
+
+	payload, err := notifications.Decrypt(key, message.Encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "campaign-a", payload.CampaignID)
+	assert.EqualValues(t, 2500, payload.Amount)
+}
+
+func TestUserNotificationKeyRequiresTopic(t *testing.T) {
+	t.Setenv("NOTIFICATION_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	nh := NewNotificationHandler(log.New(io.Discard, "", 0))
+
+	req := httptest.NewRequest(http.MethodGet, "/users/notification-key", nil)
+	rec := httptest.NewRecorder()
+	nh.HandleGetUserNotificationKey(rec, middleware.SetUser(req, &store.User{ID: "user-1"}))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestNotificationFailuresAreOnlyLogged(t *testing.T) {
+	t.Setenv("NOTIFICATION_ENCRYPTION_KEY", "not base64")
+	var logs bytes.Buffer
+
+	NotifyUser(log.New(&logs, "", 0), "0.0.4821", notifications.TypeTransaction, &notifications.Payload{Amount: 2500}, nil)
+	assert.Contains(t, logs.String(), "ERROR: error reading notification key")
+}
@@ -0,0 +1,330 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/divin3circle/orcus/backend/internals/events"
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+	"github.com/divin3circle/orcus/backend/internals/webhooks"
+)
+
+const (
+	DefaultPaymentRequestExpiry = 24 * time.Hour
+	MaxPaymentRequestExpiry     = 30 * 24 * time.Hour
+)
+
+type PaymentRequestRequest struct {
+	ShopID string `json:"shop_id"`
+	// Amount is in whole KES.
+	Amount           int64      `json:"amount"`
+	Description      string     `json:"description"`
+	CustomerUsername string     `json:"customer_username"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+}
+
+// PaymentRequestHandler lets merchants raise payment requests and users pay
+// them. Paying goes through the same path as a user-initiated payment.
+type PaymentRequestHandler struct {
+	PaymentRequestStore store.PaymentRequestStore
+	ShopStore           store.ShopStore
+	UserStore           store.UserStore
+	Payments            *TransactionHandler
+	Webhooks            webhooks.Publisher
+	Events              *events.Hub
+	Logger              *log.Logger
+}
+
+func NewPaymentRequestHandler(paymentRequestStore store.PaymentRequestStore, shopStore store.ShopStore, userStore store.UserStore, payments *TransactionHandler, publisher webhooks.Publisher, hub *events.Hub, logger *log.Logger) *PaymentRequestHandler {
+	return &PaymentRequestHandler{
+		PaymentRequestStore: paymentRequestStore,
+		ShopStore:           shopStore,
+		UserStore:           userStore,
+		Payments:            payments,
+		Webhooks:            publisher,
+		Events:              hub,
+		Logger:              logger,
+	}
+}
+
+func (ph *PaymentRequestHandler) HandleCreatePaymentRequest(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+
+	var req PaymentRequestRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ph.Logger.Printf("ERROR: error decoding payment request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	// Tills always charge for the shop they are registered to.
+	if device := middleware.GetPOSDevice(r); device != nil {
+		req.ShopID = device.ShopID
+	}
+	request, err := ph.validatePaymentRequest(&req)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	shop, err := ph.ShopStore.GetShopByID(req.ShopID)
+	if err != nil {
+		ph.Logger.Printf("ERROR: error getting shop at GetShopByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if shop == nil || shop.MerchantID != merchant.ID || !canAccessShop(r, shop.ID) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "shop not found"})
+		return
+	}
+	request.ShopID = shop.ID
+	request.MerchantID = merchant.ID
+
+	if req.CustomerUsername != "" {
+		customer, err := ph.UserStore.GetUserByUsername(req.CustomerUsername)
+		if err != nil {
+			ph.Logger.Printf("ERROR: error getting customer at GetUserByUsername: %v", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+			return
+		}
+		if customer == nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "customer not found"})
+			return
+		}
+		request.CustomerID = customer.ID
+	}
+
+	created, err := ph.PaymentRequestStore.CreatePaymentRequest(request)
+	if err != nil {
+		ph.Logger.Printf("ERROR: error creating payment request at CreatePaymentRequest: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"payment_request": created})
+}
+
+// HandleGetPaymentRequests lists the merchant's payment requests, filtered
+// by the status query parameter when given.
+func (ph *PaymentRequestHandler) HandleGetPaymentRequests(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	requests, err := ph.PaymentRequestStore.GetPaymentRequestsByMerchantID(merchant.ID, r.URL.Query().Get("status"))
+	if err != nil {
+		ph.Logger.Printf("ERROR: error getting payment requests at GetPaymentRequestsByMerchantID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	visible := []*store.PaymentRequest{}
+	for _, request := range requests {
+		if canAccessShop(r, request.ShopID) {
+			visible = append(visible, request)
+		}
+	}
+	requests = visible
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"payment_requests": requests})
+}
+
+func (ph *PaymentRequestHandler) HandleGetPaymentRequest(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	request, ok := ph.readPaymentRequest(w, r, func(request *store.PaymentRequest) bool {
+		return request.MerchantID == merchant.ID && canAccessShop(r, request.ShopID)
+	})
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"payment_request": request})
+}
+
+func (ph *PaymentRequestHandler) HandleCancelPaymentRequest(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	request, ok := ph.readPaymentRequest(w, r, func(request *store.PaymentRequest) bool {
+		return request.MerchantID == merchant.ID && canAccessShop(r, request.ShopID)
+	})
+	if !ok {
+		return
+	}
+
+	cancelled, err := ph.PaymentRequestStore.CancelPaymentRequest(request.ID)
+	if err != nil {
+		ph.Logger.Printf("ERROR: error cancelling payment request at CancelPaymentRequest: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if cancelled == nil {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": "only open payment requests can be cancelled"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"payment_request": cancelled})
+}
+
+// HandleGetUserPaymentRequests lists the open requests addressed to the
+// current user.
+func (ph *PaymentRequestHandler) HandleGetUserPaymentRequests(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	requests, err := ph.PaymentRequestStore.GetOpenPaymentRequestsByCustomerID(user.ID)
+	if err != nil {
+		ph.Logger.Printf("ERROR: error getting payment requests at GetOpenPaymentRequestsByCustomerID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"payment_requests": requests})
+}
+
+func (ph *PaymentRequestHandler) HandleGetUserPaymentRequest(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	request, ok := ph.readPaymentRequest(w, r, func(request *store.PaymentRequest) bool {
+		return request.CustomerID == "" || request.CustomerID == user.ID
+	})
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"payment_request": request})
+}
+
+// HandlePayPaymentRequest pays an open request as the current user. The
+// request is claimed first so two users cannot both pay it, and reopened if
+// the payment fails.
+func (ph *PaymentRequestHandler) HandlePayPaymentRequest(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	request, ok := ph.readPaymentRequest(w, r, func(request *store.PaymentRequest) bool {
+		return request.CustomerID == "" || request.CustomerID == user.ID
+	})
+	if !ok {
+		return
+	}
+	// A body is only sent to answer a fraud challenge.
+	var answer FraudChallengeAnswer
+	err := json.NewDecoder(r.Body).Decode(&answer)
+	if err != nil && !errors.Is(err, io.EOF) {
+		ph.Logger.Printf("ERROR: error decoding pay payment request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	shop, err := ph.ShopStore.GetShopByID(request.ShopID)
+	if err != nil {
+		ph.Logger.Printf("ERROR: error getting shop at GetShopByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if shop == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "shop not found"})
+		return
+	}
+
+	claimed, err := ph.PaymentRequestStore.ClaimPaymentRequest(request.ID)
+	if err != nil {
+		ph.Logger.Printf("ERROR: error claiming payment request at ClaimPaymentRequest: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if claimed == nil {
+		message := "payment request is " + request.Status
+		if request.Status == store.PaymentRequestStatusOpen {
+			message = "payment request is already being paid"
+		}
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": message})
+		return
+	}
+
+	payment, err := ph.Payments.pay(w, user, shop, claimed.Amount, &answer)
+	if errors.Is(err, errPaymentNotRecorded) {
+		// Reopening the request would let it be paid twice, so it stays
+		// processing until the payment is reconciled.
+		ph.Logger.Printf("WARNING: payment request %s left processing for reconciliation: %v", claimed.ID, err)
+		return
+	}
+	if err != nil {
+		err = ph.PaymentRequestStore.ReleasePaymentRequest(claimed.ID)
+		if err != nil {
+			ph.Logger.Printf("ERROR: error releasing payment request %s at ReleasePaymentRequest: %v", claimed.ID, err)
+		}
+		return
+	}
+
+	paid, err := ph.PaymentRequestStore.MarkPaymentRequestPaid(claimed.ID, payment.TransactionID)
+	if err != nil || paid == nil {
+		ph.Logger.Printf("ERROR: error marking payment request %s paid by transaction %s at MarkPaymentRequestPaid: %v", claimed.ID, payment.TransactionID, err)
+		utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"data": payment, "payment_request": claimed})
+		return
+	}
+	ph.Webhooks.Publish(paid.MerchantID, webhooks.EventPaymentRequestPaid, paid)
+	ph.Events.PublishMerchant(paid.MerchantID, webhooks.EventPaymentRequestPaid, paid)
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"data": payment, "payment_request": paid})
+}
+
+// readPaymentRequest loads the request named in the URL. Requests the caller
+// may not see are reported as not found.
+func (ph *PaymentRequestHandler) readPaymentRequest(w http.ResponseWriter, r *http.Request, visible func(*store.PaymentRequest) bool) (*store.PaymentRequest, bool) {
+	requestID, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		ph.Logger.Printf("ERROR: error reading payment request id at ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if _, err = uuid.Parse(requestID); err != nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "payment request not found"})
+		return nil, false
+	}
+
+	request, err := ph.PaymentRequestStore.GetPaymentRequestByID(requestID)
+	if err != nil {
+		ph.Logger.Printf("ERROR: error getting payment request at GetPaymentRequestByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if request == nil || !visible(request) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "payment request not found"})
+		return nil, false
+	}
+	return request, true
+}
+
+func (ph *PaymentRequestHandler) validatePaymentRequest(req *PaymentRequestRequest) (*store.PaymentRequest, error) {
+	if req.ShopID == "" {
+		return nil, errors.New("shop id is required")
+	}
+	if _, err := uuid.Parse(req.ShopID); err != nil {
+		return nil, errors.New("shop id is invalid")
+	}
+	if req.Amount <= 0 {
+		return nil, errors.New("amount is required")
+	}
+	amount, err := money.KES.FromMajor(req.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(DefaultPaymentRequestExpiry)
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+	}
+	if !expiresAt.After(now) {
+		return nil, errors.New("expires_at must be in the future")
+	}
+	if expiresAt.Sub(now) > MaxPaymentRequestExpiry {
+		return nil, errors.New("expires_at must be within 30 days")
+	}
+
+	return &store.PaymentRequest{
+		Amount:      amount,
+		Description: req.Description,
+		ExpiresAt:   expiresAt,
+	}, nil
+}
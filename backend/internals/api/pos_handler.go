@@ -0,0 +1,241 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/pos"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+	"github.com/google/uuid"
+)
+
+type RegisterPOSDeviceRequest struct {
+	Name string `json:"name"`
+}
+
+type POSHandler struct {
+	DeviceStore store.POSDeviceStore
+	ShopStore   store.ShopStore
+	Audit       *AuditRecorder
+	Logger      *log.Logger
+}
+
+func NewPOSHandler(deviceStore store.POSDeviceStore, shopStore store.ShopStore, audit *AuditRecorder, logger *log.Logger) *POSHandler {
+	return &POSHandler{DeviceStore: deviceStore, ShopStore: shopStore, Audit: audit, Logger: logger}
+}
+
+// HandleRegisterDevice registers a till for the shop in the URL. The device
+// token is only shown in this response.
+func (ph *POSHandler) HandleRegisterDevice(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	shop, ok := ph.readMerchantShop(w, r)
+	if !ok {
+		return
+	}
+
+	var req RegisterPOSDeviceRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ph.Logger.Printf("ERROR: error decoding register device request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "name must be between 1 and 100 characters long"})
+		return
+	}
+
+	device := &store.POSDevice{MerchantID: merchant.ID, ShopID: shop.ID, Name: req.Name}
+	if member := middleware.GetMember(r); member != nil {
+		device.RegisteredBy = member.ID
+	}
+	created, token, err := ph.DeviceStore.CreatePOSDevice(device)
+	if err != nil {
+		ph.Logger.Printf("ERROR: error registering device at CreatePOSDevice: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	ph.Audit.Record(r, "pos_device.register", "pos_device", created.ID, map[string]string{"name": created.Name, "shop_id": created.ShopID})
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"device": created, "device_token": token})
+}
+
+func (ph *POSHandler) HandleGetDevices(w http.ResponseWriter, r *http.Request) {
+	shop, ok := ph.readMerchantShop(w, r)
+	if !ok {
+		return
+	}
+
+	devices, err := ph.DeviceStore.GetPOSDevicesByShopID(shop.ID)
+	if err != nil {
+		ph.Logger.Printf("ERROR: error getting devices at GetPOSDevicesByShopID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"devices": devices})
+}
+
+func (ph *POSHandler) HandleRevokeDevice(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	deviceID, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		ph.Logger.Printf("ERROR: error reading device id at ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if _, err = uuid.Parse(deviceID); err != nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "device not found"})
+		return
+	}
+
+	device, err := ph.DeviceStore.GetPOSDeviceByID(deviceID)
+	if err != nil {
+		ph.Logger.Printf("ERROR: error getting device at GetPOSDeviceByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if device == nil || device.MerchantID != merchant.ID || !canAccessShop(r, device.ShopID) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "device not found"})
+		return
+	}
+
+	revoked, err := ph.DeviceStore.RevokePOSDevice(device.ID, merchant.ID)
+	if err != nil {
+		ph.Logger.Printf("ERROR: error revoking device at RevokePOSDevice: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if revoked == nil {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": "device is already revoked"})
+		return
+	}
+
+	ph.Audit.Record(r, "pos_device.revoke", "pos_device", revoked.ID, nil)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"device": revoked})
+}
+
+// HandleGetShopZReport returns the end-of-day summary of the shop in the
+// URL for ?date=YYYY-MM-DD, today by default.
+func (ph *POSHandler) HandleGetShopZReport(w http.ResponseWriter, r *http.Request) {
+	shop, ok := ph.readMerchantShop(w, r)
+	if !ok {
+		return
+	}
+
+	ph.writeZReport(w, shop, r.URL.Query().Get("date"))
+}
+
+// HandleGetSession tells a till which shop it serves.
+func (ph *POSHandler) HandleGetSession(w http.ResponseWriter, r *http.Request) {
+	device := middleware.GetPOSDevice(r)
+	shop, ok := ph.readDeviceShop(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"device": device, "shop": shop})
+}
+
+// HandleGetTodayTransactions lists the payments taken at the device's shop
+// so far today.
+func (ph *POSHandler) HandleGetTodayTransactions(w http.ResponseWriter, r *http.Request) {
+	device := middleware.GetPOSDevice(r)
+	from, to, err := pos.BusinessDay("", time.Now())
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	transactions, _, err := ph.DeviceStore.GetShopActivity(device.ShopID, from, to)
+	if err != nil {
+		ph.Logger.Printf("ERROR: error getting shop activity at GetShopActivity: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"transactions": transactions, "from": from, "to": to})
+}
+
+// HandleGetDeviceZReport returns today's summary for the device's shop.
+// Earlier days are only available to the merchant.
+func (ph *POSHandler) HandleGetDeviceZReport(w http.ResponseWriter, r *http.Request) {
+	shop, ok := ph.readDeviceShop(w, r)
+	if !ok {
+		return
+	}
+
+	ph.writeZReport(w, shop, "")
+}
+
+func (ph *POSHandler) writeZReport(w http.ResponseWriter, shop *store.Shop, date string) {
+	now := time.Now()
+	from, to, err := pos.BusinessDay(date, now)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	transactions, refunds, err := ph.DeviceStore.GetShopActivity(shop.ID, from, to)
+	if err != nil {
+		ph.Logger.Printf("ERROR: error getting shop activity at GetShopActivity: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	report, err := pos.BuildZReport(shop, from, to, transactions, refunds, now)
+	if err != nil {
+		ph.Logger.Printf("ERROR: error building z-report at BuildZReport: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"z_report": report})
+}
+
+func (ph *POSHandler) readMerchantShop(w http.ResponseWriter, r *http.Request) (*store.Shop, bool) {
+	merchant := middleware.GetMerchant(r)
+	shopID, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		ph.Logger.Printf("ERROR: error reading shop id at ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if _, err = uuid.Parse(shopID); err != nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "shop not found"})
+		return nil, false
+	}
+
+	shop, err := ph.ShopStore.GetShopByID(shopID)
+	if err != nil {
+		ph.Logger.Printf("ERROR: error getting shop at GetShopByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if shop == nil || shop.MerchantID != merchant.ID || !canAccessShop(r, shop.ID) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "shop not found"})
+		return nil, false
+	}
+	return shop, true
+}
+
+func (ph *POSHandler) readDeviceShop(w http.ResponseWriter, r *http.Request) (*store.Shop, bool) {
+	device := middleware.GetPOSDevice(r)
+	shop, err := ph.ShopStore.GetShopByID(device.ShopID)
+	if err != nil {
+		ph.Logger.Printf("ERROR: error getting shop at GetShopByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if shop == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "shop not found"})
+		return nil, false
+	}
+	return shop, true
+}
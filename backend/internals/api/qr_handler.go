@@ -0,0 +1,239 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/qrpay"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+)
+
+const (
+	DefaultQRExpiry = 15 * time.Minute
+	MaxQRExpiry     = 24 * time.Hour
+)
+
+type DynamicQRRequest struct {
+	// Amount is in whole KES.
+	Amount    int64  `json:"amount"`
+	Reference string `json:"reference"`
+	// ExpiresIn is in seconds. Zero uses DefaultQRExpiry.
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+type ResolveQRRequest struct {
+	Payload string `json:"payload"`
+}
+
+// QRCode is the JSON form of a generated code. Content is the exact string
+// to encode; the other fields repeat what it carries.
+type QRCode struct {
+	Content string `json:"payload"`
+	*qrpay.Payload
+}
+
+type QRHandler struct {
+	ShopStore  store.ShopStore
+	SigningKey []byte
+	Logger     *log.Logger
+}
+
+func NewQRHandler(shopStore store.ShopStore, signingKey []byte, logger *log.Logger) *QRHandler {
+	return &QRHandler{ShopStore: shopStore, SigningKey: signingKey, Logger: logger}
+}
+
+// HandleGetShopQR returns the shop's static code, which identifies the shop
+// and leaves the amount to the user. The format query parameter selects
+// json (default), png or svg.
+func (qh *QRHandler) HandleGetShopQR(w http.ResponseWriter, r *http.Request) {
+	shop, ok := qh.readMerchantShop(w, r)
+	if !ok {
+		return
+	}
+
+	qh.writeQR(w, r, &qrpay.Payload{PaymentID: shop.PaymentID})
+}
+
+// HandleCreateShopQR returns a signed dynamic code for a fixed amount, in
+// the same formats as HandleGetShopQR.
+func (qh *QRHandler) HandleCreateShopQR(w http.ResponseWriter, r *http.Request) {
+	shop, ok := qh.readMerchantShop(w, r)
+	if !ok {
+		return
+	}
+
+	var req DynamicQRRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		qh.Logger.Printf("ERROR: error decoding dynamic qr request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if req.Amount <= 0 {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "amount is required"})
+		return
+	}
+	amount, err := money.KES.FromMajor(req.Amount)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	expiresIn := DefaultQRExpiry
+	if req.ExpiresIn != 0 {
+		expiresIn = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if expiresIn <= 0 || expiresIn > MaxQRExpiry {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "expires_in must be between 1 second and 24 hours"})
+		return
+	}
+
+	qh.writeQR(w, r, &qrpay.Payload{
+		PaymentID: shop.PaymentID,
+		Amount:    amount,
+		Reference: req.Reference,
+		ExpiresAt: time.Now().Add(expiresIn).Truncate(time.Second).UTC(),
+	})
+}
+
+// HandleResolvePaymentID looks up the shop behind a static code so the user
+// app can pay it by shop id.
+func (qh *QRHandler) HandleResolvePaymentID(w http.ResponseWriter, r *http.Request) {
+	paymentID := chi.URLParam(r, "payment_id")
+	if paymentID == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "payment id is required"})
+		return
+	}
+
+	shop, ok := qh.resolveShop(w, paymentID)
+	if !ok {
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"shop": shop})
+}
+
+// HandleResolvePayload checks a scanned payload and returns its shop along
+// with the amount and reference of dynamic codes.
+func (qh *QRHandler) HandleResolvePayload(w http.ResponseWriter, r *http.Request) {
+	var req ResolveQRRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		qh.Logger.Printf("ERROR: error decoding resolve qr request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	payload, err := qrpay.Decode(req.Payload, qh.SigningKey, time.Now())
+	if errors.Is(err, qrpay.ErrExpired) {
+		utils.WriteJSON(w, http.StatusGone, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	shop, ok := qh.resolveShop(w, payload.PaymentID)
+	if !ok {
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"shop": shop, "payment": payload})
+}
+
+func (qh *QRHandler) resolveShop(w http.ResponseWriter, paymentID string) (*store.Shop, bool) {
+	shop, err := qh.ShopStore.GetShopByPaymentID(paymentID)
+	if err != nil {
+		qh.Logger.Printf("ERROR: error resolving payment id at GetShopByPaymentID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if shop == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "shop not found"})
+		return nil, false
+	}
+	return shop, true
+}
+
+func (qh *QRHandler) readMerchantShop(w http.ResponseWriter, r *http.Request) (*store.Shop, bool) {
+	merchant := middleware.GetMerchant(r)
+	shopID, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		qh.Logger.Printf("ERROR: error reading shop id at ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if _, err = uuid.Parse(shopID); err != nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "shop not found"})
+		return nil, false
+	}
+
+	shop, err := qh.ShopStore.GetShopByID(shopID)
+	if err != nil {
+		qh.Logger.Printf("ERROR: error getting shop at GetShopByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if shop == nil || shop.MerchantID != merchant.ID || !canAccessShop(r, shop.ID) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "shop not found"})
+		return nil, false
+	}
+	return shop, true
+}
+
+// writeQR encodes payload and writes it in the format the request asks for.
+// PNG size can be set with the size query parameter, up to 2048 pixels.
+func (qh *QRHandler) writeQR(w http.ResponseWriter, r *http.Request, payload *qrpay.Payload) {
+	encoded, err := qrpay.Encode(payload, qh.SigningKey)
+	if errors.Is(err, qrpay.ErrNoKey) {
+		utils.WriteJSON(w, http.StatusServiceUnavailable, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	var image []byte
+	var contentType string
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		utils.WriteJSON(w, http.StatusOK, utils.Envelope{"qr": &QRCode{Content: encoded, Payload: payload}})
+		return
+	case "png":
+		size := qrpay.DefaultSize
+		if raw := r.URL.Query().Get("size"); raw != "" {
+			size, err = strconv.Atoi(raw)
+			if err != nil || size < 64 || size > 2048 {
+				utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "size must be between 64 and 2048"})
+				return
+			}
+		}
+		image, err = qrpay.PNG(encoded, size)
+		contentType = "image/png"
+	case "svg":
+		image, err = qrpay.SVG(encoded)
+		contentType = "image/svg+xml"
+	default:
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "format must be json, png or svg"})
+		return
+	}
+	if err != nil {
+		qh.Logger.Printf("ERROR: error rendering qr code: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(image)
+}
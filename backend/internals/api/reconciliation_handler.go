@@ -0,0 +1,55 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+)
+
+type ReconciliationHandler struct {
+	ReconciliationStore store.ReconciliationStore
+	Logger              *log.Logger
+}
+
+func NewReconciliationHandler(reconciliationStore store.ReconciliationStore, logger *log.Logger) *ReconciliationHandler {
+	return &ReconciliationHandler{ReconciliationStore: reconciliationStore, Logger: logger}
+}
+
+// HandleGetReconciliationReport lists the merchant's reconciliation issues
+// with a count per kind. ?status= filters by open (default), resolved or all.
+func (rh *ReconciliationHandler) HandleGetReconciliationReport(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = store.ReconciliationIssueOpen
+	case "all":
+		status = ""
+	case store.ReconciliationIssueOpen, store.ReconciliationIssueResolved:
+	default:
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "status must be open, resolved or all"})
+		return
+	}
+
+	merchant := middleware.GetMerchant(r)
+	issues, err := rh.ReconciliationStore.GetReconciliationIssuesByMerchantID(merchant.ID, status)
+	if err != nil {
+		rh.Logger.Printf("ERROR: error getting reconciliation issues at GetReconciliationIssuesByMerchantID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	summary := map[string]int{
+		store.ReconciliationMissingOnLedger: 0,
+		store.ReconciliationFailedOnLedger:  0,
+		store.ReconciliationAmountMismatch:  0,
+		store.ReconciliationOrphanTransfer:  0,
+	}
+	for _, issue := range issues {
+		summary[issue.Kind]++
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"summary": summary, "issues": issues})
+}
@@ -0,0 +1,435 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	hiero "github.com/hiero-ledger/hiero-sdk-go/v2/sdk"
+
+	"github.com/divin3circle/orcus/backend/internals/balances"
+	"github.com/divin3circle/orcus/backend/internals/events"
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/notifications"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+	"github.com/divin3circle/orcus/backend/internals/webhooks"
+)
+
+// RefundSigningWindow is how long the merchant has to sign and submit a
+// prepared refund transfer. Hedera rejects transactions older than their
+// valid duration, which is at most three minutes.
+const RefundSigningWindow = 2 * time.Minute
+
+// RefundMaxTransactionFee is the most the operator will pay the network to
+// execute a refund transfer.
+var RefundMaxTransactionFee = hiero.NewHbar(1)
+
+type RefundRequest struct {
+	// Amount is in whole KES. Zero refunds everything not yet refunded.
+	Amount int64  `json:"amount"`
+	Reason string `json:"reason"`
+}
+
+type SubmitRefundRequest struct {
+	// SignedTransaction is the prepared transfer, base64 encoded, after the
+	// merchant has signed it with their account key.
+	SignedTransaction string `json:"signed_transaction"`
+}
+
+// PreparedRefund is returned when a refund is created. Transaction is the
+// frozen transfer from the merchant to the user, base64 encoded, ready to be
+// signed with the merchant's key.
+type PreparedRefund struct {
+	Refund      *store.Refund `json:"refund"`
+	Transaction string        `json:"transaction"`
+}
+
+// RefundHandler refunds payments. The platform does not hold merchant keys,
+// so a refund is prepared here, signed by the merchant and then submitted
+// back to be checked and executed with the operator paying the network fee.
+type RefundHandler struct {
+	RefundStore      store.RefundStore
+	TransactionStore store.TransactionStore
+	UserStore        store.UserStore
+	ShopStore        store.ShopStore
+	Webhooks         webhooks.Publisher
+	Events           *events.Hub
+	Balances         *balances.Cache
+	Logger           *log.Logger
+	Client           *hiero.Client
+}
+
+func NewRefundHandler(refundStore store.RefundStore, transactionStore store.TransactionStore, userStore store.UserStore, shopStore store.ShopStore, publisher webhooks.Publisher, hub *events.Hub, balanceCache *balances.Cache, logger *log.Logger, client *hiero.Client) *RefundHandler {
+	return &RefundHandler{
+		RefundStore:      refundStore,
+		TransactionStore: transactionStore,
+		UserStore:        userStore,
+		ShopStore:        shopStore,
+		Webhooks:         publisher,
+		Events:           hub,
+		Balances:         balanceCache,
+		Logger:           logger,
+		Client:           client,
+	}
+}
+
+// HandleCreateRefund reserves the amount against the payment and returns the
+// transfer the merchant has to sign.
+func (rh *RefundHandler) HandleCreateRefund(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	transaction, ok := rh.readMerchantTransaction(w, r, merchant)
+	if !ok {
+		return
+	}
+
+	var req RefundRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		rh.Logger.Printf("ERROR: error decoding refund request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if req.Amount < 0 {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "amount must not be negative"})
+		return
+	}
+	amount, err := money.KES.FromMajor(req.Amount)
+	if err != nil {
+		rh.Logger.Printf("ERROR: error converting refund amount at FromMajor: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user, err := rh.UserStore.GetUserByID(transaction.UserID)
+	if err != nil {
+		rh.Logger.Printf("ERROR: error getting refunded user at GetUserByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	tokenID, merchantAccountID, userAccountID, err := refundAccounts(merchant, user)
+	if err != nil {
+		rh.Logger.Printf("ERROR: error parsing refund accounts at refundAccounts: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	operatorAccountID, err := hiero.AccountIDFromString(os.Getenv("OPERATOR_ACCOUNT_ID"))
+	if err != nil {
+		rh.Logger.Printf("ERROR: error parsing operator account id at AccountIDFromString: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	hederaTransactionID := hiero.TransactionIDGenerate(operatorAccountID)
+	refund, err := rh.RefundStore.CreateRefund(&store.Refund{
+		TransactionID:       transaction.ID,
+		MerchantID:          merchant.ID,
+		UserID:              transaction.UserID,
+		Amount:              amount,
+		Reason:              req.Reason,
+		HederaTransactionID: hederaTransactionID.String(),
+		ExpiresAt:           time.Now().Add(RefundSigningWindow),
+	})
+	if errors.Is(err, store.ErrPaymentNotRefundable) || errors.Is(err, store.ErrRefundExceedsPayment) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		rh.Logger.Printf("ERROR: error creating refund at CreateRefund: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	transfer, err := hiero.NewTransferTransaction().
+		SetTransactionID(hederaTransactionID).
+		SetTransactionValidDuration(RefundSigningWindow).
+		SetMaxTransactionFee(RefundMaxTransactionFee).
+		SetTransactionMemo(refundMemo(refund)).
+		AddTokenTransfer(tokenID, merchantAccountID, -int64(refund.Amount)).
+		AddTokenTransfer(tokenID, userAccountID, int64(refund.Amount)).
+		FreezeWith(rh.Client)
+	if err != nil {
+		rh.Logger.Printf("ERROR: error freezing refund transfer at FreezeWith: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	transferBytes, err := transfer.ToBytes()
+	if err != nil {
+		rh.Logger.Printf("ERROR: error serializing refund transfer at ToBytes: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"data": &PreparedRefund{
+		Refund:      refund,
+		Transaction: base64.StdEncoding.EncodeToString(transferBytes),
+	}})
+}
+
+// HandleSubmitRefund executes a refund transfer the merchant has signed. The
+// transfer must be the one prepared for the refund: same transaction id and
+// exactly the refund amount moving from the merchant to the user.
+func (rh *RefundHandler) HandleSubmitRefund(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	transaction, ok := rh.readMerchantTransaction(w, r, merchant)
+	if !ok {
+		return
+	}
+
+	refundID, err := utils.ReadIDParam(r, "refund_id")
+	if err != nil {
+		rh.Logger.Printf("ERROR: error reading refund id at ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if _, err = uuid.Parse(refundID); err != nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "refund not found"})
+		return
+	}
+	refund, err := rh.RefundStore.GetRefundByID(refundID)
+	if err != nil {
+		rh.Logger.Printf("ERROR: error getting refund at GetRefundByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if refund == nil || refund.TransactionID != transaction.ID {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "refund not found"})
+		return
+	}
+	if refund.Status != store.RefundStatusPending {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": "refund has already been submitted"})
+		return
+	}
+	if refund.IsExpired(time.Now()) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": "refund signing window has passed, create a new refund"})
+		return
+	}
+
+	var req SubmitRefundRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		rh.Logger.Printf("ERROR: error decoding submit refund request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	transferBytes, err := base64.StdEncoding.DecodeString(req.SignedTransaction)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "signed_transaction must be base64 encoded"})
+		return
+	}
+	parsed, err := hiero.TransactionFromBytes(transferBytes)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	transfer, ok := parsed.(*hiero.TransferTransaction)
+	if !ok {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "signed_transaction is not a transfer"})
+		return
+	}
+
+	user, err := rh.UserStore.GetUserByID(refund.UserID)
+	if err != nil {
+		rh.Logger.Printf("ERROR: error getting refunded user at GetUserByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	tokenID, merchantAccountID, userAccountID, err := refundAccounts(merchant, user)
+	if err != nil {
+		rh.Logger.Printf("ERROR: error parsing refund accounts at refundAccounts: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	err = verifyRefundTransfer(transfer, refund, tokenID, merchantAccountID, userAccountID, rh.Client.GetNetwork())
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	response, err := transfer.Execute(rh.Client)
+	if err != nil {
+		rh.Logger.Printf("ERROR: error executing refund transfer at Execute: %v", err)
+		utils.WriteJSON(w, http.StatusBadGateway, utils.Envelope{"error": err.Error()})
+		return
+	}
+	rh.Balances.Invalidate(merchant.AccountID, user.AccountID)
+
+	// The refund only completes once the network confirms the transfer. Any
+	// other outcome fails it, which frees the amount for a new refund.
+	receipt, err := response.GetReceipt(rh.Client)
+	if err != nil || receipt.Status != hiero.StatusSuccess {
+		rh.Logger.Printf("WARNING: refund %s transfer %s did not succeed, status %s: %v", refund.ID, response.TransactionID.String(), receipt.Status.String(), err)
+		_, failErr := rh.RefundStore.FailRefund(refund.ID)
+		if failErr != nil {
+			rh.Logger.Printf("ERROR: error failing refund %s at FailRefund: %v", refund.ID, failErr)
+		}
+		utils.WriteJSON(w, http.StatusBadGateway, utils.Envelope{"error": "refund transfer failed with status " + receipt.Status.String()})
+		return
+	}
+
+	refund, transaction, err = rh.RefundStore.CompleteRefund(refund.ID)
+	if err != nil {
+		rh.Logger.Printf("ERROR: error completing refund %s executed as %s at CompleteRefund: %v", refundID, response.TransactionID.String(), err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if refund == nil {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": "refund has already been submitted"})
+		return
+	}
+
+	shop, err := rh.ShopStore.GetShopByID(transaction.ShopID)
+	if err != nil {
+		rh.Logger.Printf("ERROR: error getting refunded shop at GetShopByID: %v", err)
+	}
+	shopName := ""
+	if shop != nil {
+		shopName = shop.Name
+	}
+	NotifyMerchant(rh.Logger, merchant.TopicID, notifications.TypeRefund, &notifications.Payload{
+		TransactionID:       transaction.ID,
+		HederaTransactionID: refund.HederaTransactionID,
+		Amount:              refund.Amount,
+		ShopID:              transaction.ShopID,
+		ShopName:            shopName,
+		CounterpartID:       user.ID,
+		CounterpartName:     user.Username,
+	}, rh.Client)
+	NotifyUser(rh.Logger, user.TopicID, notifications.TypeRefund, &notifications.Payload{
+		TransactionID:       transaction.ID,
+		HederaTransactionID: refund.HederaTransactionID,
+		Amount:              refund.Amount,
+		ShopID:              transaction.ShopID,
+		ShopName:            shopName,
+		CounterpartID:       merchant.ID,
+		CounterpartName:     merchant.Username,
+	}, rh.Client)
+	rh.Webhooks.Publish(merchant.ID, webhooks.EventPaymentRefunded, refund)
+	rh.Events.PublishMerchant(merchant.ID, webhooks.EventPaymentRefunded, refund)
+	rh.Events.PublishUser(user.ID, webhooks.EventPaymentRefunded, refund)
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"refund": refund, "transaction": transaction})
+}
+
+func (rh *RefundHandler) HandleGetRefunds(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	transaction, ok := rh.readMerchantTransaction(w, r, merchant)
+	if !ok {
+		return
+	}
+
+	refunds, err := rh.RefundStore.GetRefundsByTransactionID(transaction.ID)
+	if err != nil {
+		rh.Logger.Printf("ERROR: error getting refunds at GetRefundsByTransactionID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"refunds": refunds})
+}
+
+// readMerchantTransaction loads the payment named in the URL. Payments of
+// other merchants, or of shops a team member is not assigned to, are
+// reported as not found.
+func (rh *RefundHandler) readMerchantTransaction(w http.ResponseWriter, r *http.Request, merchant *store.Merchant) (*store.Transaction, bool) {
+	transactionID, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		rh.Logger.Printf("ERROR: error reading transaction id at ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if _, err = uuid.Parse(transactionID); err != nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "transaction not found"})
+		return nil, false
+	}
+
+	transaction, err := rh.TransactionStore.GetTransactionByID(transactionID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && (transaction.MerchantID != merchant.ID || !canAccessShop(r, transaction.ShopID))) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "transaction not found"})
+		return nil, false
+	}
+	if err != nil {
+		rh.Logger.Printf("ERROR: error getting transaction at GetTransactionByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	return transaction, true
+}
+
+func refundAccounts(merchant *store.Merchant, user *store.User) (hiero.TokenID, hiero.AccountID, hiero.AccountID, error) {
+	if user == nil {
+		return hiero.TokenID{}, hiero.AccountID{}, hiero.AccountID{}, errors.New("refunded user no longer exists")
+	}
+	tokenID, err := hiero.TokenIDFromString(os.Getenv("KSH_TOKEN_ID"))
+	if err != nil {
+		return hiero.TokenID{}, hiero.AccountID{}, hiero.AccountID{}, err
+	}
+	merchantAccountID, err := hiero.AccountIDFromString(merchant.AccountID)
+	if err != nil {
+		return hiero.TokenID{}, hiero.AccountID{}, hiero.AccountID{}, err
+	}
+	userAccountID, err := hiero.AccountIDFromString(user.AccountID)
+	if err != nil {
+		return hiero.TokenID{}, hiero.AccountID{}, hiero.AccountID{}, err
+	}
+	return tokenID, merchantAccountID, userAccountID, nil
+}
+
+func refundMemo(refund *store.Refund) string {
+	return "refund " + refund.ID
+}
+
+// verifyRefundTransfer rejects a submitted transfer that differs from the one
+// prepared for refund. The operator pays the network fee of whatever is
+// executed, so nothing beyond the prepared token movement may be included,
+// and the fee cap, memo and nodes must be the ones prepared. network is the
+// client's node list, which the prepared nodes were picked from.
+func verifyRefundTransfer(transfer *hiero.TransferTransaction, refund *store.Refund, tokenID hiero.TokenID, merchantAccountID hiero.AccountID, userAccountID hiero.AccountID, network map[string]hiero.AccountID) error {
+	if transfer.GetTransactionID().String() != refund.HederaTransactionID {
+		return errors.New("signed transaction is not the prepared refund transfer")
+	}
+	if transfer.GetMaxTransactionFee().AsTinybar() != RefundMaxTransactionFee.AsTinybar() || transfer.GetTransactionMemo() != refundMemo(refund) {
+		return errors.New("signed transaction is not the prepared refund transfer")
+	}
+	nodes := map[string]bool{}
+	for _, node := range network {
+		nodes[node.String()] = true
+	}
+	nodeIDs := transfer.GetNodeAccountIDs()
+	if len(nodeIDs) == 0 {
+		return errors.New("signed transaction is not the prepared refund transfer")
+	}
+	for _, node := range nodeIDs {
+		if !nodes[node.String()] {
+			return errors.New("signed transaction is not the prepared refund transfer")
+		}
+	}
+	if len(transfer.GetHbarTransfers()) != 0 || len(transfer.GetNftTransfers()) != 0 {
+		return errors.New("signed transaction moves more than the refund")
+	}
+
+	tokenTransfers := transfer.GetTokenTransfers()
+	if len(tokenTransfers) != 1 || len(tokenTransfers[tokenID]) != 2 {
+		return errors.New("signed transaction moves more than the refund")
+	}
+	expected := map[string]int64{
+		merchantAccountID.String(): -int64(refund.Amount),
+		userAccountID.String():     int64(refund.Amount),
+	}
+	for _, tokenTransfer := range tokenTransfers[tokenID] {
+		amount, ok := expected[tokenTransfer.AccountID.String()]
+		if !ok || amount != tokenTransfer.Amount {
+			return fmt.Errorf("signed transaction does not transfer %s from the merchant to the user", money.KES.Format(refund.Amount))
+		}
+		delete(expected, tokenTransfer.AccountID.String())
+	}
+	return nil
+}
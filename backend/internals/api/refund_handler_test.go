@@ -0,0 +1,54 @@
+package api
+
+import (
+	"testing"
+
+	hiero "github.com/hiero-ledger/hiero-sdk-go/v2/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+func TestVerifyRefundTransfer(t *testing.T) {
+	client := hiero.ClientForNetwork(map[string]hiero.AccountID{"127.0.0.1:50211": {Account: 3}, "127.0.0.1:50212": {Account: 4}})
+	tokenID := hiero.TokenID{Token: 5001}
+	merchantAccountID := hiero.AccountID{Account: 1001}
+	userAccountID := hiero.AccountID{Account: 1002}
+	transactionID := hiero.TransactionIDGenerate(hiero.AccountID{Account: 2})
+	refund := &store.Refund{ID: "refund-1", Amount: 150000, HederaTransactionID: transactionID.String()}
+
+	prepare := func(edit func(*hiero.TransferTransaction)) *hiero.TransferTransaction {
+		transfer := hiero.NewTransferTransaction().
+			SetTransactionID(transactionID).
+			SetTransactionValidDuration(RefundSigningWindow).
+			SetMaxTransactionFee(RefundMaxTransactionFee).
+			SetTransactionMemo(refundMemo(refund)).
+			AddTokenTransfer(tokenID, merchantAccountID, -int64(refund.Amount)).
+			AddTokenTransfer(tokenID, userAccountID, int64(refund.Amount))
+		if edit != nil {
+			edit(transfer)
+		}
+		frozen, err := transfer.FreezeWith(client)
+		require.NoError(t, err)
+		return frozen
+	}
+	verify := func(transfer *hiero.TransferTransaction) error {
+		return verifyRefundTransfer(transfer, refund, tokenID, merchantAccountID, userAccountID, client.GetNetwork())
+	}
+
+	assert.NoError(t, verify(prepare(nil)))
+
+	for name, edit := range map[string]func(*hiero.TransferTransaction){
+		"higher fee cap": func(transfer *hiero.TransferTransaction) { transfer.SetMaxTransactionFee(hiero.NewHbar(100)) },
+		"other memo":     func(transfer *hiero.TransferTransaction) { transfer.SetTransactionMemo("gift") },
+		"unknown node": func(transfer *hiero.TransferTransaction) {
+			transfer.SetNodeAccountIDs([]hiero.AccountID{{Account: 999}})
+		},
+		"extra transfer": func(transfer *hiero.TransferTransaction) {
+			transfer.AddHbarTransfer(merchantAccountID, hiero.NewHbar(-1)).AddHbarTransfer(userAccountID, hiero.NewHbar(1))
+		},
+	} {
+		assert.Error(t, verify(prepare(edit)), name)
+	}
+}
@@ -9,21 +9,28 @@ import (
 	"os"
 	"strings"
 
+	"github.com/divin3circle/orcus/backend/internals/events"
 	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/notifications"
 	"github.com/divin3circle/orcus/backend/internals/store"
 	"github.com/divin3circle/orcus/backend/internals/utils"
+	"github.com/divin3circle/orcus/backend/internals/webhooks"
 	hiero "github.com/hiero-ledger/hiero-sdk-go/v2/sdk"
 )
 
 type ShopHandler struct{
 	ShopStore store.ShopStore
 	UserStore store.UserStore
+	Webhooks webhooks.Publisher
+	Events *events.Hub
+	Audit *AuditRecorder
 	Logger *log.Logger
 	Client *hiero.Client
 }
 
-func NewShopHandler(shopStore store.ShopStore, userStore store.UserStore, logger *log.Logger, client *hiero.Client) *ShopHandler {
-	return &ShopHandler{ShopStore: shopStore, UserStore: userStore, Logger: logger, Client: client}
+func NewShopHandler(shopStore store.ShopStore, userStore store.UserStore, publisher webhooks.Publisher, hub *events.Hub, audit *AuditRecorder, logger *log.Logger, client *hiero.Client) *ShopHandler {
+	return &ShopHandler{ShopStore: shopStore, UserStore: userStore, Webhooks: publisher, Events: hub, Audit: audit, Logger: logger, Client: client}
 }
 
 func (sh *ShopHandler) HandlerGetShopByID(w http.ResponseWriter, r *http.Request) {
@@ -35,11 +42,32 @@ func (sh *ShopHandler) HandlerGetShopByID(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	shop, ok := ownedShop(w, r, sh.ShopStore, sh.Logger, shopID)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"shop": shop})
+}
+
+// HandlerGetUserShopByID shows any shop to a logged in user, such as one
+// they are about to pay.
+func (sh *ShopHandler) HandlerGetUserShopByID(w http.ResponseWriter, r *http.Request) {
+	shopID, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		sh.Logger.Printf("ERROR: error getting shop by id ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
 	shop, err := sh.ShopStore.GetShopByID(shopID)
 	if err != nil {
 		sh.Logger.Printf("ERROR: error getting shop by id GetShopByID: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if shop == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "shop not found"})
 		return
 	}
 
@@ -73,7 +101,12 @@ func (sh *ShopHandler) HandlerCreateShop(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	NotifyMerchant(w, cm.TopicID, "shop_created", sh.Client)
+	NotifyMerchant(sh.Logger, cm.TopicID, notifications.TypeShopCreated, &notifications.Payload{
+		ShopID:   createdShop.ID,
+		ShopName: createdShop.Name,
+	}, sh.Client)
+	sh.Webhooks.Publish(cm.ID, webhooks.EventShopCreated, createdShop)
+	sh.Audit.Record(r, "shop.create", "shop", createdShop.ID, map[string]string{"name": createdShop.Name})
 
 	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"shop": createdShop})
 }
@@ -100,16 +133,8 @@ func (sh *ShopHandler) HandlerUpdateShop(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	existingShop, err := sh.ShopStore.GetShopByID(shopID)
-	if err != nil {
-		sh.Logger.Printf("ERROR: error getting existing shop by id GetShopByID: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
-	}
-
-	if existingShop == nil {
-		sh.Logger.Printf("ERROR: error getting existing shop by id GetShopByID, existing shop is nil: %v", err)
-		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "shop not found"})
+	existingShop, ok := ownedShop(w, r, sh.ShopStore, sh.Logger, shopID)
+	if !ok {
 		return
 	}
 
@@ -130,6 +155,11 @@ func (sh *ShopHandler) HandlerUpdateShop(w http.ResponseWriter, r *http.Request)
 		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
 		return
 	}
+
+	if member := middleware.GetMember(r); member != nil && len(updateShopRequest.Campaigns) > 0 && !member.Can(store.PermissionCampaignsManage) {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "your role does not allow " + store.PermissionCampaignsManage})
+		return
+	}
 	
 	if updateShopRequest.Name != nil {
 		existingShop.Name = *updateShopRequest.Name
@@ -147,7 +177,7 @@ func (sh *ShopHandler) HandlerUpdateShop(w http.ResponseWriter, r *http.Request)
 			transaction, _ := hiero.NewTokenCreateTransaction().
 				SetTokenName(campaignCreationRequest.Name).
 				SetTokenSymbol(tokenSymbol).
-				SetDecimals(2).
+				SetDecimals(uint(money.CampaignToken.Decimals)).
 				SetInitialSupply(uint64(campaignCreationRequest.Target)).
 				SetSupplyType(hiero.TokenSupplyTypeFinite).
 				SetMaxSupply(campaignCreationRequest.Target).
@@ -203,8 +233,24 @@ func (sh *ShopHandler) HandlerUpdateShop(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	updateShopResponse.Shop = existingShop
+	sh.Audit.Record(r, "shop.update", "shop", existingShop.ID, map[string]any{"name": existingShop.Name, "profile_image_url": existingShop.ProfileImageUrl, "campaigns_added": len(updateShopRequest.Campaigns)})
+	for _, campaign := range updateShopRequest.Campaigns {
+		sh.Audit.Record(r, "campaign.create", "shop", existingShop.ID, map[string]any{"name": campaign.Name, "token_id": campaign.TokenID, "target": campaign.Target})
+	}
 
-	NotifyMerchant(w, cm.TopicID, "campaign_created", sh.Client)
+	for _, campaign := range updateShopRequest.Campaigns {
+		NotifyMerchant(sh.Logger, cm.TopicID, notifications.TypeCampaignCreated, &notifications.Payload{
+			ShopID:       existingShop.ID,
+			ShopName:     existingShop.Name,
+			CampaignID:   campaign.ID,
+			CampaignName: campaign.Name,
+			TokenID:      campaign.TokenID,
+		}, sh.Client)
+	}
+	if len(updateShopRequest.Campaigns) > 0 {
+		sh.Webhooks.Publish(cm.ID, webhooks.EventCampaignCreated, updateShopRequest.Campaigns)
+		sh.Events.PublishMerchant(cm.ID, webhooks.EventCampaignCreated, updateShopRequest.Campaigns)
+	}
 
 	_ = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"response": updateShopResponse})
 }
@@ -216,6 +262,9 @@ func (sh *ShopHandler) HandlerGetShopCampaigns(w http.ResponseWriter, r *http.Re
 		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
 		return
 	}
+	if _, ok := ownedShop(w, r, sh.ShopStore, sh.Logger, shopID); !ok {
+		return
+	}
 
 	campaigns, err := sh.ShopStore.GetShopCampaigns(shopID)
 	if err != nil {
@@ -234,6 +283,10 @@ func (sh *ShopHandler) HandlerGetShopsByMerchantID(w http.ResponseWriter, r *htt
 		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
 		return
 	}
+	if merchantID != middleware.GetMerchant(r).ID {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "forbidden"})
+		return
+	}
 
 	shops, err := sh.ShopStore.GetShopsByMerchantID(merchantID)
 	if err != nil {
@@ -241,6 +294,13 @@ func (sh *ShopHandler) HandlerGetShopsByMerchantID(w http.ResponseWriter, r *htt
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
 		return
 	}
+	visible := []*store.Shop{}
+	for _, shop := range shops {
+		if canAccessShop(r, shop.ID) {
+			visible = append(visible, shop)
+		}
+	}
+	shops = visible
 	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"shops": shops})
 }
 
@@ -251,7 +311,9 @@ func (sh *ShopHandler) HandlerGetUserCampaignsEntryByShopID(w http.ResponseWrite
 		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
 		return
 	}
-
+	if _, ok := ownedShop(w, r, sh.ShopStore, sh.Logger, shopID); !ok {
+		return
+	}
 
 	campaigns, err := sh.ShopStore.GetUserCampaignEntryByShopID(shopID)
 	if err != nil {
@@ -269,7 +331,27 @@ func (sh *ShopHandler) HandlerGetShopCampaignsByShopID(w http.ResponseWriter, r
 		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
 		return
 	}
+	if _, ok := ownedShop(w, r, sh.ShopStore, sh.Logger, shopID); !ok {
+		return
+	}
+
+	sh.writeShopCampaigns(w, shopID)
+}
+
+// HandlerGetUserShopCampaignsByShopID lists any shop's campaigns to a logged
+// in user.
+func (sh *ShopHandler) HandlerGetUserShopCampaignsByShopID(w http.ResponseWriter, r *http.Request) {
+	shopID, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		sh.Logger.Printf("ERROR: error getting shop by id ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
 
+	sh.writeShopCampaigns(w, shopID)
+}
+
+func (sh *ShopHandler) writeShopCampaigns(w http.ResponseWriter, shopID string) {
 	campaigns, err := sh.ShopStore.GetShopCampaignsByShopID(shopID)
 	if err != nil {
 		sh.Logger.Printf("ERROR: error getting shop campaigns by shop id GetShopCampaignsByShopID: %v", err)
@@ -320,12 +402,16 @@ func (sh *ShopHandler) HandlerEndCampaign(w http.ResponseWriter, r *http.Request
         utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
         return
     }
+    if _, ok := sh.ownedCampaign(w, r, campaignID); !ok {
+        return
+    }
     err = sh.ShopStore.EndCampaign(campaignID)
     if err != nil {
         sh.Logger.Printf("ERROR: error ending campaign EndCampaign: %v", err)
         utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
         return
     }
+    sh.Audit.Record(r, "campaign.end", "campaign", campaignID, nil)
     utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "Campaign ended successfully"})
 
 	// TODO: Can be improved by use of message queue
@@ -342,7 +428,10 @@ func (sh *ShopHandler) HandlerEndCampaign(w http.ResponseWriter, r *http.Request
                 sh.Logger.Printf("ERROR: error getting user by id: %v", err)
                 continue
             }
-            NotifyUser(w, user.TopicID, "airdrop", sh.Client)
+            NotifyUser(sh.Logger, user.TopicID, notifications.TypeAirdrop, &notifications.Payload{
+                Amount:     participant.TokenBalance,
+                CampaignID: campaignID,
+            }, sh.Client)
 			sh.Logger.Printf("INFO: notifying user: %s", user.TopicID)
         }
     }()
@@ -352,7 +441,7 @@ func (sh *ShopHandler) HandlerEndCampaign(w http.ResponseWriter, r *http.Request
 func (sh *ShopHandler) HandleGetCampaignParticipants(w http.ResponseWriter, r *http.Request) {
 	var detailedParticipants []struct {
 	AccountID string `json:"account_id"`
-	TokenBalance int64 `json:"token_balance"`
+	TokenBalance money.Amount `json:"token_balance"`
 	UserID string `json:"user_id"`
 	UserTopicID string `json:"user_topic_id"`
 	}
@@ -364,10 +453,8 @@ func (sh *ShopHandler) HandleGetCampaignParticipants(w http.ResponseWriter, r *h
 		return
 	}
 	
-	campaign, err := sh.ShopStore.GetShopCampaignByCampaignID(campaignID)
-	if err != nil {
-		sh.Logger.Printf("ERROR: error getting shop campaign by campaign id GetShopCampaignByCampaignID: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+	campaign, ok := sh.ownedCampaign(w, r, campaignID)
+	if !ok {
 		return
 	}
 	
@@ -387,7 +474,7 @@ func (sh *ShopHandler) HandleGetCampaignParticipants(w http.ResponseWriter, r *h
 		}
 		detailedParticipant := struct {
 			AccountID string `json:"account_id"`
-			TokenBalance int64 `json:"token_balance"`
+			TokenBalance money.Amount `json:"token_balance"`
 			UserID string `json:"user_id"`
 			UserTopicID string `json:"user_topic_id"`
 		}{
@@ -402,6 +489,25 @@ func (sh *ShopHandler) HandleGetCampaignParticipants(w http.ResponseWriter, r *h
 	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"participants": detailedParticipants, "campaign": campaign})
 }
 
+// ownedCampaign loads campaignID for a merchant route, reporting campaigns
+// in shops the caller cannot reach as not found.
+func (sh *ShopHandler) ownedCampaign(w http.ResponseWriter, r *http.Request, campaignID string) (*store.CampaignEntry, bool) {
+	campaign, err := sh.ShopStore.GetShopCampaignByCampaignID(campaignID)
+	if err != nil {
+		sh.Logger.Printf("ERROR: error getting campaign GetShopCampaignByCampaignID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if campaign == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "campaign not found"})
+		return nil, false
+	}
+	if _, ok := ownedShop(w, r, sh.ShopStore, sh.Logger, campaign.ShopID); !ok {
+		return nil, false
+	}
+	return campaign, true
+}
+
 func generateTokenSymbol(name string) string {
 	if name == "" {
 		return "TKN"
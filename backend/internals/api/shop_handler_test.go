@@ -0,0 +1,174 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	hiero "github.com/hiero-ledger/hiero-sdk-go/v2/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+var (
+	ownerMerchant = &store.Merchant{ID: "merchant-a", Username: "duka"}
+	otherMerchant = &store.Merchant{ID: "merchant-b", Username: "kibanda"}
+)
+
+type fakeShopStore struct {
+	store.ShopStore
+	shops     map[string]*store.Shop
+	campaigns map[string]*store.CampaignEntry
+	updated   []*store.Shop
+	ended     []string
+}
+
+func newFakeShopStore() *fakeShopStore {
+	return &fakeShopStore{
+		shops:     map[string]*store.Shop{"shop-a": {ID: "shop-a", Name: "Duka", MerchantID: ownerMerchant.ID}},
+		campaigns: map[string]*store.CampaignEntry{"campaign-a": {ID: "campaign-a", Name: "Loyalty", ShopID: "shop-a"}},
+	}
+}
+
+func (fs *fakeShopStore) GetShopByID(id string) (*store.Shop, error) {
+	shop, ok := fs.shops[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *shop
+	return &copied, nil
+}
+
+func (fs *fakeShopStore) GetShopOwner(id string) (string, error) {
+	return fs.shops[id].MerchantID, nil
+}
+
+func (fs *fakeShopStore) UpdateShop(shop *store.Shop) error {
+	fs.updated = append(fs.updated, shop)
+	return nil
+}
+
+func (fs *fakeShopStore) GetShopsByMerchantID(merchantID string) ([]*store.Shop, error) {
+	shops := []*store.Shop{}
+	for _, shop := range fs.shops {
+		if shop.MerchantID == merchantID {
+			shops = append(shops, shop)
+		}
+	}
+	return shops, nil
+}
+
+func (fs *fakeShopStore) GetShopCampaignByCampaignID(campaignID string) (*store.CampaignEntry, error) {
+	return fs.campaigns[campaignID], nil
+}
+
+func (fs *fakeShopStore) GetShopCampaignsByShopID(shopID string) ([]*store.CampaignEntry, error) {
+	return []*store.CampaignEntry{}, nil
+}
+
+func (fs *fakeShopStore) GetCampaignParticipants(campaignID string) ([]*store.UserCampaignEntry, error) {
+	return []*store.UserCampaignEntry{}, nil
+}
+
+func (fs *fakeShopStore) EndCampaign(campaignID string) error {
+	fs.ended = append(fs.ended, campaignID)
+	return nil
+}
+
+type fakeTransactionStore struct {
+	store.TransactionStore
+}
+
+func (fs *fakeTransactionStore) GetTransactionsByShopID(shopID string) ([]*store.Transaction, error) {
+	return []*store.Transaction{{ID: "txn-1", ShopID: shopID, MerchantID: ownerMerchant.ID}}, nil
+}
+
+func (fs *fakeTransactionStore) GetTransactionsByMerchantID(merchantID string) ([]*store.Transaction, error) {
+	return []*store.Transaction{{ID: "txn-1", ShopID: "shop-a", MerchantID: merchantID}}, nil
+}
+
+// serveAs routes a request for pattern to handler as merchant, the way the
+// merchant route group would after authentication.
+func serveAs(merchant *store.Merchant, method string, pattern string, target string, body string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	router := chi.NewRouter()
+	router.Method(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w, middleware.SetMerchant(r, merchant))
+	}))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
+
+func TestShopRoutesRejectOtherMerchants(t *testing.T) {
+	key, err := hiero.PrivateKeyGenerateEd25519()
+	require.NoError(t, err)
+	t.Setenv("OPERATOR_ACCOUNT_ID", "0.0.2")
+	t.Setenv("OPERATOR_KEY", key.String())
+
+	logger := log.New(io.Discard, "", 0)
+	shopStore := newFakeShopStore()
+	sh := NewShopHandler(shopStore, nil, nil, nil, nil, logger, nil)
+	th := &TransactionHandler{TransactionStore: &fakeTransactionStore{}, ShopStore: shopStore, Logger: logger}
+
+	routes := []struct {
+		name    string
+		method  string
+		pattern string
+		target  string
+		body    string
+		handler http.HandlerFunc
+		denied  int
+	}{
+		{"get shop", http.MethodGet, "/shops/{id}", "/shops/shop-a", "", sh.HandlerGetShopByID, http.StatusNotFound},
+		{"update shop", http.MethodPut, "/shops/{id}", "/shops/shop-a", `{"name":"Mine now"}`, sh.HandlerUpdateShop, http.StatusNotFound},
+		{"shop campaigns", http.MethodGet, "/shops/campaigns/{id}", "/shops/campaigns/shop-a", "", sh.HandlerGetShopCampaignsByShopID, http.StatusNotFound},
+		{"campaign participants", http.MethodGet, "/shops/campaigns/participants/{id}", "/shops/campaigns/participants/campaign-a", "", sh.HandleGetCampaignParticipants, http.StatusNotFound},
+		{"end campaign", http.MethodPost, "/shops/campaigns/end/{id}", "/shops/campaigns/end/campaign-a", "", sh.HandlerEndCampaign, http.StatusNotFound},
+		{"shops by merchant", http.MethodGet, "/shops/merchant/{id}", "/shops/merchant/merchant-a", "", sh.HandlerGetShopsByMerchantID, http.StatusForbidden},
+		{"transactions by shop", http.MethodGet, "/transactions/shop/{id}", "/transactions/shop/shop-a", "", th.HandleGetTransactionsByShopID, http.StatusNotFound},
+		{"transactions by merchant", http.MethodGet, "/transactions/merchant/{id}", "/transactions/merchant/merchant-a", "", th.HandleGetTransactionsByMerchantID, http.StatusForbidden},
+	}
+
+	for _, route := range routes {
+		t.Run(route.name, func(t *testing.T) {
+			rec := serveAs(otherMerchant, route.method, route.pattern, route.target, route.body, route.handler)
+			assert.Equal(t, route.denied, rec.Code, rec.Body.String())
+		})
+	}
+	assert.Empty(t, shopStore.updated, "another merchant's shop was updated")
+	assert.Empty(t, shopStore.ended, "another merchant's campaign was ended")
+
+	for _, route := range routes {
+		if route.method != http.MethodGet {
+			continue
+		}
+		t.Run(route.name+" as owner", func(t *testing.T) {
+			rec := serveAs(ownerMerchant, route.method, route.pattern, route.target, route.body, route.handler)
+			assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestShopRoutesLimitMembersToTheirShops(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	shopStore := newFakeShopStore()
+	shopStore.shops["shop-b"] = &store.Shop{ID: "shop-b", Name: "Annex", MerchantID: ownerMerchant.ID}
+	sh := NewShopHandler(shopStore, nil, nil, nil, nil, logger, nil)
+
+	member := &store.MerchantMember{ID: "member-1", MerchantID: ownerMerchant.ID, Role: store.MemberRoleCashier, ShopIDs: []string{"shop-b"}}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		sh.HandlerGetShopByID(w, middleware.SetMember(r, member))
+	}
+
+	rec := serveAs(ownerMerchant, http.MethodGet, "/shops/{id}", "/shops/shop-a", "", handler)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = serveAs(ownerMerchant, http.MethodGet, "/shops/{id}", "/shops/shop-b", "", handler)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,375 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/sms"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+	"github.com/google/uuid"
+)
+
+const InvitationTTL = 7 * 24 * time.Hour
+
+type InviteMemberRequest struct {
+	MobileNumber string   `json:"mobile_number"`
+	Role         string   `json:"role"`
+	ShopIDs      []string `json:"shop_ids"`
+}
+
+type UpdateMemberRequest struct {
+	Role    string   `json:"role"`
+	ShopIDs []string `json:"shop_ids"`
+}
+
+type AcceptInvitationRequest struct {
+	Token    string `json:"token"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type TeamHandler struct {
+	MemberStore store.MerchantMemberStore
+	Sender      sms.Sender
+	Audit       *AuditRecorder
+	Logger      *log.Logger
+}
+
+func NewTeamHandler(memberStore store.MerchantMemberStore, sender sms.Sender, audit *AuditRecorder, logger *log.Logger) *TeamHandler {
+	return &TeamHandler{MemberStore: memberStore, Sender: sender, Audit: audit, Logger: logger}
+}
+
+// HandleGetCurrentMember reports the caller's role and permissions. The
+// merchant's own login is reported as the owner with no member record.
+func (th *TeamHandler) HandleGetCurrentMember(w http.ResponseWriter, r *http.Request) {
+	member := middleware.GetMember(r)
+	if member == nil {
+		utils.WriteJSON(w, http.StatusOK, utils.Envelope{
+			"member":      nil,
+			"role":        store.MemberRoleOwner,
+			"permissions": store.RolePermissions(store.MemberRoleOwner),
+		})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{
+		"member":      member,
+		"role":        member.Role,
+		"permissions": store.RolePermissions(member.Role),
+	})
+}
+
+func (th *TeamHandler) HandleGetMembers(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	members, err := th.MemberStore.GetMembersByMerchantID(merchant.ID)
+	if err != nil {
+		th.Logger.Printf("ERROR: error getting members at GetMembersByMerchantID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"members": members})
+}
+
+// HandleCreateInvitation adds an invited member and texts them a one-time
+// code to set their username and password. The code is also returned once
+// so it can be passed on if the SMS does not arrive.
+func (th *TeamHandler) HandleCreateInvitation(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+
+	var req InviteMemberRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		th.Logger.Printf("ERROR: error decoding invitation request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if req.MobileNumber == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "mobile number is required"})
+		return
+	}
+	if len(req.MobileNumber) != 13 {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "mobile number must be 13 digits"})
+		return
+	}
+	shopIDs, err := validateMemberAssignment(req.Role, req.ShopIDs)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if !canAssign(middleware.GetMember(r), req.Role, shopIDs) {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "your role cannot invite members with this role or shops"})
+		return
+	}
+
+	member := &store.MerchantMember{
+		MerchantID:   merchant.ID,
+		MobileNumber: req.MobileNumber,
+		Role:         req.Role,
+		ShopIDs:      shopIDs,
+	}
+	if inviter := middleware.GetMember(r); inviter != nil {
+		member.InvitedBy = inviter.ID
+	}
+
+	created, token, err := th.MemberStore.CreateInvitation(member, InvitationTTL)
+	if errors.Is(err, store.ErrMemberShopNotFound) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		th.Logger.Printf("ERROR: error creating invitation at CreateInvitation: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = th.Sender.Send(created.MobileNumber, invitationMessage(merchant.Username, created.Role, token.Plaintext))
+	if err != nil {
+		th.Logger.Printf("ERROR: error sending invitation sms at Send: %v", err)
+	}
+
+	th.Audit.Record(r, "member.invite", "member", created.ID, map[string]any{"role": created.Role, "shop_ids": created.ShopIDs})
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"member": created, "invitation_token": token})
+}
+
+// HandleAcceptInvitation activates an invited member with the username and
+// password they chose.
+func (th *TeamHandler) HandleAcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	var req AcceptInvitationRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		th.Logger.Printf("ERROR: error decoding accept invitation request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if req.Token == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "token is required"})
+		return
+	}
+	if len(req.Username) < 3 || len(req.Username) > 50 {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "username must be between 3 and 50 characters long"})
+		return
+	}
+	if len(req.Password) < 6 {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "password must be at least 6 characters long"})
+		return
+	}
+
+	member, err := th.MemberStore.AcceptInvitation(req.Token, req.Username, req.Password)
+	if errors.Is(err, store.ErrInvitationNotFound) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, store.ErrMemberUsernameTaken) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		th.Logger.Printf("ERROR: error accepting invitation at AcceptInvitation: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	th.Audit.RecordEntry(r, AuditEntry{
+		Action:     "member.accept",
+		TargetType: "member",
+		TargetID:   member.ID,
+		ActorType:  store.AuditActorMember,
+		ActorID:    member.ID,
+		MerchantID: member.MerchantID,
+	})
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"member": member})
+}
+
+func (th *TeamHandler) HandleUpdateMember(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	existing, ok := th.readManagedMember(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateMemberRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		th.Logger.Printf("ERROR: error decoding update member request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	shopIDs, err := validateMemberAssignment(req.Role, req.ShopIDs)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if !canAssign(middleware.GetMember(r), req.Role, shopIDs) {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "your role cannot assign this role or shops"})
+		return
+	}
+
+	existing.MerchantID = merchant.ID
+	existing.Role = req.Role
+	existing.ShopIDs = shopIDs
+	updated, err := th.MemberStore.UpdateMember(existing)
+	if errors.Is(err, store.ErrMemberShopNotFound) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		th.Logger.Printf("ERROR: error updating member at UpdateMember: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if updated == nil {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": "disabled members cannot be changed"})
+		return
+	}
+
+	th.Audit.Record(r, "member.update", "member", updated.ID, map[string]any{"role": updated.Role, "shop_ids": updated.ShopIDs})
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"member": updated})
+}
+
+// HandleDisableMember blocks the member from signing in and ends their
+// sessions. Members are kept so their history stays attributable.
+func (th *TeamHandler) HandleDisableMember(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	existing, ok := th.readManagedMember(w, r)
+	if !ok {
+		return
+	}
+
+	disabled, err := th.MemberStore.DisableMember(existing.ID, merchant.ID)
+	if err != nil {
+		th.Logger.Printf("ERROR: error disabling member at DisableMember: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if disabled == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "member not found"})
+		return
+	}
+
+	th.Audit.Record(r, "member.disable", "member", disabled.ID, nil)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"member": disabled})
+}
+
+// readManagedMember loads the member named in the path if the caller may
+// change them. Members cannot change themselves, and only the owner may
+// change another owner or manager.
+func (th *TeamHandler) readManagedMember(w http.ResponseWriter, r *http.Request) (*store.MerchantMember, bool) {
+	merchant := middleware.GetMerchant(r)
+	memberID, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		th.Logger.Printf("ERROR: error reading member id at ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if _, err = uuid.Parse(memberID); err != nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "member not found"})
+		return nil, false
+	}
+
+	member, err := th.MemberStore.GetMemberByID(memberID)
+	if err != nil {
+		th.Logger.Printf("ERROR: error getting member at GetMemberByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if member == nil || member.MerchantID != merchant.ID {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "member not found"})
+		return nil, false
+	}
+
+	caller := middleware.GetMember(r)
+	if caller != nil && caller.ID == member.ID {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "you cannot change your own membership"})
+		return nil, false
+	}
+	if !canAssign(caller, member.Role, member.ShopIDs) {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "your role cannot change this member"})
+		return nil, false
+	}
+	return member, true
+}
+
+// validateMemberAssignment checks role and returns shopIDs without
+// duplicates. Cashiers always work at named shops.
+func validateMemberAssignment(role string, shopIDs []string) ([]string, error) {
+	if !store.IsMemberRole(role) {
+		return nil, fmt.Errorf("role must be one of %v", store.MemberRoles)
+	}
+
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, id := range shopIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, store.ErrMemberShopNotFound
+		}
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if role == store.MemberRoleCashier && len(unique) == 0 {
+		return nil, errors.New("cashiers must be assigned at least one shop")
+	}
+	return unique, nil
+}
+
+// canAssign reports whether caller may give a member role at shopIDs. The
+// owner may assign anything; others may only assign cashiers and
+// accountants within their own shops.
+func canAssign(caller *store.MerchantMember, role string, shopIDs []string) bool {
+	if caller == nil || caller.Role == store.MemberRoleOwner {
+		return true
+	}
+	if role == store.MemberRoleOwner || role == store.MemberRoleManager {
+		return false
+	}
+	if len(caller.ShopIDs) == 0 {
+		return true
+	}
+	if len(shopIDs) == 0 {
+		return false
+	}
+	for _, id := range shopIDs {
+		if !caller.CanAccessShop(id) {
+			return false
+		}
+	}
+	return true
+}
+
+// canAccessShop reports whether the caller may act on shopID. Team members
+// can be limited to some shops and POS devices to the one they serve.
+func canAccessShop(r *http.Request, shopID string) bool {
+	if device := middleware.GetPOSDevice(r); device != nil {
+		return device.ShopID == shopID
+	}
+	member := middleware.GetMember(r)
+	return member == nil || member.CanAccessShop(shopID)
+}
+
+// ownedShop loads shopID for a merchant route. Shops of other merchants are
+// reported as not found, as are shops the caller's role cannot reach.
+func ownedShop(w http.ResponseWriter, r *http.Request, shopStore store.ShopStore, logger *log.Logger, shopID string) (*store.Shop, bool) {
+	shop, err := shopStore.GetShopByID(shopID)
+	if err != nil {
+		logger.Printf("ERROR: error getting shop at GetShopByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if shop == nil || shop.MerchantID != middleware.GetMerchant(r).ID || !canAccessShop(r, shop.ID) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "shop not found"})
+		return nil, false
+	}
+	return shop, true
+}
+
+func invitationMessage(merchantName string, role string, code string) string {
+	return fmt.Sprintf("You have been invited to join %s on Orcus as %s. Use code %s to set up your account. It expires in 7 days.", merchantName, role, code)
+}
@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/ratelimit"
 	"github.com/divin3circle/orcus/backend/internals/store"
 	"github.com/divin3circle/orcus/backend/internals/tokens"
 	"github.com/divin3circle/orcus/backend/internals/utils"
@@ -21,11 +24,15 @@ type TokenHandler struct {
 	UserTokenStore store.UserTokenStore
 	MerchantStore store.MerchantStore
 	UserStore store.UserStore
+	MemberStore store.MerchantMemberStore
+	AdminStore store.AdminStore
+	Audit *AuditRecorder
+	Lockout ratelimit.Lockout
 	Logger *log.Logger
 }
 
-func NewTokenHandler(tokenStore store.TokenStore, merchantStore store.MerchantStore, userStore store.UserStore, userTokenStore store.UserTokenStore, logger *log.Logger) *TokenHandler {
-	return &TokenHandler{TokenStore: tokenStore, UserTokenStore: userTokenStore, MerchantStore: merchantStore, UserStore: userStore, Logger: logger}
+func NewTokenHandler(tokenStore store.TokenStore, merchantStore store.MerchantStore, userStore store.UserStore, userTokenStore store.UserTokenStore, memberStore store.MerchantMemberStore, adminStore store.AdminStore, audit *AuditRecorder, lockout ratelimit.Lockout, logger *log.Logger) *TokenHandler {
+	return &TokenHandler{TokenStore: tokenStore, UserTokenStore: userTokenStore, MerchantStore: merchantStore, UserStore: userStore, MemberStore: memberStore, AdminStore: adminStore, Audit: audit, Lockout: lockout, Logger: logger}
 }
 
 func (th *TokenHandler) HandleCreateToken(w http.ResponseWriter, r *http.Request) {
@@ -38,13 +45,26 @@ func (th *TokenHandler) HandleCreateToken(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	lockoutKey := "merchant:" + strings.ToLower(req.Username)
+	if th.isLockedOut(w, lockoutKey) {
+		return
+	}
+
 	merchant, err := th.MerchantStore.GetMerchantByUsername(req.Username)
-	if err != nil || merchant == nil {
+	if err != nil {
 		th.Logger.Printf("ERROR: error getting merchant by username in GetMerchantByUsername: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
 		return
 	}
 
+	if merchant == nil {
+		th.Logger.Printf("ERROR: error getting merchant by username in GetMerchantByUsername: %v", req.Username)
+		th.recordLoginFailure(lockoutKey)
+		th.auditLogin(r, store.AuditActorMerchant, "", "", req.Username, store.AuditOutcomeFailure)
+		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid username or password"})
+		return
+	}
+
 	ok, err := merchant.PasswordHash.Matches(req.Password)
 	if err != nil {
 		th.Logger.Printf("ERROR: error matching password at Matches: %v", err)
@@ -53,9 +73,17 @@ func (th *TokenHandler) HandleCreateToken(w http.ResponseWriter, r *http.Request
 	}
 	if !ok {
 		th.Logger.Printf("ERROR: error matching password at Matches: %v", err)
+		th.recordLoginFailure(lockoutKey)
+		th.auditLogin(r, store.AuditActorMerchant, merchant.ID, merchant.ID, req.Username, store.AuditOutcomeFailure)
 		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid username or password"})
 		return
 	}
+	th.resetLoginFailures(lockoutKey)
+
+	if merchant.IsSuspended() {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "merchant account is suspended"})
+		return
+	}
 
 	token, err := th.TokenStore.Create(merchant.ID, 24*time.Hour, tokens.ScopeAuthentication)
 	if err != nil {
@@ -64,6 +92,7 @@ func (th *TokenHandler) HandleCreateToken(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	th.auditLogin(r, store.AuditActorMerchant, merchant.ID, merchant.ID, req.Username, store.AuditOutcomeSuccess)
 	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"token": token, "merchant_id": merchant.ID})
 }
 
@@ -77,6 +106,11 @@ func (th *TokenHandler) HandleCreateUserToken(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	lockoutKey := "user:" + strings.ToLower(req.Username)
+	if th.isLockedOut(w, lockoutKey) {
+		return
+	}
+
 	user, err := th.UserStore.GetUserByUsername(req.Username)
 	if err != nil{
 		th.Logger.Printf("ERROR: error getting user by username in GetUserByUsername: %v", err)
@@ -86,6 +120,8 @@ func (th *TokenHandler) HandleCreateUserToken(w http.ResponseWriter, r *http.Req
 
 	if user == nil {
 		th.Logger.Printf("ERROR: error getting user by username in GetUserByUsername: %v", req.Username)
+		th.recordLoginFailure(lockoutKey)
+		th.auditLogin(r, store.AuditActorUser, "", "", req.Username, store.AuditOutcomeFailure)
 		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid username or password"})
 		return
 	}
@@ -98,9 +134,17 @@ func (th *TokenHandler) HandleCreateUserToken(w http.ResponseWriter, r *http.Req
 	}
 	if !ok {
 		th.Logger.Printf("ERROR: error matching password at Matches: %v", err)
+		th.recordLoginFailure(lockoutKey)
+		th.auditLogin(r, store.AuditActorUser, user.ID, "", req.Username, store.AuditOutcomeFailure)
 		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid username or password"})
 		return
 	}
+	th.resetLoginFailures(lockoutKey)
+
+	if user.IsSuspended() {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "account is suspended"})
+		return
+	}
 
 	token, err := th.UserTokenStore.Create(user.ID, 24*time.Hour, tokens.ScopeAuthentication)
 	if err != nil {
@@ -109,6 +153,181 @@ func (th *TokenHandler) HandleCreateUserToken(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	th.auditLogin(r, store.AuditActorUser, user.ID, "", req.Username, store.AuditOutcomeSuccess)
 	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"token": token, "user_id": user.ID})
 }
 
+// HandleCreateMemberToken signs in a merchant team member. The token acts
+// for the member's merchant, limited by the member's role and shops.
+func (th *TokenHandler) HandleCreateMemberToken(w http.ResponseWriter, r *http.Request) {
+	var req CreateTokenRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		th.Logger.Printf("ERROR: error decoding create token request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	lockoutKey := "member:" + strings.ToLower(req.Username)
+	if th.isLockedOut(w, lockoutKey) {
+		return
+	}
+
+	member, err := th.MemberStore.GetMemberByUsername(req.Username)
+	if err != nil {
+		th.Logger.Printf("ERROR: error getting member by username in GetMemberByUsername: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if member == nil || member.Status != store.MemberStatusActive {
+		th.Logger.Printf("ERROR: error getting active member by username in GetMemberByUsername: %v", req.Username)
+		th.recordLoginFailure(lockoutKey)
+		th.auditLogin(r, store.AuditActorMember, "", "", req.Username, store.AuditOutcomeFailure)
+		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid username or password"})
+		return
+	}
+
+	ok, err := member.PasswordHash.Matches(req.Password)
+	if err != nil {
+		th.Logger.Printf("ERROR: error matching password at Matches: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if !ok {
+		th.recordLoginFailure(lockoutKey)
+		th.auditLogin(r, store.AuditActorMember, member.ID, member.MerchantID, req.Username, store.AuditOutcomeFailure)
+		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid username or password"})
+		return
+	}
+	th.resetLoginFailures(lockoutKey)
+
+	merchant, err := th.MerchantStore.GetMerchantByID(member.MerchantID)
+	if err != nil {
+		th.Logger.Printf("ERROR: error getting merchant at GetMerchantByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if merchant == nil || merchant.IsSuspended() {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "merchant account is suspended"})
+		return
+	}
+
+	token, err := th.MemberStore.CreateMemberToken(member, 24*time.Hour, tokens.ScopeAuthentication)
+	if err != nil {
+		th.Logger.Printf("ERROR: error creating token at CreateMemberToken: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	th.auditLogin(r, store.AuditActorMember, member.ID, member.MerchantID, req.Username, store.AuditOutcomeSuccess)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"token": token, "merchant_id": member.MerchantID, "member": member})
+}
+
+// HandleCreateAdminToken signs in a platform admin. Admin sessions are
+// shorter than merchant and user sessions.
+func (th *TokenHandler) HandleCreateAdminToken(w http.ResponseWriter, r *http.Request) {
+	var req CreateTokenRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		th.Logger.Printf("ERROR: error decoding create token request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	lockoutKey := "admin:" + strings.ToLower(req.Username)
+	if th.isLockedOut(w, lockoutKey) {
+		return
+	}
+
+	admin, err := th.AdminStore.GetAdminByUsername(req.Username)
+	if err != nil {
+		th.Logger.Printf("ERROR: error getting admin by username in GetAdminByUsername: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if admin == nil || admin.Status != store.AdminStatusActive {
+		th.Logger.Printf("ERROR: error getting active admin by username in GetAdminByUsername: %v", req.Username)
+		th.recordLoginFailure(lockoutKey)
+		th.auditLogin(r, store.AuditActorAdmin, "", "", req.Username, store.AuditOutcomeFailure)
+		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid username or password"})
+		return
+	}
+
+	ok, err := admin.PasswordHash.Matches(req.Password)
+	if err != nil {
+		th.Logger.Printf("ERROR: error matching password at Matches: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if !ok {
+		th.recordLoginFailure(lockoutKey)
+		th.auditLogin(r, store.AuditActorAdmin, admin.ID, "", req.Username, store.AuditOutcomeFailure)
+		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid username or password"})
+		return
+	}
+	th.resetLoginFailures(lockoutKey)
+
+	token, err := th.AdminStore.CreateAdminToken(admin, 8*time.Hour)
+	if err != nil {
+		th.Logger.Printf("ERROR: error creating token at CreateAdminToken: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	th.auditLogin(r, store.AuditActorAdmin, admin.ID, "", req.Username, store.AuditOutcomeSuccess)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"token": token, "admin_id": admin.ID})
+}
+
+// auditLogin records a sign-in attempt. actorID is empty when the username
+// matched no account.
+func (th *TokenHandler) auditLogin(r *http.Request, actorType string, actorID string, merchantID string, username string, outcome string) {
+	th.Audit.RecordEntry(r, AuditEntry{
+		Action:     "auth.login",
+		TargetType: actorType,
+		TargetID:   actorID,
+		Outcome:    outcome,
+		Details:    map[string]string{"username": username},
+		ActorType:  actorType,
+		ActorID:    actorID,
+		MerchantID: merchantID,
+	})
+}
+
+// isLockedOut writes a 429 and returns true while key is locked after
+// repeated failed logins.
+func (th *TokenHandler) isLockedOut(w http.ResponseWriter, key string) bool {
+	lockedUntil, err := th.Lockout.LockedUntil(key)
+	if err != nil {
+		th.Logger.Printf("ERROR: error checking lockout at LockedUntil: %v", err)
+		return false
+	}
+
+	if wait := time.Until(lockedUntil); wait > 0 {
+		th.Logger.Printf("WARNING: login attempt for locked account %s", key)
+		middleware.WriteTooManyRequests(w, wait)
+		return true
+	}
+	return false
+}
+
+func (th *TokenHandler) recordLoginFailure(key string) {
+	lockedUntil, err := th.Lockout.RecordFailure(key)
+	if err != nil {
+		th.Logger.Printf("ERROR: error recording login failure at RecordFailure: %v", err)
+		return
+	}
+	if lockedUntil.After(time.Now()) {
+		th.Logger.Printf("WARNING: account %s locked until %v", key, lockedUntil)
+	}
+}
+
+func (th *TokenHandler) resetLoginFailures(key string) {
+	err := th.Lockout.Reset(key)
+	if err != nil {
+		th.Logger.Printf("ERROR: error resetting login failures at Reset: %v", err)
+	}
+}
@@ -1,26 +1,52 @@
 package api
 
 import (
+	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 
+	"github.com/google/uuid"
 	hiero "github.com/hiero-ledger/hiero-sdk-go/v2/sdk"
 
+	"github.com/divin3circle/orcus/backend/internals/balances"
+	"github.com/divin3circle/orcus/backend/internals/events"
+	"github.com/divin3circle/orcus/backend/internals/fees"
+	"github.com/divin3circle/orcus/backend/internals/fraud"
+	"github.com/divin3circle/orcus/backend/internals/kyc"
+	"github.com/divin3circle/orcus/backend/internals/mandates"
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/notifications"
+	"github.com/divin3circle/orcus/backend/internals/splits"
 	"github.com/divin3circle/orcus/backend/internals/store"
 	"github.com/divin3circle/orcus/backend/internals/utils"
+	"github.com/divin3circle/orcus/backend/internals/webhooks"
 )
 
-const (
-	TOKENDECIMALS = 100
+var errPhoneNotVerified = errors.New("mobile number must be verified before making payments")
+
+var (
+	errAccountSuspended = errors.New("account is suspended")
+	errShopSuspended    = errors.New("shop is not accepting payments")
+	// errPaymentNotRecorded is wrapped by pay's error once money has moved on
+	// the ledger and a later step failed. Such a payment must not be retried
+	// or reopened; it is left for reconciliation.
+	errPaymentNotRecorded = errors.New("payment was sent but not recorded")
 )
 
+var errPayerMismatch = errors.New("username does not match the signed in user")
+
 type TransactionRequest struct {
-	ShopID     string `json:"shop_id"`
-	Username   string `json:"username"`
-	Amount     int64  `json:"amount"`
+	ShopID string `json:"shop_id"`
+	// Username is optional. Payments are always made by the signed in user,
+	// so a username naming anyone else is rejected.
+	Username string `json:"username"`
+	Amount   int64  `json:"amount"`
+	FraudChallengeAnswer
 }
 
 type TransactionResponse struct {
@@ -35,24 +61,24 @@ type TransactionHandler struct {
 	UserStore        store.UserStore
 	MerchantStore    store.MerchantStore
 	ShopStore        store.ShopStore
+	Fees             *fees.Engine
+	Webhooks         webhooks.Publisher
+	Events           *events.Hub
+	Balances         *balances.Cache
+	Limits           *kyc.Limiter
+	Fraud            *FraudHandler
 	Logger           *log.Logger
 	Client           *hiero.Client
 }
 
-func NewTransactionHandler(transactionStore store.TransactionStore, userStore store.UserStore, merchantStore store.MerchantStore, shopStore store.ShopStore, logger *log.Logger, client *hiero.Client) *TransactionHandler {
-	return &TransactionHandler{TransactionStore: transactionStore, UserStore: userStore, Client: client, MerchantStore: merchantStore, Logger: logger, ShopStore: shopStore}
+func NewTransactionHandler(transactionStore store.TransactionStore, userStore store.UserStore, merchantStore store.MerchantStore, shopStore store.ShopStore, feeEngine *fees.Engine, publisher webhooks.Publisher, hub *events.Hub, balanceCache *balances.Cache, limits *kyc.Limiter, fraudHandler *FraudHandler, logger *log.Logger, client *hiero.Client) *TransactionHandler {
+	return &TransactionHandler{TransactionStore: transactionStore, UserStore: userStore, Client: client, MerchantStore: merchantStore, Fees: feeEngine, Webhooks: publisher, Events: hub, Balances: balanceCache, Limits: limits, Fraud: fraudHandler, Logger: logger, ShopStore: shopStore}
 }
 
 func (th *TransactionHandler) HandleCreateTransaction(w http.ResponseWriter, r *http.Request) {
-	operatorAccountID, err := hiero.AccountIDFromString(os.Getenv("OPERATOR_ACCOUNT_ID"))
-	if err != nil {
-		th.Logger.Printf("Failed to parse operator account ID: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
-	}
 	// parse & validate the request body to get the transaction request
 	var transactionRequest TransactionRequest
-	err = json.NewDecoder(r.Body).Decode(&transactionRequest)
+	err := json.NewDecoder(r.Body).Decode(&transactionRequest)
 	if err != nil {
 		th.Logger.Printf("ERROR: error decoding transaction request body at Decode: %v", err)
 		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
@@ -66,11 +92,70 @@ func (th *TransactionHandler) HandleCreateTransaction(w http.ResponseWriter, r *
 		return
 	}
 
-	// get the current user, from db and their encrypted key
-	currentUser, err := th.UserStore.GetUserByUsername(transactionRequest.Username)
+	currentUser := middleware.GetUser(r)
+	if transactionRequest.Username != "" && transactionRequest.Username != currentUser.Username {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": errPayerMismatch.Error()})
+		return
+	}
+
+	shop, err := th.ShopStore.GetShopByID(transactionRequest.ShopID)
 	if err != nil {
-		th.Logger.Printf("ERROR: error getting current user: %v", err)
+		th.Logger.Printf("ERROR: error getting shop: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if shop == nil {
+		th.Logger.Printf("ERROR: error getting shop: %v", err)
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "shop not found"})
+		return
+	}
+
+	amount, err := money.KES.FromMajor(transactionRequest.Amount)
+	if err != nil {
+		th.Logger.Printf("ERROR: error converting amount at FromMajor: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	successfulTxn, err := th.pay(w, currentUser, shop, amount, &transactionRequest.FraudChallengeAnswer)
+	if err != nil {
+		return
+	}
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"data": successfulTxn})
+}
+
+// pay moves amount plus the fee from currentUser to the shop's merchant and
+// records the transaction. On failure it has already written the error
+// response and returns the error, which wraps errPaymentNotRecorded when the
+// amount had already been sent.
+func (th *TransactionHandler) pay(w http.ResponseWriter, currentUser *store.User, shop *store.Shop, amount money.Amount, answer *FraudChallengeAnswer) (*TransactionResponse, error) {
+	operatorAccountID, err := hiero.AccountIDFromString(os.Getenv("OPERATOR_ACCOUNT_ID"))
+	if err != nil {
+		th.Logger.Printf("Failed to parse operator account ID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return nil, err
+	}
+	if !currentUser.PhoneVerified {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": errPhoneNotVerified.Error()})
+		return nil, errPhoneNotVerified
+	}
+	// Mandate charges reach pay without a request, so suspensions are
+	// checked here as well as at sign in.
+	if currentUser.IsSuspended() {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": errAccountSuspended.Error()})
+		return nil, errAccountSuspended
+	}
+	if shop.IsSuspended() {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": errShopSuspended.Error()})
+		return nil, errShopSuspended
+	}
+	err = checkKYCLimit(w, th.Limits, th.Logger, currentUser.ID, currentUser.KYCLevel, store.KYCOperationPayment, amount)
+	if err != nil {
+		return nil, err
+	}
+	decision, err := th.Fraud.screen(w, fraud.Payment{User: currentUser, ShopID: shop.ID, Amount: amount}, answer)
+	if err != nil {
+		return nil, err
 	}
 	userKeyString := currentUser.EncryptedKey
 	userAccountIDString := currentUser.AccountID
@@ -78,7 +163,7 @@ func (th *TransactionHandler) HandleCreateTransaction(w http.ResponseWriter, r *
 	if err != nil {
 		th.Logger.Printf("ERROR: error getting user account ID: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
+		return nil, err
 	}
 
 	// TODO: This is where decryption would happen to the key, skipped for simplicity
@@ -86,106 +171,116 @@ func (th *TransactionHandler) HandleCreateTransaction(w http.ResponseWriter, r *
 	if err != nil {
 		th.Logger.Printf("ERROR: error getting user account key: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
-	}
-
-	shop, err := th.ShopStore.GetShopByID(transactionRequest.ShopID)
-	if err != nil {
-		th.Logger.Printf("ERROR: error getting shop: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
-	}
-	if shop == nil {
-		th.Logger.Printf("ERROR: error getting shop: %v", err)
-		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "shop not found"})
-		return
+		return nil, err
 	}
 
 	merchant, err := th.MerchantStore.GetMerchantByID(shop.MerchantID)
 	if err != nil {
 		th.Logger.Printf("ERROR: error getting merchant: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
+		return nil, err
+	}
+	if merchant.IsSuspended() {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": errShopSuspended.Error()})
+		return nil, errShopSuspended
 	}
 	merchantAccountId, err := hiero.AccountIDFromString(merchant.AccountID)
 	if err != nil {
 		th.Logger.Printf("ERROR: error getting account ID: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
+		return nil, err
 	}
 
-	fees := calculateFeesInKsh(transactionRequest.Amount)
+	quote, err := th.Fees.Quote(store.FeeTransactionPayment, merchant.ID, amount)
+	if err != nil {
+		th.Logger.Printf("ERROR: error pricing payment at Quote: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return nil, err
+	}
+	fee := quote.Fee
+	total, err := amount.Add(fee)
+	if err != nil {
+		th.Logger.Printf("ERROR: error adding fee at Add: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return nil, err
+	}
 
 	// use decrypted the key and sign the hedera transaction with it to transfer funds to the merchant
 	tokenId, err := hiero.TokenIDFromString(os.Getenv("KSH_TOKEN_ID"))
 	if err != nil {
 		th.Logger.Printf("ERROR: error getting token id: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
+		return nil, err
 	}
 
 	// check if the user has enough tokens
-	err = th.checkTokenBalance(userAccountID, tokenId, float64(transactionRequest.Amount + parseFeesToInt64(fees)))
+	err = th.Balances.Require(userAccountIDString, tokenId.String(), total)
 	if err != nil {
 		th.Logger.Printf("ERROR: error checking token balance: %v", err)
 		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
-		return
+		return nil, err
 	}
 
-
-	amount := transactionRequest.Amount * TOKENDECIMALS
-
 	tokenTransferTransaction, err := hiero.NewTransferTransaction().
-		AddTokenTransfer(tokenId, userAccountID, -amount).
-		AddTokenTransfer(tokenId, merchantAccountId, amount).
+		AddTokenTransfer(tokenId, userAccountID, -int64(amount)).
+		AddTokenTransfer(tokenId, merchantAccountId, int64(amount)).
 		FreezeWith(th.Client)
 	if err != nil {
 		th.Logger.Printf("ERROR: error freezing transaction: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
+		return nil, err
 	}
 	transferTransaction := tokenTransferTransaction.Sign(userKey)
 	transactionResponse1, err := transferTransaction.Execute(th.Client)
 	if err != nil {
 		th.Logger.Printf("ERROR: error executing transaction: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
+		return nil, err
 	}
+	th.Balances.Invalidate(userAccountIDString, merchant.AccountID)
 
 	
 
 	tokenFeeTransaction, err := hiero.NewTransferTransaction().
-		AddTokenTransfer(tokenId, userAccountID, -parseFeesToInt64(fees)).
-		AddTokenTransfer(tokenId, operatorAccountID, parseFeesToInt64(fees)).
+		AddTokenTransfer(tokenId, userAccountID, -int64(fee)).
+		AddTokenTransfer(tokenId, operatorAccountID, int64(fee)).
 		FreezeWith(th.Client)
 	if err != nil {
-		th.Logger.Printf("ERROR: error freezing transaction: %v", err)
+		th.Logger.Printf("ERROR: error freezing fee transaction for payment %s: %v", transactionResponse1.TransactionID.String(), err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
+		return nil, fmt.Errorf("%w: %w", errPaymentNotRecorded, err)
 	}
 	feeTransaction := tokenFeeTransaction.Sign(userKey)
 	transactionResponse2, err := feeTransaction.Execute(th.Client)
 	if err != nil {
-		th.Logger.Printf("ERROR: error executing fee transaction: %v", err)
+		th.Logger.Printf("ERROR: error executing fee transaction for payment %s: %v", transactionResponse1.TransactionID.String(), err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
+		return nil, fmt.Errorf("%w: %w", errPaymentNotRecorded, err)
 	}
+	th.Balances.Invalidate(userAccountIDString, operatorAccountID.String())
 
 	// create the transaction in the db
 	var transaction = &store.Transaction{}
-	transaction.Fee = parseFeesToInt64(fees)
-	transaction.Amount = transactionRequest.Amount * TOKENDECIMALS
-	transaction.Status = "completed"
+	transaction.Fee = fee
+	transaction.FeeRule = quote.Rule
+	if quote.Rule != nil {
+		transaction.FeeRuleID = quote.Rule.ID
+	}
+	transaction.Amount = amount
+	transaction.Status = store.TransactionStatusCompleted
 	transaction.MerchantID = shop.MerchantID
-	transaction.ShopID = transactionRequest.ShopID
+	transaction.ShopID = shop.ID
 	transaction.UserID = currentUser.ID
+	transaction.HederaTransactionID = transactionResponse1.TransactionID.String()
+	transaction.HederaFeeTransactionID = transactionResponse2.TransactionID.String()
 
 	txn, err := th.TransactionStore.CreateTransaction(transaction)
 	if err != nil {
-		th.Logger.Printf("ERROR: error creating transaction: %v", err)
+		th.Logger.Printf("ERROR: error creating transaction for payment %s: %v", transactionResponse1.TransactionID.String(), err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
+		return nil, fmt.Errorf("%w: %w", errPaymentNotRecorded, err)
 	}
+	th.Fraud.linkTransaction(decision, txn.ID)
 
 	var successfulTxn = &TransactionResponse{
 		TransactionID:     txn.ID,
@@ -194,25 +289,417 @@ func (th *TransactionHandler) HandleCreateTransaction(w http.ResponseWriter, r *
 		HederaTransaction: transactionResponse1,
 	}
 
-	NotifyMerchant(w, merchant.TopicID, "transaction", th.Client)
-	NotifyUser(w, currentUser.TopicID, "transaction", th.Client)
+	NotifyMerchant(th.Logger, merchant.TopicID, notifications.TypeTransaction, &notifications.Payload{
+		TransactionID:       txn.ID,
+		HederaTransactionID: transactionResponse1.TransactionID.String(),
+		Amount:              txn.Amount,
+		Fee:                 txn.Fee,
+		ShopID:              shop.ID,
+		ShopName:            shop.Name,
+		CounterpartID:       currentUser.ID,
+		CounterpartName:     currentUser.Username,
+	}, th.Client)
+	NotifyUser(th.Logger, currentUser.TopicID, notifications.TypeTransaction, &notifications.Payload{
+		TransactionID:       txn.ID,
+		HederaTransactionID: transactionResponse1.TransactionID.String(),
+		Amount:              txn.Amount,
+		Fee:                 txn.Fee,
+		ShopID:              shop.ID,
+		ShopName:            shop.Name,
+		CounterpartID:       merchant.ID,
+		CounterpartName:     merchant.Username,
+	}, th.Client)
+	th.Webhooks.Publish(merchant.ID, webhooks.EventPaymentCompleted, txn)
+	th.Events.PublishMerchant(merchant.ID, webhooks.EventPaymentCompleted, txn)
+	th.Events.PublishUser(currentUser.ID, webhooks.EventPaymentCompleted, txn)
+
+	return successfulTxn, nil
+}
 
-	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"data": successfulTxn})
+// ChargeMandate takes one mandate payment through pay for the mandates
+// scheduler. There is no request to answer, so failures are only returned.
+// Refusals and payments that already reached the ledger are marked
+// permanent so the scheduler does not charge them again.
+func (th *TransactionHandler) ChargeMandate(mandate *store.Mandate) (*store.Transaction, error) {
+	user, err := th.UserStore.GetUserByID(mandate.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+	shop, err := th.ShopStore.GetShopByID(mandate.ShopID)
+	if err != nil {
+		return nil, err
+	}
+	if shop == nil {
+		return nil, errors.New("shop not found")
+	}
+
+	payment, err := th.pay(discardResponse{}, user, shop, mandate.Amount, nil)
+	if err != nil {
+		if isPaymentRefusal(err) || errors.Is(err, errPaymentNotRecorded) {
+			return nil, mandates.Permanent(err)
+		}
+		return nil, err
+	}
+	return payment.Transaction, nil
+}
+
+// isPaymentRefusal reports whether pay turned the payment down by policy
+// rather than failing to make it. A mandate charge has no one to answer a
+// fraud challenge, so a challenge counts as a refusal.
+func isPaymentRefusal(err error) bool {
+	var limitErr *kyc.LimitError
+	return errors.As(err, &limitErr) ||
+		errors.Is(err, errPaymentDeclined) ||
+		errors.Is(err, errPaymentChallenged) ||
+		errors.Is(err, errAccountSuspended) ||
+		errors.Is(err, errShopSuspended) ||
+		errors.Is(err, errPhoneNotVerified)
+}
+
+// discardResponse stands in for the response when pay runs outside a
+// request.
+type discardResponse struct{}
+
+func (discardResponse) Header() http.Header { return http.Header{} }
+
+func (discardResponse) Write(b []byte) (int, error) { return len(b), nil }
+
+func (discardResponse) WriteHeader(int) {}
+
+type SplitPayee struct {
+	ShopID string `json:"shop_id"`
+	// Amount is in whole KES. Leave it zero to use BasisPoints instead.
+	Amount int64 `json:"amount"`
+	// BasisPoints is the payee's share, out of 10000, of what the fixed
+	// amounts leave of the total.
+	BasisPoints int64 `json:"basis_points"`
+}
+
+type SplitTransactionRequest struct {
+	// Amount is the total in whole KES. It may be left out when every payee
+	// has a fixed amount.
+	Amount int64        `json:"amount"`
+	Payees []SplitPayee `json:"payees"`
+	FraudChallengeAnswer
+}
+
+// splitLeg is one payee of a split payment once its shop and share are known.
+type splitLeg struct {
+	shop      *store.Shop
+	merchant  *store.Merchant
+	accountID hiero.AccountID
+	amount    money.Amount
+	quote     *fees.Quote
+}
+
+// HandleCreateSplitTransaction pays several merchants from one customer
+// payment. Every leg, and the fees, move in a single Hedera transfer so the
+// merchants are either all paid or none are. The response is the parent
+// transaction with one child per merchant.
+func (th *TransactionHandler) HandleCreateSplitTransaction(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	var req SplitTransactionRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		th.Logger.Printf("ERROR: error decoding split transaction request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if req.Amount < 0 {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "amount must not be negative"})
+		return
+	}
+	if !currentUser.PhoneVerified {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": errPhoneNotVerified.Error()})
+		return
+	}
+
+	total, err := money.KES.FromMajor(req.Amount)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	shares := make([]splits.Share, len(req.Payees))
+	for i, payee := range req.Payees {
+		shares[i].BasisPoints = payee.BasisPoints
+		shares[i].Amount, err = money.KES.FromMajor(payee.Amount)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+	}
+	parts, total, err := splits.Allocate(total, shares)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if checkKYCLimit(w, th.Limits, th.Logger, currentUser.ID, currentUser.KYCLevel, store.KYCOperationPayment, total) != nil {
+		return
+	}
+	decision, err := th.Fraud.screen(w, fraud.Payment{User: currentUser, Amount: total}, &req.FraudChallengeAnswer)
+	if err != nil {
+		return
+	}
+
+	legs, ok := th.readSplitLegs(w, req.Payees, parts)
+	if !ok {
+		return
+	}
+
+	var fee money.Amount
+	for _, leg := range legs {
+		leg.quote, err = th.Fees.Quote(store.FeeTransactionPayment, leg.merchant.ID, leg.amount)
+		if err == nil {
+			fee, err = fee.Add(leg.quote.Fee)
+		}
+		if err != nil {
+			th.Logger.Printf("ERROR: error pricing split payment at Quote: %v", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+			return
+		}
+	}
+	debit, err := total.Add(fee)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	tokenID, err := hiero.TokenIDFromString(os.Getenv("KSH_TOKEN_ID"))
+	if err != nil {
+		th.Logger.Printf("ERROR: error getting token id: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	operatorAccountID, err := hiero.AccountIDFromString(os.Getenv("OPERATOR_ACCOUNT_ID"))
+	if err != nil {
+		th.Logger.Printf("Failed to parse operator account ID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	userAccountID, err := hiero.AccountIDFromString(currentUser.AccountID)
+	if err != nil {
+		th.Logger.Printf("ERROR: error getting user account ID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	// TODO: This is where decryption would happen to the key, skipped for simplicity
+	userKey, err := hiero.PrivateKeyFromStringEd25519(currentUser.EncryptedKey)
+	if err != nil {
+		th.Logger.Printf("ERROR: error getting user account key: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = th.Balances.Require(currentUser.AccountID, tokenID.String(), debit)
+	if err != nil {
+		th.Logger.Printf("ERROR: error checking token balance: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	transfer := hiero.NewTransferTransaction().
+		AddTokenTransfer(tokenID, userAccountID, -int64(debit))
+	touched := []string{currentUser.AccountID}
+	for _, leg := range legs {
+		transfer = transfer.AddTokenTransfer(tokenID, leg.accountID, int64(leg.amount))
+		touched = append(touched, leg.merchant.AccountID)
+	}
+	if fee > 0 {
+		transfer = transfer.AddTokenTransfer(tokenID, operatorAccountID, int64(fee))
+		touched = append(touched, operatorAccountID.String())
+	}
+	frozen, err := transfer.FreezeWith(th.Client)
+	if err != nil {
+		th.Logger.Printf("ERROR: error freezing split transaction: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	response, err := frozen.Sign(userKey).Execute(th.Client)
+	if err != nil {
+		th.Logger.Printf("ERROR: error executing split transaction: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	th.Balances.Invalidate(touched...)
+	hederaTransactionID := response.TransactionID.String()
+
+	// The fee is credited in the same transfer as every leg, so children
+	// carry no separate fee transaction for reconciliation to check.
+	parent := &store.Transaction{
+		UserID:              currentUser.ID,
+		Amount:              total,
+		Fee:                 fee,
+		Status:              store.TransactionStatusCompleted,
+		HederaTransactionID: hederaTransactionID,
+	}
+	children := make([]*store.Transaction, len(legs))
+	for i, leg := range legs {
+		children[i] = &store.Transaction{
+			ShopID:              leg.shop.ID,
+			UserID:              currentUser.ID,
+			MerchantID:          leg.merchant.ID,
+			Amount:              leg.amount,
+			Fee:                 leg.quote.Fee,
+			FeeRule:             leg.quote.Rule,
+			Status:              store.TransactionStatusCompleted,
+			HederaTransactionID: hederaTransactionID,
+		}
+		if leg.quote.Rule != nil {
+			children[i].FeeRuleID = leg.quote.Rule.ID
+		}
+	}
+	parent, err = th.TransactionStore.CreateSplitTransaction(parent, children)
+	if err != nil {
+		th.Logger.Printf("ERROR: error recording split transaction %s at CreateSplitTransaction: %v", hederaTransactionID, err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	th.Fraud.linkTransaction(decision, parent.ID)
+
+	for i, leg := range legs {
+		child := children[i]
+		NotifyMerchant(th.Logger, leg.merchant.TopicID, notifications.TypeTransaction, &notifications.Payload{
+			TransactionID:       child.ID,
+			HederaTransactionID: hederaTransactionID,
+			Amount:              child.Amount,
+			Fee:                 child.Fee,
+			ShopID:              leg.shop.ID,
+			ShopName:            leg.shop.Name,
+			CounterpartID:       currentUser.ID,
+			CounterpartName:     currentUser.Username,
+		}, th.Client)
+		NotifyUser(th.Logger, currentUser.TopicID, notifications.TypeTransaction, &notifications.Payload{
+			TransactionID:       child.ID,
+			HederaTransactionID: hederaTransactionID,
+			Amount:              child.Amount,
+			Fee:                 child.Fee,
+			ShopID:              leg.shop.ID,
+			ShopName:            leg.shop.Name,
+			CounterpartID:       leg.merchant.ID,
+			CounterpartName:     leg.merchant.Username,
+		}, th.Client)
+		th.Webhooks.Publish(leg.merchant.ID, webhooks.EventPaymentCompleted, child)
+		th.Events.PublishMerchant(leg.merchant.ID, webhooks.EventPaymentCompleted, child)
+	}
+	th.Events.PublishUser(currentUser.ID, webhooks.EventPaymentCompleted, parent)
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"transaction": parent})
 }
 
+// readSplitLegs looks up each payee's shop and merchant. Payees must be
+// different merchants: reconciliation matches each child to what its
+// merchant's account was credited, which two children cannot share.
+func (th *TransactionHandler) readSplitLegs(w http.ResponseWriter, payees []SplitPayee, parts []money.Amount) ([]*splitLeg, bool) {
+	legs := make([]*splitLeg, len(payees))
+	seen := make(map[string]bool, len(payees))
+	for i, payee := range payees {
+		if _, err := uuid.Parse(payee.ShopID); err != nil {
+			utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "shop not found"})
+			return nil, false
+		}
+		shop, err := th.ShopStore.GetShopByID(payee.ShopID)
+		if err != nil {
+			th.Logger.Printf("ERROR: error getting shop: %v", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+			return nil, false
+		}
+		if shop == nil {
+			utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "shop not found"})
+			return nil, false
+		}
+		if shop.IsSuspended() {
+			utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": errShopSuspended.Error()})
+			return nil, false
+		}
+		if seen[shop.MerchantID] {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "each payee must belong to a different merchant"})
+			return nil, false
+		}
+		seen[shop.MerchantID] = true
+
+		merchant, err := th.MerchantStore.GetMerchantByID(shop.MerchantID)
+		if err != nil {
+			th.Logger.Printf("ERROR: error getting merchant: %v", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+			return nil, false
+		}
+		if merchant.IsSuspended() {
+			utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": errShopSuspended.Error()})
+			return nil, false
+		}
+		accountID, err := hiero.AccountIDFromString(merchant.AccountID)
+		if err != nil {
+			th.Logger.Printf("ERROR: error getting account ID: %v", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+			return nil, false
+		}
+		legs[i] = &splitLeg{shop: shop, merchant: merchant, accountID: accountID, amount: parts[i]}
+	}
+	return legs, true
+}
+
+// HandleGetTransactionByID returns one of the user's own payments.
 func (th *TransactionHandler) HandleGetTransactionByID(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	transaction, ok := th.findTransaction(w, r, func(transaction *store.Transaction) bool {
+		return transaction.UserID == user.ID
+	})
+	if !ok {
+		return
+	}
+	th.writeTransaction(w, transaction)
+}
+
+// HandleGetMerchantTransactionByID returns a payment to one of the merchant's
+// shops that the caller may see.
+func (th *TransactionHandler) HandleGetMerchantTransactionByID(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	transaction, ok := th.findTransaction(w, r, func(transaction *store.Transaction) bool {
+		return transaction.MerchantID == merchant.ID && canAccessShop(r, transaction.ShopID)
+	})
+	if !ok {
+		return
+	}
+	th.writeTransaction(w, transaction)
+}
+
+// findTransaction loads the transaction named in the path. Transactions the
+// caller may not see are reported as not found.
+func (th *TransactionHandler) findTransaction(w http.ResponseWriter, r *http.Request, visible func(*store.Transaction) bool) (*store.Transaction, bool) {
 	paramID, err := utils.ReadIDParam(r, "id")
 	if err != nil {
 		th.Logger.Printf("ERROR: error reading transaction id at ReadIDParam: %v", err)
 		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
-		return
+		return nil, false
 	}
 
 	transaction, err := th.TransactionStore.GetTransactionByID(paramID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !visible(transaction)) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "transaction not found"})
+		return nil, false
+	}
 	if err != nil {
 		th.Logger.Printf("ERROR: error getting transaction by id at GetTransactionByID: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
+		return nil, false
+	}
+	return transaction, true
+}
+
+func (th *TransactionHandler) writeTransaction(w http.ResponseWriter, transaction *store.Transaction) {
+	if transaction.Kind == store.TransactionKindSplit {
+		children, err := th.TransactionStore.GetChildTransactions(transaction.ID)
+		if err != nil {
+			th.Logger.Printf("ERROR: error getting split payments at GetChildTransactions: %v", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+			return
+		}
+		transaction.Children = children
 	}
 	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"transaction": transaction})
 }
@@ -224,6 +711,9 @@ func (th *TransactionHandler) HandleGetTransactionsByShopID(w http.ResponseWrite
 		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
 		return
 	}
+	if _, ok := ownedShop(w, r, th.ShopStore, th.Logger, paramID); !ok {
+		return
+	}
 
 	transactions, err := th.TransactionStore.GetTransactionsByShopID(paramID)
 	if err != nil {
@@ -257,12 +747,23 @@ func (th *TransactionHandler) HandleGetTransactionsByMerchantID(w http.ResponseW
 		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
 		return
 	}
+	if paramID != middleware.GetMerchant(r).ID {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "forbidden"})
+		return
+	}
 	transactions, err := th.TransactionStore.GetTransactionsByMerchantID(paramID)
 	if err != nil {
 		th.Logger.Printf("ERROR: error getting transactions by merchant id at GetTransactionsByMerchantID: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
 		return
 	}
+	visible := []*store.Transaction{}
+	for _, transaction := range transactions {
+		if canAccessShop(r, transaction.ShopID) {
+			visible = append(visible, transaction)
+		}
+	}
+	transactions = visible
 	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"transactions": transactions})
 }
 
@@ -270,45 +771,8 @@ func (th *TransactionHandler) validateTransactionRequest(transactionRequest *Tra
 	if transactionRequest.ShopID == "" {
 		return errors.New("shop id is required")
 	}
-	if transactionRequest.Username == "" {
-		return errors.New("user id is required")
-	}
 	if transactionRequest.Amount <= 0 {
 		return errors.New("amount is required")
 	}
 	return nil
 }
-
-func calculateFeesInKsh(amount int64) float64 {
-	if amount <= 100 {
-		return float64(0)
-	} else {
-		return 0.005 * float64(amount)
-	}
-}
-
-func (th *TransactionHandler) checkTokenBalance(accountID hiero.AccountID, tokenId hiero.TokenID, amount float64) error {
-	balanceQuery := hiero.NewAccountBalanceQuery().
-	SetAccountID(accountID)
-
-	balanceResponse, err := balanceQuery.Execute(th.Client)
-	if err != nil {
-		th.Logger.Printf("ERROR: error getting balance: %v", err)
-		return err
-	}
-
-	accountBalance := float64(balanceResponse.Tokens.Get(tokenId)) / 100
-
-	th.Logger.Printf("Account balance: %f", accountBalance)
-	th.Logger.Printf("Amount: %f", amount)
-
-	if accountBalance < amount {
-		return errors.New("insufficient balance")
-	}
-
-	return nil
-}
-
-func parseFeesToInt64(amount float64) int64 {
-	return int64(amount * TOKENDECIMALS)
-}
\ No newline at end of file
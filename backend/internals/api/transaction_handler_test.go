@@ -0,0 +1,27 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+func TestCreateTransactionPaysAsTheCaller(t *testing.T) {
+	th := &TransactionHandler{TransactionStore: &fakeTransactionStore{}, ShopStore: newFakeShopStore(), Logger: log.New(io.Discard, "", 0)}
+	caller := &store.User{ID: "user-a", Username: "wanjiru"}
+
+	body := `{"shop_id":"shop-a","username":"otieno","amount":100}`
+	req := httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	th.HandleCreateTransaction(rec, middleware.SetUser(req, caller))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), errPayerMismatch.Error())
+}
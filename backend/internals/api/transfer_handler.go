@@ -0,0 +1,300 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	hiero "github.com/hiero-ledger/hiero-sdk-go/v2/sdk"
+
+	"github.com/divin3circle/orcus/backend/internals/balances"
+	"github.com/divin3circle/orcus/backend/internals/events"
+	"github.com/divin3circle/orcus/backend/internals/fees"
+	"github.com/divin3circle/orcus/backend/internals/fraud"
+	"github.com/divin3circle/orcus/backend/internals/kyc"
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/notifications"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+	"github.com/divin3circle/orcus/backend/internals/webhooks"
+)
+
+const (
+	DefaultActivityLimit = 20
+	MaxActivityLimit     = 100
+)
+
+// TransferRequest names the recipient by exactly one of Username or
+// MobileNumber.
+type TransferRequest struct {
+	Username     string `json:"username"`
+	MobileNumber string `json:"mobile_number"`
+	// Amount is in whole KES.
+	Amount int64  `json:"amount"`
+	Note   string `json:"note"`
+	FraudChallengeAnswer
+}
+
+type TransferHandler struct {
+	TransferStore store.TransferStore
+	UserStore     store.UserStore
+	Fees          *fees.Engine
+	Events        *events.Hub
+	Balances      *balances.Cache
+	Limits        *kyc.Limiter
+	Fraud         *FraudHandler
+	Logger        *log.Logger
+	Client        *hiero.Client
+}
+
+func NewTransferHandler(transferStore store.TransferStore, userStore store.UserStore, feeEngine *fees.Engine, hub *events.Hub, balanceCache *balances.Cache, limits *kyc.Limiter, fraudHandler *FraudHandler, logger *log.Logger, client *hiero.Client) *TransferHandler {
+	return &TransferHandler{
+		TransferStore: transferStore,
+		UserStore:     userStore,
+		Fees:          feeEngine,
+		Events:        hub,
+		Balances:      balanceCache,
+		Limits:        limits,
+		Fraud:         fraudHandler,
+		Logger:        logger,
+		Client:        client,
+	}
+}
+
+// HandleCreateTransfer sends KES tokens from the current user to another
+// user. The amount, and the fee when there is one, move in a single ledger
+// transaction so the recipient is never paid without the fee being taken.
+// Transfers count as payments for KYC limits and fraud screening, so money
+// cannot be moved to a second account to get round either.
+func (trh *TransferHandler) HandleCreateTransfer(w http.ResponseWriter, r *http.Request) {
+	sender := middleware.GetUser(r)
+
+	var req TransferRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		trh.Logger.Printf("ERROR: error decoding transfer request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if (req.Username == "") == (req.MobileNumber == "") {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "either username or mobile_number is required"})
+		return
+	}
+	if req.Amount <= 0 {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "amount is required"})
+		return
+	}
+	amount, err := money.KES.FromMajor(req.Amount)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if !sender.PhoneVerified {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": errPhoneNotVerified.Error()})
+		return
+	}
+
+	recipient, ok := trh.readRecipient(w, &req)
+	if !ok {
+		return
+	}
+	if recipient.ID == sender.ID {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "cannot transfer to yourself"})
+		return
+	}
+	if checkKYCLimit(w, trh.Limits, trh.Logger, sender.ID, sender.KYCLevel, store.KYCOperationPayment, amount) != nil {
+		return
+	}
+	// Fraud decisions link to card payments only, so a confirmed transfer
+	// leaves its decision unlinked.
+	_, err = trh.Fraud.screen(w, fraud.Payment{User: sender, Amount: amount}, &req.FraudChallengeAnswer)
+	if err != nil {
+		return
+	}
+
+	quote, err := trh.Fees.Quote(store.FeeTransactionTransfer, "", amount)
+	if err != nil {
+		trh.Logger.Printf("ERROR: error pricing transfer at Quote: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	total, err := amount.Add(quote.Fee)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	tokenID, err := hiero.TokenIDFromString(os.Getenv("KSH_TOKEN_ID"))
+	if err != nil {
+		trh.Logger.Printf("ERROR: error getting token id: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	operatorAccountID, err := hiero.AccountIDFromString(os.Getenv("OPERATOR_ACCOUNT_ID"))
+	if err != nil {
+		trh.Logger.Printf("ERROR: error parsing operator account id: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	senderAccountID, err := hiero.AccountIDFromString(sender.AccountID)
+	if err != nil {
+		trh.Logger.Printf("ERROR: error getting sender account ID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	recipientAccountID, err := hiero.AccountIDFromString(recipient.AccountID)
+	if err != nil {
+		trh.Logger.Printf("ERROR: error getting recipient account ID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	// TODO: This is where decryption would happen to the key, skipped for simplicity
+	senderKey, err := hiero.PrivateKeyFromStringEd25519(sender.EncryptedKey)
+	if err != nil {
+		trh.Logger.Printf("ERROR: error getting sender account key: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = trh.Balances.Require(sender.AccountID, tokenID.String(), total)
+	if err != nil {
+		trh.Logger.Printf("ERROR: error checking token balance: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	transfer := hiero.NewTransferTransaction().
+		AddTokenTransfer(tokenID, senderAccountID, -int64(total)).
+		AddTokenTransfer(tokenID, recipientAccountID, int64(amount))
+	if quote.Fee > 0 {
+		transfer = transfer.AddTokenTransfer(tokenID, operatorAccountID, int64(quote.Fee))
+	}
+	frozen, err := transfer.FreezeWith(trh.Client)
+	if err != nil {
+		trh.Logger.Printf("ERROR: error freezing transfer: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	response, err := frozen.Sign(senderKey).Execute(trh.Client)
+	if err != nil {
+		trh.Logger.Printf("ERROR: error executing transfer: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	trh.Balances.Invalidate(sender.AccountID, recipient.AccountID, operatorAccountID.String())
+
+	// Execute only means a node accepted the transfer. It is recorded once
+	// the network confirms it, since an unassociated recipient or a spent
+	// balance still fails at consensus.
+	receipt, err := response.GetReceipt(trh.Client)
+	if err != nil || receipt.Status != hiero.StatusSuccess {
+		trh.Logger.Printf("WARNING: transfer %s did not succeed, status %s: %v", response.TransactionID.String(), receipt.Status.String(), err)
+		utils.WriteJSON(w, http.StatusBadGateway, utils.Envelope{"error": "transfer failed with status " + receipt.Status.String()})
+		return
+	}
+
+	record := &store.Transfer{
+		SenderID:            sender.ID,
+		RecipientID:         recipient.ID,
+		Amount:              amount,
+		Fee:                 quote.Fee,
+		Note:                req.Note,
+		Status:              store.TransactionStatusCompleted,
+		HederaTransactionID: response.TransactionID.String(),
+		FeeRule:             quote.Rule,
+	}
+	if quote.Rule != nil {
+		record.FeeRuleID = quote.Rule.ID
+	}
+	record, err = trh.TransferStore.CreateTransfer(record)
+	if err != nil {
+		trh.Logger.Printf("ERROR: error recording transfer %s at CreateTransfer: %v", response.TransactionID.String(), err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	NotifyUser(trh.Logger, sender.TopicID, notifications.TypeSend, &notifications.Payload{
+		TransactionID:       record.ID,
+		HederaTransactionID: record.HederaTransactionID,
+		Amount:              record.Amount,
+		Fee:                 record.Fee,
+		CounterpartID:       recipient.ID,
+		CounterpartName:     recipient.Username,
+	}, trh.Client)
+	NotifyUser(trh.Logger, recipient.TopicID, notifications.TypeReceive, &notifications.Payload{
+		TransactionID:       record.ID,
+		HederaTransactionID: record.HederaTransactionID,
+		Amount:              record.Amount,
+		CounterpartID:       sender.ID,
+		CounterpartName:     sender.Username,
+	}, trh.Client)
+	trh.Events.PublishUser(sender.ID, webhooks.EventTransferCompleted, record)
+	trh.Events.PublishUser(recipient.ID, webhooks.EventTransferCompleted, record)
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"transfer": record})
+}
+
+// HandleGetActivity returns the current user's payments, transfers and
+// refunds, newest first. Pages are requested with limit and before, an
+// RFC 3339 timestamp taken from the last entry of the previous page.
+func (trh *TransferHandler) HandleGetActivity(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	limit := DefaultActivityLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > MaxActivityLimit {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "limit must be between 1 and 100"})
+			return
+		}
+		limit = parsed
+	}
+	before := time.Now().Add(time.Minute)
+	if raw := r.URL.Query().Get("before"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "before must be an RFC 3339 timestamp"})
+			return
+		}
+		before = parsed
+	}
+
+	activity, err := trh.TransferStore.GetUserActivity(user.ID, before, limit)
+	if err != nil {
+		trh.Logger.Printf("ERROR: error getting activity at GetUserActivity: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"activity": activity})
+}
+
+func (trh *TransferHandler) readRecipient(w http.ResponseWriter, req *TransferRequest) (*store.User, bool) {
+	var recipient *store.User
+	var err error
+	if req.Username != "" {
+		recipient, err = trh.UserStore.GetUserByUsername(req.Username)
+	} else {
+		recipient, err = trh.TransferStore.GetUserByVerifiedMobileNumber(req.MobileNumber)
+	}
+	if errors.Is(err, store.ErrAmbiguousMobileNumber) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error() + ", send by username instead"})
+		return nil, false
+	}
+	if err != nil {
+		trh.Logger.Printf("ERROR: error getting transfer recipient: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if recipient == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "recipient not found"})
+		return nil, false
+	}
+	return recipient, true
+}
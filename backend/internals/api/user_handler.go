@@ -7,12 +7,17 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"time"
 
 	hiero "github.com/hiero-ledger/hiero-sdk-go/v2/sdk"
 
+	"github.com/divin3circle/orcus/backend/internals/balances"
+	"github.com/divin3circle/orcus/backend/internals/events"
+	"github.com/divin3circle/orcus/backend/internals/kyc"
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/notifications"
 	"github.com/divin3circle/orcus/backend/internals/store"
 	"github.com/divin3circle/orcus/backend/internals/utils"
+	"github.com/divin3circle/orcus/backend/internals/webhooks"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -35,15 +40,29 @@ type UserCampaignRequest struct {
 	TokenBalance int64 `json:"token_balance"`
 }
 
+// CampaignJoinedEvent is the webhook payload sent to the campaign's merchant.
+type CampaignJoinedEvent struct {
+	CampaignID    string `json:"campaign_id"`
+	ShopID        string `json:"shop_id"`
+	UserID        string `json:"user_id"`
+	TokenBalance  money.Amount `json:"token_balance"`
+	TransactionID string `json:"transaction_id"`
+}
+
 type UserHandler struct {
-	UserStore store.UserStore
-	ShopStore store.ShopStore
-	Logger    *log.Logger
-	Client    *hiero.Client
+	UserStore     store.UserStore
+	ShopStore     store.ShopStore
+	MerchantStore store.MerchantStore
+	Webhooks      webhooks.Publisher
+	Events        *events.Hub
+	Balances      *balances.Cache
+	Limits        *kyc.Limiter
+	Logger        *log.Logger
+	Client        *hiero.Client
 }
 
-func NewUserHandler(userStore store.UserStore, shopStore store.ShopStore, logger *log.Logger, client *hiero.Client) *UserHandler {
-	return &UserHandler{UserStore: userStore, ShopStore: shopStore, Logger: logger, Client: client}
+func NewUserHandler(userStore store.UserStore, shopStore store.ShopStore, merchantStore store.MerchantStore, publisher webhooks.Publisher, hub *events.Hub, balanceCache *balances.Cache, limits *kyc.Limiter, logger *log.Logger, client *hiero.Client) *UserHandler {
+	return &UserHandler{UserStore: userStore, ShopStore: shopStore, MerchantStore: merchantStore, Webhooks: publisher, Events: hub, Balances: balanceCache, Limits: limits, Logger: logger, Client: client}
 }
 
 func (uh *UserHandler) HandleCreateUser(w http.ResponseWriter, r *http.Request) {
@@ -138,7 +157,7 @@ func (uh *UserHandler) HandleCreateUser(w http.ResponseWriter, r *http.Request)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
 		return
 	}
-	NotifyUser(w, user.TopicID, "account", uh.Client)
+	NotifyUser(uh.Logger, user.TopicID, notifications.TypeAccount, &notifications.Payload{AccountID: createdUser.AccountID}, uh.Client)
 	uh.Logger.Printf("KSH token associated successfully with user account: %v", receipt.AccountID)
 
 	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"user": createdUser})
@@ -178,21 +197,39 @@ func (uh *UserHandler) HandleBuyToken(w http.ResponseWriter, r *http.Request) {
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
 		return
 	}
+	if user == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "user not found"})
+		return
+	}
 
-	transactionID, err := uh.transferTokenToUser(user.AccountID, req.Amount * TOKENDECIMALS, os.Getenv("KSH_TOKEN_ID"))
+	amount, err := money.KES.FromMajor(req.Amount)
+	if err != nil {
+		uh.Logger.Printf("ERROR: error converting amount in FromMajor: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if checkKYCLimit(w, uh.Limits, uh.Logger, user.ID, user.KYCLevel, store.KYCOperationPurchase, amount) != nil {
+		return
+	}
+
+	transactionID, err := uh.transferTokenToUser(user.AccountID, amount, os.Getenv("KSH_TOKEN_ID"))
 	if err != nil {
 		uh.Logger.Printf("ERROR: error transferring token to user in transferTokenToUser: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
 		return
 	}
 
-	err = uh.UserStore.BuyToken(req.UserID, req.Amount)
+	err = uh.UserStore.BuyToken(req.UserID, amount)
 	if err != nil {
 		uh.Logger.Printf("ERROR: error buying token in BuyToken: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
 		return
 	}
-	NotifyUser(w, user.TopicID, "buy", uh.Client)
+	NotifyUser(uh.Logger, user.TopicID, notifications.TypeBuy, &notifications.Payload{
+		HederaTransactionID: transactionID,
+		Amount:              amount,
+		TokenID:             os.Getenv("KSH_TOKEN_ID"),
+	}, uh.Client)
 	message := fmt.Sprintf("KSH token bought successfully for %d KSH", req.Amount)
 	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": message, "transaction_id": transactionID})
 }
@@ -275,21 +312,68 @@ func (uh *UserHandler) HandleJoinCampaign(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = uh.UserStore.JoinCampaign(user.ID, req.CampaignID, req.TokenBalance)
+	tokenAmount, err := money.CampaignToken.FromMajor(req.TokenBalance)
+	if err != nil {
+		uh.Logger.Printf("ERROR: error converting token balance in FromMajor: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = uh.UserStore.JoinCampaign(user.ID, req.CampaignID, tokenAmount)
 	if err != nil {
 		uh.Logger.Printf("ERROR: error joining campaign in JoinCampaign: %v", err)
 		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
 		return
 	}
 
-	NotifyMerchant(w, req.CampaignID, "joined_campaign", uh.Client)
-	transactionID, err := uh.transferTokenToUser(user.AccountID, req.TokenBalance * TOKENDECIMALS, campaign.TokenID)
+	shop, err := uh.ShopStore.GetShopByID(campaign.ShopID)
+	if err != nil || shop == nil {
+		uh.Logger.Printf("ERROR: error getting campaign shop in GetShopByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "campaign shop not found"})
+		return
+	}
+	merchant, err := uh.MerchantStore.GetMerchantByID(shop.MerchantID)
+	if err != nil || merchant == nil {
+		uh.Logger.Printf("ERROR: error getting campaign merchant in GetMerchantByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "campaign merchant not found"})
+		return
+	}
+
+	NotifyMerchant(uh.Logger, merchant.TopicID, notifications.TypeJoinedCampaign, &notifications.Payload{
+		Amount:          tokenAmount,
+		ShopID:          shop.ID,
+		ShopName:        shop.Name,
+		CampaignID:      campaign.ID,
+		CampaignName:    campaign.Name,
+		CounterpartID:   user.ID,
+		CounterpartName: user.Username,
+	}, uh.Client)
+	transactionID, err := uh.transferTokenToUser(user.AccountID, tokenAmount, campaign.TokenID)
 	if err != nil {
 		uh.Logger.Printf("ERROR: error transferring token to user in transferTokenToUser: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
 		return
 	}
-	NotifyUser(w, user.TopicID, "join", uh.Client)
+	NotifyUser(uh.Logger, user.TopicID, notifications.TypeJoin, &notifications.Payload{
+		HederaTransactionID: transactionID,
+		Amount:              tokenAmount,
+		TokenID:             campaign.TokenID,
+		ShopID:              shop.ID,
+		ShopName:            shop.Name,
+		CampaignID:          campaign.ID,
+		CampaignName:        campaign.Name,
+	}, uh.Client)
+
+	joined := CampaignJoinedEvent{
+		CampaignID:    campaign.ID,
+		ShopID:        shop.ID,
+		UserID:        user.ID,
+		TokenBalance:  tokenAmount,
+		TransactionID: transactionID,
+	}
+	uh.Webhooks.Publish(shop.MerchantID, webhooks.EventCampaignJoined, joined)
+	uh.Events.PublishMerchant(shop.MerchantID, webhooks.EventCampaignJoined, joined)
+	uh.Events.PublishUser(user.ID, webhooks.EventCampaignJoined, joined)
 
 	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "Campaign joined successfully", "transaction_id": transactionID})
 }
@@ -356,20 +440,34 @@ func (uh *UserHandler) HandleUpdateCampaignEntry(w http.ResponseWriter, r *http.
 		return
 	}
 
-	err = uh.UserStore.UpdateCampaignEntry(user.ID, req.CampaignID, req.TokenBalance)
+	tokenAmount, err := money.CampaignToken.FromMajor(req.TokenBalance)
+	if err != nil {
+		uh.Logger.Printf("ERROR: error converting token balance in FromMajor: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = uh.UserStore.UpdateCampaignEntry(user.ID, req.CampaignID, tokenAmount)
 	if err != nil {
 		uh.Logger.Printf("ERROR: error updating campaign entry in UpdateCampaignEntry: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
 		return
 	}
 
-	transactionID, err := uh.transferTokenToUser(user.AccountID, req.TokenBalance * TOKENDECIMALS, campaign.TokenID)
+	transactionID, err := uh.transferTokenToUser(user.AccountID, tokenAmount, campaign.TokenID)
 	if err != nil {
 		uh.Logger.Printf("ERROR: error transferring token to user in transferTokenToUser: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
 		return
 	}
-	NotifyUser(w, user.TopicID, "update", uh.Client)
+	NotifyUser(uh.Logger, user.TopicID, notifications.TypeUpdate, &notifications.Payload{
+		HederaTransactionID: transactionID,
+		Amount:              tokenAmount,
+		TokenID:             campaign.TokenID,
+		ShopID:              campaign.ShopID,
+		CampaignID:          campaign.ID,
+		CampaignName:        campaign.Name,
+	}, uh.Client)
 	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "Campaign entry updated successfully", "transaction_id": transactionID})
 }
 
@@ -436,7 +534,7 @@ func (uh *UserHandler) validateRegisterRequest(registerRequest *UserRegisterRequ
 	return nil
 }
 
-func (uh *UserHandler) transferTokenToUser(accountID string, amount int64, tokenId string) (string, error) {
+func (uh *UserHandler) transferTokenToUser(accountID string, amount money.Amount, tokenId string) (string, error) {
 	uh.Logger.Printf("Transferring token to user account: %v", accountID)
 	uh.Logger.Printf("Amount: %v", amount)
 	uh.Logger.Printf("Token ID: %v", tokenId)
@@ -460,8 +558,8 @@ func (uh *UserHandler) transferTokenToUser(accountID string, amount int64, token
 	}
 
 	tokenTransferTransaction, err := hiero.NewTransferTransaction().
-		AddTokenTransfer(tokenID, operatorAccountID, -amount).
-		AddTokenTransfer(tokenID, userAccountID, amount).
+		AddTokenTransfer(tokenID, operatorAccountID, -int64(amount)).
+		AddTokenTransfer(tokenID, userAccountID, int64(amount)).
 		FreezeWith(uh.Client)
 	if err != nil {
 		uh.Logger.Printf("ERROR: error freezing transaction: %v", err)
@@ -473,6 +571,7 @@ func (uh *UserHandler) transferTokenToUser(accountID string, amount int64, token
 		uh.Logger.Printf("ERROR: error executing transaction: %v", err)
 		return "", err
 	}
+	uh.Balances.Invalidate(accountID, operatorAccountID.String())
 	receipt, err := txnResponse.GetReceipt(uh.Client)
 	if err != nil {
 		uh.Logger.Printf("ERROR: error getting receipt response for token transfer transaction: %v", err)
@@ -482,62 +581,29 @@ func (uh *UserHandler) transferTokenToUser(accountID string, amount int64, token
 	return receipt.TransactionID.String(), nil
 }
 
-func NotifyUser(w http.ResponseWriter, topicID string, messageType string, client *hiero.Client) {
+func NotifyUser(logger *log.Logger, topicID string, messageType string, data *notifications.Payload, client *hiero.Client) {
 	var messageContent string
 
 	switch messageType {
-	case "transaction":
+	case notifications.TypeTransaction:
 		messageContent = "Payment sent successfully"
-	case "buy":
+	case notifications.TypeBuy:
 		messageContent = "KSH Token bought successfully"
-	case "send":
+	case notifications.TypeSend:
 		messageContent = "KSH Token sent successfully"
-	case "airdrop":
+	case notifications.TypeAirdrop:
 		messageContent = "You have received KSH tokens from a merchant"
-	case "join":
+	case notifications.TypeJoin:
 		messageContent = "Campaign joined successfully"
-	case "update":
+	case notifications.TypeUpdate:
 		messageContent = "Campaign entry updated successfully"
+	case notifications.TypeRefund:
+		messageContent = "You have received a refund"
+	case notifications.TypeReceive:
+		messageContent = "You have received KSH tokens"
 	default:
 		messageContent = "Account created successfully"
 	}
 
-	topicIDObj, err := hiero.TopicIDFromString(topicID)
-	if err != nil {
-		fmt.Printf("ERROR: error getting topic id: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
-	}
-
-	topicMessage := NotificationMessage{
-		Type: messageType,
-		MessageContent: messageContent,
-		Timestamp: time.Now().Unix(),
-	}
-
-	marshalledMessage, err := json.Marshal(topicMessage)
-	if err != nil {
-		fmt.Printf("ERROR: error marshalling message: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
-	}
-
-	submitMessage, err := hiero.NewTopicMessageSubmitTransaction().
-		SetMessage(marshalledMessage).
-		SetTopicID(topicIDObj).
-		Execute(client)
-	if err != nil {
-		fmt.Printf("ERROR: error freezing transaction: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
-	}
-
-	receipt, err := submitMessage.GetReceipt(client)
-	if err != nil {
-		fmt.Printf("ERROR: error getting receipt response for topic message submit transaction: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
-		return
-	}
-
-	fmt.Printf("Topic message submitted successfully: %v", receipt.TopicID)
+	submitNotification(logger, topicID, messageType, messageContent, data, client)
 }
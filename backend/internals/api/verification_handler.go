@@ -0,0 +1,379 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/sms"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/tokens"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+)
+
+const (
+	OTPTTL      = 10 * time.Minute
+	OTPCooldown = time.Minute
+)
+
+// errOTPCooldown is returned when a code of the same kind was sent within
+// OTPCooldown.
+var errOTPCooldown = errors.New("a code was sent recently, try again later")
+
+type PasswordResetRequest struct {
+	Username string `json:"username"`
+}
+
+type ResetPasswordRequest struct {
+	Username string `json:"username"`
+	Code     string `json:"code"`
+	Password string `json:"password"`
+}
+
+type VerifyPhoneRequest struct {
+	Code string `json:"code"`
+}
+
+type VerificationHandler struct {
+	TokenStore     store.TokenStore
+	UserTokenStore store.UserTokenStore
+	MerchantStore  store.MerchantStore
+	UserStore      store.UserStore
+	Sender         sms.Sender
+	Audit          *AuditRecorder
+	Logger         *log.Logger
+}
+
+func NewVerificationHandler(tokenStore store.TokenStore, userTokenStore store.UserTokenStore, merchantStore store.MerchantStore, userStore store.UserStore, sender sms.Sender, audit *AuditRecorder, logger *log.Logger) *VerificationHandler {
+	return &VerificationHandler{TokenStore: tokenStore, UserTokenStore: userTokenStore, MerchantStore: merchantStore, UserStore: userStore, Sender: sender, Audit: audit, Logger: logger}
+}
+
+// HandleRequestPasswordReset sends a reset code to the merchant's mobile
+// number. The response is the same whether or not the username exists, so a
+// request within OTPCooldown of the last code is dropped without saying so.
+func (vh *VerificationHandler) HandleRequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		vh.Logger.Printf("ERROR: error decoding password reset request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	merchant, err := vh.MerchantStore.GetMerchantByUsername(req.Username)
+	if err != nil {
+		vh.Logger.Printf("ERROR: error getting merchant by username in GetMerchantByUsername: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if merchant != nil {
+		status, err := vh.sendOTP(merchantOTPStore{vh.TokenStore}, merchant.ID, merchant.MobileNumber, tokens.ScopePasswordReset, passwordResetMessage)
+		if err != nil && !errors.Is(err, errOTPCooldown) {
+			utils.WriteJSON(w, status, utils.Envelope{"error": err.Error()})
+			return
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "If the account exists, a reset code has been sent to its mobile number"})
+}
+
+func (vh *VerificationHandler) HandleResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		vh.Logger.Printf("ERROR: error decoding reset password request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = validateResetPasswordRequest(&req)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	merchant, err := vh.MerchantStore.GetMerchantByUsername(req.Username)
+	if err != nil {
+		vh.Logger.Printf("ERROR: error getting merchant by username in GetMerchantByUsername: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if merchant == nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid or expired code"})
+		return
+	}
+
+	ok, err := vh.TokenStore.MatchOTP(merchant.ID, tokens.ScopePasswordReset, req.Code)
+	if err != nil {
+		vh.Logger.Printf("ERROR: error matching otp at MatchOTP: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if !ok {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid or expired code"})
+		return
+	}
+
+	err = merchant.PasswordHash.Set(req.Password)
+	if err != nil {
+		vh.Logger.Printf("ERROR: error hashing password at Set: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = vh.MerchantStore.UpdateMerchant(merchant)
+	if err != nil {
+		vh.Logger.Printf("ERROR: error updating merchant at UpdateMerchant: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = vh.TokenStore.DeleteAllForMerchant(tokens.ScopeAuthentication, merchant.ID)
+	if err != nil {
+		vh.Logger.Printf("ERROR: error revoking merchant tokens at DeleteAllForMerchant: %v", err)
+	}
+
+	vh.Audit.RecordEntry(r, AuditEntry{
+		Action:     "auth.password_reset",
+		TargetType: store.AuditActorMerchant,
+		TargetID:   merchant.ID,
+		ActorType:  store.AuditActorMerchant,
+		ActorID:    merchant.ID,
+		MerchantID: merchant.ID,
+	})
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "Password reset successfully"})
+}
+
+// HandleRequestUserPasswordReset is the user-app counterpart of
+// HandleRequestPasswordReset, and drops requests within OTPCooldown the same
+// way.
+func (vh *VerificationHandler) HandleRequestUserPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		vh.Logger.Printf("ERROR: error decoding password reset request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user, err := vh.UserStore.GetUserByUsername(req.Username)
+	if err != nil {
+		vh.Logger.Printf("ERROR: error getting user by username in GetUserByUsername: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if user != nil {
+		status, err := vh.sendOTP(userOTPStore{vh.UserTokenStore}, user.ID, user.MobileNumber, tokens.ScopePasswordReset, passwordResetMessage)
+		if err != nil && !errors.Is(err, errOTPCooldown) {
+			utils.WriteJSON(w, status, utils.Envelope{"error": err.Error()})
+			return
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "If the account exists, a reset code has been sent to its mobile number"})
+}
+
+func (vh *VerificationHandler) HandleResetUserPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		vh.Logger.Printf("ERROR: error decoding reset password request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = validateResetPasswordRequest(&req)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user, err := vh.UserStore.GetUserByUsername(req.Username)
+	if err != nil {
+		vh.Logger.Printf("ERROR: error getting user by username in GetUserByUsername: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if user == nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid or expired code"})
+		return
+	}
+
+	ok, err := vh.UserTokenStore.MatchOTP(user.ID, tokens.ScopePasswordReset, req.Code)
+	if err != nil {
+		vh.Logger.Printf("ERROR: error matching otp at MatchOTP: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if !ok {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid or expired code"})
+		return
+	}
+
+	err = user.PasswordHash.Set(req.Password)
+	if err != nil {
+		vh.Logger.Printf("ERROR: error hashing password at Set: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = vh.UserStore.UpdateUser(user)
+	if err != nil {
+		vh.Logger.Printf("ERROR: error updating user at UpdateUser: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = vh.UserTokenStore.DeleteAllForUser(tokens.ScopeAuthentication, user.ID)
+	if err != nil {
+		vh.Logger.Printf("ERROR: error revoking user tokens at DeleteAllForUser: %v", err)
+	}
+
+	vh.Audit.RecordEntry(r, AuditEntry{
+		Action:     "auth.password_reset",
+		TargetType: store.AuditActorUser,
+		TargetID:   user.ID,
+		ActorType:  store.AuditActorUser,
+		ActorID:    user.ID,
+	})
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "Password reset successfully"})
+}
+
+// HandleRequestPhoneVerification sends a code to the authenticated user's
+// mobile number.
+func (vh *VerificationHandler) HandleRequestPhoneVerification(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user.PhoneVerified {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "mobile number is already verified"})
+		return
+	}
+
+	status, err := vh.sendOTP(userOTPStore{vh.UserTokenStore}, user.ID, user.MobileNumber, tokens.ScopePhoneVerification, phoneVerificationMessage)
+	if err != nil {
+		utils.WriteJSON(w, status, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "Verification code sent"})
+}
+
+func (vh *VerificationHandler) HandleVerifyPhone(w http.ResponseWriter, r *http.Request) {
+	var req VerifyPhoneRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		vh.Logger.Printf("ERROR: error decoding verify phone request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if req.Code == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "code is required"})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	ok, err := vh.UserTokenStore.MatchOTP(user.ID, tokens.ScopePhoneVerification, req.Code)
+	if err != nil {
+		vh.Logger.Printf("ERROR: error matching otp at MatchOTP: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if !ok {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid or expired code"})
+		return
+	}
+
+	err = vh.UserStore.SetPhoneVerified(user.ID, true)
+	if err != nil {
+		vh.Logger.Printf("ERROR: error setting phone verified at SetPhoneVerified: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "Mobile number verified", "phone_verified": true})
+}
+
+// otpStore issues one-time codes to either merchants or users.
+type otpStore interface {
+	HasRecentOTP(ownerID string, scope string, since time.Time) (bool, error)
+	IssueOTP(ownerID string, ttl time.Duration, scope string) (string, error)
+}
+
+type merchantOTPStore struct {
+	store.TokenStore
+}
+
+func (ms merchantOTPStore) IssueOTP(merchantID string, ttl time.Duration, scope string) (string, error) {
+	otp, err := ms.CreateOTP(merchantID, ttl, scope)
+	if err != nil {
+		return "", err
+	}
+	return otp.Plaintext, nil
+}
+
+type userOTPStore struct {
+	store.UserTokenStore
+}
+
+func (us userOTPStore) IssueOTP(userID string, ttl time.Duration, scope string) (string, error) {
+	otp, err := us.CreateOTP(userID, ttl, scope)
+	if err != nil {
+		return "", err
+	}
+	return otp.Plaintext, nil
+}
+
+// sendOTP texts ownerID a new code for scope at mobileNumber. It returns
+// errOTPCooldown, with 429, when a code was sent within OTPCooldown, and
+// otherwise the status to answer a failure with.
+func (vh *VerificationHandler) sendOTP(otps otpStore, ownerID string, mobileNumber string, scope string, message func(code string) string) (int, error) {
+	recent, err := otps.HasRecentOTP(ownerID, scope, time.Now().Add(-OTPCooldown))
+	if err != nil {
+		vh.Logger.Printf("ERROR: error checking recent otp at HasRecentOTP: %v", err)
+		return http.StatusInternalServerError, err
+	}
+	if recent {
+		return http.StatusTooManyRequests, errOTPCooldown
+	}
+
+	code, err := otps.IssueOTP(ownerID, OTPTTL, scope)
+	if err != nil {
+		vh.Logger.Printf("ERROR: error creating otp at CreateOTP: %v", err)
+		return http.StatusInternalServerError, err
+	}
+
+	err = vh.Sender.Send(mobileNumber, message(code))
+	if err != nil {
+		vh.Logger.Printf("ERROR: error sending sms at Send: %v", err)
+		return http.StatusInternalServerError, errors.New("failed to send verification code")
+	}
+	return http.StatusOK, nil
+}
+
+func validateResetPasswordRequest(req *ResetPasswordRequest) error {
+	if req.Username == "" {
+		return errors.New("username is required")
+	}
+	if req.Code == "" {
+		return errors.New("code is required")
+	}
+	if len(req.Password) < 6 {
+		return errors.New("password must be at least 6 characters long")
+	}
+	return nil
+}
+
+func passwordResetMessage(code string) string {
+	return fmt.Sprintf("Your Orcus password reset code is %s. It expires in %d minutes.", code, int(OTPTTL.Minutes()))
+}
+
+func phoneVerificationMessage(code string) string {
+	return fmt.Sprintf("Your Orcus verification code is %s. It expires in %d minutes.", code, int(OTPTTL.Minutes()))
+}
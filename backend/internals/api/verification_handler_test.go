@@ -0,0 +1,111 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/tokens"
+)
+
+type fakeResetMerchantStore struct {
+	store.MerchantStore
+	merchants map[string]*store.Merchant
+}
+
+func (fs *fakeResetMerchantStore) GetMerchantByUsername(username string) (*store.Merchant, error) {
+	return fs.merchants[username], nil
+}
+
+type fakeResetTokenStore struct {
+	store.TokenStore
+	recent map[string]bool
+}
+
+func (fs *fakeResetTokenStore) HasRecentOTP(merchantID string, scope string, since time.Time) (bool, error) {
+	return fs.recent[merchantID], nil
+}
+
+func (fs *fakeResetTokenStore) CreateOTP(merchantID string, ttl time.Duration, scope string) (*tokens.Token, error) {
+	return &tokens.Token{Plaintext: "123456"}, nil
+}
+
+type fakeResetUserStore struct {
+	store.UserStore
+	users map[string]*store.User
+}
+
+func (fs *fakeResetUserStore) GetUserByUsername(username string) (*store.User, error) {
+	return fs.users[username], nil
+}
+
+type fakeResetUserTokenStore struct {
+	store.UserTokenStore
+	recent map[string]bool
+}
+
+func (fs *fakeResetUserTokenStore) HasRecentOTP(userID string, scope string, since time.Time) (bool, error) {
+	return fs.recent[userID], nil
+}
+
+func (fs *fakeResetUserTokenStore) CreateOTP(userID string, ttl time.Duration, scope string) (*tokens.UserToken, error) {
+	return &tokens.UserToken{Plaintext: "123456"}, nil
+}
+
+type recordingSender struct {
+	sent []string
+}
+
+func (rs *recordingSender) Send(to string, message string) error {
+	rs.sent = append(rs.sent, to)
+	return nil
+}
+
+func TestPasswordResetDoesNotRevealAccounts(t *testing.T) {
+	sender := &recordingSender{}
+	vh := NewVerificationHandler(
+		&fakeResetTokenStore{recent: map[string]bool{"merchant-waiting": true}},
+		&fakeResetUserTokenStore{recent: map[string]bool{"user-waiting": true}},
+		&fakeResetMerchantStore{merchants: map[string]*store.Merchant{
+			"duka":    {ID: "merchant-a", MobileNumber: "+254700000001"},
+			"kibanda": {ID: "merchant-waiting", MobileNumber: "+254700000002"},
+		}},
+		&fakeResetUserStore{users: map[string]*store.User{
+			"wanjiru": {ID: "user-a", MobileNumber: "+254700000003"},
+			"otieno":  {ID: "user-waiting", MobileNumber: "+254700000004"},
+		}},
+		sender, nil, log.New(io.Discard, "", 0),
+	)
+
+	requests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		username string
+	}{
+		{"merchant", vh.HandleRequestPasswordReset, "duka"},
+		{"merchant in cooldown", vh.HandleRequestPasswordReset, "kibanda"},
+		{"unknown merchant", vh.HandleRequestPasswordReset, "nobody"},
+		{"user", vh.HandleRequestUserPasswordReset, "wanjiru"},
+		{"user in cooldown", vh.HandleRequestUserPasswordReset, "otieno"},
+		{"unknown user", vh.HandleRequestUserPasswordReset, "nobody"},
+	}
+
+	var first string
+	for _, req := range requests {
+		rec := httptest.NewRecorder()
+		req.handler(rec, httptest.NewRequest(http.MethodPost, "/password-reset", strings.NewReader(`{"username":"`+req.username+`"}`)))
+		assert.Equal(t, http.StatusOK, rec.Code, req.name)
+		if first == "" {
+			first = rec.Body.String()
+		}
+		assert.Equal(t, first, rec.Body.String(), req.name)
+	}
+	assert.Equal(t, []string{"+254700000001", "+254700000003"}, sender.sent)
+}
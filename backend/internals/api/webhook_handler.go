@@ -0,0 +1,179 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+	"github.com/divin3circle/orcus/backend/internals/webhooks"
+)
+
+const defaultDeliveryLimit = 50
+
+type CreateWebhookEndpointRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+type WebhookHandler struct {
+	WebhookStore store.WebhookStore
+	Audit        *AuditRecorder
+	Logger       *log.Logger
+}
+
+func NewWebhookHandler(webhookStore store.WebhookStore, audit *AuditRecorder, logger *log.Logger) *WebhookHandler {
+	return &WebhookHandler{WebhookStore: webhookStore, Audit: audit, Logger: logger}
+}
+
+// HandleCreateWebhookEndpoint registers an endpoint and returns its signing
+// secret. The secret is only shown in this response.
+func (wh *WebhookHandler) HandleCreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	var req CreateWebhookEndpointRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error decoding create webhook endpoint request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = wh.validateCreateWebhookEndpointRequest(&req)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	secret, err := webhooks.GenerateSecret()
+	if err != nil {
+		wh.Logger.Printf("ERROR: error generating webhook secret at GenerateSecret: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	merchant := middleware.GetMerchant(r)
+	endpoint, err := wh.WebhookStore.CreateWebhookEndpoint(&store.WebhookEndpoint{
+		MerchantID: merchant.ID,
+		URL:        req.URL,
+		Secret:     secret,
+		Events:     req.Events,
+	})
+	if err != nil {
+		wh.Logger.Printf("ERROR: error creating webhook endpoint at CreateWebhookEndpoint: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	wh.Audit.Record(r, "webhook_endpoint.create", "webhook_endpoint", endpoint.ID, map[string]any{"url": endpoint.URL, "events": endpoint.Events})
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"endpoint": endpoint, "secret": secret})
+}
+
+func (wh *WebhookHandler) HandleGetWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	endpoints, err := wh.WebhookStore.GetWebhookEndpointsByMerchantID(merchant.ID)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error getting webhook endpoints at GetWebhookEndpointsByMerchantID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"endpoints": endpoints})
+}
+
+func (wh *WebhookHandler) HandleDeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	endpointID, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		wh.Logger.Printf("ERROR: error reading webhook endpoint id at ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	merchant := middleware.GetMerchant(r)
+	err = wh.WebhookStore.DeleteWebhookEndpoint(merchant.ID, endpointID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "webhook endpoint not found"})
+		return
+	}
+	if err != nil {
+		wh.Logger.Printf("ERROR: error deleting webhook endpoint at DeleteWebhookEndpoint: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	wh.Audit.Record(r, "webhook_endpoint.delete", "webhook_endpoint", endpointID, nil)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "Webhook endpoint deleted"})
+}
+
+// HandleGetWebhookDeliveries returns the merchant's delivery log, newest
+// first. ?limit= caps the number of rows (default 50, max 500).
+func (wh *WebhookHandler) HandleGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	limit := defaultDeliveryLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > 500 {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "limit must be between 1 and 500"})
+			return
+		}
+		limit = parsed
+	}
+
+	merchant := middleware.GetMerchant(r)
+	deliveries, err := wh.WebhookStore.GetWebhookDeliveriesByMerchantID(merchant.ID, limit)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error getting webhook deliveries at GetWebhookDeliveriesByMerchantID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"deliveries": deliveries})
+}
+
+func (wh *WebhookHandler) HandleReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	deliveryID, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		wh.Logger.Printf("ERROR: error reading webhook delivery id at ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	merchant := middleware.GetMerchant(r)
+	delivery, err := wh.WebhookStore.ReplayWebhookDelivery(merchant.ID, deliveryID)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error replaying webhook delivery at ReplayWebhookDelivery: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if delivery == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "webhook delivery not found"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, utils.Envelope{"delivery": delivery})
+}
+
+func (wh *WebhookHandler) validateCreateWebhookEndpointRequest(req *CreateWebhookEndpointRequest) error {
+	if req.URL == "" {
+		return errors.New("url is required")
+	}
+	err := webhooks.CheckURL(req.URL)
+	if err != nil {
+		return err
+	}
+	if len(req.URL) > 2048 {
+		return errors.New("url must be at most 2048 characters long")
+	}
+	if len(req.Events) == 0 {
+		return errors.New("at least one event is required")
+	}
+	for _, event := range req.Events {
+		if !webhooks.IsEventType(event) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,621 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	hiero "github.com/hiero-ledger/hiero-sdk-go/v2/sdk"
+
+	"github.com/divin3circle/orcus/backend/internals/balances"
+	"github.com/divin3circle/orcus/backend/internals/events"
+	"github.com/divin3circle/orcus/backend/internals/fees"
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/notifications"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+	"github.com/divin3circle/orcus/backend/internals/webhooks"
+)
+
+const (
+	// DefaultLargeWithdrawalThreshold is in whole KES.
+	DefaultLargeWithdrawalThreshold = 100000
+	// WithdrawalApprovalWindow is how long a large withdrawal waits for
+	// approvals before the ledger drops its schedule.
+	WithdrawalApprovalWindow = 24 * time.Hour
+	// WithdrawalVolumeWindow is how far back earlier withdrawals count
+	// towards the approval threshold, so a large withdrawal cannot skip
+	// approval by being split into smaller ones.
+	WithdrawalVolumeWindow = 24 * time.Hour
+	// ApprovalSigningWindow is how long an approver has to sign and submit a
+	// prepared schedule signature.
+	ApprovalSigningWindow = 2 * time.Minute
+)
+
+type WithdrawalApproverRequest struct {
+	Name      string `json:"name"`
+	PublicKey string `json:"public_key"`
+}
+
+type SubmitApprovalRequest struct {
+	// SignedTransaction is the prepared schedule signature, base64 encoded,
+	// after the approver has signed it with their key.
+	SignedTransaction string `json:"signed_transaction"`
+}
+
+// WithdrawalApprovalHandler holds large withdrawals for approval. Instead of
+// paying out at once, a withdrawal at or above Threshold becomes a Hedera
+// schedule for the transfer out of the merchant account. The schedule only
+// executes once the merchant account's key is satisfied, so the approvers'
+// keys have to make up that key, typically as a threshold key. Approvers
+// sign through this API; the operator pays for every step.
+type WithdrawalApprovalHandler struct {
+	Store     store.WithdrawalApprovalStore
+	Threshold money.Amount
+	Webhooks  webhooks.Publisher
+	Events    *events.Hub
+	Balances  *balances.Cache
+	Audit     *AuditRecorder
+	Logger    *log.Logger
+	Client    *hiero.Client
+}
+
+func NewWithdrawalApprovalHandler(approvalStore store.WithdrawalApprovalStore, threshold money.Amount, publisher webhooks.Publisher, hub *events.Hub, balanceCache *balances.Cache, audit *AuditRecorder, logger *log.Logger, client *hiero.Client) *WithdrawalApprovalHandler {
+	return &WithdrawalApprovalHandler{
+		Store:     approvalStore,
+		Threshold: threshold,
+		Webhooks:  publisher,
+		Events:    hub,
+		Balances:  balanceCache,
+		Audit:     audit,
+		Logger:    logger,
+		Client:    client,
+	}
+}
+
+// WithdrawalApprovalThresholdFromEnv reads LARGE_WITHDRAWAL_THRESHOLD in whole
+// KES, defaulting to DefaultLargeWithdrawalThreshold. Zero turns approvals off.
+func WithdrawalApprovalThresholdFromEnv() (money.Amount, error) {
+	major := int64(DefaultLargeWithdrawalThreshold)
+	if raw := os.Getenv("LARGE_WITHDRAWAL_THRESHOLD"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 0 {
+			return 0, fmt.Errorf("LARGE_WITHDRAWAL_THRESHOLD must be a whole number of KES, got %q", raw)
+		}
+		major = parsed
+	}
+	return money.KES.FromMajor(major)
+}
+
+// Requires reports whether a withdrawal of amount has to be approved: it
+// does when, together with what the merchant withdrew over the last
+// WithdrawalVolumeWindow, it reaches Threshold.
+func (wh *WithdrawalApprovalHandler) Requires(merchantID string, amount money.Amount) (bool, error) {
+	if wh.Threshold <= 0 {
+		return false, nil
+	}
+	if amount >= wh.Threshold {
+		return true, nil
+	}
+	withdrawn, err := wh.Store.GetWithdrawnSince(merchantID, time.Now().Add(-WithdrawalVolumeWindow))
+	if err != nil {
+		return false, err
+	}
+	total, err := withdrawn.Add(amount)
+	if err != nil {
+		return false, err
+	}
+	return total >= wh.Threshold, nil
+}
+
+// scheduleWithdrawal creates the ledger schedule moving amount and fee from
+// the merchant to the operator, who pays out to receiver once it executes.
+func (wh *WithdrawalApprovalHandler) scheduleWithdrawal(w http.ResponseWriter, r *http.Request, merchant *store.Merchant, amount money.Amount, quote *fees.Quote, receiver string) {
+	approvers, err := wh.Store.GetApproversByMerchantID(merchant.ID)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error getting withdrawal approvers at GetApproversByMerchantID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if len(approvers) == 0 {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": fmt.Sprintf("withdrawals of %s or more in a day need approval, register withdrawal approvers first", money.KES.Format(wh.Threshold))})
+		return
+	}
+	total, err := amount.Add(quote.Fee)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	tokenID, err := hiero.TokenIDFromString(os.Getenv("KSH_TOKEN_ID"))
+	if err != nil {
+		wh.Logger.Printf("ERROR: error getting token id at TokenIDFromString: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	operatorAccountID, err := hiero.AccountIDFromString(os.Getenv("OPERATOR_ACCOUNT_ID"))
+	if err != nil {
+		wh.Logger.Printf("ERROR: error parsing operator account id at AccountIDFromString: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	merchantAccountID, err := hiero.AccountIDFromString(merchant.AccountID)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error parsing merchant account id at AccountIDFromString: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	transfer := hiero.NewTransferTransaction().
+		AddTokenTransfer(tokenID, merchantAccountID, -int64(total)).
+		AddTokenTransfer(tokenID, operatorAccountID, int64(total))
+	schedule, err := hiero.NewScheduleCreateTransaction().SetScheduledTransaction(transfer)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error building withdrawal schedule at SetScheduledTransaction: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	expiresAt := time.Now().Add(WithdrawalApprovalWindow)
+	response, err := schedule.
+		SetPayerAccountID(operatorAccountID).
+		SetAdminKey(wh.Client.GetOperatorPublicKey()).
+		SetExpirationTime(expiresAt).
+		SetWaitForExpiry(false).
+		SetScheduleMemo("withdrawal " + merchant.ID).
+		Execute(wh.Client)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error creating withdrawal schedule at Execute: %v", err)
+		utils.WriteJSON(w, http.StatusBadGateway, utils.Envelope{"error": err.Error()})
+		return
+	}
+	receipt, err := response.GetReceipt(wh.Client)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error getting withdrawal schedule receipt at GetReceipt: %v", err)
+		utils.WriteJSON(w, http.StatusBadGateway, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if receipt.ScheduleID == nil || receipt.ScheduledTransactionID == nil {
+		wh.Logger.Printf("ERROR: withdrawal schedule receipt for %s has no schedule id", response.TransactionID.String())
+		utils.WriteJSON(w, http.StatusBadGateway, utils.Envelope{"error": "ledger did not return a schedule id"})
+		return
+	}
+
+	withdrawal, err := wh.Store.CreateScheduledWithdrawal(&store.Withdrawal{
+		MerchantID:             merchant.ID,
+		Amount:                 amount,
+		Fee:                    quote.Fee,
+		Receiver:               receiver,
+		FeeRule:                quote.Rule,
+		ScheduleID:             receipt.ScheduleID.String(),
+		ScheduledTransactionID: receipt.ScheduledTransactionID.String(),
+		ExpiresAt:              &expiresAt,
+	})
+	if err != nil {
+		wh.Logger.Printf("ERROR: error recording withdrawal for schedule %s at CreateScheduledWithdrawal: %v", receipt.ScheduleID.String(), err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	NotifyMerchant(wh.Logger, merchant.TopicID, notifications.TypeWithdrawalApproval, &notifications.Payload{
+		TransactionID: withdrawal.ID,
+		Amount:        withdrawal.Amount,
+		Fee:           withdrawal.Fee,
+		Receiver:      withdrawal.Receiver,
+	}, wh.Client)
+	wh.Webhooks.Publish(merchant.ID, webhooks.EventWithdrawalPendingApproval, withdrawal)
+	wh.Events.PublishMerchant(merchant.ID, webhooks.EventWithdrawalPendingApproval, withdrawal)
+	wh.Audit.Record(r, "withdrawal.create", "withdrawal", withdrawal.ID, map[string]any{"amount": withdrawal.Amount, "fee": withdrawal.Fee, "receiver": withdrawal.Receiver, "schedule_id": withdrawal.ScheduleID})
+	utils.WriteJSON(w, http.StatusAccepted, utils.Envelope{"withdrawal": withdrawal})
+}
+
+func (wh *WithdrawalApprovalHandler) HandleGetApprovers(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	approvers, err := wh.Store.GetApproversByMerchantID(merchant.ID)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error getting withdrawal approvers at GetApproversByMerchantID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"approvers": approvers})
+}
+
+func (wh *WithdrawalApprovalHandler) HandleCreateApprover(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+
+	var req WithdrawalApproverRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error decoding withdrawal approver request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if req.Name == "" || len(req.Name) > 100 {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "name must be between 1 and 100 characters long"})
+		return
+	}
+	publicKey, err := hiero.PublicKeyFromString(req.PublicKey)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "public_key is not a valid ED25519 or ECDSA public key"})
+		return
+	}
+
+	approver, err := wh.Store.CreateApprover(&store.WithdrawalApprover{
+		MerchantID: merchant.ID,
+		Name:       req.Name,
+		PublicKey:  publicKey.String(),
+	})
+	if errors.Is(err, store.ErrApproverExists) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		wh.Logger.Printf("ERROR: error creating withdrawal approver at CreateApprover: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	wh.Audit.Record(r, "withdrawal_approver.create", "withdrawal_approver", approver.ID, map[string]string{"name": approver.Name, "public_key": approver.PublicKey})
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"approver": approver})
+}
+
+// HandleRevokeApprover stops a key from approving through the API. Any
+// signature it already added to a pending schedule stays on the ledger.
+func (wh *WithdrawalApprovalHandler) HandleRevokeApprover(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	approverID, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		wh.Logger.Printf("ERROR: error reading approver id at ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if _, err = uuid.Parse(approverID); err != nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "approver not found"})
+		return
+	}
+
+	approver, err := wh.Store.RevokeApprover(approverID, merchant.ID)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error revoking withdrawal approver at RevokeApprover: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if approver == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "approver not found"})
+		return
+	}
+
+	wh.Audit.Record(r, "withdrawal_approver.revoke", "withdrawal_approver", approver.ID, nil)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"approver": approver})
+}
+
+func (wh *WithdrawalApprovalHandler) HandleGetWithdrawal(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	withdrawal, ok := wh.readWithdrawal(w, r, merchant)
+	if !ok {
+		return
+	}
+
+	approvals, err := wh.Store.GetWithdrawalApprovals(withdrawal.ID)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error getting withdrawal approvals at GetWithdrawalApprovals: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"withdrawal": withdrawal, "approvals": approvals})
+}
+
+// HandlePrepareApproval returns a frozen ScheduleSignTransaction for the
+// withdrawal's schedule, base64 encoded, for an approver to sign.
+func (wh *WithdrawalApprovalHandler) HandlePrepareApproval(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	withdrawal, ok := wh.readPendingWithdrawal(w, r, merchant)
+	if !ok {
+		return
+	}
+	scheduleID, err := hiero.ScheduleIDFromString(withdrawal.ScheduleID)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error parsing schedule id at ScheduleIDFromString: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	operatorAccountID, err := hiero.AccountIDFromString(os.Getenv("OPERATOR_ACCOUNT_ID"))
+	if err != nil {
+		wh.Logger.Printf("ERROR: error parsing operator account id at AccountIDFromString: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	sign, err := hiero.NewScheduleSignTransaction().
+		SetTransactionID(hiero.TransactionIDGenerate(operatorAccountID)).
+		SetTransactionValidDuration(ApprovalSigningWindow).
+		SetScheduleID(scheduleID).
+		FreezeWith(wh.Client)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error freezing schedule signature at FreezeWith: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	signBytes, err := sign.ToBytes()
+	if err != nil {
+		wh.Logger.Printf("ERROR: error serializing schedule signature at ToBytes: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"withdrawal": withdrawal, "transaction": base64.StdEncoding.EncodeToString(signBytes)})
+}
+
+// HandleSubmitApproval executes a schedule signature from one of the
+// merchant's approvers. When the signature completes the merchant account's
+// key the ledger runs the withdrawal transfer straight away.
+func (wh *WithdrawalApprovalHandler) HandleSubmitApproval(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	withdrawal, ok := wh.readPendingWithdrawal(w, r, merchant)
+	if !ok {
+		return
+	}
+
+	var req SubmitApprovalRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error decoding submit approval request body at Decode: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	signBytes, err := base64.StdEncoding.DecodeString(req.SignedTransaction)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "signed_transaction must be base64 encoded"})
+		return
+	}
+	parsed, err := hiero.TransactionFromBytes(signBytes)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	sign, ok := parsed.(*hiero.ScheduleSignTransaction)
+	if !ok {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "signed_transaction is not a schedule signature"})
+		return
+	}
+	scheduleID := sign.GetScheduleID()
+	if scheduleID.String() != withdrawal.ScheduleID {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "signed_transaction is for a different schedule"})
+		return
+	}
+
+	approver, err := wh.findSigner(merchant, sign)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error getting withdrawal approvers at GetApproversByMerchantID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if approver == nil {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "signed_transaction is not signed by a registered approver"})
+		return
+	}
+
+	response, err := sign.Execute(wh.Client)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error executing schedule signature at Execute: %v", err)
+		utils.WriteJSON(w, http.StatusBadGateway, utils.Envelope{"error": err.Error()})
+		return
+	}
+	_, err = response.GetReceipt(wh.Client)
+	var statusErr hiero.ErrHederaReceiptStatus
+	if errors.As(err, &statusErr) && statusErr.Status == hiero.StatusScheduleAlreadyExecuted {
+		wh.settle(w, merchant, withdrawal, scheduleID)
+		return
+	}
+	if errors.As(err, &statusErr) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": fmt.Sprintf("ledger rejected the signature: %s", statusErr.Status.String())})
+		return
+	}
+	if err != nil {
+		wh.Logger.Printf("ERROR: error getting schedule signature receipt at GetReceipt: %v", err)
+		utils.WriteJSON(w, http.StatusBadGateway, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	_, err = wh.Store.AddWithdrawalApproval(&store.WithdrawalApproval{
+		WithdrawalID:        withdrawal.ID,
+		ApproverID:          approver.ID,
+		HederaTransactionID: response.TransactionID.String(),
+	})
+	if err != nil && !errors.Is(err, store.ErrAlreadyApproved) {
+		wh.Logger.Printf("ERROR: error recording approval %s at AddWithdrawalApproval: %v", response.TransactionID.String(), err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	wh.Audit.Record(r, "withdrawal.approve", "withdrawal", withdrawal.ID, map[string]string{"approver_id": approver.ID, "hedera_transaction_id": response.TransactionID.String()})
+
+	wh.settle(w, merchant, withdrawal, scheduleID)
+}
+
+// HandleCancelWithdrawal deletes the schedule of a withdrawal still waiting
+// on approvals.
+func (wh *WithdrawalApprovalHandler) HandleCancelWithdrawal(w http.ResponseWriter, r *http.Request) {
+	merchant := middleware.GetMerchant(r)
+	withdrawal, ok := wh.readPendingWithdrawal(w, r, merchant)
+	if !ok {
+		return
+	}
+	scheduleID, err := hiero.ScheduleIDFromString(withdrawal.ScheduleID)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error parsing schedule id at ScheduleIDFromString: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	response, err := hiero.NewScheduleDeleteTransaction().
+		SetScheduleID(scheduleID).
+		Execute(wh.Client)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error deleting withdrawal schedule at Execute: %v", err)
+		utils.WriteJSON(w, http.StatusBadGateway, utils.Envelope{"error": err.Error()})
+		return
+	}
+	_, err = response.GetReceipt(wh.Client)
+	var statusErr hiero.ErrHederaReceiptStatus
+	if errors.As(err, &statusErr) && statusErr.Status == hiero.StatusScheduleAlreadyExecuted {
+		wh.settle(w, merchant, withdrawal, scheduleID)
+		return
+	}
+	if err != nil && !(errors.As(err, &statusErr) && statusErr.Status == hiero.StatusScheduleAlreadyDeleted) {
+		wh.Logger.Printf("ERROR: error getting schedule delete receipt at GetReceipt: %v", err)
+		utils.WriteJSON(w, http.StatusBadGateway, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	cancelled, err := wh.Store.ResolveScheduledWithdrawal(withdrawal.ID, store.WithdrawalStatusCancelled)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error cancelling withdrawal at ResolveScheduledWithdrawal: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if cancelled == nil {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": "withdrawal is no longer awaiting approval"})
+		return
+	}
+
+	wh.Audit.Record(r, "withdrawal.cancel", "withdrawal", cancelled.ID, nil)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"withdrawal": cancelled})
+}
+
+// settle checks whether the ledger has run the withdrawal's schedule and, if
+// so, records the outcome and writes the withdrawal with its approvals.
+func (wh *WithdrawalApprovalHandler) settle(w http.ResponseWriter, merchant *store.Merchant, withdrawal *store.Withdrawal, scheduleID hiero.ScheduleID) {
+	info, err := hiero.NewScheduleInfoQuery().
+		SetScheduleID(scheduleID).
+		Execute(wh.Client)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error getting withdrawal schedule at ScheduleInfoQuery: %v", err)
+		utils.WriteJSON(w, http.StatusBadGateway, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if info.ExecutedAt != nil && info.ScheduledTransactionID != nil {
+		receipt, err := hiero.NewTransactionReceiptQuery().
+			SetTransactionID(*info.ScheduledTransactionID).
+			Execute(wh.Client)
+		if err != nil {
+			wh.Logger.Printf("ERROR: error getting scheduled withdrawal receipt at TransactionReceiptQuery: %v", err)
+			utils.WriteJSON(w, http.StatusBadGateway, utils.Envelope{"error": err.Error()})
+			return
+		}
+		status := store.WithdrawalStatusCompleted
+		if receipt.Status != hiero.StatusSuccess {
+			wh.Logger.Printf("WARNING: scheduled withdrawal %s executed with status %s", withdrawal.ID, receipt.Status.String())
+			status = store.WithdrawalStatusFailed
+		}
+
+		resolved, err := wh.Store.ResolveScheduledWithdrawal(withdrawal.ID, status)
+		if err != nil {
+			wh.Logger.Printf("ERROR: error resolving withdrawal at ResolveScheduledWithdrawal: %v", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+			return
+		}
+		if resolved != nil {
+			withdrawal = resolved
+			wh.publishResolved(w, merchant, withdrawal)
+		}
+	}
+
+	approvals, err := wh.Store.GetWithdrawalApprovals(withdrawal.ID)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error getting withdrawal approvals at GetWithdrawalApprovals: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"withdrawal": withdrawal, "approvals": approvals})
+}
+
+func (wh *WithdrawalApprovalHandler) publishResolved(w http.ResponseWriter, merchant *store.Merchant, withdrawal *store.Withdrawal) {
+	if withdrawal.Status != store.WithdrawalStatusCompleted {
+		wh.Webhooks.Publish(merchant.ID, webhooks.EventWithdrawalFailed, withdrawal)
+		wh.Events.PublishMerchant(merchant.ID, webhooks.EventWithdrawalFailed, withdrawal)
+		return
+	}
+
+	wh.Balances.Invalidate(merchant.AccountID, os.Getenv("OPERATOR_ACCOUNT_ID"))
+	NotifyMerchant(wh.Logger, merchant.TopicID, notifications.TypeWithdrawal, &notifications.Payload{
+		TransactionID:       withdrawal.ID,
+		HederaTransactionID: withdrawal.ScheduledTransactionID,
+		Amount:              withdrawal.Amount,
+		Fee:                 withdrawal.Fee,
+		Receiver:            withdrawal.Receiver,
+	}, wh.Client)
+	wh.Webhooks.Publish(merchant.ID, webhooks.EventWithdrawalCompleted, withdrawal)
+	wh.Events.PublishMerchant(merchant.ID, webhooks.EventWithdrawalCompleted, withdrawal)
+}
+
+// findSigner returns the merchant's active approver whose key signed sign,
+// or nil when none did.
+func (wh *WithdrawalApprovalHandler) findSigner(merchant *store.Merchant, sign *hiero.ScheduleSignTransaction) (*store.WithdrawalApprover, error) {
+	approvers, err := wh.Store.GetApproversByMerchantID(merchant.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, approver := range approvers {
+		publicKey, err := hiero.PublicKeyFromString(approver.PublicKey)
+		if err != nil {
+			wh.Logger.Printf("WARNING: withdrawal approver %s has an unreadable key: %v", approver.ID, err)
+			continue
+		}
+		if publicKey.VerifyTransaction(sign) {
+			return approver, nil
+		}
+	}
+	return nil, nil
+}
+
+// readWithdrawal loads the withdrawal named in the URL. Withdrawals of other
+// merchants are reported as not found.
+func (wh *WithdrawalApprovalHandler) readWithdrawal(w http.ResponseWriter, r *http.Request, merchant *store.Merchant) (*store.Withdrawal, bool) {
+	withdrawalID, err := utils.ReadIDParam(r, "id")
+	if err != nil {
+		wh.Logger.Printf("ERROR: error reading withdrawal id at ReadIDParam: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if _, err = uuid.Parse(withdrawalID); err != nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "withdrawal not found"})
+		return nil, false
+	}
+
+	withdrawal, err := wh.Store.GetWithdrawalByID(withdrawalID)
+	if err != nil {
+		wh.Logger.Printf("ERROR: error getting withdrawal at GetWithdrawalByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if withdrawal == nil || withdrawal.MerchantID != merchant.ID {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "withdrawal not found"})
+		return nil, false
+	}
+	return withdrawal, true
+}
+
+// readPendingWithdrawal is readWithdrawal for withdrawals still awaiting
+// approval; any other withdrawal is a conflict.
+func (wh *WithdrawalApprovalHandler) readPendingWithdrawal(w http.ResponseWriter, r *http.Request, merchant *store.Merchant) (*store.Withdrawal, bool) {
+	withdrawal, ok := wh.readWithdrawal(w, r, merchant)
+	if !ok {
+		return nil, false
+	}
+	if withdrawal.Status != store.WithdrawalStatusPendingApproval {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": fmt.Sprintf("withdrawal is %s, not awaiting approval", withdrawal.Status)})
+		return nil, false
+	}
+	return withdrawal, true
+}
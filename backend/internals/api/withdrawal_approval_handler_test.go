@@ -0,0 +1,56 @@
+package api
+
+import (
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+type fakeWithdrawalApprovalStore struct {
+	store.WithdrawalApprovalStore
+	withdrawn map[string]money.Amount
+	since     time.Time
+}
+
+func (fs *fakeWithdrawalApprovalStore) GetWithdrawnSince(merchantID string, since time.Time) (money.Amount, error) {
+	fs.since = since
+	return fs.withdrawn[merchantID], nil
+}
+
+func TestWithdrawalApprovalCountsRecentWithdrawals(t *testing.T) {
+	threshold, err := money.KES.FromMajor(100000)
+	require.NoError(t, err)
+	earlier, err := money.KES.FromMajor(80000)
+	require.NoError(t, err)
+	approvalStore := &fakeWithdrawalApprovalStore{withdrawn: map[string]money.Amount{ownerMerchant.ID: earlier}}
+	wh := NewWithdrawalApprovalHandler(approvalStore, threshold, nil, nil, nil, nil, log.New(io.Discard, "", 0), nil)
+
+	small, err := money.KES.FromMajor(30000)
+	require.NoError(t, err)
+
+	// A withdrawal split in two still reaches the threshold.
+	requires, err := wh.Requires(ownerMerchant.ID, small)
+	require.NoError(t, err)
+	assert.True(t, requires)
+	assert.WithinDuration(t, time.Now().Add(-WithdrawalVolumeWindow), approvalStore.since, time.Minute)
+
+	requires, err = wh.Requires(otherMerchant.ID, small)
+	require.NoError(t, err)
+	assert.False(t, requires)
+
+	requires, err = wh.Requires(otherMerchant.ID, threshold)
+	require.NoError(t, err)
+	assert.True(t, requires)
+
+	wh.Threshold = 0
+	requires, err = wh.Requires(ownerMerchant.ID, small)
+	require.NoError(t, err)
+	assert.False(t, requires)
+}
@@ -8,9 +8,23 @@ import (
 	"os"
 	"strconv"
 
+	"github.com/divin3circle/orcus/backend/internals/aml"
+	"github.com/divin3circle/orcus/backend/internals/anchoring"
 	"github.com/divin3circle/orcus/backend/internals/api"
+	"github.com/divin3circle/orcus/backend/internals/balances"
+	"github.com/divin3circle/orcus/backend/internals/events"
+	"github.com/divin3circle/orcus/backend/internals/fees"
+	"github.com/divin3circle/orcus/backend/internals/fraud"
+	"github.com/divin3circle/orcus/backend/internals/kyc"
+	"github.com/divin3circle/orcus/backend/internals/mandates"
 	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/notifications"
+	"github.com/divin3circle/orcus/backend/internals/qrpay"
+	"github.com/divin3circle/orcus/backend/internals/ratelimit"
+	"github.com/divin3circle/orcus/backend/internals/reconciliation"
+	"github.com/divin3circle/orcus/backend/internals/sms"
 	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/webhooks"
 	"github.com/divin3circle/orcus/backend/migrations"
 	hiero "github.com/hiero-ledger/hiero-sdk-go/v2/sdk"
 	"github.com/joho/godotenv"
@@ -23,16 +37,45 @@ import (
 // 4. Handlers
 
 type Application struct {
-	Logger             *log.Logger
-	Port               int
-	UserHandler        *api.UserHandler
-	MerchantHandler    *api.MerchantHandler
-	ShopHandler        *api.ShopHandler
-	TokenHandler       *api.TokenHandler
-	Middleware         *middleware.MerchantMiddleware
-	DB                 *sql.DB
-	TransactionHandler *api.TransactionHandler
-	HieroClient        *hiero.Client
+	Logger                *log.Logger
+	Port                  int
+	UserHandler           *api.UserHandler
+	MerchantHandler       *api.MerchantHandler
+	ShopHandler           *api.ShopHandler
+	TokenHandler          *api.TokenHandler
+	Middleware            *middleware.MerchantMiddleware
+	RateLimiter           *middleware.RateLimitMiddleware
+	DB                    *sql.DB
+	TransactionHandler    *api.TransactionHandler
+	VerificationHandler   *api.VerificationHandler
+	APIKeyHandler         *api.APIKeyHandler
+	WebhookHandler        *api.WebhookHandler
+	NotificationHandler   *api.NotificationHandler
+	EventHandler          *api.EventHandler
+	ReconciliationHandler *api.ReconciliationHandler
+	BalanceHandler        *api.BalanceHandler
+	FeeHandler            *api.FeeHandler
+	RefundHandler         *api.RefundHandler
+	PaymentRequestHandler *api.PaymentRequestHandler
+	QRHandler             *api.QRHandler
+	TransferHandler       *api.TransferHandler
+	MandateHandler        *api.MandateHandler
+	WithdrawalApprovalHandler *api.WithdrawalApprovalHandler
+	TeamHandler           *api.TeamHandler
+	POSHandler            *api.POSHandler
+	AdminHandler          *api.AdminHandler
+	AuditHandler          *api.AuditHandler
+	AnchorHandler         *api.AnchorHandler
+	KYCHandler            *api.KYCHandler
+	FraudHandler          *api.FraudHandler
+	AMLHandler            *api.AMLHandler
+	AdminMiddleware       *middleware.AdminMiddleware
+	WebhookDispatcher     *webhooks.Dispatcher
+	Reconciler            *reconciliation.Reconciler
+	MandateScheduler      *mandates.Scheduler
+	Anchorer              *anchoring.Anchorer
+	AMLMonitor            *aml.Monitor
+	HieroClient           *hiero.Client
 }
 
 func loadEnvironmentVariables() {
@@ -87,30 +130,149 @@ func NewApplication() (*Application, error) {
 	userTokenStore := store.NewPostgresUserTokenStore(pgDB)
 	userStore := store.NewPostgresUserStore(pgDB)
 	transactionStore := store.NewPostgresTransactionStore(pgDB)
+	apiKeyStore := store.NewPostgresAPIKeyStore(pgDB)
+	webhookStore := store.NewPostgresWebhookStore(pgDB)
+	reconciliationStore := store.NewPostgresReconciliationStore(pgDB)
+	feeStore := store.NewPostgresFeeStore(pgDB)
+	refundStore := store.NewPostgresRefundStore(pgDB)
+	paymentRequestStore := store.NewPostgresPaymentRequestStore(pgDB)
+	transferStore := store.NewPostgresTransferStore(pgDB)
+	mandateStore := store.NewPostgresMandateStore(pgDB)
+	withdrawalApprovalStore := store.NewPostgresWithdrawalApprovalStore(pgDB)
+	memberStore := store.NewPostgresMerchantMemberStore(pgDB)
+	posDeviceStore := store.NewPostgresPOSDeviceStore(pgDB)
+	adminStore := store.NewPostgresAdminStore(pgDB)
+	auditStore := store.NewPostgresAuditStore(pgDB)
+	anchorStore := store.NewPostgresAnchorStore(pgDB)
+	kycStore := store.NewPostgresKYCStore(pgDB)
+	fraudStore := store.NewPostgresFraudStore(pgDB)
+	amlStore := store.NewPostgresAMLStore(pgDB)
+
+	smsSender := sms.NewSenderFromEnv(logger)
+	rateLimitStore, lockout := newRateLimitBackends(pgDB)
+	dispatcher := webhooks.NewDispatcher(webhookStore, logger)
+	hub := events.NewHub()
+	balanceCache := balances.NewCache(balances.NewLedgerFetcher(client))
+	feeEngine := fees.NewEngine(feeStore)
+	auditRecorder := api.NewAuditRecorder(auditStore, logger)
+	kycProvider := kyc.NewProviderFromEnv(logger)
+	kycLimits := kyc.NewLimiter(kycStore)
+	fraudEngine := fraud.NewEngine(fraudStore)
+	watchlist, err := aml.NewWatchlistFromEnv(logger)
+	if err != nil {
+		return nil, err
+	}
+	qrSigningKey, err := qrpay.SigningKeyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if qrSigningKey == nil {
+		logger.Println("WARNING: QR_SIGNING_KEY is not set, dynamic QR codes are disabled")
+	}
+	// Notifications only log their failures, so a bad key has to stop
+	// startup rather than silently drop every message.
+	notificationKey, err := notifications.MasterKeyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if notificationKey == nil {
+		logger.Println("WARNING: NOTIFICATION_ENCRYPTION_KEY is not set, notification payloads are sent unencrypted")
+	}
+	reconciler := reconciliation.NewReconciler(reconciliationStore, reconciliation.NewMirrorClientFromEnv(), os.Getenv("KSH_TOKEN_ID"), accountID.String(), logger)
+
+	largeWithdrawalThreshold, err := api.WithdrawalApprovalThresholdFromEnv()
+	if err != nil {
+		return nil, err
+	}
 
 	// handlers
-	mh := api.NewMerchantHandler(merchantStore, logger, client)
-	sh := api.NewShopHandler(shopStore, userStore, logger, client)
-	th := api.NewTokenHandler(tokenStore, merchantStore, userStore, userTokenStore, logger)
-	mwh := middleware.NewMerchantMiddleware(merchantStore, userStore)
-	uh := api.NewUserHandler(userStore, shopStore, logger, client)
-	txh := api.NewTransactionHandler(transactionStore, userStore, merchantStore, shopStore, logger, client)
+	wah := api.NewWithdrawalApprovalHandler(withdrawalApprovalStore, largeWithdrawalThreshold, dispatcher, hub, balanceCache, auditRecorder, logger, client)
+	mh := api.NewMerchantHandler(merchantStore, feeEngine, dispatcher, hub, wah, auditRecorder, kycLimits, logger, client)
+	sh := api.NewShopHandler(shopStore, userStore, dispatcher, hub, auditRecorder, logger, client)
+	th := api.NewTokenHandler(tokenStore, merchantStore, userStore, userTokenStore, memberStore, adminStore, auditRecorder, lockout, logger)
+	mwh := middleware.NewMerchantMiddleware(merchantStore, userStore, apiKeyStore, memberStore, posDeviceStore)
+	rlm := middleware.NewRateLimitMiddleware(rateLimitStore, logger)
+	uh := api.NewUserHandler(userStore, shopStore, merchantStore, dispatcher, hub, balanceCache, kycLimits, logger, client)
+	frh := api.NewFraudHandler(fraudStore, fraudEngine, userTokenStore, smsSender, adminStore, auditRecorder, logger)
+	txh := api.NewTransactionHandler(transactionStore, userStore, merchantStore, shopStore, feeEngine, dispatcher, hub, balanceCache, kycLimits, frh, logger, client)
+	vh := api.NewVerificationHandler(tokenStore, userTokenStore, merchantStore, userStore, smsSender, auditRecorder, logger)
+	akh := api.NewAPIKeyHandler(apiKeyStore, auditRecorder, logger)
+	whh := api.NewWebhookHandler(webhookStore, auditRecorder, logger)
+	nth := api.NewNotificationHandler(logger)
+	evh := api.NewEventHandler(hub, logger)
+	rch := api.NewReconciliationHandler(reconciliationStore, logger)
+	bh := api.NewBalanceHandler(balanceCache, shopStore, logger)
+	fh := api.NewFeeHandler(feeStore, merchantStore, adminStore, auditRecorder, logger)
+	rfh := api.NewRefundHandler(refundStore, transactionStore, userStore, shopStore, dispatcher, hub, balanceCache, logger, client)
+	prh := api.NewPaymentRequestHandler(paymentRequestStore, shopStore, userStore, txh, dispatcher, hub, logger)
+	qh := api.NewQRHandler(shopStore, qrSigningKey, logger)
+	tfh := api.NewTransferHandler(transferStore, userStore, feeEngine, hub, balanceCache, kycLimits, frh, logger, client)
+	mdh := api.NewMandateHandler(mandateStore, shopStore, logger)
+	tmh := api.NewTeamHandler(memberStore, smsSender, auditRecorder, logger)
+	posh := api.NewPOSHandler(posDeviceStore, shopStore, auditRecorder, logger)
+	adh := api.NewAdminHandler(adminStore, merchantStore, userStore, shopStore, auditRecorder, logger)
+	auh := api.NewAuditHandler(auditStore, logger)
+	anh := api.NewAnchorHandler(anchorStore, transactionStore, logger)
+	kh := api.NewKYCHandler(kycStore, kycProvider, kycLimits, adminStore, auditRecorder, logger)
+	mandateScheduler := mandates.NewScheduler(mandateStore, txh, logger)
+	anchorer := anchoring.NewAnchorerFromEnv(anchorStore, client, logger)
+	amlh := api.NewAMLHandler(amlStore, adminStore, auditRecorder, logger)
+	amlMonitor := aml.NewMonitor(amlStore, watchlist, logger)
+	adm := middleware.NewAdminMiddlewareFromEnv(adminStore)
 
 	app := &Application{
-		Logger:             logger,
-		Port:               port,
-		UserHandler:        uh,
-		MerchantHandler:    mh,
-		ShopHandler:        sh,
-		TokenHandler:       th,
-		Middleware:         mwh,
-		DB:                 pgDB,
-		TransactionHandler: txh,
-		HieroClient:        client,
+		Logger:                logger,
+		Port:                  port,
+		UserHandler:           uh,
+		MerchantHandler:       mh,
+		ShopHandler:           sh,
+		TokenHandler:          th,
+		Middleware:            mwh,
+		RateLimiter:           rlm,
+		DB:                    pgDB,
+		TransactionHandler:    txh,
+		VerificationHandler:   vh,
+		APIKeyHandler:         akh,
+		WebhookHandler:        whh,
+		NotificationHandler:   nth,
+		EventHandler:          evh,
+		ReconciliationHandler: rch,
+		BalanceHandler:        bh,
+		FeeHandler:            fh,
+		RefundHandler:         rfh,
+		PaymentRequestHandler: prh,
+		QRHandler:             qh,
+		TransferHandler:       tfh,
+		MandateHandler:        mdh,
+		WithdrawalApprovalHandler: wah,
+		TeamHandler:           tmh,
+		POSHandler:            posh,
+		AdminHandler:          adh,
+		AuditHandler:          auh,
+		AnchorHandler:         anh,
+		KYCHandler:            kh,
+		FraudHandler:          frh,
+		AMLHandler:            amlh,
+		AdminMiddleware:       adm,
+		WebhookDispatcher:     dispatcher,
+		Reconciler:            reconciler,
+		MandateScheduler:      mandateScheduler,
+		Anchorer:              anchorer,
+		AMLMonitor:            amlMonitor,
+		HieroClient:           client,
 	}
 	return app, nil
 }
 
+// newRateLimitBackends keeps buckets in memory unless RATE_LIMIT_STORE is
+// "postgres", which is needed once more than one backend instance runs.
+func newRateLimitBackends(db *sql.DB) (ratelimit.Store, ratelimit.Lockout) {
+	if os.Getenv("RATE_LIMIT_STORE") == "postgres" {
+		return ratelimit.NewPostgresStore(db), ratelimit.NewPostgresLockout(db, ratelimit.DefaultLockoutPolicy)
+	}
+	return ratelimit.NewMemoryStore(), ratelimit.NewMemoryLockout(ratelimit.DefaultLockoutPolicy)
+}
+
 func (a *Application) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	_, _ = fmt.Fprintf(w, "Status is healthy.")
 }
@@ -0,0 +1,160 @@
+package balances
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	hiero "github.com/hiero-ledger/hiero-sdk-go/v2/sdk"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+)
+
+// TTL is how long a balance read from the ledger is served before the next
+// read fetches it again. Transfers made through the API invalidate the
+// accounts involved straight away, so this only bounds how long transfers
+// made elsewhere take to show up.
+const TTL = time.Minute
+
+// Settle is how long after an invalidation readings are returned but not
+// cached. Handlers invalidate as soon as a transfer is submitted, and it
+// takes a few seconds to reach consensus, so an immediate read may predate it.
+const Settle = 5 * time.Second
+
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
+// Balance holds an account's token balances in the token's minor unit,
+// keyed by token id, as of FetchedAt.
+type Balance struct {
+	AccountID string
+	Tokens    map[string]money.Amount
+	FetchedAt time.Time
+}
+
+func (b *Balance) Token(tokenID string) money.Amount {
+	return b.Tokens[tokenID]
+}
+
+// Fetcher reads an account's current token balances from the ledger.
+type Fetcher interface {
+	FetchBalances(accountID string) (map[string]money.Amount, error)
+}
+
+// LedgerFetcher queries a consensus node with AccountBalanceQuery, which is
+// free of charge but still a network round trip.
+type LedgerFetcher struct {
+	Client *hiero.Client
+}
+
+func NewLedgerFetcher(client *hiero.Client) *LedgerFetcher {
+	return &LedgerFetcher{Client: client}
+}
+
+func (lf *LedgerFetcher) FetchBalances(accountID string) (map[string]money.Amount, error) {
+	account, err := hiero.AccountIDFromString(accountID)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := hiero.NewAccountBalanceQuery().
+		SetAccountID(account).
+		Execute(lf.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make(map[string]money.Amount, len(balance.Token))
+	for tokenID, amount := range balance.Token {
+		tokens[tokenID.String()] = money.Amount(amount)
+	}
+	return tokens, nil
+}
+
+// Cache serves balances from memory, fetching from the ledger when an entry
+// is missing, older than TTL or has been invalidated. It is per process, so
+// with several instances a transfer only invalidates the instance that made
+// it; the others catch up within TTL.
+type Cache struct {
+	Fetcher Fetcher
+	TTL     time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*Balance
+	// settling is when each invalidated account's readings may be cached
+	// again. It also covers fetches that were in flight at invalidation.
+	settling map[string]time.Time
+	now      func() time.Time
+}
+
+func NewCache(fetcher Fetcher) *Cache {
+	return &Cache{
+		Fetcher:  fetcher,
+		TTL:      TTL,
+		entries:  map[string]*Balance{},
+		settling: map[string]time.Time{},
+		now:      time.Now,
+	}
+}
+
+// Get returns the account's balances, fetching them if the cached entry is
+// missing or stale.
+func (c *Cache) Get(accountID string) (*Balance, error) {
+	c.mu.Lock()
+	balance, ok := c.entries[accountID]
+	c.mu.Unlock()
+	if ok && c.now().Sub(balance.FetchedAt) < c.TTL {
+		return balance, nil
+	}
+	return c.Refresh(accountID)
+}
+
+// Refresh fetches the account's balances from the ledger regardless of what
+// is cached.
+func (c *Cache) Refresh(accountID string) (*Balance, error) {
+	fetchedAt := c.now()
+	tokens, err := c.Fetcher.FetchBalances(accountID)
+	if err != nil {
+		return nil, err
+	}
+	balance := &Balance{AccountID: accountID, Tokens: tokens, FetchedAt: fetchedAt}
+
+	c.mu.Lock()
+	if !fetchedAt.Before(c.settling[accountID]) {
+		c.entries[accountID] = balance
+		delete(c.settling, accountID)
+	}
+	c.mu.Unlock()
+	return balance, nil
+}
+
+// Invalidate drops the cached balances of accounts a transfer touched so the
+// next Get reads them from the ledger.
+func (c *Cache) Invalidate(accountIDs ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, accountID := range accountIDs {
+		delete(c.entries, accountID)
+		c.settling[accountID] = c.now().Add(Settle)
+	}
+}
+
+// Require returns ErrInsufficientBalance unless the account holds at least
+// amount of the token. A cached reading that comes up short is fetched again
+// before refusing, since it may predate a top-up made outside the API.
+func (c *Cache) Require(accountID string, tokenID string, amount money.Amount) error {
+	balance, err := c.Get(accountID)
+	if err != nil {
+		return err
+	}
+	if balance.Token(tokenID) >= amount {
+		return nil
+	}
+
+	balance, err = c.Refresh(accountID)
+	if err != nil {
+		return err
+	}
+	if balance.Token(tokenID) < amount {
+		return ErrInsufficientBalance
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package balances
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+)
+
+type fakeFetcher struct {
+	balances map[string]map[string]money.Amount
+	calls    int
+	err      error
+	// during runs inside FetchBalances, standing in for a transfer that lands
+	// while the query is in flight.
+	during func()
+}
+
+func (ff *fakeFetcher) FetchBalances(accountID string) (map[string]money.Amount, error) {
+	ff.calls++
+	if ff.during != nil {
+		ff.during()
+	}
+	if ff.err != nil {
+		return nil, ff.err
+	}
+	return ff.balances[accountID], nil
+}
+
+func TestCacheGet(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	fetcher := &fakeFetcher{balances: map[string]map[string]money.Amount{"0.0.200": {"0.0.500": 1000}}}
+	cache := NewCache(fetcher)
+	cache.now = func() time.Time { return now }
+
+	balance, err := cache.Get("0.0.200")
+	require.NoError(t, err)
+	assert.Equal(t, money.Amount(1000), balance.Token("0.0.500"))
+	assert.Equal(t, now, balance.FetchedAt)
+
+	fetcher.balances["0.0.200"] = map[string]money.Amount{"0.0.500": 400}
+	balance, err = cache.Get("0.0.200")
+	require.NoError(t, err)
+	assert.Equal(t, money.Amount(1000), balance.Token("0.0.500"), "fresh entries are served from memory")
+	assert.Equal(t, 1, fetcher.calls)
+
+	now = now.Add(TTL)
+	balance, err = cache.Get("0.0.200")
+	require.NoError(t, err)
+	assert.Equal(t, money.Amount(400), balance.Token("0.0.500"), "stale entries are fetched again")
+	assert.Equal(t, 2, fetcher.calls)
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	fetcher := &fakeFetcher{balances: map[string]map[string]money.Amount{"0.0.200": {"0.0.500": 1000}}}
+	cache := NewCache(fetcher)
+	cache.now = func() time.Time { return now }
+
+	_, err := cache.Get("0.0.200")
+	require.NoError(t, err)
+	cache.Invalidate("0.0.200", "0.0.100")
+	_, err = cache.Get("0.0.200")
+	require.NoError(t, err)
+	_, err = cache.Get("0.0.200")
+	require.NoError(t, err)
+	assert.Equal(t, 3, fetcher.calls, "readings are not cached while a transfer settles")
+
+	now = now.Add(Settle)
+	_, err = cache.Get("0.0.200")
+	require.NoError(t, err)
+	_, err = cache.Get("0.0.200")
+	require.NoError(t, err)
+	assert.Equal(t, 4, fetcher.calls)
+
+	now = now.Add(TTL)
+	fetcher.during = func() {
+		cache.Invalidate("0.0.200")
+		now = now.Add(Settle)
+	}
+	_, err = cache.Get("0.0.200")
+	require.NoError(t, err)
+	fetcher.during = nil
+	_, err = cache.Get("0.0.200")
+	require.NoError(t, err)
+	assert.Equal(t, 6, fetcher.calls, "a reading taken across an invalidation is not cached")
+
+	fetcher.err = errors.New("unavailable")
+	cache.Invalidate("0.0.200")
+	_, err = cache.Get("0.0.200")
+	assert.Error(t, err)
+}
+
+func TestCacheRequire(t *testing.T) {
+	fetcher := &fakeFetcher{balances: map[string]map[string]money.Amount{"0.0.200": {"0.0.500": 1000}}}
+	cache := NewCache(fetcher)
+
+	require.NoError(t, cache.Require("0.0.200", "0.0.500", 1000))
+	assert.ErrorIs(t, cache.Require("0.0.200", "0.0.500", 1001), ErrInsufficientBalance)
+	assert.Equal(t, 2, fetcher.calls, "a short cached balance is fetched again")
+
+	// A top-up made outside the API shows up without waiting for the TTL.
+	fetcher.balances["0.0.200"]["0.0.500"] = 5000
+	require.NoError(t, cache.Require("0.0.200", "0.0.500", 5000))
+}
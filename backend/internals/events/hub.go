@@ -0,0 +1,92 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// subscriberBuffer is how many events a slow stream may fall behind before
+// further events to it are dropped.
+const subscriberBuffer = 32
+
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// Hub is an in-process pub/sub for live dashboard updates. Events are keyed
+// by principal, see MerchantTopic and UserTopic. Nothing is persisted, so a
+// client only sees events published while it is subscribed.
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[chan Event]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subscribers: map[string]map[chan Event]struct{}{}}
+}
+
+func MerchantTopic(merchantID string) string {
+	return "merchant:" + merchantID
+}
+
+func UserTopic(userID string) string {
+	return "user:" + userID
+}
+
+// Subscribe returns a channel of events for topic and a function that must
+// be called to unsubscribe once the caller stops reading.
+func (h *Hub) Subscribe(topic string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.subscribers[topic] == nil {
+		h.subscribers[topic] = map[chan Event]struct{}{}
+	}
+	h.subscribers[topic][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers[topic], ch)
+			if len(h.subscribers[topic]) == 0 {
+				delete(h.subscribers, topic)
+			}
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish never blocks; subscribers whose buffer is full miss the event.
+func (h *Hub) Publish(topic string, eventType string, data any) {
+	event := Event{
+		ID:        uuid.NewString(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for ch := range h.subscribers[topic] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+func (h *Hub) PublishMerchant(merchantID string, eventType string, data any) {
+	h.Publish(MerchantTopic(merchantID), eventType, data)
+}
+
+func (h *Hub) PublishUser(userID string, eventType string, data any) {
+	h.Publish(UserTopic(userID), eventType, data)
+}
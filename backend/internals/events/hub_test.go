@@ -0,0 +1,46 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHubPublishesToTopicSubscribers(t *testing.T) {
+	hub := NewHub()
+	merchantEvents, unsubscribeMerchant := hub.Subscribe(MerchantTopic("m-1"))
+	defer unsubscribeMerchant()
+	userEvents, unsubscribeUser := hub.Subscribe(UserTopic("u-1"))
+	defer unsubscribeUser()
+
+	hub.PublishMerchant("m-1", "payment.completed", map[string]int{"amount": 100})
+
+	require.Len(t, merchantEvents, 1)
+	event := <-merchantEvents
+	assert.Equal(t, "payment.completed", event.Type)
+	assert.NotEmpty(t, event.ID)
+	assert.Len(t, userEvents, 0)
+}
+
+func TestHubUnsubscribe(t *testing.T) {
+	hub := NewHub()
+	ch, unsubscribe := hub.Subscribe(UserTopic("u-1"))
+	unsubscribe()
+	unsubscribe()
+
+	_, open := <-ch
+	assert.False(t, open)
+	assert.NotPanics(t, func() { hub.PublishUser("u-1", "campaign.joined", nil) })
+}
+
+func TestHubDropsWhenSubscriberIsFull(t *testing.T) {
+	hub := NewHub()
+	ch, unsubscribe := hub.Subscribe(MerchantTopic("m-1"))
+	defer unsubscribe()
+
+	for i := 0; i < subscriberBuffer+5; i++ {
+		hub.PublishMerchant("m-1", "payment.completed", i)
+	}
+	assert.Len(t, ch, subscriberBuffer)
+}
@@ -0,0 +1,168 @@
+package fees
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+// Quote is the fee for one transaction and the rule that set it. Rule is nil
+// when no rule applies, in which case the fee is zero.
+type Quote struct {
+	Fee  money.Amount
+	Rule *store.FeeRule
+}
+
+// Engine prices transactions from the fee rules in the database.
+type Engine struct {
+	Store store.FeeStore
+	now   func() time.Time
+}
+
+func NewEngine(feeStore store.FeeStore) *Engine {
+	return &Engine{Store: feeStore, now: time.Now}
+}
+
+// Quote prices a transactionType of amount for merchantID at the current
+// time.
+func (e *Engine) Quote(transactionType string, merchantID string, amount money.Amount) (*Quote, error) {
+	rules, err := e.Store.GetApplicableFeeRules(transactionType, merchantID, e.now())
+	if err != nil {
+		return nil, err
+	}
+	rule := Select(rules, merchantID)
+	if rule == nil {
+		return &Quote{}, nil
+	}
+	fee, err := Calculate(rule, amount)
+	if err != nil {
+		return nil, fmt.Errorf("applying fee rule %s: %w", rule.ID, err)
+	}
+	return &Quote{Fee: fee, Rule: rule}, nil
+}
+
+// Select picks the rule that wins among rules that all apply right now.
+// Promotions beat standing rules, a merchant's own rules beat platform rules
+// and otherwise the newest rule wins.
+func Select(rules []*store.FeeRule, merchantID string) *store.FeeRule {
+	if len(rules) == 0 {
+		return nil
+	}
+	ranked := append([]*store.FeeRule{}, rules...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.IsPromotion() != b.IsPromotion() {
+			return a.IsPromotion()
+		}
+		aOwn := a.MerchantID != "" && a.MerchantID == merchantID
+		bOwn := b.MerchantID != "" && b.MerchantID == merchantID
+		if aOwn != bOwn {
+			return aOwn
+		}
+		return a.CreatedAt.After(b.CreatedAt)
+	})
+	return ranked[0]
+}
+
+// Calculate applies rule to amount. Tiers charge their rate on the whole
+// amount, not just the part inside the tier. The result is then held between
+// MinFee and MaxFee.
+func Calculate(rule *store.FeeRule, amount money.Amount) (money.Amount, error) {
+	mode, err := money.ParseRoundingMode(rule.Rounding)
+	if err != nil {
+		return 0, err
+	}
+
+	var fee money.Amount
+	switch rule.Kind {
+	case store.FeeKindFree:
+		return 0, nil
+	case store.FeeKindFlat:
+		fee = rule.Flat
+	case store.FeeKindPercentage:
+		fee, err = amount.Percent(rule.BasisPoints, mode)
+	case store.FeeKindTiered:
+		tier := findTier(rule.Tiers, amount)
+		if tier == nil {
+			return 0, errors.New("no tier covers the amount")
+		}
+		fee, err = amount.Percent(tier.BasisPoints, mode)
+		if err == nil {
+			fee, err = fee.Add(tier.Flat)
+		}
+	default:
+		return 0, fmt.Errorf("unknown fee kind %q", rule.Kind)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if fee < rule.MinFee {
+		fee = rule.MinFee
+	}
+	if rule.MaxFee != nil && fee > *rule.MaxFee {
+		fee = *rule.MaxFee
+	}
+	return fee, nil
+}
+
+func findTier(tiers []store.FeeTier, amount money.Amount) *store.FeeTier {
+	for i := range tiers {
+		if tiers[i].UpTo == nil || amount <= *tiers[i].UpTo {
+			return &tiers[i]
+		}
+	}
+	return nil
+}
+
+// Validate checks a rule before it is saved, so Calculate cannot fail on it
+// for any amount.
+func Validate(rule *store.FeeRule) error {
+	if rule.Name == "" {
+		return errors.New("name is required")
+	}
+	switch rule.TransactionType {
+	case store.FeeTransactionPayment, store.FeeTransactionWithdrawal, store.FeeTransactionTransfer:
+	default:
+		return fmt.Errorf("transaction_type must be %s, %s or %s", store.FeeTransactionPayment, store.FeeTransactionWithdrawal, store.FeeTransactionTransfer)
+	}
+	if _, err := money.ParseRoundingMode(rule.Rounding); err != nil {
+		return err
+	}
+	if rule.Flat < 0 || rule.BasisPoints < 0 || rule.MinFee < 0 || rule.MaxFee != nil && *rule.MaxFee < rule.MinFee {
+		return errors.New("fees must not be negative and max_fee must not be below min_fee")
+	}
+	if rule.StartsAt != nil && rule.EndsAt != nil && !rule.EndsAt.After(*rule.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+
+	switch rule.Kind {
+	case store.FeeKindFree, store.FeeKindFlat, store.FeeKindPercentage:
+		if len(rule.Tiers) > 0 {
+			return errors.New("tiers are only allowed on tiered rules")
+		}
+	case store.FeeKindTiered:
+		if len(rule.Tiers) == 0 {
+			return errors.New("tiered rules need at least one tier")
+		}
+		for i, tier := range rule.Tiers {
+			if tier.Flat < 0 || tier.BasisPoints < 0 {
+				return errors.New("tier fees must not be negative")
+			}
+			last := i == len(rule.Tiers)-1
+			if tier.UpTo == nil && !last || tier.UpTo != nil && last {
+				return errors.New("the last tier must leave up_to unset and no other tier may")
+			}
+			if i > 0 && !last && *tier.UpTo <= *rule.Tiers[i-1].UpTo {
+				return errors.New("tier up_to values must increase")
+			}
+		}
+	default:
+		return fmt.Errorf("kind must be %s, %s, %s or %s", store.FeeKindFree, store.FeeKindFlat, store.FeeKindPercentage, store.FeeKindTiered)
+	}
+	return nil
+}
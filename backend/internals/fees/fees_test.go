@@ -0,0 +1,105 @@
+package fees
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+func amount(a money.Amount) *money.Amount {
+	return &a
+}
+
+// standard is the schedule seeded by the fee_rules migration.
+var standard = &store.FeeRule{
+	ID:              "standard",
+	Name:            "Standard payment fee",
+	TransactionType: store.FeeTransactionPayment,
+	Kind:            store.FeeKindTiered,
+	Tiers: []store.FeeTier{
+		{UpTo: amount(10000)},
+		{BasisPoints: 50},
+	},
+	Rounding: "down",
+}
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   *store.FeeRule
+		amount money.Amount
+		want   money.Amount
+	}{
+		{"standard free tier", standard, 10000, 0},
+		{"standard above free tier", standard, 10100, 50},
+		{"standard large", standard, 1000000, 5000},
+		{"flat", &store.FeeRule{Kind: store.FeeKindFlat, Flat: 2500, Rounding: "down"}, 100, 2500},
+		{"free", &store.FeeRule{Kind: store.FeeKindFree, MinFee: 100, Rounding: "down"}, 100000, 0},
+		{"percentage half up", &store.FeeRule{Kind: store.FeeKindPercentage, BasisPoints: 150, Rounding: "half_up"}, 1033, 15},
+		{"percentage min", &store.FeeRule{Kind: store.FeeKindPercentage, BasisPoints: 100, MinFee: 500, Rounding: "down"}, 1000, 500},
+		{"percentage max", &store.FeeRule{Kind: store.FeeKindPercentage, BasisPoints: 100, MaxFee: amount(2000), Rounding: "down"}, 1000000, 2000},
+		{"tier flat plus rate", &store.FeeRule{Kind: store.FeeKindTiered, Tiers: []store.FeeTier{{UpTo: amount(5000), Flat: 100}, {Flat: 200, BasisPoints: 100}}, Rounding: "up"}, 5001, 251},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee, err := Calculate(tt.rule, tt.amount)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, fee)
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	platform := &store.FeeRule{ID: "platform", CreatedAt: now.Add(-time.Hour)}
+	newerPlatform := &store.FeeRule{ID: "newer-platform", CreatedAt: now}
+	override := &store.FeeRule{ID: "override", MerchantID: "m-1", CreatedAt: now.Add(-2 * time.Hour)}
+	promotion := &store.FeeRule{ID: "promotion", EndsAt: &now, CreatedAt: now.Add(-3 * time.Hour)}
+
+	assert.Nil(t, Select(nil, "m-1"))
+	assert.Equal(t, "newer-platform", Select([]*store.FeeRule{platform, newerPlatform}, "m-1").ID)
+	assert.Equal(t, "override", Select([]*store.FeeRule{platform, newerPlatform, override}, "m-1").ID)
+	assert.Equal(t, "promotion", Select([]*store.FeeRule{platform, override, promotion}, "m-1").ID)
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *store.FeeRule {
+		return &store.FeeRule{Name: "n", TransactionType: store.FeeTransactionPayment, Kind: store.FeeKindPercentage, BasisPoints: 50, Rounding: "down"}
+	}
+	require.NoError(t, Validate(valid()))
+	require.NoError(t, Validate(standard))
+
+	tests := map[string]func(rule *store.FeeRule){
+		"missing name":       func(rule *store.FeeRule) { rule.Name = "" },
+		"unknown type":       func(rule *store.FeeRule) { rule.TransactionType = "refund" },
+		"unknown kind":       func(rule *store.FeeRule) { rule.Kind = "magic" },
+		"unknown rounding":   func(rule *store.FeeRule) { rule.Rounding = "nearest" },
+		"negative rate":      func(rule *store.FeeRule) { rule.BasisPoints = -1 },
+		"max below min":      func(rule *store.FeeRule) { rule.MinFee, rule.MaxFee = 100, amount(50) },
+		"tiers on flat rule": func(rule *store.FeeRule) { rule.Tiers = standard.Tiers },
+		"empty window": func(rule *store.FeeRule) {
+			at := time.Unix(1700000000, 0)
+			rule.StartsAt, rule.EndsAt = &at, &at
+		},
+		"bounded last tier": func(rule *store.FeeRule) {
+			rule.Kind, rule.BasisPoints = store.FeeKindTiered, 0
+			rule.Tiers = []store.FeeTier{{UpTo: amount(100)}}
+		},
+		"decreasing tiers": func(rule *store.FeeRule) {
+			rule.Kind, rule.BasisPoints = store.FeeKindTiered, 0
+			rule.Tiers = []store.FeeTier{{UpTo: amount(100)}, {UpTo: amount(50)}, {}}
+		},
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			rule := valid()
+			mutate(rule)
+			assert.Error(t, Validate(rule))
+		})
+	}
+}
@@ -0,0 +1,209 @@
+// Package fraud screens payments against the configurable rules in the
+// fraud_rules table before any money moves.
+//
+// Every active rule is checked and the payment gets the most severe action
+// among the rules it hits: allow lets it through but keeps a record for
+// review, challenge asks the customer to confirm with a code sent to their
+// mobile number, and block refuses it. Daily volume is counted over the
+// calendar day in Location.
+package fraud
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+// Location is East Africa Time, which has no daylight saving.
+var Location = time.FixedZone("EAT", 3*60*60)
+
+// Payment is what a rule sees of a payment about to be made. ShopID is empty
+// for split payments, which the unusual shop rule skips.
+type Payment struct {
+	User   *store.User
+	ShopID string
+	Amount money.Amount
+}
+
+// Result is the outcome of screening one payment and the rules it hit.
+type Result struct {
+	Outcome string
+	Hits    []*store.FraudRuleHit
+}
+
+// Engine screens payments with the active rules in the database.
+type Engine struct {
+	Store store.FraudStore
+	now   func() time.Time
+}
+
+func NewEngine(fraudStore store.FraudStore) *Engine {
+	return &Engine{Store: fraudStore, now: time.Now}
+}
+
+// Evaluate checks payment against every active rule.
+func (e *Engine) Evaluate(payment Payment) (*Result, error) {
+	rules, err := e.Store.GetActiveFraudRules()
+	if err != nil {
+		return nil, err
+	}
+
+	history := &history{store: e.Store, payment: payment}
+	now := e.now()
+	result := &Result{Outcome: store.FraudOutcomeAllow}
+	for _, rule := range rules {
+		detail, hit, err := check(rule, payment, history, now)
+		if err != nil {
+			return nil, fmt.Errorf("checking fraud rule %s: %w", rule.ID, err)
+		}
+		if !hit {
+			continue
+		}
+		result.Hits = append(result.Hits, &store.FraudRuleHit{RuleID: rule.ID, RuleName: rule.Name, Kind: rule.Kind, Action: rule.Action, Detail: detail})
+		if Severity(rule.Action) > Severity(result.Outcome) {
+			result.Outcome = rule.Action
+		}
+	}
+	return result, nil
+}
+
+// Severity orders outcomes so the strictest one wins.
+func Severity(outcome string) int {
+	switch outcome {
+	case store.FraudOutcomeChallenge:
+		return 1
+	case store.FraudOutcomeBlock:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// check reports whether payment trips rule and, if so, why.
+func check(rule *store.FraudRule, payment Payment, history *history, now time.Time) (string, bool, error) {
+	switch rule.Kind {
+	case store.FraudRuleDailyVolume:
+		local := now.In(Location)
+		volume, err := history.volume(time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, Location))
+		if err != nil {
+			return "", false, err
+		}
+		total, err := volume.Add(payment.Amount)
+		if err != nil || total > rule.AmountThreshold {
+			return fmt.Sprintf("paid KES %s today, KES %s with this payment, above KES %s", money.KES.Format(volume), money.KES.Format(total), money.KES.Format(rule.AmountThreshold)), true, nil
+		}
+	case store.FraudRuleVelocity:
+		count, err := history.count(now.Add(-rule.Window()))
+		if err != nil {
+			return "", false, err
+		}
+		if count+1 > rule.CountThreshold {
+			return fmt.Sprintf("payment %d within %s, above %d", count+1, rule.Window(), rule.CountThreshold), true, nil
+		}
+	case store.FraudRuleNewAccountLargePayment:
+		age := now.Sub(payment.User.CreatedAt)
+		if age < rule.Window() && payment.Amount > rule.AmountThreshold {
+			return fmt.Sprintf("account is %s old and paying KES %s, above KES %s", age.Truncate(time.Minute), money.KES.Format(payment.Amount), money.KES.Format(rule.AmountThreshold)), true, nil
+		}
+	case store.FraudRuleUnusualShop:
+		if payment.ShopID == "" || payment.Amount <= rule.AmountThreshold {
+			return "", false, nil
+		}
+		count, err := history.shopCount()
+		if err != nil {
+			return "", false, err
+		}
+		if count == 0 {
+			return fmt.Sprintf("first payment to this shop is KES %s, above KES %s", money.KES.Format(payment.Amount), money.KES.Format(rule.AmountThreshold)), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// history answers the questions rules ask about the paying user, asking the
+// store at most once per question since several rules may share one.
+type history struct {
+	store   store.FraudStore
+	payment Payment
+	volumes map[time.Time]money.Amount
+	counts  map[time.Time]int
+	shops   *int
+}
+
+func (h *history) volume(since time.Time) (money.Amount, error) {
+	if volume, ok := h.volumes[since]; ok {
+		return volume, nil
+	}
+	volume, err := h.store.GetUserPaymentVolume(h.payment.User.ID, since)
+	if err != nil {
+		return 0, err
+	}
+	if h.volumes == nil {
+		h.volumes = map[time.Time]money.Amount{}
+	}
+	h.volumes[since] = volume
+	return volume, nil
+}
+
+func (h *history) count(since time.Time) (int, error) {
+	if count, ok := h.counts[since]; ok {
+		return count, nil
+	}
+	count, err := h.store.CountUserPayments(h.payment.User.ID, since)
+	if err != nil {
+		return 0, err
+	}
+	if h.counts == nil {
+		h.counts = map[time.Time]int{}
+	}
+	h.counts[since] = count
+	return count, nil
+}
+
+func (h *history) shopCount() (int, error) {
+	if h.shops != nil {
+		return *h.shops, nil
+	}
+	count, err := h.store.CountUserShopPayments(h.payment.User.ID, h.payment.ShopID)
+	if err != nil {
+		return 0, err
+	}
+	h.shops = &count
+	return count, nil
+}
+
+// Validate checks a rule before it is saved.
+func Validate(rule *store.FraudRule) error {
+	if rule.Name == "" {
+		return errors.New("name is required")
+	}
+	switch rule.Action {
+	case store.FraudOutcomeAllow, store.FraudOutcomeChallenge, store.FraudOutcomeBlock:
+	default:
+		return fmt.Errorf("action must be %s, %s or %s", store.FraudOutcomeAllow, store.FraudOutcomeChallenge, store.FraudOutcomeBlock)
+	}
+	if rule.AmountThreshold < 0 || rule.CountThreshold < 0 || rule.WindowSeconds < 0 {
+		return errors.New("thresholds must not be negative")
+	}
+
+	switch rule.Kind {
+	case store.FraudRuleDailyVolume, store.FraudRuleUnusualShop:
+		if rule.CountThreshold != 0 || rule.WindowSeconds != 0 {
+			return fmt.Errorf("%s rules only use amount_threshold", rule.Kind)
+		}
+	case store.FraudRuleVelocity:
+		if rule.CountThreshold == 0 || rule.WindowSeconds == 0 || rule.AmountThreshold != 0 {
+			return errors.New("velocity rules need count_threshold and window_seconds and no amount_threshold")
+		}
+	case store.FraudRuleNewAccountLargePayment:
+		if rule.WindowSeconds == 0 || rule.CountThreshold != 0 {
+			return errors.New("new account rules need window_seconds and amount_threshold and no count_threshold")
+		}
+	default:
+		return fmt.Errorf("kind must be %s, %s, %s or %s", store.FraudRuleDailyVolume, store.FraudRuleVelocity, store.FraudRuleNewAccountLargePayment, store.FraudRuleUnusualShop)
+	}
+	return nil
+}
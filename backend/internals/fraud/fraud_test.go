@@ -0,0 +1,167 @@
+package fraud
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+type fakeFraudStore struct {
+	store.FraudStore
+	rules      []*store.FraudRule
+	volume     money.Amount
+	recent     int
+	shopCount  int
+	queries    int
+	volumeFrom time.Time
+}
+
+func (fs *fakeFraudStore) GetActiveFraudRules() ([]*store.FraudRule, error) {
+	return fs.rules, nil
+}
+
+func (fs *fakeFraudStore) GetUserPaymentVolume(userID string, since time.Time) (money.Amount, error) {
+	fs.queries++
+	fs.volumeFrom = since
+	return fs.volume, nil
+}
+
+func (fs *fakeFraudStore) CountUserPayments(userID string, since time.Time) (int, error) {
+	fs.queries++
+	return fs.recent, nil
+}
+
+func (fs *fakeFraudStore) CountUserShopPayments(userID string, shopID string) (int, error) {
+	fs.queries++
+	return fs.shopCount, nil
+}
+
+var now = time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+func newTestEngine(fraudStore *fakeFraudStore) *Engine {
+	return &Engine{Store: fraudStore, now: func() time.Time { return now }}
+}
+
+func oldUser() *store.User {
+	return &store.User{ID: "user-1", CreatedAt: now.AddDate(-1, 0, 0)}
+}
+
+func TestEvaluateTakesTheStrictestAction(t *testing.T) {
+	fraudStore := &fakeFraudStore{
+		rules: []*store.FraudRule{
+			{ID: "r1", Name: "volume challenge", Kind: store.FraudRuleDailyVolume, Action: store.FraudOutcomeChallenge, AmountThreshold: 10000000},
+			{ID: "r2", Name: "volume block", Kind: store.FraudRuleDailyVolume, Action: store.FraudOutcomeBlock, AmountThreshold: 30000000},
+			{ID: "r3", Name: "velocity", Kind: store.FraudRuleVelocity, Action: store.FraudOutcomeBlock, CountThreshold: 5, WindowSeconds: 60},
+		},
+		volume: 9000000,
+		recent: 2,
+	}
+	engine := newTestEngine(fraudStore)
+
+	result, err := engine.Evaluate(Payment{User: oldUser(), ShopID: "shop-1", Amount: 500000})
+	require.NoError(t, err)
+	assert.Equal(t, store.FraudOutcomeAllow, result.Outcome)
+	assert.Empty(t, result.Hits)
+
+	result, err = engine.Evaluate(Payment{User: oldUser(), ShopID: "shop-1", Amount: 2000000})
+	require.NoError(t, err)
+	assert.Equal(t, store.FraudOutcomeChallenge, result.Outcome)
+	require.Len(t, result.Hits, 1)
+	assert.Equal(t, "r1", result.Hits[0].RuleID)
+
+	result, err = engine.Evaluate(Payment{User: oldUser(), ShopID: "shop-1", Amount: 25000000})
+	require.NoError(t, err)
+	assert.Equal(t, store.FraudOutcomeBlock, result.Outcome)
+	assert.Len(t, result.Hits, 2)
+
+	// Volume is counted from midnight in Nairobi.
+	assert.Equal(t, time.Date(2026, 10, 18, 21, 0, 0, 0, time.UTC), fraudStore.volumeFrom.UTC())
+}
+
+func TestEvaluateVelocity(t *testing.T) {
+	fraudStore := &fakeFraudStore{
+		rules:  []*store.FraudRule{{ID: "r1", Kind: store.FraudRuleVelocity, Action: store.FraudOutcomeBlock, CountThreshold: 5, WindowSeconds: 60}},
+		recent: 4,
+	}
+	result, err := newTestEngine(fraudStore).Evaluate(Payment{User: oldUser(), Amount: 100})
+	require.NoError(t, err)
+	assert.Equal(t, store.FraudOutcomeAllow, result.Outcome)
+
+	fraudStore.recent = 5
+	result, err = newTestEngine(fraudStore).Evaluate(Payment{User: oldUser(), Amount: 100})
+	require.NoError(t, err)
+	assert.Equal(t, store.FraudOutcomeBlock, result.Outcome)
+}
+
+func TestEvaluateNewAccountLargePayment(t *testing.T) {
+	fraudStore := &fakeFraudStore{
+		rules: []*store.FraudRule{{ID: "r1", Kind: store.FraudRuleNewAccountLargePayment, Action: store.FraudOutcomeChallenge, AmountThreshold: 2000000, WindowSeconds: 7 * 24 * 60 * 60}},
+	}
+	engine := newTestEngine(fraudStore)
+	newUser := &store.User{ID: "user-2", CreatedAt: now.Add(-48 * time.Hour)}
+
+	result, err := engine.Evaluate(Payment{User: newUser, Amount: 2000000})
+	require.NoError(t, err)
+	assert.Equal(t, store.FraudOutcomeAllow, result.Outcome)
+
+	result, err = engine.Evaluate(Payment{User: newUser, Amount: 2000001})
+	require.NoError(t, err)
+	assert.Equal(t, store.FraudOutcomeChallenge, result.Outcome)
+
+	result, err = engine.Evaluate(Payment{User: oldUser(), Amount: 2000001})
+	require.NoError(t, err)
+	assert.Equal(t, store.FraudOutcomeAllow, result.Outcome)
+	assert.Zero(t, fraudStore.queries)
+}
+
+func TestEvaluateUnusualShop(t *testing.T) {
+	fraudStore := &fakeFraudStore{
+		rules: []*store.FraudRule{{ID: "r1", Kind: store.FraudRuleUnusualShop, Action: store.FraudOutcomeAllow, AmountThreshold: 5000000}},
+	}
+	engine := newTestEngine(fraudStore)
+
+	result, err := engine.Evaluate(Payment{User: oldUser(), ShopID: "shop-1", Amount: 6000000})
+	require.NoError(t, err)
+	assert.Equal(t, store.FraudOutcomeAllow, result.Outcome)
+	require.Len(t, result.Hits, 1, "allow rules are still recorded")
+
+	fraudStore.shopCount = 3
+	result, err = engine.Evaluate(Payment{User: oldUser(), ShopID: "shop-1", Amount: 6000000})
+	require.NoError(t, err)
+	assert.Empty(t, result.Hits)
+
+	// Split payments have no single shop to compare against.
+	fraudStore.shopCount = 0
+	result, err = engine.Evaluate(Payment{User: oldUser(), Amount: 6000000})
+	require.NoError(t, err)
+	assert.Empty(t, result.Hits)
+}
+
+func TestValidate(t *testing.T) {
+	valid := []*store.FraudRule{
+		{Name: "volume", Kind: store.FraudRuleDailyVolume, Action: store.FraudOutcomeBlock, AmountThreshold: 100},
+		{Name: "velocity", Kind: store.FraudRuleVelocity, Action: store.FraudOutcomeChallenge, CountThreshold: 3, WindowSeconds: 60},
+		{Name: "new account", Kind: store.FraudRuleNewAccountLargePayment, Action: store.FraudOutcomeChallenge, AmountThreshold: 100, WindowSeconds: 3600},
+		{Name: "unusual shop", Kind: store.FraudRuleUnusualShop, Action: store.FraudOutcomeAllow, AmountThreshold: 100},
+	}
+	for _, rule := range valid {
+		assert.NoError(t, Validate(rule), rule.Name)
+	}
+
+	invalid := []*store.FraudRule{
+		{Kind: store.FraudRuleDailyVolume, Action: store.FraudOutcomeBlock},
+		{Name: "bad action", Kind: store.FraudRuleDailyVolume, Action: "review"},
+		{Name: "bad kind", Kind: "country", Action: store.FraudOutcomeBlock},
+		{Name: "velocity without window", Kind: store.FraudRuleVelocity, Action: store.FraudOutcomeBlock, CountThreshold: 3},
+		{Name: "volume with count", Kind: store.FraudRuleDailyVolume, Action: store.FraudOutcomeBlock, AmountThreshold: 100, CountThreshold: 3},
+		{Name: "negative", Kind: store.FraudRuleUnusualShop, Action: store.FraudOutcomeBlock, AmountThreshold: -1},
+	}
+	for _, rule := range invalid {
+		assert.Error(t, Validate(rule), rule.Name)
+	}
+}
@@ -0,0 +1,161 @@
+// Package kyc decides which KYC level an account may apply for and enforces
+// the daily and monthly limits attached to each level.
+//
+// Users start at none and reach phone_verified by confirming their mobile
+// number. Both users and merchants reach id_verified by sending an identity
+// document, and merchants can go on to business_verified with their business
+// registration. Limits are counted over calendar days and months in
+// Location; a level with no limit row for an operation is not limited.
+package kyc
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+// Location is East Africa Time, which has no daylight saving.
+var Location = time.FixedZone("EAT", 3*60*60)
+
+// DocumentTypes lists the documents accepted for each level that is reached
+// by submission.
+var DocumentTypes = map[string][]string{
+	store.KYCLevelID:       {"national_id", "passport", "alien_id"},
+	store.KYCLevelBusiness: {"business_registration", "kra_pin"},
+}
+
+var (
+	ErrInvalidLevel        = errors.New("level must be id_verified or business_verified")
+	ErrInvalidDocumentType = errors.New("document type is not accepted for this level")
+	ErrAlreadyAtLevel      = errors.New("account is already verified at this level or above")
+	ErrPhoneRequired       = errors.New("verify your mobile number before submitting an identity document")
+	ErrIDRequired          = errors.New("complete ID verification before submitting business documents")
+)
+
+// ValidateSubmission checks that an account of subjectType at currentLevel
+// may apply for level with documentType.
+func ValidateSubmission(subjectType string, currentLevel string, level string, documentType string) error {
+	documents, ok := DocumentTypes[level]
+	if !ok || subjectType == store.KYCSubjectUser && level == store.KYCLevelBusiness {
+		return ErrInvalidLevel
+	}
+	if !slices.Contains(documents, documentType) {
+		return ErrInvalidDocumentType
+	}
+	if store.KYCRank(currentLevel) >= store.KYCRank(level) {
+		return ErrAlreadyAtLevel
+	}
+	switch {
+	case subjectType == store.KYCSubjectUser && currentLevel != store.KYCLevelPhone:
+		return ErrPhoneRequired
+	case level == store.KYCLevelBusiness && currentLevel != store.KYCLevelID:
+		return ErrIDRequired
+	}
+	return nil
+}
+
+// Windows returns the start of the day and of the month containing now, in
+// Location.
+func Windows(now time.Time) (time.Time, time.Time) {
+	local := now.In(Location)
+	dayStart := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, Location)
+	monthStart := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, Location)
+	return dayStart, monthStart
+}
+
+// LimitError reports an operation that would take an account over its
+// level's limit for Period, which is daily or monthly.
+type LimitError struct {
+	Level     string       `json:"level"`
+	Operation string       `json:"operation"`
+	Period    string       `json:"period"`
+	Limit     money.Amount `json:"limit"`
+	Remaining money.Amount `json:"remaining"`
+}
+
+func (le *LimitError) Error() string {
+	return fmt.Sprintf("%s %s limit for %s accounts reached, %s remaining", le.Period, le.Operation, le.Level, money.KES.Format(le.Remaining))
+}
+
+// CheckLimit returns a *LimitError when adding amount to usage would exceed
+// limit. A nil limit allows everything.
+func CheckLimit(limit *store.KYCLimit, usage *store.KYCUsage, amount money.Amount) error {
+	if limit == nil {
+		return nil
+	}
+	periods := []struct {
+		name  string
+		limit money.Amount
+		used  money.Amount
+	}{
+		{"daily", limit.DailyLimit, usage.Daily},
+		{"monthly", limit.MonthlyLimit, usage.Monthly},
+	}
+	for _, period := range periods {
+		if amount <= period.limit-period.used {
+			continue
+		}
+		remaining := max(period.limit-period.used, 0)
+		return &LimitError{Level: limit.Level, Operation: limit.Operation, Period: period.name, Limit: period.limit, Remaining: remaining}
+	}
+	return nil
+}
+
+// Status is an account's limit for one operation and how much of it is used.
+// Limit is nil when the operation is not limited at the account's level.
+type Status struct {
+	Operation string          `json:"operation"`
+	Limit     *store.KYCLimit `json:"limit"`
+	Usage     *store.KYCUsage `json:"usage"`
+}
+
+// Limiter enforces KYC limits against what accounts have already moved.
+type Limiter struct {
+	Store store.KYCStore
+	now   func() time.Time
+}
+
+func NewLimiter(kycStore store.KYCStore) *Limiter {
+	return &Limiter{Store: kycStore, now: time.Now}
+}
+
+// Check returns a *LimitError when subjectID, at level, may not move amount
+// through operation.
+func (l *Limiter) Check(subjectID string, level string, operation string, amount money.Amount) error {
+	status, err := l.status(subjectID, level, operation)
+	if err != nil {
+		return err
+	}
+	return CheckLimit(status.Limit, status.Usage, amount)
+}
+
+// Statuses reports the limits and usage of subjectID, at level, for each of
+// operations.
+func (l *Limiter) Statuses(subjectID string, level string, operations ...string) ([]*Status, error) {
+	statuses := make([]*Status, 0, len(operations))
+	for _, operation := range operations {
+		status, err := l.status(subjectID, level, operation)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (l *Limiter) status(subjectID string, level string, operation string) (*Status, error) {
+	limit, err := l.Store.GetKYCLimit(level, operation)
+	if err != nil {
+		return nil, err
+	}
+	dayStart, monthStart := Windows(l.now())
+	usage, err := l.Store.GetKYCUsage(subjectID, operation, dayStart, monthStart)
+	if err != nil {
+		return nil, err
+	}
+	return &Status{Operation: operation, Limit: limit, Usage: usage}, nil
+}
@@ -0,0 +1,87 @@
+package kyc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+func TestValidateSubmission(t *testing.T) {
+	tests := []struct {
+		name         string
+		subjectType  string
+		currentLevel string
+		level        string
+		documentType string
+		want         error
+	}{
+		{"user with phone submits id", store.KYCSubjectUser, store.KYCLevelPhone, store.KYCLevelID, "national_id", nil},
+		{"user without phone submits id", store.KYCSubjectUser, store.KYCLevelNone, store.KYCLevelID, "passport", ErrPhoneRequired},
+		{"user submits business", store.KYCSubjectUser, store.KYCLevelID, store.KYCLevelBusiness, "kra_pin", ErrInvalidLevel},
+		{"merchant submits id", store.KYCSubjectMerchant, store.KYCLevelNone, store.KYCLevelID, "national_id", nil},
+		{"merchant skips id", store.KYCSubjectMerchant, store.KYCLevelNone, store.KYCLevelBusiness, "business_registration", ErrIDRequired},
+		{"merchant submits business", store.KYCSubjectMerchant, store.KYCLevelID, store.KYCLevelBusiness, "business_registration", nil},
+		{"wrong document", store.KYCSubjectMerchant, store.KYCLevelID, store.KYCLevelBusiness, "passport", ErrInvalidDocumentType},
+		{"phone is not submitted", store.KYCSubjectUser, store.KYCLevelNone, store.KYCLevelPhone, "national_id", ErrInvalidLevel},
+		{"already verified", store.KYCSubjectMerchant, store.KYCLevelBusiness, store.KYCLevelID, "national_id", ErrAlreadyAtLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ValidateSubmission(tt.subjectType, tt.currentLevel, tt.level, tt.documentType))
+		})
+	}
+}
+
+func TestWindowsUseEastAfricaTime(t *testing.T) {
+	// 22:30 UTC on 31 October is already 1 November in Nairobi.
+	dayStart, monthStart := Windows(time.Date(2026, 10, 31, 22, 30, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2026, 10, 31, 21, 0, 0, 0, time.UTC), dayStart.UTC())
+	assert.Equal(t, time.Date(2026, 10, 31, 21, 0, 0, 0, time.UTC), monthStart.UTC())
+
+	dayStart, monthStart = Windows(time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2026, 10, 18, 21, 0, 0, 0, time.UTC), dayStart.UTC())
+	assert.Equal(t, time.Date(2026, 9, 30, 21, 0, 0, 0, time.UTC), monthStart.UTC())
+}
+
+func TestCheckLimit(t *testing.T) {
+	limit := &store.KYCLimit{Level: store.KYCLevelPhone, Operation: store.KYCOperationPayment, DailyLimit: 2000000, MonthlyLimit: 10000000}
+
+	assert.NoError(t, CheckLimit(nil, &store.KYCUsage{Daily: 1 << 40}, 1<<40))
+	assert.NoError(t, CheckLimit(limit, &store.KYCUsage{Daily: 1500000, Monthly: 1500000}, 500000))
+
+	err := CheckLimit(limit, &store.KYCUsage{Daily: 1500000, Monthly: 1500000}, 500001)
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "daily", limitErr.Period)
+	assert.EqualValues(t, 500000, limitErr.Remaining)
+
+	err = CheckLimit(limit, &store.KYCUsage{Daily: 0, Monthly: 9900000}, 200000)
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "monthly", limitErr.Period)
+	assert.EqualValues(t, 100000, limitErr.Remaining)
+
+	// Usage already over a lowered limit reports nothing remaining.
+	err = CheckLimit(limit, &store.KYCUsage{Daily: 2500000, Monthly: 2500000}, 100)
+	require.ErrorAs(t, err, &limitErr)
+	assert.EqualValues(t, 0, limitErr.Remaining)
+}
+
+func TestMockProvider(t *testing.T) {
+	provider := &MockProvider{}
+	tests := map[string]string{
+		"12345678":    store.KYCStatusApproved,
+		"reject-1234": store.KYCStatusRejected,
+		"REVIEW-1234": store.KYCStatusPending,
+	}
+	for number, want := range tests {
+		result, err := provider.Verify(context.Background(), &store.KYCSubmission{ID: "sub-1", DocumentNumber: number})
+		require.NoError(t, err)
+		assert.Equal(t, want, result.Status, number)
+		assert.Equal(t, "mock-sub-1", result.Reference)
+	}
+}
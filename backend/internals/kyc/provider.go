@@ -0,0 +1,59 @@
+package kyc
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+// Result is a provider's answer on a submission. Status is one of the
+// store.KYCStatus values; pending means the provider, or an admin, will
+// decide later.
+type Result struct {
+	Status    string
+	Reference string
+	Reason    string
+}
+
+// Provider checks identity and business documents. Implementations wrap a
+// verification service such as Smile ID; MockProvider is used locally.
+type Provider interface {
+	Name() string
+	Verify(ctx context.Context, submission *store.KYCSubmission) (*Result, error)
+}
+
+// MockProvider decides from the document number: numbers starting with
+// REJECT are rejected, numbers starting with REVIEW are left for an admin
+// and everything else is approved.
+type MockProvider struct{}
+
+func (mp *MockProvider) Name() string {
+	return "mock"
+}
+
+func (mp *MockProvider) Verify(ctx context.Context, submission *store.KYCSubmission) (*Result, error) {
+	result := &Result{Status: store.KYCStatusApproved, Reference: "mock-" + submission.ID}
+	number := strings.ToUpper(submission.DocumentNumber)
+	switch {
+	case strings.HasPrefix(number, "REJECT"):
+		result.Status = store.KYCStatusRejected
+		result.Reason = "document could not be verified"
+	case strings.HasPrefix(number, "REVIEW"):
+		result.Status = store.KYCStatusPending
+	}
+	return result, nil
+}
+
+// NewProviderFromEnv picks a Provider based on KYC_PROVIDER. Only the mock
+// provider ships with the backend, other providers plug in here.
+func NewProviderFromEnv(logger *log.Logger) Provider {
+	provider := os.Getenv("KYC_PROVIDER")
+	if provider != "" && provider != "mock" {
+		logger.Printf("WARNING: unknown KYC_PROVIDER %q, falling back to mock provider", provider)
+	}
+	logger.Println("WARNING: KYC documents are checked by the mock provider, which approves them automatically")
+	return &MockProvider{}
+}
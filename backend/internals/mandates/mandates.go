@@ -0,0 +1,191 @@
+// Package mandates collects recurring payments.
+//
+// A Scheduler polls for mandates that have fallen due and charges each one
+// through the normal payment path. A failed charge is retried with growing
+// delays; after MaxAttempts tries the period is given up and the mandate
+// moves on to the next one. A charge that fails with a Permanent error gives
+// up the period at once.
+package mandates
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+const (
+	// MaxAttempts is how many times one period's payment is tried.
+	MaxAttempts = 4
+
+	pollInterval = time.Minute
+	batchSize    = 20
+	// leaseTime must cover a charge, which waits on Hedera consensus and
+	// topic notifications.
+	leaseTime = 5 * time.Minute
+)
+
+// retryDelays is the wait after each failed attempt. Insufficient balance is
+// the usual cause, so the delays give the user time to top up.
+var retryDelays = []time.Duration{time.Hour, 6 * time.Hour, 24 * time.Hour}
+
+// Charger makes one mandate payment and returns the recorded transaction.
+type Charger interface {
+	ChargeMandate(mandate *store.Mandate) (*store.Transaction, error)
+}
+
+// PermanentError is a charge failure that trying again cannot fix, such as a
+// payment refused by policy or one whose funds have already moved.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// Permanent marks err so the scheduler does not retry the charge.
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+type Scheduler struct {
+	Store   store.MandateStore
+	Charger Charger
+	Logger  *log.Logger
+	now     func() time.Time
+}
+
+func NewScheduler(mandateStore store.MandateStore, charger Charger, logger *log.Logger) *Scheduler {
+	return &Scheduler{
+		Store:   mandateStore,
+		Charger: charger,
+		Logger:  logger,
+		now:     time.Now,
+	}
+}
+
+// Run collects due mandates every pollInterval until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := s.RunOnce()
+			if err != nil {
+				s.Logger.Printf("ERROR: error claiming due mandates at ClaimDueMandates: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce charges one batch of due mandates and returns how many it tried.
+func (s *Scheduler) RunOnce() (int, error) {
+	due, err := s.Store.ClaimDueMandates(batchSize, leaseTime)
+	if err != nil {
+		return 0, err
+	}
+	for _, mandate := range due {
+		s.collect(mandate)
+	}
+	return len(due), nil
+}
+
+func (s *Scheduler) collect(mandate *store.Mandate) {
+	run := &store.MandateRun{Cycle: mandate.Cycle, Attempt: mandate.Attempts + 1}
+
+	transaction, err := s.Charger.ChargeMandate(mandate)
+	if err == nil {
+		run.Status = store.MandateRunSucceeded
+		run.TransactionID = transaction.ID
+		mandate.PaymentsMade++
+		mandate.LastError = ""
+		Advance(mandate)
+	} else {
+		s.Logger.Printf("WARNING: mandate %s payment attempt %d failed: %v", mandate.ID, run.Attempt, err)
+		run.Status = store.MandateRunFailed
+		run.Error = err.Error()
+		mandate.LastError = err.Error()
+		var permanent *PermanentError
+		if errors.As(err, &permanent) {
+			Advance(mandate)
+		} else {
+			Retry(mandate, s.now())
+		}
+	}
+
+	err = s.Store.RecordMandateRun(mandate, run)
+	if err != nil {
+		s.Logger.Printf("ERROR: error recording mandate %s run at RecordMandateRun: %v", mandate.ID, err)
+	}
+}
+
+// DueAt returns when the given period falls due. Monthly mandates keep
+// their day of the month, falling back to the last day of shorter months.
+func DueAt(mandate *store.Mandate, cycle int) time.Time {
+	steps := cycle * mandate.IntervalCount
+	start := mandate.StartsAt
+	switch mandate.IntervalUnit {
+	case store.MandateIntervalDay:
+		return start.AddDate(0, 0, steps)
+	case store.MandateIntervalWeek:
+		return start.AddDate(0, 0, 7*steps)
+	default:
+		year, month, day := start.Date()
+		first := time.Date(year, month+time.Month(steps), 1, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+		last := first.AddDate(0, 1, -1).Day()
+		return first.AddDate(0, 0, min(day, last)-1)
+	}
+}
+
+// Advance moves the mandate to its next period and schedules it, or
+// completes it once MaxPayments is reached or the next payment would fall
+// after EndsAt.
+func Advance(mandate *store.Mandate) {
+	mandate.Cycle++
+	mandate.Attempts = 0
+	schedule(mandate)
+}
+
+// Retry schedules the next attempt after a failure at now. When the
+// attempts are used up, or the retry would run into the next period, the
+// current period is given up.
+func Retry(mandate *store.Mandate, now time.Time) {
+	mandate.Attempts++
+	if mandate.Attempts >= MaxAttempts {
+		Advance(mandate)
+		return
+	}
+	next := now.Add(retryDelays[mandate.Attempts-1])
+	if !next.Before(DueAt(mandate, mandate.Cycle+1)) {
+		Advance(mandate)
+		return
+	}
+	mandate.NextRunAt = &next
+}
+
+// Resume skips the periods that fell due before now, which are not charged
+// after a pause, and schedules the next one.
+func Resume(mandate *store.Mandate, now time.Time) {
+	mandate.Attempts = 0
+	for DueAt(mandate, mandate.Cycle).Before(now) {
+		mandate.Cycle++
+	}
+	schedule(mandate)
+}
+
+func schedule(mandate *store.Mandate) {
+	due := DueAt(mandate, mandate.Cycle)
+	if (mandate.MaxPayments > 0 && mandate.PaymentsMade >= mandate.MaxPayments) || (mandate.EndsAt != nil && due.After(*mandate.EndsAt)) {
+		mandate.Status = store.MandateStatusCompleted
+		mandate.NextRunAt = nil
+		return
+	}
+	mandate.NextRunAt = &due
+}
@@ -0,0 +1,159 @@
+package mandates
+
+import (
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+type fakeMandateStore struct {
+	store.MandateStore
+	due  []*store.Mandate
+	runs []*store.MandateRun
+}
+
+func (fs *fakeMandateStore) ClaimDueMandates(limit int, lease time.Duration) ([]*store.Mandate, error) {
+	due := fs.due
+	fs.due = nil
+	return due, nil
+}
+
+func (fs *fakeMandateStore) RecordMandateRun(mandate *store.Mandate, run *store.MandateRun) error {
+	fs.runs = append(fs.runs, run)
+	return nil
+}
+
+type fakeCharger struct {
+	err error
+}
+
+func (fc *fakeCharger) ChargeMandate(mandate *store.Mandate) (*store.Transaction, error) {
+	if fc.err != nil {
+		return nil, fc.err
+	}
+	return &store.Transaction{ID: "txn-1", Amount: mandate.Amount}, nil
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 9, 0, 0, 0, time.UTC)
+}
+
+func TestDueAt(t *testing.T) {
+	monthly := &store.Mandate{IntervalUnit: store.MandateIntervalMonth, IntervalCount: 1, StartsAt: date(2026, time.January, 31)}
+	assert.Equal(t, date(2026, time.January, 31), DueAt(monthly, 0))
+	assert.Equal(t, date(2026, time.February, 28), DueAt(monthly, 1))
+	assert.Equal(t, date(2026, time.March, 31), DueAt(monthly, 2))
+	assert.Equal(t, date(2027, time.January, 31), DueAt(monthly, 12))
+
+	termly := &store.Mandate{IntervalUnit: store.MandateIntervalMonth, IntervalCount: 3, StartsAt: date(2026, time.November, 30)}
+	assert.Equal(t, date(2027, time.February, 28), DueAt(termly, 1))
+
+	weekly := &store.Mandate{IntervalUnit: store.MandateIntervalWeek, IntervalCount: 2, StartsAt: date(2026, time.March, 2)}
+	assert.Equal(t, date(2026, time.March, 30), DueAt(weekly, 2))
+
+	daily := &store.Mandate{IntervalUnit: store.MandateIntervalDay, IntervalCount: 1, StartsAt: date(2026, time.March, 2)}
+	assert.Equal(t, date(2026, time.March, 5), DueAt(daily, 3))
+}
+
+func TestAdvanceCompletes(t *testing.T) {
+	mandate := &store.Mandate{IntervalUnit: store.MandateIntervalMonth, IntervalCount: 1, StartsAt: date(2026, time.January, 1), MaxPayments: 2, PaymentsMade: 2, Cycle: 1, Status: store.MandateStatusActive}
+	Advance(mandate)
+	assert.Equal(t, store.MandateStatusCompleted, mandate.Status)
+	assert.Nil(t, mandate.NextRunAt)
+
+	endsAt := date(2026, time.March, 15)
+	mandate = &store.Mandate{IntervalUnit: store.MandateIntervalMonth, IntervalCount: 1, StartsAt: date(2026, time.January, 1), EndsAt: &endsAt, Cycle: 1, Status: store.MandateStatusActive}
+	Advance(mandate)
+	require.NotNil(t, mandate.NextRunAt)
+	assert.Equal(t, date(2026, time.March, 1), *mandate.NextRunAt)
+	Advance(mandate)
+	assert.Equal(t, store.MandateStatusCompleted, mandate.Status)
+}
+
+func TestRetry(t *testing.T) {
+	now := date(2026, time.January, 1)
+	mandate := &store.Mandate{IntervalUnit: store.MandateIntervalMonth, IntervalCount: 1, StartsAt: now, Status: store.MandateStatusActive}
+
+	Retry(mandate, now)
+	assert.Equal(t, 1, mandate.Attempts)
+	assert.Equal(t, now.Add(time.Hour), *mandate.NextRunAt)
+
+	Retry(mandate, now)
+	Retry(mandate, now)
+	assert.Equal(t, 3, mandate.Attempts)
+	assert.Equal(t, now.Add(24*time.Hour), *mandate.NextRunAt)
+
+	Retry(mandate, now)
+	assert.Equal(t, 0, mandate.Attempts)
+	assert.Equal(t, 1, mandate.Cycle)
+	assert.Equal(t, date(2026, time.February, 1), *mandate.NextRunAt)
+
+	// A daily mandate cannot wait a day for its retry, so the period is
+	// given up instead.
+	daily := &store.Mandate{IntervalUnit: store.MandateIntervalDay, IntervalCount: 1, StartsAt: now, Attempts: 2, Status: store.MandateStatusActive}
+	Retry(daily, now)
+	assert.Equal(t, 1, daily.Cycle)
+	assert.Equal(t, 0, daily.Attempts)
+}
+
+func TestResumeSkipsMissedPeriods(t *testing.T) {
+	mandate := &store.Mandate{IntervalUnit: store.MandateIntervalWeek, IntervalCount: 1, StartsAt: date(2026, time.January, 5), Cycle: 1, Attempts: 2}
+	Resume(mandate, date(2026, time.February, 1))
+	assert.Equal(t, 4, mandate.Cycle)
+	assert.Equal(t, 0, mandate.Attempts)
+	assert.Equal(t, date(2026, time.February, 2), *mandate.NextRunAt)
+}
+
+func TestRunOnce(t *testing.T) {
+	now := date(2026, time.January, 1)
+	newMandate := func() *store.Mandate {
+		return &store.Mandate{ID: "m-1", Amount: 50000, IntervalUnit: store.MandateIntervalMonth, IntervalCount: 1, StartsAt: now, Status: store.MandateStatusActive}
+	}
+
+	fs := &fakeMandateStore{due: []*store.Mandate{newMandate()}}
+	scheduler := NewScheduler(fs, &fakeCharger{}, log.New(io.Discard, "", 0))
+	scheduler.now = func() time.Time { return now }
+	tried, err := scheduler.RunOnce()
+	require.NoError(t, err)
+	assert.Equal(t, 1, tried)
+	require.Len(t, fs.runs, 1)
+	assert.Equal(t, store.MandateRunSucceeded, fs.runs[0].Status)
+	assert.Equal(t, "txn-1", fs.runs[0].TransactionID)
+
+	mandate := newMandate()
+	fs = &fakeMandateStore{due: []*store.Mandate{mandate}}
+	scheduler = NewScheduler(fs, &fakeCharger{err: errors.New("insufficient token balance")}, log.New(io.Discard, "", 0))
+	scheduler.now = func() time.Time { return now }
+	_, err = scheduler.RunOnce()
+	require.NoError(t, err)
+	require.Len(t, fs.runs, 1)
+	assert.Equal(t, store.MandateRunFailed, fs.runs[0].Status)
+	assert.Equal(t, 1, fs.runs[0].Attempt)
+	assert.Equal(t, "insufficient token balance", mandate.LastError)
+	assert.Equal(t, now.Add(time.Hour), *mandate.NextRunAt)
+}
+
+func TestRunOnceGivesUpPermanentFailures(t *testing.T) {
+	now := date(2026, time.January, 1)
+	mandate := &store.Mandate{ID: "m-1", Amount: 50000, IntervalUnit: store.MandateIntervalMonth, IntervalCount: 1, StartsAt: now, Status: store.MandateStatusActive}
+	fs := &fakeMandateStore{due: []*store.Mandate{mandate}}
+	scheduler := NewScheduler(fs, &fakeCharger{err: Permanent(errors.New("payment was declined"))}, log.New(io.Discard, "", 0))
+	scheduler.now = func() time.Time { return now }
+
+	_, err := scheduler.RunOnce()
+	require.NoError(t, err)
+	require.Len(t, fs.runs, 1)
+	assert.Equal(t, store.MandateRunFailed, fs.runs[0].Status)
+	assert.Equal(t, "payment was declined", mandate.LastError)
+	assert.Equal(t, 1, mandate.Cycle)
+	assert.Equal(t, 0, mandate.Attempts)
+	assert.Equal(t, 0, mandate.PaymentsMade)
+	assert.Equal(t, date(2026, time.February, 1), *mandate.NextRunAt)
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+)
+
+// BootstrapAdminUsername is the actor recorded for requests made with the
+// shared ADMIN_API_TOKEN rather than an admin's own session.
+const BootstrapAdminUsername = "bootstrap"
+
+const AdminContextKey = contextKey("admin")
+
+// AdminMiddleware guards the platform admin API. Admins sign in for a
+// session token; the shared token in ADMIN_API_TOKEN also works so the
+// first admin can be created. The admin API is switched off while
+// ADMIN_API_TOKEN is unset.
+type AdminMiddleware struct {
+	Token      string
+	AdminStore store.AdminStore
+}
+
+func NewAdminMiddleware(token string, adminStore store.AdminStore) *AdminMiddleware {
+	return &AdminMiddleware{Token: token, AdminStore: adminStore}
+}
+
+func NewAdminMiddlewareFromEnv(adminStore store.AdminStore) *AdminMiddleware {
+	return NewAdminMiddleware(os.Getenv("ADMIN_API_TOKEN"), adminStore)
+}
+
+func SetAdmin(r *http.Request, admin *store.Admin) *http.Request {
+	ctx := context.WithValue(r.Context(), AdminContextKey, admin)
+	return r.WithContext(ctx)
+}
+
+// GetAdmin returns the admin making the request. Requests made with the
+// shared token carry an admin with no ID and BootstrapAdminUsername.
+func GetAdmin(r *http.Request) *store.Admin {
+	admin, ok := r.Context().Value(AdminContextKey).(*store.Admin)
+	if !ok {
+		panic("missing admin in request")
+	}
+	return admin
+}
+
+func (am *AdminMiddleware) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Vary", "Authorization")
+		if am.Token == "" {
+			utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "admin api is disabled"})
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(token), []byte(am.Token)) == 1 {
+			admin := &store.Admin{Username: BootstrapAdminUsername, Status: store.AdminStatusActive}
+			next.ServeHTTP(w, SetAdmin(r, admin))
+			return
+		}
+
+		admin, err := am.AdminStore.GetAdminToken(token)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": err.Error()})
+			return
+		}
+		if admin == nil {
+			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, SetAdmin(r, admin))
+	})
+}
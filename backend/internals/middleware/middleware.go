@@ -14,16 +14,22 @@ import (
 type MerchantMiddleware struct {
 	MerchantStore store.MerchantStore
 	UserStore store.UserStore
+	APIKeyStore store.APIKeyStore
+	MemberStore store.MerchantMemberStore
+	POSDeviceStore store.POSDeviceStore
 }
 
-func NewMerchantMiddleware(merchantStore store.MerchantStore, userStore store.UserStore) *MerchantMiddleware {
-	return &MerchantMiddleware{MerchantStore: merchantStore, UserStore: userStore}
+func NewMerchantMiddleware(merchantStore store.MerchantStore, userStore store.UserStore, apiKeyStore store.APIKeyStore, memberStore store.MerchantMemberStore, posDeviceStore store.POSDeviceStore) *MerchantMiddleware {
+	return &MerchantMiddleware{MerchantStore: merchantStore, UserStore: userStore, APIKeyStore: apiKeyStore, MemberStore: memberStore, POSDeviceStore: posDeviceStore}
 }
 
 type contextKey string
 
 const MerchantContextKey = contextKey("merchant")
 const UserContextKey = contextKey("user")
+const APIKeyContextKey = contextKey("api_key")
+const MemberContextKey = contextKey("member")
+const POSDeviceContextKey = contextKey("pos_device")
 
 func SetMerchant(r *http.Request, merchant *store.Merchant) *http.Request {
 	// ctx := context.WithValue(r.Context(), MerchantContextKey, merchant)
@@ -40,6 +46,39 @@ func GetMerchant(r *http.Request) *store.Merchant {
 	return merchant
 }
 
+// GetAPIKey returns the API key the merchant authenticated with, or nil for
+// a regular login token.
+func GetAPIKey(r *http.Request) *store.APIKey {
+	key, _ := r.Context().Value(APIKeyContextKey).(*store.APIKey)
+	return key
+}
+
+func SetAPIKey(r *http.Request, key *store.APIKey) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), APIKeyContextKey, key))
+}
+
+// GetMember returns the team member acting for the merchant, or nil for the
+// merchant's own login and API keys.
+func GetMember(r *http.Request) *store.MerchantMember {
+	member, _ := r.Context().Value(MemberContextKey).(*store.MerchantMember)
+	return member
+}
+
+func SetMember(r *http.Request, member *store.MerchantMember) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), MemberContextKey, member))
+}
+
+// GetPOSDevice returns the shop till the request came from, or nil for any
+// other credential.
+func GetPOSDevice(r *http.Request) *store.POSDevice {
+	device, _ := r.Context().Value(POSDeviceContextKey).(*store.POSDevice)
+	return device
+}
+
+func SetPOSDevice(r *http.Request, device *store.POSDevice) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), POSDeviceContextKey, device))
+}
+
 func GetUser(r *http.Request) *store.User {
 	user, ok := r.Context().Value(UserContextKey).(*store.User)
 	if !ok {
@@ -69,15 +108,72 @@ func (mm *MerchantMiddleware) Authenticate(next http.Handler) http.Handler {
 		}
 
 		tokenPlainText := headerParts[1]
+		if strings.HasPrefix(tokenPlainText, tokens.APIKeyPrefix) {
+			merchant, key, err := mm.APIKeyStore.GetMerchantByAPIKey(tokenPlainText)
+			if err != nil {
+				utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid api key"})
+				return
+			}
+			if merchant == nil {
+				utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "api key revoked or not found"})
+				return
+			}
+
+			if merchant.IsSuspended() {
+				utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "merchant account is suspended"})
+				return
+			}
+
+			r = SetAPIKey(SetMerchant(r, merchant), key)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if tokens.IsPOSDeviceToken(tokenPlainText) {
+			merchant, device, err := mm.POSDeviceStore.GetMerchantByPOSDeviceToken(tokenPlainText)
+			if err != nil {
+				utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid device token"})
+				return
+			}
+			if merchant == nil {
+				utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "device revoked or not found"})
+				return
+			}
+
+			if merchant.IsSuspended() {
+				utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "merchant account is suspended"})
+				return
+			}
+
+			r = SetPOSDevice(SetMerchant(r, merchant), device)
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		merchant, err := mm.MerchantStore.GetMerchantToken(tokens.ScopeAuthentication, tokenPlainText)
 		if err != nil {
 			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid token"})
 			return
 		}
+		if merchant == nil {
+			var member *store.MerchantMember
+			merchant, member, err = mm.MemberStore.GetMemberToken(tokens.ScopeAuthentication, tokenPlainText)
+			if err != nil {
+				utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid token"})
+				return
+			}
+			if member != nil {
+				r = SetMember(r, member)
+			}
+		}
 		if merchant == nil {
 			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "token expired or not found"})
 			return
 		}
+		if merchant.IsSuspended() {
+			utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "merchant account is suspended"})
+			return
+		}
 
 		r = SetMerchant(r, merchant)
 		next.ServeHTTP(w, r)
@@ -109,12 +205,18 @@ func (mm *MerchantMiddleware) AuthenticateUser(next http.Handler) http.Handler {
 			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "token expired or not found"})
 			return
 		}
+		if user.IsSuspended() {
+			utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "account is suspended"})
+			return
+		}
 
 		r = SetUser(r, user)
 		next.ServeHTTP(w, r)
 	})
 }
 
+// RequireAuthenticatedMerchant only admits logged in merchants. API keys are
+// limited to routes wrapped with RequireMerchantScope.
 func (mm *MerchantMiddleware) RequireAuthenticatedMerchant(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func (w http.ResponseWriter, r *http.Request) {
 		merchant := GetMerchant(r)
@@ -122,6 +224,59 @@ func (mm *MerchantMiddleware) RequireAuthenticatedMerchant(next http.HandlerFunc
 			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
 			return
 		}
+		if GetAPIKey(r) != nil {
+			utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "api keys cannot access this route"})
+			return
+		}
+		if GetPOSDevice(r) != nil {
+			utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "pos devices cannot access this route"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireMerchantScope admits logged in merchants and API keys granted scope.
+func (mm *MerchantMiddleware) RequireMerchantScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func (w http.ResponseWriter, r *http.Request) {
+		merchant := GetMerchant(r)
+		if merchant.IsAnonymous() {
+			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "unauthorized"})
+			return
+		}
+		if key := GetAPIKey(r); key != nil && !key.HasScope(scope) {
+			utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "api key is missing scope " + scope})
+			return
+		}
+		if GetPOSDevice(r) != nil {
+			utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "pos devices cannot access this route"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequirePOSDevice admits only registered shop tills. Cashier routes use it
+// so a device token cannot reach the rest of the merchant API.
+func (mm *MerchantMiddleware) RequirePOSDevice(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func (w http.ResponseWriter, r *http.Request) {
+		if GetPOSDevice(r) == nil {
+			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "a pos device token is required"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequirePermission stops team members whose role lacks permission. It only
+// applies to member logins, so it is used inside RequireAuthenticatedMerchant
+// or RequireMerchantScope, which handle everyone else.
+func (mm *MerchantMiddleware) RequirePermission(permission string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func (w http.ResponseWriter, r *http.Request) {
+		if member := GetMember(r); member != nil && !member.Can(permission) {
+			utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "your role does not allow " + permission})
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
@@ -135,4 +290,22 @@ func (mm *MerchantMiddleware) RequireAuthenticatedUser(next http.HandlerFunc) ht
 		}
 		next.ServeHTTP(w, r)
 	})
-}
\ No newline at end of file
+}
+// TokenFromQuery lets the browser EventSource API, which cannot set headers,
+// pass its token as ?access_token=. It only applies to event stream requests
+// and must run before request logging so the token never reaches the logs.
+func (mm *MerchantMiddleware) TokenFromQuery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		token := query.Get("access_token")
+		if token != "" && strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			query.Del("access_token")
+			r.URL.RawQuery = query.Encode()
+			r.RequestURI = r.URL.RequestURI()
+			if r.Header.Get("Authorization") == "" {
+				r.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
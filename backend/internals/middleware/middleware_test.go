@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/tokens"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeAPIKeyStore struct {
+	store.APIKeyStore
+	merchant *store.Merchant
+	key      *store.APIKey
+}
+
+func (fs *fakeAPIKeyStore) GetMerchantByAPIKey(plaintext string) (*store.Merchant, *store.APIKey, error) {
+	return fs.merchant, fs.key, nil
+}
+
+type fakePOSDeviceStore struct {
+	store.POSDeviceStore
+	merchant *store.Merchant
+	device   *store.POSDevice
+}
+
+func (fs *fakePOSDeviceStore) GetMerchantByPOSDeviceToken(plaintext string) (*store.Merchant, *store.POSDevice, error) {
+	return fs.merchant, fs.device, nil
+}
+
+var testMerchant = &store.Merchant{ID: "merchant-1", Username: "acme"}
+
+const (
+	testAPIKey         = tokens.APIKeyPrefix + "abcd1234_secret"
+	testPOSDeviceToken = tokens.POSDevicePrefix + "secret"
+)
+
+// serve runs a request with token through Authenticate and guard, and
+// reports the status and whether the final handler was reached.
+func serve(mm *MerchantMiddleware, token string, guard func(http.HandlerFunc) http.HandlerFunc) (int, bool) {
+	reached := false
+	handler := mm.Authenticate(guard(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr.Code, reached
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	scoped := func(mm *MerchantMiddleware, next http.HandlerFunc) http.HandlerFunc {
+		return mm.RequireMerchantScope(tokens.ScopePaymentsRead, next)
+	}
+
+	tests := []struct {
+		name     string
+		keys     *fakeAPIKeyStore
+		guard    func(mm *MerchantMiddleware, next http.HandlerFunc) http.HandlerFunc
+		wantCode int
+	}{
+		{
+			name:     "valid key with scope",
+			keys:     &fakeAPIKeyStore{merchant: testMerchant, key: &store.APIKey{Scopes: []string{tokens.ScopePaymentsRead}}},
+			guard:    scoped,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "revoked key",
+			keys:     &fakeAPIKeyStore{},
+			guard:    scoped,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "key missing scope",
+			keys:     &fakeAPIKeyStore{merchant: testMerchant, key: &store.APIKey{Scopes: []string{tokens.ScopePaymentsCreate}}},
+			guard:    scoped,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name: "key on a human only route",
+			keys: &fakeAPIKeyStore{merchant: testMerchant, key: &store.APIKey{Scopes: []string{tokens.ScopePaymentsRead}}},
+			guard: func(mm *MerchantMiddleware, next http.HandlerFunc) http.HandlerFunc {
+				return mm.RequireAuthenticatedMerchant(next)
+			},
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mm := NewMerchantMiddleware(nil, nil, tt.keys, nil, nil)
+			code, reached := serve(mm, testAPIKey, func(next http.HandlerFunc) http.HandlerFunc {
+				return tt.guard(mm, next)
+			})
+			assert.Equal(t, tt.wantCode, code)
+			assert.Equal(t, tt.wantCode == http.StatusOK, reached)
+		})
+	}
+}
+
+func TestPOSDevicesAreKeptOffMerchantRoutes(t *testing.T) {
+	devices := &fakePOSDeviceStore{merchant: testMerchant, device: &store.POSDevice{ID: "device-1", ShopID: "shop-1"}}
+	mm := NewMerchantMiddleware(nil, nil, nil, nil, devices)
+
+	code, reached := serve(mm, testPOSDeviceToken, func(next http.HandlerFunc) http.HandlerFunc {
+		return mm.RequireMerchantScope(tokens.ScopePaymentsRead, next)
+	})
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.False(t, reached)
+
+	code, reached = serve(mm, testPOSDeviceToken, mm.RequireAuthenticatedMerchant)
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.False(t, reached)
+
+	code, reached = serve(mm, testPOSDeviceToken, mm.RequirePOSDevice)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, reached, "the cashier routes still admit the device")
+}
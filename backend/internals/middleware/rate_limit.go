@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/ratelimit"
+	"github.com/divin3circle/orcus/backend/internals/utils"
+)
+
+const maxUsernameBodyBytes = 1 << 20
+
+type RateLimitMiddleware struct {
+	Store  ratelimit.Store
+	Logger *log.Logger
+}
+
+func NewRateLimitMiddleware(rateLimitStore ratelimit.Store, logger *log.Logger) *RateLimitMiddleware {
+	return &RateLimitMiddleware{Store: rateLimitStore, Logger: logger}
+}
+
+// LimitByIP applies limit to each client address separately. name keeps
+// buckets for different routes apart.
+func (rl *RateLimitMiddleware) LimitByIP(name string, limit ratelimit.Limit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := name + ":ip:" + ClientIP(r)
+			if !rl.allow(w, key, limit) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// LimitByUsername applies limit per "username" field in the JSON body. The
+// body is buffered and restored so the handler can decode it again.
+func (rl *RateLimitMiddleware) LimitByUsername(name string, limit ratelimit.Limit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxUsernameBodyBytes))
+			if err != nil {
+				utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			var req struct {
+				Username string `json:"username"`
+			}
+			_ = json.Unmarshal(body, &req)
+
+			if req.Username != "" {
+				key := name + ":username:" + strings.ToLower(req.Username)
+				if !rl.allow(w, key, limit) {
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (rl *RateLimitMiddleware) allow(w http.ResponseWriter, key string, limit ratelimit.Limit) bool {
+	allowed, wait, err := rl.Store.Take(key, limit)
+	if err != nil {
+		// fail open so a rate limit store outage doesn't take logins down
+		rl.Logger.Printf("ERROR: error taking rate limit token at Take: %v", err)
+		return true
+	}
+	if !allowed {
+		WriteTooManyRequests(w, wait)
+		return false
+	}
+	return true
+}
+
+// WriteTooManyRequests responds with 429 and a Retry-After header rounded up
+// to whole seconds.
+func WriteTooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	utils.WriteJSON(w, http.StatusTooManyRequests, utils.Envelope{"error": "too many requests", "retry_after": seconds})
+}
+
+// ClientIP returns the host part of RemoteAddr. Deployments behind a proxy
+// should add chi's RealIP middleware ahead of the limiter.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postUsername(handler http.Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestLimitByUsernameRestoresBody(t *testing.T) {
+	rl := NewRateLimitMiddleware(ratelimit.NewMemoryStore(), log.New(io.Discard, "", 0))
+
+	var got string
+	handler := rl.LimitByUsername("login", ratelimit.Limit{Burst: 5, Per: time.Minute})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		got = string(body)
+	}))
+
+	body := `{"username":"alice","password":"hunter22"}`
+	rr := postUsername(handler, body)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, body, got)
+}
+
+func TestLimitByUsernameIgnoresCase(t *testing.T) {
+	rl := NewRateLimitMiddleware(ratelimit.NewMemoryStore(), log.New(io.Discard, "", 0))
+	handler := rl.LimitByUsername("login", ratelimit.Limit{Burst: 1, Per: time.Minute})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rr := postUsername(handler, `{"username":"Alice"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = postUsername(handler, `{"username":"alice"}`)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code, "alice and Alice share a bucket")
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+
+	rr = postUsername(handler, `{"username":"bob"}`)
+	assert.Equal(t, http.StatusOK, rr.Code, "other usernames have their own bucket")
+}
@@ -0,0 +1,273 @@
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrOverflow      = errors.New("amount is out of range")
+	ErrInvalidAmount = errors.New("amount is not a valid decimal number")
+	ErrPrecision     = errors.New("amount has more decimal places than the currency allows")
+)
+
+// Currency describes how an Amount is scaled. Decimals is the number of minor
+// units digits, so an Amount of 1234 in a two decimal currency is 12.34.
+type Currency struct {
+	Code     string
+	Name     string
+	Decimals int
+}
+
+var (
+	// KES is the Kenyan shilling, which the KSH token tracks one to one with
+	// the same two decimals.
+	KES = Currency{Code: "KES", Name: "Kenyan Shilling", Decimals: 2}
+	// CampaignToken is the unit of campaign reward tokens, which shops mint
+	// with two decimals.
+	CampaignToken = Currency{Code: "CPT", Name: "Campaign Token", Decimals: 2}
+)
+
+// Scale is the number of minor units in one major unit.
+func (c Currency) Scale() int64 {
+	scale := int64(1)
+	for i := 0; i < c.Decimals; i++ {
+		scale *= 10
+	}
+	return scale
+}
+
+// FromMajor converts a whole number of major units, as API requests send
+// them, to an Amount.
+func (c Currency) FromMajor(major int64) (Amount, error) {
+	scale := c.Scale()
+	if major > 0 && major > (1<<63-1)/scale || major < 0 && major < -(1<<63-1)/scale {
+		return 0, ErrOverflow
+	}
+	return Amount(major * scale), nil
+}
+
+// Parse reads a decimal string such as "12.5" or "-0.05". It fails rather
+// than round when the string is more precise than the currency.
+func (c Currency) Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" && fraction == "" || strings.Trim(whole+fraction, "0123456789") != "" {
+		return 0, ErrInvalidAmount
+	}
+	if len(fraction) > c.Decimals {
+		if strings.TrimRight(fraction[c.Decimals:], "0") != "" {
+			return 0, ErrPrecision
+		}
+		fraction = fraction[:c.Decimals]
+	}
+	fraction += strings.Repeat("0", c.Decimals-len(fraction))
+	if whole == "" {
+		whole = "0"
+	}
+
+	minor, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return 0, ErrOverflow
+		}
+		return 0, ErrInvalidAmount
+	}
+	if negative {
+		minor = -minor
+	}
+	return Amount(minor), nil
+}
+
+// Format renders a as a plain decimal string, "12.34" or "-0.05".
+func (c Currency) Format(a Amount) string {
+	if c.Decimals == 0 {
+		return strconv.FormatInt(int64(a), 10)
+	}
+	sign := ""
+	digits := strconv.FormatInt(int64(a), 10)
+	if a < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	if len(digits) <= c.Decimals {
+		digits = strings.Repeat("0", c.Decimals-len(digits)+1) + digits
+	}
+	split := len(digits) - c.Decimals
+	return sign + digits[:split] + "." + digits[split:]
+}
+
+// RoundingMode says how a result that falls between two minor units is
+// settled. Every operation that can produce one takes a mode explicitly.
+type RoundingMode int
+
+const (
+	// RoundDown truncates toward zero.
+	RoundDown RoundingMode = iota
+	// RoundUp rounds away from zero.
+	RoundUp
+	// RoundHalfUp rounds to the nearest unit, ties away from zero.
+	RoundHalfUp
+	// RoundHalfEven rounds to the nearest unit, ties to the even one.
+	RoundHalfEven
+)
+
+func (m RoundingMode) String() string {
+	switch m {
+	case RoundDown:
+		return "down"
+	case RoundUp:
+		return "up"
+	case RoundHalfUp:
+		return "half_up"
+	case RoundHalfEven:
+		return "half_even"
+	}
+	return fmt.Sprintf("RoundingMode(%d)", int(m))
+}
+
+// ParseRoundingMode is the inverse of RoundingMode.String.
+func ParseRoundingMode(s string) (RoundingMode, error) {
+	for _, mode := range []RoundingMode{RoundDown, RoundUp, RoundHalfUp, RoundHalfEven} {
+		if mode.String() == s {
+			return mode, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown rounding mode %q", s)
+}
+
+// Amount is a quantity of money in a currency's minor unit, cents for KES.
+// Its JSON and SQL forms are the plain integer.
+type Amount int64
+
+// Add returns a + b, failing instead of wrapping around.
+func (a Amount) Add(b Amount) (Amount, error) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, ErrOverflow
+	}
+	return sum, nil
+}
+
+// Sub returns a - b, failing instead of wrapping around.
+func (a Amount) Sub(b Amount) (Amount, error) {
+	if b == -1<<63 {
+		return 0, ErrOverflow
+	}
+	return a.Add(-b)
+}
+
+// MulRate returns a * numerator / denominator rounded with mode. The
+// intermediate product is exact, so no precision is lost before rounding.
+func (a Amount) MulRate(numerator int64, denominator int64, mode RoundingMode) (Amount, error) {
+	if denominator == 0 {
+		return 0, errors.New("rate denominator must not be zero")
+	}
+	product := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(numerator))
+	result := divide(product, big.NewInt(denominator), mode)
+	if !result.IsInt64() {
+		return 0, ErrOverflow
+	}
+	return Amount(result.Int64()), nil
+}
+
+// Percent returns basisPoints hundredths of a percent of a, so 50 is 0.5%.
+func (a Amount) Percent(basisPoints int64, mode RoundingMode) (Amount, error) {
+	return a.MulRate(basisPoints, 10000, mode)
+}
+
+// Allocate splits a in proportion to weights. The parts always sum to a: the
+// minor units left over after rounding every share down go one each to the
+// shares with the largest remainders, earliest first on ties.
+func (a Amount) Allocate(weights ...int64) ([]Amount, error) {
+	if len(weights) == 0 {
+		return nil, errors.New("at least one weight is required")
+	}
+	total := new(big.Int)
+	for _, weight := range weights {
+		if weight < 0 {
+			return nil, errors.New("weights must not be negative")
+		}
+		total.Add(total, big.NewInt(weight))
+	}
+	if total.Sign() == 0 {
+		return nil, errors.New("weights must not all be zero")
+	}
+
+	parts := make([]Amount, len(weights))
+	remainders := make([]*big.Int, len(weights))
+	allocated := Amount(0)
+	for i, weight := range weights {
+		share := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(weight))
+		quotient, remainder := new(big.Int).QuoRem(share, total, new(big.Int))
+		parts[i] = Amount(quotient.Int64())
+		remainders[i] = remainder.Abs(remainder)
+		allocated += parts[i]
+	}
+
+	step := Amount(1)
+	if a < 0 {
+		step = -1
+	}
+	for left := a - allocated; left != 0; left -= step {
+		largest := -1
+		for i, remainder := range remainders {
+			if remainder.Sign() > 0 && (largest == -1 || remainder.Cmp(remainders[largest]) > 0) {
+				largest = i
+			}
+		}
+		parts[largest] += step
+		remainders[largest].SetInt64(0)
+	}
+	return parts, nil
+}
+
+func (a Amount) Value() (driver.Value, error) {
+	return int64(a), nil
+}
+
+func (a *Amount) Scan(src any) error {
+	switch v := src.(type) {
+	case int64:
+		*a = Amount(v)
+	case nil:
+		*a = 0
+	default:
+		return fmt.Errorf("cannot scan %T into money.Amount", src)
+	}
+	return nil
+}
+
+// divide rounds n / d with mode.
+func divide(n *big.Int, d *big.Int, mode RoundingMode) *big.Int {
+	quotient, remainder := new(big.Int).QuoRem(n, d, new(big.Int))
+	if remainder.Sign() == 0 {
+		return quotient
+	}
+
+	// away is the direction of the exact result from the truncated one.
+	away := big.NewInt(int64(n.Sign() * d.Sign()))
+	twice := new(big.Int).Abs(remainder)
+	twice.Lsh(twice, 1)
+	half := twice.Cmp(new(big.Int).Abs(d))
+
+	switch mode {
+	case RoundUp:
+		return quotient.Add(quotient, away)
+	case RoundHalfUp:
+		if half >= 0 {
+			return quotient.Add(quotient, away)
+		}
+	case RoundHalfEven:
+		if half > 0 || half == 0 && quotient.Bit(0) == 1 {
+			return quotient.Add(quotient, away)
+		}
+	}
+	return quotient
+}
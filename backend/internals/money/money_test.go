@@ -0,0 +1,165 @@
+package money
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMulRateRounding(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount Amount
+		mode   RoundingMode
+		want   Amount
+	}{
+		// 0.5% of 101.00 is 50.5 cents.
+		{"tie down", 10100, RoundDown, 50},
+		{"tie up", 10100, RoundUp, 51},
+		{"tie half up", 10100, RoundHalfUp, 51},
+		{"tie half even to even", 10100, RoundHalfEven, 50},
+		{"tie half even from odd", 10300, RoundHalfEven, 52},
+		// 0.5% of 100.99 is 50.495 cents.
+		{"below tie half up", 10099, RoundHalfUp, 50},
+		{"below tie up", 10099, RoundUp, 51},
+		// 0.5% of 101.01 is 50.505 cents.
+		{"above tie half even", 10101, RoundHalfEven, 51},
+		{"above tie down", 10101, RoundDown, 50},
+		{"exact", 20000, RoundUp, 100},
+		{"negative tie down", -10100, RoundDown, -50},
+		{"negative tie up", -10100, RoundUp, -51},
+		{"negative tie half up", -10100, RoundHalfUp, -51},
+		{"negative tie half even", -10100, RoundHalfEven, -50},
+		{"zero", 0, RoundUp, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.amount.Percent(50, tt.mode)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestPercentNoDrift checks every whole shilling payment up to 100,000 against
+// the exact rational result, which float64 math drifts from.
+func TestPercentNoDrift(t *testing.T) {
+	for shillings := int64(1); shillings <= 100000; shillings++ {
+		amount, err := KES.FromMajor(shillings)
+		require.NoError(t, err)
+
+		fee, err := amount.Percent(50, RoundDown)
+		require.NoError(t, err)
+		// 0.5% of n shillings is n/2 cents exactly.
+		require.Equal(t, Amount(shillings/2), fee, "%d shillings", shillings)
+
+		net, err := amount.Sub(fee)
+		require.NoError(t, err)
+		total, err := net.Add(fee)
+		require.NoError(t, err)
+		require.Equal(t, amount, total)
+	}
+}
+
+func TestMulRateLargeAmounts(t *testing.T) {
+	largest := Amount(1<<63 - 1)
+	half, err := largest.MulRate(1, 2, RoundDown)
+	require.NoError(t, err)
+	assert.Equal(t, Amount((1<<63-1)/2), half, "the product is not allowed to overflow")
+
+	_, err = largest.MulRate(3, 2, RoundDown)
+	assert.ErrorIs(t, err, ErrOverflow)
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  Amount
+		weights []int64
+		want    []Amount
+	}{
+		{"even", 300, []int64{1, 1, 1}, []Amount{100, 100, 100}},
+		{"leftover to largest remainder", 100, []int64{1, 1, 1}, []Amount{34, 33, 33}},
+		{"weighted", 1001, []int64{70, 30}, []Amount{701, 300}},
+		{"zero weight", 500, []int64{0, 1}, []Amount{0, 500}},
+		{"negative", -100, []int64{1, 1, 1}, []Amount{-34, -33, -33}},
+		{"one cent", 1, []int64{1, 1}, []Amount{1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts, err := tt.amount.Allocate(tt.weights...)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, parts)
+		})
+	}
+
+	for total := Amount(0); total < 1000; total++ {
+		parts, err := total.Allocate(3, 5, 7, 11)
+		require.NoError(t, err)
+		sum := Amount(0)
+		for _, part := range parts {
+			sum += part
+		}
+		require.Equal(t, total, sum)
+	}
+
+	_, err := Amount(100).Allocate(0, 0)
+	assert.Error(t, err)
+}
+
+func TestParseAndFormat(t *testing.T) {
+	tests := []struct {
+		input     string
+		want      Amount
+		formatted string
+	}{
+		{"12.34", 1234, "12.34"},
+		{"12.3", 1230, "12.30"},
+		{"12", 1200, "12.00"},
+		{"0.05", 5, "0.05"},
+		{".5", 50, "0.50"},
+		{"-0.05", -5, "-0.05"},
+		{"+7.10", 710, "7.10"},
+		{"1.2300", 123, "1.23"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := KES.Parse(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.formatted, KES.Format(got))
+		})
+	}
+
+	invalid := []struct {
+		input string
+		err   error
+	}{
+		{"1.234", ErrPrecision},
+		{"", ErrInvalidAmount},
+		{".", ErrInvalidAmount},
+		{"1.2.3", ErrInvalidAmount},
+		{"--1", ErrInvalidAmount},
+		{"1e3", ErrInvalidAmount},
+		{"99999999999999999999", ErrOverflow},
+	}
+	for _, tt := range invalid {
+		_, err := KES.Parse(tt.input)
+		assert.ErrorIs(t, err, tt.err, tt.input)
+	}
+}
+
+func TestFromMajorAndArithmeticOverflow(t *testing.T) {
+	amount, err := KES.FromMajor(150)
+	require.NoError(t, err)
+	assert.Equal(t, Amount(15000), amount)
+
+	_, err = KES.FromMajor(1 << 62)
+	assert.ErrorIs(t, err, ErrOverflow)
+
+	_, err = Amount(1<<63 - 1).Add(1)
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = Amount(-1 << 63).Sub(1)
+	assert.ErrorIs(t, err, ErrOverflow)
+}
@@ -0,0 +1,202 @@
+package notifications
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+)
+
+// SchemaVersion is bumped whenever a field changes meaning or is removed.
+// Adding optional fields does not bump it.
+const SchemaVersion = 1
+
+const (
+	TypeTransaction     = "transaction"
+	TypeWithdrawal      = "withdrawal"
+	TypeShopCreated     = "shop_created"
+	TypeCampaignCreated = "campaign_created"
+	TypeJoinedCampaign  = "joined_campaign"
+	TypeAccount         = "account"
+	TypeBuy             = "buy"
+	TypeSend            = "send"
+	TypeAirdrop         = "airdrop"
+	TypeJoin            = "join"
+	TypeUpdate          = "update"
+	TypeRefund          = "refund"
+	TypeReceive         = "receive"
+	// TypeWithdrawalApproval announces a withdrawal waiting on approvers.
+	TypeWithdrawalApproval = "withdrawal_approval"
+)
+
+// EncryptionAlgorithm identifies how Encrypted.Ciphertext was produced.
+const EncryptionAlgorithm = "A256GCM"
+
+// Schema is the JSON schema for Message, served at /notifications/schema.
+//
+//go:embed schema.json
+var Schema []byte
+
+// Message is the JSON document submitted to a merchant or user HCS topic.
+// Type, MessageContent and Timestamp are kept from the unversioned format so
+// older clients keep rendering. When encryption is enabled Data is sent as
+// Encrypted instead.
+type Message struct {
+	Version        int        `json:"version"`
+	Type           string     `json:"type"`
+	MessageContent string     `json:"message_content"`
+	Timestamp      int64      `json:"timestamp"`
+	Data           *Payload   `json:"data,omitempty"`
+	Encrypted      *Encrypted `json:"encrypted,omitempty"`
+}
+
+// Payload carries the event details. Amounts are in token minor units.
+// Which fields are set depends on Type; see schema.json.
+type Payload struct {
+	TransactionID       string       `json:"transaction_id,omitempty"`
+	HederaTransactionID string       `json:"hedera_transaction_id,omitempty"`
+	Amount              money.Amount `json:"amount,omitempty"`
+	Fee                 money.Amount `json:"fee,omitempty"`
+	TokenID             string       `json:"token_id,omitempty"`
+	AccountID           string       `json:"account_id,omitempty"`
+	ShopID              string       `json:"shop_id,omitempty"`
+	ShopName            string       `json:"shop_name,omitempty"`
+	CounterpartID       string       `json:"counterpart_id,omitempty"`
+	CounterpartName     string       `json:"counterpart_name,omitempty"`
+	CampaignID          string       `json:"campaign_id,omitempty"`
+	CampaignName        string       `json:"campaign_name,omitempty"`
+	Receiver            string       `json:"receiver,omitempty"`
+}
+
+// Encrypted is an AES-256-GCM sealed Payload. Nonce and Ciphertext are
+// standard base64.
+type Encrypted struct {
+	Algorithm  string `json:"alg"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// MasterKeyFromEnv reads NOTIFICATION_ENCRYPTION_KEY, a base64 encoded
+// 32-byte key. It returns nil when unset, which disables encryption.
+func MasterKeyFromEnv() ([]byte, error) {
+	raw := os.Getenv("NOTIFICATION_ENCRYPTION_KEY")
+	if raw == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("decoding NOTIFICATION_ENCRYPTION_KEY: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, errors.New("NOTIFICATION_ENCRYPTION_KEY must be 32 bytes")
+	}
+	return key, nil
+}
+
+// TopicKey derives the per-topic key from the master key so one leaked
+// topic key doesn't expose every other topic.
+func TopicKey(masterKey []byte, topicID string) []byte {
+	mac := hmac.New(sha256.New, masterKey)
+	mac.Write([]byte("orcus-notifications:" + topicID))
+	return mac.Sum(nil)
+}
+
+// NewMessage builds a message for topicID, sealing data with the topic key
+// when masterKey is set.
+func NewMessage(messageType string, messageContent string, data *Payload, topicID string, masterKey []byte) (*Message, error) {
+	message := &Message{
+		Version:        SchemaVersion,
+		Type:           messageType,
+		MessageContent: messageContent,
+		Timestamp:      time.Now().Unix(),
+	}
+	if data == nil {
+		return message, nil
+	}
+	if masterKey == nil {
+		message.Data = data
+		return message, nil
+	}
+
+	encrypted, err := Encrypt(TopicKey(masterKey, topicID), data)
+	if err != nil {
+		return nil, err
+	}
+	message.Encrypted = encrypted
+	return message, nil
+}
+
+func Encrypt(key []byte, data *Payload) (*Encrypted, error) {
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Encrypted{
+		Algorithm:  EncryptionAlgorithm,
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, plaintext, nil)),
+	}, nil
+}
+
+// Decrypt is the inverse of Encrypt, used by clients holding the topic key.
+func Decrypt(key []byte, encrypted *Encrypted) (*Payload, error) {
+	if encrypted.Algorithm != EncryptionAlgorithm {
+		return nil, fmt.Errorf("unsupported algorithm %q", encrypted.Algorithm)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(encrypted.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypted.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid nonce")
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &Payload{}
+	err = json.Unmarshal(plaintext, data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,46 @@
+package notifications
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMessagePlain(t *testing.T) {
+	data := &Payload{TransactionID: "txn-1", Amount: 2500, ShopID: "shop-1"}
+	message, err := NewMessage(TypeTransaction, "Payment received", data, "0.0.1234", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, SchemaVersion, message.Version)
+	assert.Equal(t, data, message.Data)
+	assert.Nil(t, message.Encrypted)
+}
+
+func TestNewMessageEncrypted(t *testing.T) {
+	masterKey := make([]byte, 32)
+	data := &Payload{TransactionID: "txn-1", Amount: 2500, ShopID: "shop-1"}
+	message, err := NewMessage(TypeTransaction, "Payment received", data, "0.0.1234", masterKey)
+	require.NoError(t, err)
+
+	require.NotNil(t, message.Encrypted)
+	assert.Nil(t, message.Data)
+
+	raw, err := json.Marshal(message)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "txn-1")
+
+	opened, err := Decrypt(TopicKey(masterKey, "0.0.1234"), message.Encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, data, opened)
+
+	_, err = Decrypt(TopicKey(masterKey, "0.0.9999"), message.Encrypted)
+	assert.Error(t, err, "another topic's key must not open the payload")
+}
+
+func TestSchemaIsJSON(t *testing.T) {
+	var schema map[string]any
+	require.NoError(t, json.Unmarshal(Schema, &schema))
+	assert.Equal(t, "https://json-schema.org/draft/2020-12/schema", schema["$schema"])
+}
@@ -0,0 +1,40 @@
+package notifications
+
+import (
+	hiero "github.com/hiero-ledger/hiero-sdk-go/v2/sdk"
+)
+
+// TopicReceipt identifies a message once the network has reached consensus
+// on it.
+type TopicReceipt struct {
+	TopicID             string
+	SequenceNumber      uint64
+	HederaTransactionID string
+}
+
+// SubmitTopicMessage submits message to topicID and waits for its receipt.
+func SubmitTopicMessage(client *hiero.Client, topicID string, message []byte) (*TopicReceipt, error) {
+	topicIDObj, err := hiero.TopicIDFromString(topicID)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := hiero.NewTopicMessageSubmitTransaction().
+		SetMessage(message).
+		SetTopicID(topicIDObj).
+		Execute(client)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt, err := response.GetReceipt(client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TopicReceipt{
+		TopicID:             topicIDObj.String(),
+		SequenceNumber:      receipt.TopicSequenceNumber,
+		HederaTransactionID: response.TransactionID.String(),
+	}, nil
+}
@@ -0,0 +1,138 @@
+// Package pos builds the end-of-day summary (Z-report) a shop's till prints
+// when it closes.
+//
+// Days run midnight to midnight in Location. Payments count on the day they
+// were made and refunds on the day they completed, so a refund of last
+// week's sale lowers today's net rather than rewriting an old report.
+package pos
+
+import (
+	"errors"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+// DateLayout is the form of the date query parameter, e.g. 2026-10-19.
+const DateLayout = "2006-01-02"
+
+// Location is East Africa Time, which has no daylight saving.
+var Location = time.FixedZone("EAT", 3*60*60)
+
+var ErrInvalidDate = errors.New("date must be formatted as YYYY-MM-DD")
+
+// BusinessDay returns the bounds [from, to) of date in Location, or of the
+// day containing now when date is empty.
+func BusinessDay(date string, now time.Time) (time.Time, time.Time, error) {
+	var day time.Time
+	if date == "" {
+		local := now.In(Location)
+		day = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, Location)
+	} else {
+		parsed, err := time.ParseInLocation(DateLayout, date, Location)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidDate
+		}
+		day = parsed
+	}
+	return day, day.AddDate(0, 0, 1), nil
+}
+
+type HourTotal struct {
+	Hour   int          `json:"hour"`
+	Count  int          `json:"count"`
+	Amount money.Amount `json:"amount"`
+}
+
+type ZReport struct {
+	ShopID         string       `json:"shop_id"`
+	ShopName       string       `json:"shop_name"`
+	Date           string       `json:"date"`
+	From           time.Time    `json:"from"`
+	To             time.Time    `json:"to"`
+	Closed         bool         `json:"closed"`
+	PaymentCount   int          `json:"payment_count"`
+	Gross          money.Amount `json:"gross"`
+	CustomerFees   money.Amount `json:"customer_fees"`
+	RefundCount    int          `json:"refund_count"`
+	Refunded       money.Amount `json:"refunded"`
+	Net            money.Amount `json:"net"`
+	AveragePayment money.Amount `json:"average_payment"`
+	FirstPaymentAt *time.Time   `json:"first_payment_at"`
+	LastPaymentAt  *time.Time   `json:"last_payment_at"`
+	Hourly         []HourTotal  `json:"hourly"`
+	GeneratedAt    time.Time    `json:"generated_at"`
+}
+
+// BuildZReport totals the shop's payments and completed refunds for the day
+// [from, to). Split payment parents are skipped since each shop's part is
+// its own payment. Closed stays false until the day has ended.
+func BuildZReport(shop *store.Shop, from time.Time, to time.Time, transactions []*store.Transaction, refunds []*store.Refund, now time.Time) (*ZReport, error) {
+	report := &ZReport{
+		ShopID:      shop.ID,
+		ShopName:    shop.Name,
+		Date:        from.In(Location).Format(DateLayout),
+		From:        from,
+		To:          to,
+		Closed:      !now.Before(to),
+		Hourly:      []HourTotal{},
+		GeneratedAt: now,
+	}
+
+	hours := map[int]int{}
+	var err error
+	for _, transaction := range transactions {
+		if transaction.Kind == store.TransactionKindSplit {
+			continue
+		}
+		report.PaymentCount++
+		report.Gross, err = report.Gross.Add(transaction.Amount)
+		if err != nil {
+			return nil, err
+		}
+		report.CustomerFees, err = report.CustomerFees.Add(transaction.Fee)
+		if err != nil {
+			return nil, err
+		}
+
+		createdAt := transaction.CreatedAt
+		if report.FirstPaymentAt == nil || createdAt.Before(*report.FirstPaymentAt) {
+			report.FirstPaymentAt = &createdAt
+		}
+		if report.LastPaymentAt == nil || createdAt.After(*report.LastPaymentAt) {
+			report.LastPaymentAt = &createdAt
+		}
+
+		hour := createdAt.In(Location).Hour()
+		i, ok := hours[hour]
+		if !ok {
+			i = len(report.Hourly)
+			hours[hour] = i
+			report.Hourly = append(report.Hourly, HourTotal{Hour: hour})
+		}
+		total := &report.Hourly[i]
+		total.Count++
+		total.Amount, err = total.Amount.Add(transaction.Amount)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, refund := range refunds {
+		report.RefundCount++
+		report.Refunded, err = report.Refunded.Add(refund.Amount)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	report.Net, err = report.Gross.Sub(report.Refunded)
+	if err != nil {
+		return nil, err
+	}
+	if report.PaymentCount > 0 {
+		report.AveragePayment = report.Gross / money.Amount(report.PaymentCount)
+	}
+	return report, nil
+}
@@ -0,0 +1,69 @@
+package pos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBusinessDay(t *testing.T) {
+	// 22:30 UTC is already the next day in Nairobi.
+	now := time.Date(2026, 10, 18, 22, 30, 0, 0, time.UTC)
+
+	from, to, err := BusinessDay("", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 10, 18, 21, 0, 0, 0, time.UTC), from.UTC())
+	assert.Equal(t, time.Date(2026, 10, 19, 21, 0, 0, 0, time.UTC), to.UTC())
+
+	from, _, err = BusinessDay("2026-10-01", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 9, 30, 21, 0, 0, 0, time.UTC), from.UTC())
+
+	_, _, err = BusinessDay("01/10/2026", now)
+	assert.ErrorIs(t, err, ErrInvalidDate)
+}
+
+func TestBuildZReport(t *testing.T) {
+	shop := &store.Shop{ID: "shop-1", Name: "Corner Shop"}
+	from, to, err := BusinessDay("2026-10-19", time.Now())
+	require.NoError(t, err)
+
+	at := func(hour int, minute int) time.Time {
+		return time.Date(2026, 10, 19, hour, minute, 0, 0, Location)
+	}
+	transactions := []*store.Transaction{
+		{Amount: 10000, Fee: 100, Kind: store.TransactionKindPayment, CreatedAt: at(9, 15)},
+		{Amount: 25000, Fee: 250, Kind: store.TransactionKindPayment, CreatedAt: at(9, 45)},
+		{Amount: 5000, Fee: 50, Kind: store.TransactionKindPayment, CreatedAt: at(14, 5)},
+		{Amount: 99999, Fee: 999, Kind: store.TransactionKindSplit, CreatedAt: at(15, 0)},
+	}
+	refunds := []*store.Refund{{Amount: 2500}}
+
+	report, err := BuildZReport(shop, from, to, transactions, refunds, at(18, 0))
+	require.NoError(t, err)
+
+	assert.Equal(t, "2026-10-19", report.Date)
+	assert.False(t, report.Closed)
+	assert.Equal(t, 3, report.PaymentCount)
+	assert.Equal(t, money.Amount(40000), report.Gross)
+	assert.Equal(t, money.Amount(400), report.CustomerFees)
+	assert.Equal(t, 1, report.RefundCount)
+	assert.Equal(t, money.Amount(37500), report.Net)
+	assert.Equal(t, money.Amount(13333), report.AveragePayment)
+	assert.Equal(t, at(9, 15), *report.FirstPaymentAt)
+	assert.Equal(t, at(14, 5), *report.LastPaymentAt)
+	assert.Equal(t, []HourTotal{
+		{Hour: 9, Count: 2, Amount: 35000},
+		{Hour: 14, Count: 1, Amount: 5000},
+	}, report.Hourly)
+
+	report, err = BuildZReport(shop, from, to, nil, nil, to)
+	require.NoError(t, err)
+	assert.True(t, report.Closed)
+	assert.Zero(t, report.PaymentCount)
+	assert.Empty(t, report.Hourly)
+}
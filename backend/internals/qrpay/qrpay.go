@@ -0,0 +1,240 @@
+// Package qrpay encodes and decodes the payloads carried by Orcus payment QR
+// codes.
+//
+// A payload is a string of tag-length-value fields in the style of EMVCo
+// merchant QR codes: a two digit tag, a two digit length and the value.
+//
+//	00  format version, always "01"
+//	01  "11" for a static shop code, "12" for a dynamic one
+//	26  shop payment id
+//	54  amount in KES with two decimals, e.g. "150.00" (dynamic only)
+//	62  merchant reference (optional)
+//	80  expiry as unix seconds (dynamic only, optional)
+//	81  HMAC-SHA256 of everything before it, base64url (dynamic only)
+//	63  CRC-16/CCITT-FALSE of everything before its value, four hex digits
+//
+// Static codes only identify the shop, so anyone may print them. Dynamic
+// codes fix an amount and are signed so the amount cannot be edited.
+package qrpay
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+)
+
+const (
+	FormatVersion = "01"
+
+	tagVersion    = "00"
+	tagInitiation = "01"
+	tagPaymentID  = "26"
+	tagAmount     = "54"
+	tagReference  = "62"
+	tagExpiry     = "80"
+	tagSignature  = "81"
+	tagChecksum   = "63"
+
+	initiationStatic  = "11"
+	initiationDynamic = "12"
+
+	// MaxReferenceLength keeps the reference within one TLV field.
+	MaxReferenceLength = 25
+)
+
+var (
+	ErrMalformed = errors.New("qr payload is malformed")
+	ErrChecksum  = errors.New("qr payload checksum does not match")
+	ErrSignature = errors.New("qr payload signature is invalid")
+	ErrExpired   = errors.New("qr payload has expired")
+	ErrNoKey     = errors.New("dynamic qr payloads are disabled")
+)
+
+// Payload is the decoded content of a QR code. A zero Amount marks a static
+// code; ExpiresAt is zero when the code does not expire.
+type Payload struct {
+	PaymentID string       `json:"payment_id"`
+	Amount    money.Amount `json:"amount,omitempty"`
+	Reference string       `json:"reference,omitempty"`
+	ExpiresAt time.Time    `json:"expires_at,omitzero"`
+}
+
+func (p *Payload) IsDynamic() bool {
+	return p.Amount > 0
+}
+
+// SigningKeyFromEnv reads QR_SIGNING_KEY, a base64 encoded key of at least
+// 32 bytes. It returns nil when unset, which disables dynamic codes.
+func SigningKeyFromEnv() ([]byte, error) {
+	raw := os.Getenv("QR_SIGNING_KEY")
+	if raw == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("decoding QR_SIGNING_KEY: %w", err)
+	}
+	if len(key) < 32 {
+		return nil, errors.New("QR_SIGNING_KEY must be at least 32 bytes")
+	}
+	return key, nil
+}
+
+// Encode builds the payload string. Dynamic payloads are signed with key,
+// which must then be set.
+func Encode(p *Payload, key []byte) (string, error) {
+	if p.PaymentID == "" || len(p.PaymentID) > 99 {
+		return "", errors.New("payment id must be 1 to 99 characters")
+	}
+	if len(p.Reference) > MaxReferenceLength {
+		return "", fmt.Errorf("reference must be at most %d characters", MaxReferenceLength)
+	}
+	if p.Amount < 0 {
+		return "", errors.New("amount must not be negative")
+	}
+
+	payload := field(tagVersion, FormatVersion)
+	if !p.IsDynamic() {
+		if !p.ExpiresAt.IsZero() {
+			return "", errors.New("static payloads do not expire")
+		}
+		payload += field(tagInitiation, initiationStatic) + field(tagPaymentID, p.PaymentID)
+		if p.Reference != "" {
+			payload += field(tagReference, p.Reference)
+		}
+		return withChecksum(payload), nil
+	}
+
+	if len(key) == 0 {
+		return "", ErrNoKey
+	}
+	payload += field(tagInitiation, initiationDynamic) +
+		field(tagPaymentID, p.PaymentID) +
+		field(tagAmount, money.KES.Format(p.Amount))
+	if p.Reference != "" {
+		payload += field(tagReference, p.Reference)
+	}
+	if !p.ExpiresAt.IsZero() {
+		payload += field(tagExpiry, strconv.FormatInt(p.ExpiresAt.Unix(), 10))
+	}
+	payload += field(tagSignature, sign(payload, key))
+	return withChecksum(payload), nil
+}
+
+// Decode parses and checks a payload. The checksum is always verified;
+// dynamic payloads must also carry a valid signature and not be expired.
+func Decode(payload string, key []byte, now time.Time) (*Payload, error) {
+	if len(payload) < 8 || payload[len(payload)-8:len(payload)-4] != tagChecksum+"04" {
+		return nil, ErrMalformed
+	}
+	body := payload[:len(payload)-4]
+	if fmt.Sprintf("%04X", CRC16([]byte(body))) != payload[len(payload)-4:] {
+		return nil, ErrChecksum
+	}
+
+	fieldsPart := body[:len(body)-4]
+	fields := map[string]string{}
+	signedUpTo := -1
+	for rest := fieldsPart; rest != ""; {
+		if len(rest) < 4 {
+			return nil, ErrMalformed
+		}
+		tag := rest[:2]
+		length, err := strconv.Atoi(rest[2:4])
+		if err != nil || length == 0 || len(rest) < 4+length {
+			return nil, ErrMalformed
+		}
+		if _, seen := fields[tag]; seen {
+			return nil, ErrMalformed
+		}
+		if tag == tagSignature {
+			// Nothing may follow the signature unsigned.
+			if len(rest) != 4+length {
+				return nil, ErrMalformed
+			}
+			signedUpTo = len(fieldsPart) - len(rest)
+		}
+		fields[tag] = rest[4 : 4+length]
+		rest = rest[4+length:]
+	}
+	if fields[tagVersion] != FormatVersion || fields[tagPaymentID] == "" {
+		return nil, ErrMalformed
+	}
+
+	p := &Payload{PaymentID: fields[tagPaymentID], Reference: fields[tagReference]}
+	switch fields[tagInitiation] {
+	case initiationStatic:
+		if fields[tagAmount] != "" || fields[tagExpiry] != "" || fields[tagSignature] != "" {
+			return nil, ErrMalformed
+		}
+		return p, nil
+	case initiationDynamic:
+	default:
+		return nil, ErrMalformed
+	}
+
+	if signedUpTo < 0 {
+		return nil, ErrSignature
+	}
+	if len(key) == 0 {
+		return nil, ErrNoKey
+	}
+	if !hmac.Equal([]byte(sign(fieldsPart[:signedUpTo], key)), []byte(fields[tagSignature])) {
+		return nil, ErrSignature
+	}
+
+	amount, err := money.KES.Parse(fields[tagAmount])
+	if err != nil || amount <= 0 {
+		return nil, ErrMalformed
+	}
+	p.Amount = amount
+	if expiry := fields[tagExpiry]; expiry != "" {
+		seconds, err := strconv.ParseInt(expiry, 10, 64)
+		if err != nil {
+			return nil, ErrMalformed
+		}
+		p.ExpiresAt = time.Unix(seconds, 0).UTC()
+		if !now.Before(p.ExpiresAt) {
+			return nil, ErrExpired
+		}
+	}
+	return p, nil
+}
+
+func field(tag string, value string) string {
+	return fmt.Sprintf("%s%02d%s", tag, len(value), value)
+}
+
+func sign(payload string, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("orcus-qr:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func withChecksum(payload string) string {
+	payload += tagChecksum + "04"
+	return payload + fmt.Sprintf("%04X", CRC16([]byte(payload)))
+}
+
+// CRC16 is CRC-16/CCITT-FALSE, the checksum EMVCo QR codes use.
+func CRC16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,95 @@
+package qrpay
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var key = bytes.Repeat([]byte{7}, 32)
+
+func TestCRC16(t *testing.T) {
+	// The CRC-16/CCITT-FALSE check value.
+	assert.Equal(t, uint16(0x29B1), CRC16([]byte("123456789")))
+}
+
+func TestStaticRoundTrip(t *testing.T) {
+	payload, err := Encode(&Payload{PaymentID: "pay-1"}, nil)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(payload, "000201"+"010211"+"2605pay-1"))
+
+	decoded, err := Decode(payload, nil, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, &Payload{PaymentID: "pay-1"}, decoded)
+}
+
+func TestDynamicRoundTrip(t *testing.T) {
+	now := time.Unix(1700000000, 0).UTC()
+	p := &Payload{PaymentID: "pay-1", Amount: 15050, Reference: "table 4", ExpiresAt: now.Add(time.Hour)}
+
+	payload, err := Encode(p, key)
+	require.NoError(t, err)
+	assert.Contains(t, payload, "5406150.50")
+
+	decoded, err := Decode(payload, key, now)
+	require.NoError(t, err)
+	assert.Equal(t, p, decoded)
+
+	_, err = Decode(payload, key, now.Add(time.Hour))
+	assert.ErrorIs(t, err, ErrExpired)
+}
+
+func TestDynamicRequiresKey(t *testing.T) {
+	_, err := Encode(&Payload{PaymentID: "pay-1", Amount: 100}, nil)
+	assert.ErrorIs(t, err, ErrNoKey)
+}
+
+func TestDecodeRejectsTampering(t *testing.T) {
+	payload, err := Encode(&Payload{PaymentID: "pay-1", Amount: 15000}, key)
+	require.NoError(t, err)
+
+	// Changing the amount breaks the checksum...
+	edited := strings.Replace(payload, "150.00", "950.00", 1)
+	_, err = Decode(edited, key, time.Now())
+	assert.ErrorIs(t, err, ErrChecksum)
+
+	// ...and recomputing the checksum still leaves the signature wrong.
+	body := edited[:len(edited)-8]
+	_, err = Decode(withChecksum(body), key, time.Now())
+	assert.ErrorIs(t, err, ErrSignature)
+
+	_, err = Decode(payload, bytes.Repeat([]byte{8}, 32), time.Now())
+	assert.ErrorIs(t, err, ErrSignature)
+}
+
+func TestDecodeRejectsMalformed(t *testing.T) {
+	for _, payload := range []string{
+		"",
+		withChecksum(""),
+		withChecksum("000202010211" + "2605pay-1"),
+		withChecksum("000201010211"),
+		withChecksum("000201010211" + "2605pay-1" + "5406150.00"),
+		withChecksum("000201010211" + "2699pay-1"),
+	} {
+		_, err := Decode(payload, key, time.Now())
+		assert.ErrorIs(t, err, ErrMalformed, payload)
+	}
+}
+
+func TestRender(t *testing.T) {
+	payload, err := Encode(&Payload{PaymentID: "pay-1"}, nil)
+	require.NoError(t, err)
+
+	png, err := PNG(payload, DefaultSize)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("\x89PNG"), png[:4])
+
+	svg, err := SVG(payload)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(svg, []byte("<svg ")))
+	assert.True(t, bytes.HasSuffix(svg, []byte("</svg>")))
+}
@@ -0,0 +1,53 @@
+package qrpay
+
+import (
+	"bytes"
+	"fmt"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// DefaultSize is the width in pixels of rendered PNG codes.
+const DefaultSize = 512
+
+// Medium recovery survives smudged prints without making the codes of
+// dynamic payloads too dense to scan from a phone screen.
+const recoveryLevel = qrcode.Medium
+
+// PNG renders payload as a size by size pixel PNG.
+func PNG(payload string, size int) ([]byte, error) {
+	code, err := qrcode.New(payload, recoveryLevel)
+	if err != nil {
+		return nil, err
+	}
+	return code.PNG(size)
+}
+
+// SVG renders payload with one unit per module, so it scales to any size.
+// Dark modules in a row are merged into a single path segment.
+func SVG(payload string) ([]byte, error) {
+	code, err := qrcode.New(payload, recoveryLevel)
+	if err != nil {
+		return nil, err
+	}
+	bitmap := code.Bitmap()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, len(bitmap), len(bitmap))
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, len(bitmap), len(bitmap))
+	for y, row := range bitmap {
+		for x := 0; x < len(row); {
+			if !row[x] {
+				x++
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,50 @@
+package ratelimit
+
+import "time"
+
+// LockoutPolicy locks an account once Threshold consecutive failures have
+// been recorded, doubling the lock from BaseDelay on each further failure up
+// to MaxDelay. Failures older than Window are forgotten.
+type LockoutPolicy struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Window    time.Duration
+}
+
+var DefaultLockoutPolicy = LockoutPolicy{
+	Threshold: 5,
+	BaseDelay: time.Minute,
+	MaxDelay:  time.Hour,
+	Window:    24 * time.Hour,
+}
+
+// Lockout tracks failed logins per key and reports when a key may try again.
+type Lockout interface {
+	LockedUntil(key string) (time.Time, error)
+	RecordFailure(key string) (time.Time, error)
+	Reset(key string) error
+}
+
+func (p LockoutPolicy) delay(failures int) time.Duration {
+	if failures < p.Threshold {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.Threshold; i < failures; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
+
+// failures returns the count to continue from, dropping stale history.
+func (p LockoutPolicy) failures(previous int, lastFailure time.Time, now time.Time) int {
+	if now.Sub(lastFailure) > p.Window {
+		return 0
+	}
+	return previous
+}
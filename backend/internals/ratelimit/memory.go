@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+const pruneEvery = 1000
+
+type memoryEntry struct {
+	bucket bucket
+	per    time.Duration
+}
+
+// MemoryStore keeps buckets in process. It is suitable for a single backend
+// instance and for tests.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryEntry
+	takes   int
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*memoryEntry{}, now: time.Now}
+}
+
+func (ms *MemoryStore) Take(key string, limit Limit) (bool, time.Duration, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := ms.now()
+	ms.takes++
+	if ms.takes%pruneEvery == 0 {
+		ms.prune(now)
+	}
+
+	entry, ok := ms.buckets[key]
+	if !ok {
+		entry = &memoryEntry{bucket: limit.full(now), per: limit.Per}
+		ms.buckets[key] = entry
+	}
+
+	updated, allowed, wait := limit.take(entry.bucket, now)
+	entry.bucket = updated
+	return allowed, wait, nil
+}
+
+// prune drops buckets that have had time to refill completely, since they
+// are indistinguishable from a fresh bucket.
+func (ms *MemoryStore) prune(now time.Time) {
+	for key, entry := range ms.buckets {
+		if now.Sub(entry.bucket.UpdatedAt) >= entry.per {
+			delete(ms.buckets, key)
+		}
+	}
+}
+
+type lockoutEntry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+type MemoryLockout struct {
+	mu      sync.Mutex
+	policy  LockoutPolicy
+	entries map[string]*lockoutEntry
+	records int
+	now     func() time.Time
+}
+
+func NewMemoryLockout(policy LockoutPolicy) *MemoryLockout {
+	return &MemoryLockout{policy: policy, entries: map[string]*lockoutEntry{}, now: time.Now}
+}
+
+func (ml *MemoryLockout) LockedUntil(key string) (time.Time, error) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	entry, ok := ml.entries[key]
+	if !ok {
+		return time.Time{}, nil
+	}
+	return entry.lockedUntil, nil
+}
+
+func (ml *MemoryLockout) RecordFailure(key string) (time.Time, error) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	now := ml.now()
+	ml.records++
+	if ml.records%pruneEvery == 0 {
+		ml.prune(now)
+	}
+
+	entry, ok := ml.entries[key]
+	if !ok {
+		entry = &lockoutEntry{}
+		ml.entries[key] = entry
+	}
+
+	entry.failures = ml.policy.failures(entry.failures, entry.lastFailure, now) + 1
+	entry.lastFailure = now
+	if delay := ml.policy.delay(entry.failures); delay > 0 {
+		entry.lockedUntil = now.Add(delay)
+	}
+	return entry.lockedUntil, nil
+}
+
+// prune drops entries whose failures have aged out of the window and whose
+// lock has ended, since the next failure would start from zero anyway.
+func (ml *MemoryLockout) prune(now time.Time) {
+	for key, entry := range ml.entries {
+		if now.Sub(entry.lastFailure) > ml.policy.Window && !now.Before(entry.lockedUntil) {
+			delete(ml.entries, key)
+		}
+	}
+}
+
+func (ml *MemoryLockout) Reset(key string) error {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	delete(ml.entries, key)
+	return nil
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// PostgresStore shares buckets between backend instances through the
+// rate_limit_buckets table.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (ps *PostgresStore) Take(key string, limit Limit) (bool, time.Duration, error) {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return false, 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	_, err = tx.Exec(`
+	INSERT INTO rate_limit_buckets (key, tokens, updated_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (key) DO NOTHING
+	`, key, float64(limit.Burst), now)
+	if err != nil {
+		return false, 0, err
+	}
+
+	var b bucket
+	err = tx.QueryRow(`
+	SELECT tokens, updated_at
+	FROM rate_limit_buckets
+	WHERE key = $1
+	FOR UPDATE
+	`, key).Scan(&b.Tokens, &b.UpdatedAt)
+	if err != nil {
+		return false, 0, err
+	}
+
+	updated, allowed, wait := limit.take(b, now)
+	_, err = tx.Exec(`
+	UPDATE rate_limit_buckets
+	SET tokens = $1, updated_at = $2
+	WHERE key = $3
+	`, updated.Tokens, updated.UpdatedAt, key)
+	if err != nil {
+		return false, 0, err
+	}
+
+	return allowed, wait, tx.Commit()
+}
+
+type PostgresLockout struct {
+	db     *sql.DB
+	policy LockoutPolicy
+}
+
+func NewPostgresLockout(db *sql.DB, policy LockoutPolicy) *PostgresLockout {
+	return &PostgresLockout{db: db, policy: policy}
+}
+
+func (pl *PostgresLockout) LockedUntil(key string) (time.Time, error) {
+	var lockedUntil sql.NullTime
+	err := pl.db.QueryRow(`
+	SELECT locked_until
+	FROM login_lockouts
+	WHERE key = $1
+	`, key).Scan(&lockedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return lockedUntil.Time, nil
+}
+
+func (pl *PostgresLockout) RecordFailure(key string) (time.Time, error) {
+	tx, err := pl.db.Begin()
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	var failures int
+	var lastFailure time.Time
+	var lockedUntil sql.NullTime
+	err = tx.QueryRow(`
+	SELECT failures, last_failure_at, locked_until
+	FROM login_lockouts
+	WHERE key = $1
+	FOR UPDATE
+	`, key).Scan(&failures, &lastFailure, &lockedUntil)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, err
+	}
+
+	failures = pl.policy.failures(failures, lastFailure, now) + 1
+	if delay := pl.policy.delay(failures); delay > 0 {
+		lockedUntil = sql.NullTime{Time: now.Add(delay), Valid: true}
+	}
+
+	_, err = tx.Exec(`
+	INSERT INTO login_lockouts (key, failures, last_failure_at, locked_until)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (key) DO UPDATE
+	SET failures = EXCLUDED.failures, last_failure_at = EXCLUDED.last_failure_at, locked_until = EXCLUDED.locked_until
+	`, key, failures, now, lockedUntil)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return lockedUntil.Time, tx.Commit()
+}
+
+func (pl *PostgresLockout) Reset(key string) error {
+	_, err := pl.db.Exec(`DELETE FROM login_lockouts WHERE key = $1`, key)
+	return err
+}
@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Limit describes a token bucket holding Burst tokens that refill evenly over
+// Per. A limit of {Burst: 5, Per: time.Minute} allows five requests at once
+// and one more every twelve seconds after that.
+type Limit struct {
+	Burst int
+	Per   time.Duration
+}
+
+var (
+	// LoginIPLimit caps login attempts from a single address.
+	LoginIPLimit = Limit{Burst: 20, Per: time.Minute}
+	// LoginUsernameLimit caps login attempts against a single account.
+	LoginUsernameLimit = Limit{Burst: 5, Per: time.Minute}
+	// RegisterIPLimit is deliberately strict since registration creates and
+	// funds a Hedera account.
+	RegisterIPLimit = Limit{Burst: 3, Per: time.Hour}
+	// VerificationIPLimit covers OTP issuance and confirmation endpoints.
+	VerificationIPLimit = Limit{Burst: 10, Per: 10 * time.Minute}
+)
+
+// Store takes a token from the bucket identified by key, reporting how long
+// the caller should wait when the bucket is empty.
+type Store interface {
+	Take(key string, limit Limit) (bool, time.Duration, error)
+}
+
+type bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+func (l Limit) rate() float64 {
+	return float64(l.Burst) / l.Per.Seconds()
+}
+
+func (l Limit) full(now time.Time) bucket {
+	return bucket{Tokens: float64(l.Burst), UpdatedAt: now}
+}
+
+// take refills b up to now and tries to spend a single token.
+func (l Limit) take(b bucket, now time.Time) (bucket, bool, time.Duration) {
+	elapsed := now.Sub(b.UpdatedAt).Seconds()
+	if elapsed > 0 {
+		b.Tokens = math.Min(float64(l.Burst), b.Tokens+elapsed*l.rate())
+	}
+	b.UpdatedAt = now
+
+	if b.Tokens >= 1 {
+		b.Tokens--
+		return b, true, 0
+	}
+
+	wait := time.Duration((1 - b.Tokens) / l.rate() * float64(time.Second))
+	return b, false, wait
+}
@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := Limit{Burst: 2, Per: time.Minute}
+
+	for i := 0; i < 2; i++ {
+		allowed, _, err := store.Take("login:ip:127.0.0.1", limit)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+	}
+
+	allowed, wait, err := store.Take("login:ip:127.0.0.1", limit)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, 30*time.Second, wait)
+
+	allowed, _, err = store.Take("login:ip:10.0.0.1", limit)
+	require.NoError(t, err)
+	assert.True(t, allowed, "other keys have their own bucket")
+
+	now = now.Add(30 * time.Second)
+	allowed, _, err = store.Take("login:ip:127.0.0.1", limit)
+	require.NoError(t, err)
+	assert.True(t, allowed, "one token refills every 30s")
+}
+
+func TestLockoutPolicyDelay(t *testing.T) {
+	policy := LockoutPolicy{Threshold: 3, BaseDelay: time.Minute, MaxDelay: 5 * time.Minute, Window: time.Hour}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 1, want: 0},
+		{failures: 2, want: 0},
+		{failures: 3, want: time.Minute},
+		{failures: 4, want: 2 * time.Minute},
+		{failures: 5, want: 4 * time.Minute},
+		{failures: 6, want: 5 * time.Minute},
+		{failures: 50, want: 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, policy.delay(tt.failures), "failures=%d", tt.failures)
+	}
+}
+
+func TestMemoryLockout(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	policy := LockoutPolicy{Threshold: 2, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour}
+	lockout := NewMemoryLockout(policy)
+	lockout.now = func() time.Time { return now }
+
+	lockedUntil, err := lockout.RecordFailure("user:alice")
+	require.NoError(t, err)
+	assert.True(t, lockedUntil.IsZero())
+
+	lockedUntil, err = lockout.RecordFailure("user:alice")
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(time.Minute), lockedUntil)
+
+	lockedUntil, err = lockout.RecordFailure("user:alice")
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(2*time.Minute), lockedUntil)
+
+	require.NoError(t, lockout.Reset("user:alice"))
+	lockedUntil, err = lockout.LockedUntil("user:alice")
+	require.NoError(t, err)
+	assert.True(t, lockedUntil.IsZero())
+
+	_, err = lockout.RecordFailure("user:bob")
+	require.NoError(t, err)
+	now = now.Add(2 * time.Hour)
+	lockedUntil, err = lockout.RecordFailure("user:bob")
+	require.NoError(t, err)
+	assert.True(t, lockedUntil.IsZero(), "failures outside the window are forgotten")
+}
+
+func TestMemoryLockoutPrune(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	policy := LockoutPolicy{Threshold: 1, BaseDelay: 2 * time.Hour, MaxDelay: 2 * time.Hour, Window: time.Hour}
+	lockout := NewMemoryLockout(policy)
+	lockout.now = func() time.Time { return now }
+
+	_, err := lockout.RecordFailure("user:alice")
+	require.NoError(t, err)
+	now = now.Add(30 * time.Minute)
+	_, err = lockout.RecordFailure("user:bob")
+	require.NoError(t, err)
+
+	now = now.Add(time.Hour + time.Minute)
+	lockout.prune(now)
+	assert.Len(t, lockout.entries, 2, "both keys are still locked")
+
+	now = now.Add(45 * time.Minute)
+	lockout.prune(now)
+	assert.NotContains(t, lockout.entries, "user:alice")
+	assert.Contains(t, lockout.entries, "user:bob", "bob is still locked")
+}
@@ -0,0 +1,185 @@
+package reconciliation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+)
+
+const DefaultMirrorNodeURL = "https://testnet.mirrornode.hedera.com"
+
+const ResultSuccess = "SUCCESS"
+
+// MirrorClient reads settled transactions from a Hedera mirror node REST API.
+type MirrorClient struct {
+	BaseURL string
+	HTTP    *http.Client
+}
+
+func NewMirrorClient(baseURL string) *MirrorClient {
+	return &MirrorClient{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		HTTP:    &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// NewMirrorClientFromEnv uses MIRROR_NODE_URL, defaulting to testnet.
+func NewMirrorClientFromEnv() *MirrorClient {
+	baseURL := os.Getenv("MIRROR_NODE_URL")
+	if baseURL == "" {
+		baseURL = DefaultMirrorNodeURL
+	}
+	return NewMirrorClient(baseURL)
+}
+
+type TokenTransfer struct {
+	TokenID string `json:"token_id"`
+	Account string `json:"account"`
+	Amount  int64  `json:"amount"`
+}
+
+type LedgerTransaction struct {
+	TransactionID      string          `json:"transaction_id"`
+	ConsensusTimestamp string          `json:"consensus_timestamp"`
+	Result             string          `json:"result"`
+	TokenTransfers     []TokenTransfer `json:"token_transfers"`
+}
+
+type transactionsResponse struct {
+	Transactions []*LedgerTransaction `json:"transactions"`
+	Links        struct {
+		Next string `json:"next"`
+	} `json:"links"`
+}
+
+// NetTokenAmount is what account gained (positive) or lost (negative) of
+// tokenID in this transaction.
+func (lt *LedgerTransaction) NetTokenAmount(account string, tokenID string) money.Amount {
+	var net money.Amount
+	for _, transfer := range lt.TokenTransfers {
+		if transfer.Account == account && transfer.TokenID == tokenID {
+			net += money.Amount(transfer.Amount)
+		}
+	}
+	return net
+}
+
+func (lt *LedgerTransaction) ConsensusTime() (time.Time, error) {
+	seconds, nanos, _ := strings.Cut(lt.ConsensusTimestamp, ".")
+	sec, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var nsec int64
+	if nanos != "" {
+		nsec, err = strconv.ParseInt(nanos, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.Unix(sec, nsec), nil
+}
+
+// AccountTransactions returns every transaction touching accountID with a
+// consensus timestamp in [from, to), following pagination.
+func (mc *MirrorClient) AccountTransactions(ctx context.Context, accountID string, from time.Time, to time.Time) ([]*LedgerTransaction, error) {
+	query := url.Values{}
+	query.Set("account.id", accountID)
+	query.Add("timestamp", "gte:"+mirrorTimestamp(from))
+	query.Add("timestamp", "lt:"+mirrorTimestamp(to))
+	query.Set("order", "asc")
+	query.Set("limit", "100")
+	next := "/api/v1/transactions?" + query.Encode()
+
+	transactions := []*LedgerTransaction{}
+	for next != "" {
+		var page transactionsResponse
+		found, err := mc.get(ctx, next, &page)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			break
+		}
+		transactions = append(transactions, page.Transactions...)
+		next = page.Links.Next
+	}
+	return transactions, nil
+}
+
+// GetTransaction looks up a transaction by its SDK or mirror formatted id.
+// It returns nil, nil when the mirror node has no record of it. If the id was
+// submitted more than once the successful record wins.
+func (mc *MirrorClient) GetTransaction(ctx context.Context, transactionID string) (*LedgerTransaction, error) {
+	var page transactionsResponse
+	found, err := mc.get(ctx, "/api/v1/transactions/"+url.PathEscape(MirrorTransactionID(transactionID)), &page)
+	if err != nil || !found || len(page.Transactions) == 0 {
+		return nil, err
+	}
+	for _, transaction := range page.Transactions {
+		if transaction.Result == ResultSuccess {
+			return transaction, nil
+		}
+	}
+	return page.Transactions[0], nil
+}
+
+func (mc *MirrorClient) get(ctx context.Context, path string, dest any) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mc.BaseURL+path, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := mc.HTTP.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("mirror node responded with status %d for %s", res.StatusCode, path)
+	}
+	return true, json.NewDecoder(res.Body).Decode(dest)
+}
+
+// MirrorTransactionID converts the SDK form 0.0.5005@1700000000.000000001 to
+// the mirror node form 0.0.5005-1700000000-000000001. Ids already in mirror
+// form are returned unchanged.
+func MirrorTransactionID(transactionID string) string {
+	transactionID, _, _ = strings.Cut(transactionID, "?")
+	transactionID, _, _ = strings.Cut(transactionID, "/")
+	account, validStart, ok := strings.Cut(transactionID, "@")
+	if !ok {
+		return transactionID
+	}
+	return account + "-" + strings.Replace(validStart, ".", "-", 1)
+}
+
+// SDKTransactionID is the inverse of MirrorTransactionID and matches what the
+// API stores on transactions.
+func SDKTransactionID(transactionID string) string {
+	if strings.Contains(transactionID, "@") {
+		return transactionID
+	}
+	parts := strings.Split(transactionID, "-")
+	if len(parts) != 3 {
+		return transactionID
+	}
+	return parts[0] + "@" + parts[1] + "." + parts[2]
+}
+
+func mirrorTimestamp(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
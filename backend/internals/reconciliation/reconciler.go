@@ -0,0 +1,220 @@
+package reconciliation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+const (
+	// Interval is how often Run reconciles.
+	Interval = 15 * time.Minute
+	// Window is how far back each run looks. It overlaps earlier runs so a
+	// failed run is covered by the next; issues are only recorded once.
+	Window = 2 * time.Hour
+	// Grace gives the mirror node and the API's own DB write time to catch up
+	// before a missing counterpart is reported.
+	Grace = 5 * time.Minute
+	// settlementSlack covers the gap between consensus and the DB insert.
+	settlementSlack = time.Minute
+)
+
+// Reconciler compares payments in the database with the KSH token transfers
+// the mirror node reports on merchant accounts.
+type Reconciler struct {
+	Store             store.ReconciliationStore
+	Mirror            *MirrorClient
+	TokenID           string
+	OperatorAccountID string
+	Logger            *log.Logger
+	now               func() time.Time
+}
+
+func NewReconciler(reconciliationStore store.ReconciliationStore, mirror *MirrorClient, tokenID string, operatorAccountID string, logger *log.Logger) *Reconciler {
+	return &Reconciler{
+		Store:             reconciliationStore,
+		Mirror:            mirror,
+		TokenID:           tokenID,
+		OperatorAccountID: operatorAccountID,
+		Logger:            logger,
+		now:               time.Now,
+	}
+}
+
+// Run reconciles every Interval until ctx is cancelled.
+func (rc *Reconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			recorded, err := rc.RunOnce(ctx)
+			if err != nil {
+				rc.Logger.Printf("ERROR: error reconciling with mirror node at RunOnce: %v", err)
+				continue
+			}
+			if recorded > 0 {
+				rc.Logger.Printf("WARNING: reconciliation recorded %d new issues", recorded)
+			}
+		}
+	}
+}
+
+// RunOnce checks payments created in the last Window (minus Grace) and the
+// merchant account transfers over the same period. It returns how many new
+// issues were recorded.
+func (rc *Reconciler) RunOnce(ctx context.Context) (int, error) {
+	to := rc.now().Add(-Grace)
+	from := to.Add(-Window)
+
+	transactions, err := rc.Store.GetTransactionsForReconciliation(from, to)
+	if err != nil {
+		return 0, err
+	}
+	merchants, err := rc.Store.GetMerchantAccounts()
+	if err != nil {
+		return 0, err
+	}
+
+	ledger := map[string]*LedgerTransaction{}
+	ledgerMerchant := map[string]*store.MerchantAccount{}
+	for _, merchant := range merchants {
+		merchantTransactions, err := rc.Mirror.AccountTransactions(ctx, merchant.AccountID, from.Add(-settlementSlack), to)
+		if err != nil {
+			return 0, fmt.Errorf("listing transactions for %s: %w", merchant.AccountID, err)
+		}
+		for _, transaction := range merchantTransactions {
+			ledger[transaction.TransactionID] = transaction
+			ledgerMerchant[transaction.TransactionID] = merchant
+		}
+	}
+
+	var issues []*store.ReconciliationIssue
+	matched := map[string]bool{}
+	for _, transaction := range transactions {
+		paymentID := MirrorTransactionID(transaction.HederaTransactionID)
+		matched[paymentID] = true
+		payment, ok := ledger[paymentID]
+		if !ok {
+			payment, err = rc.Mirror.GetTransaction(ctx, paymentID)
+			if err != nil {
+				return 0, err
+			}
+		}
+		if issue := rc.check(transaction, transaction.HederaTransactionID, payment, transaction.MerchantAccountID, transaction.Amount, "payment"); issue != nil {
+			issues = append(issues, issue)
+		}
+
+		if transaction.HederaFeeTransactionID == "" {
+			continue
+		}
+		feeID := MirrorTransactionID(transaction.HederaFeeTransactionID)
+		matched[feeID] = true
+		fee, err := rc.Mirror.GetTransaction(ctx, feeID)
+		if err != nil {
+			return 0, err
+		}
+		if issue := rc.check(transaction, transaction.HederaFeeTransactionID, fee, rc.OperatorAccountID, transaction.Fee, "fee"); issue != nil {
+			issues = append(issues, issue)
+		}
+	}
+
+	for transactionID, transaction := range ledger {
+		if matched[transactionID] {
+			continue
+		}
+		issue, err := rc.checkOrphan(transaction, ledgerMerchant[transactionID], from.Add(-settlementSlack), to)
+		if err != nil {
+			return 0, err
+		}
+		if issue != nil {
+			issues = append(issues, issue)
+		}
+	}
+
+	recorded := 0
+	for _, issue := range issues {
+		created, err := rc.Store.CreateReconciliationIssue(issue)
+		if err != nil {
+			return recorded, err
+		}
+		if created {
+			recorded++
+		}
+	}
+	return recorded, nil
+}
+
+// check compares one recorded transfer with its ledger counterpart, expecting
+// account to have been credited amount.
+func (rc *Reconciler) check(transaction *store.ReconciliationTransaction, hederaTransactionID string, ledger *LedgerTransaction, account string, amount money.Amount, leg string) *store.ReconciliationIssue {
+	issue := &store.ReconciliationIssue{
+		MerchantID:          transaction.MerchantID,
+		TransactionID:       transaction.ID,
+		HederaTransactionID: hederaTransactionID,
+		AccountID:           account,
+		ExpectedAmount:      &amount,
+	}
+
+	if ledger == nil {
+		issue.Kind = store.ReconciliationMissingOnLedger
+		issue.Details = fmt.Sprintf("%s transfer recorded in the database was not found on the mirror node", leg)
+		return issue
+	}
+	if ledger.Result != ResultSuccess {
+		issue.Kind = store.ReconciliationFailedOnLedger
+		issue.Details = fmt.Sprintf("%s transfer settled with result %s", leg, ledger.Result)
+		return issue
+	}
+
+	credited := ledger.NetTokenAmount(account, rc.TokenID)
+	if credited != amount {
+		issue.Kind = store.ReconciliationAmountMismatch
+		issue.LedgerAmount = &credited
+		issue.Details = fmt.Sprintf("%s transfer credited %d on the ledger but %d in the database", leg, credited, amount)
+		return issue
+	}
+	return nil
+}
+
+// checkOrphan reports successful KSH credits to a merchant from anyone but
+// the operator that have no transactions row, such as when the DB write
+// after an on-chain transfer failed.
+func (rc *Reconciler) checkOrphan(ledger *LedgerTransaction, merchant *store.MerchantAccount, from time.Time, to time.Time) (*store.ReconciliationIssue, error) {
+	if ledger.Result != ResultSuccess {
+		return nil, nil
+	}
+	credited := ledger.NetTokenAmount(merchant.AccountID, rc.TokenID)
+	if credited <= 0 || ledger.NetTokenAmount(rc.OperatorAccountID, rc.TokenID) < 0 {
+		return nil, nil
+	}
+	consensusAt, err := ledger.ConsensusTime()
+	if err != nil {
+		return nil, err
+	}
+	// Transfers just after the window belong to rows the next run will see.
+	if consensusAt.Before(from) || !consensusAt.Before(to) {
+		return nil, nil
+	}
+
+	exists, err := rc.Store.TransactionExistsByHederaID(SDKTransactionID(ledger.TransactionID))
+	if err != nil || exists {
+		return nil, err
+	}
+
+	return &store.ReconciliationIssue{
+		Kind:                store.ReconciliationOrphanTransfer,
+		MerchantID:          merchant.MerchantID,
+		HederaTransactionID: SDKTransactionID(ledger.TransactionID),
+		AccountID:           merchant.AccountID,
+		LedgerAmount:        &credited,
+		Details:             "token transfer to merchant has no matching transaction in the database",
+	}, nil
+}
@@ -0,0 +1,167 @@
+package reconciliation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+const (
+	testToken    = "0.0.500"
+	testOperator = "0.0.2"
+	testMerchant = "0.0.100"
+	testUser     = "0.0.200"
+)
+
+type fakeReconciliationStore struct {
+	transactions []*store.ReconciliationTransaction
+	merchants    []*store.MerchantAccount
+	issues       map[string]*store.ReconciliationIssue
+}
+
+func (fs *fakeReconciliationStore) GetTransactionsForReconciliation(from time.Time, to time.Time) ([]*store.ReconciliationTransaction, error) {
+	return fs.transactions, nil
+}
+
+func (fs *fakeReconciliationStore) GetMerchantAccounts() ([]*store.MerchantAccount, error) {
+	return fs.merchants, nil
+}
+
+func (fs *fakeReconciliationStore) TransactionExistsByHederaID(hederaTransactionID string) (bool, error) {
+	for _, transaction := range fs.transactions {
+		if transaction.HederaTransactionID == hederaTransactionID || transaction.HederaFeeTransactionID == hederaTransactionID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (fs *fakeReconciliationStore) CreateReconciliationIssue(issue *store.ReconciliationIssue) (bool, error) {
+	key := issue.Kind + "|" + issue.HederaTransactionID
+	if _, ok := fs.issues[key]; ok {
+		return false, nil
+	}
+	fs.issues[key] = issue
+	return true, nil
+}
+
+func (fs *fakeReconciliationStore) GetReconciliationIssuesByMerchantID(merchantID string, status string) ([]*store.ReconciliationIssue, error) {
+	return nil, nil
+}
+
+func transfer(id string, consensus time.Time, from string, to string, amount int64) *LedgerTransaction {
+	return &LedgerTransaction{
+		TransactionID:      id,
+		ConsensusTimestamp: mirrorTimestamp(consensus),
+		Result:             ResultSuccess,
+		TokenTransfers: []TokenTransfer{
+			{TokenID: testToken, Account: from, Amount: -amount},
+			{TokenID: testToken, Account: to, Amount: amount},
+		},
+	}
+}
+
+// newMirrorStub serves the account listing in two pages and single lookups
+// for anything in byID.
+func newMirrorStub(t *testing.T, listing []*LedgerTransaction, byID map[string]*LedgerTransaction) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := strings.CutPrefix(r.URL.Path, "/api/v1/transactions/"); ok {
+			transaction, found := byID[id]
+			if !found {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"transactions": []*LedgerTransaction{transaction}})
+			return
+		}
+
+		assert.Equal(t, testMerchant, r.URL.Query().Get("account.id"))
+		page := listing[len(listing)/2:]
+		next := ""
+		if r.URL.Query().Get("page") == "" {
+			page = listing[:len(listing)/2]
+			next = "/api/v1/transactions?account.id=" + testMerchant + "&page=2"
+		}
+		json.NewEncoder(w).Encode(map[string]any{"transactions": page, "links": map[string]string{"next": next}})
+	}))
+}
+
+func TestRunOnce(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	at := now.Add(-30 * time.Minute)
+
+	matched := transfer("0.0.200-1699998000-000000001", at, testUser, testMerchant, 1000)
+	matchedFee := transfer("0.0.200-1699998000-000000002", at, testUser, testOperator, 20)
+	mismatched := transfer("0.0.200-1699998100-000000001", at, testUser, testMerchant, 900)
+	orphan := transfer("0.0.200-1699998200-000000001", at, testUser, testMerchant, 500)
+	purchase := transfer("0.0.2-1699998300-000000001", at, testOperator, testMerchant, 700)
+
+	mirror := newMirrorStub(t,
+		[]*LedgerTransaction{matched, mismatched, orphan, purchase},
+		map[string]*LedgerTransaction{matchedFee.TransactionID: matchedFee},
+	)
+	defer mirror.Close()
+
+	fake := &fakeReconciliationStore{
+		merchants: []*store.MerchantAccount{{MerchantID: "m-1", AccountID: testMerchant}},
+		transactions: []*store.ReconciliationTransaction{
+			{ID: "t-ok", MerchantID: "m-1", Amount: 1000, Fee: 20, HederaTransactionID: "0.0.200@1699998000.000000001", HederaFeeTransactionID: "0.0.200@1699998000.000000002", MerchantAccountID: testMerchant, UserAccountID: testUser},
+			{ID: "t-mismatch", MerchantID: "m-1", Amount: 1000, HederaTransactionID: "0.0.200@1699998100.000000001", MerchantAccountID: testMerchant, UserAccountID: testUser},
+			{ID: "t-missing", MerchantID: "m-1", Amount: 300, HederaTransactionID: "0.0.200@1699998400.000000001", MerchantAccountID: testMerchant, UserAccountID: testUser},
+		},
+		issues: map[string]*store.ReconciliationIssue{},
+	}
+
+	reconciler := NewReconciler(fake, NewMirrorClient(mirror.URL), testToken, testOperator, log.New(&strings.Builder{}, "", 0))
+	reconciler.now = func() time.Time { return now }
+
+	recorded, err := reconciler.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, recorded)
+
+	mismatch := fake.issues[store.ReconciliationAmountMismatch+"|0.0.200@1699998100.000000001"]
+	require.NotNil(t, mismatch)
+	assert.Equal(t, "t-mismatch", mismatch.TransactionID)
+	assert.Equal(t, money.Amount(900), *mismatch.LedgerAmount)
+
+	missing := fake.issues[store.ReconciliationMissingOnLedger+"|0.0.200@1699998400.000000001"]
+	require.NotNil(t, missing)
+	assert.Equal(t, "t-missing", missing.TransactionID)
+
+	orphanIssue := fake.issues[store.ReconciliationOrphanTransfer+"|0.0.200@1699998200.000000001"]
+	require.NotNil(t, orphanIssue)
+	assert.Equal(t, "m-1", orphanIssue.MerchantID)
+	assert.Equal(t, money.Amount(500), *orphanIssue.LedgerAmount)
+
+	recorded, err = reconciler.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, recorded, "issues are recorded once")
+}
+
+func TestTransactionIDFormats(t *testing.T) {
+	tests := []struct {
+		sdk    string
+		mirror string
+	}{
+		{"0.0.5005@1700000000.000000001", "0.0.5005-1700000000-000000001"},
+		{"0.0.5005@1700000000.123456789?scheduled", "0.0.5005-1700000000-123456789"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.mirror, MirrorTransactionID(tt.sdk))
+		assert.Equal(t, tt.mirror, MirrorTransactionID(tt.mirror))
+		assert.Equal(t, strings.Split(tt.sdk, "?")[0], SDKTransactionID(tt.mirror))
+	}
+	assert.Equal(t, fmt.Sprintf("%d.%09d", 1700000000, 5), mirrorTimestamp(time.Unix(1700000000, 5)))
+}
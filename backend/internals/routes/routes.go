@@ -4,6 +4,9 @@ import (
 	"net/http"
 
 	"github.com/divin3circle/orcus/backend/internals/app"
+	"github.com/divin3circle/orcus/backend/internals/ratelimit"
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/divin3circle/orcus/backend/internals/tokens"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
@@ -33,6 +36,9 @@ func SetUpRoutes(orcus *app.Application) *chi.Mux {
 		})
 	})
 
+	// Event stream tokens from the query string, stripped before logging
+	r.Use(orcus.Middleware.TokenFromQuery)
+
 	// Request logging middleware
 	r.Use(middleware.Logger)
 
@@ -41,52 +47,209 @@ func SetUpRoutes(orcus *app.Application) *chi.Mux {
 
 	r.Group(func (r chi.Router) {
 		r.Use(orcus.Middleware.Authenticate)
-		r.Post("/shops", orcus.Middleware.RequireAuthenticatedMerchant(orcus.ShopHandler.HandlerCreateShop))
-		r.Post("/withdraw", orcus.Middleware.RequireAuthenticatedMerchant(orcus.MerchantHandler.HandleWithdraw))
+		r.Post("/shops", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionShopsManage, orcus.ShopHandler.HandlerCreateShop)))
+		r.Post("/withdraw", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionWithdrawalsCreate, orcus.MerchantHandler.HandleWithdraw)))
 
 		r.Get("/shops/{id}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.ShopHandler.HandlerGetShopByID))
 		r.Get("/merchants-id/{id}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.MerchantHandler.HandleGetMerchantByID))
 		r.Get("/merchants/{username}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.MerchantHandler.HandleGetMerchantByUsername))
-		r.Get("/withdrawals", orcus.Middleware.RequireAuthenticatedMerchant(orcus.MerchantHandler.HandleGetWithdrawals))
-		r.Get("/transactions/{id}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.TransactionHandler.HandleGetTransactionByID))
-		r.Get("/transactions/shop/{id}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.TransactionHandler.HandleGetTransactionsByShopID))
-		r.Get("/transactions/merchant/{id}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.TransactionHandler.HandleGetTransactionsByMerchantID))
+		r.Get("/merchants/notification-key", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionPaymentsRead, orcus.NotificationHandler.HandleGetMerchantNotificationKey)))
+		r.Get("/withdrawals", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionWithdrawalsRead, orcus.MerchantHandler.HandleGetWithdrawals)))
+		r.Get("/withdrawals/{id}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionWithdrawalsRead, orcus.WithdrawalApprovalHandler.HandleGetWithdrawal)))
+		r.Post("/withdrawals/{id}/signatures/prepare", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionWithdrawalsCreate, orcus.WithdrawalApprovalHandler.HandlePrepareApproval)))
+		r.Post("/withdrawals/{id}/signatures", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionWithdrawalsCreate, orcus.WithdrawalApprovalHandler.HandleSubmitApproval)))
+		r.Post("/withdrawals/{id}/cancel", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionWithdrawalsCreate, orcus.WithdrawalApprovalHandler.HandleCancelWithdrawal)))
+		r.Get("/withdrawal-approvers", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionWithdrawalsRead, orcus.WithdrawalApprovalHandler.HandleGetApprovers)))
+		r.Post("/withdrawal-approvers", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionSettingsManage, orcus.WithdrawalApprovalHandler.HandleCreateApprover)))
+		r.Delete("/withdrawal-approvers/{id}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionSettingsManage, orcus.WithdrawalApprovalHandler.HandleRevokeApprover)))
+		r.Get("/merchant/transactions/{id}", orcus.Middleware.RequireMerchantScope(tokens.ScopePaymentsRead, orcus.Middleware.RequirePermission(store.PermissionPaymentsRead, orcus.TransactionHandler.HandleGetMerchantTransactionByID)))
+		r.Get("/transactions/shop/{id}", orcus.Middleware.RequireMerchantScope(tokens.ScopePaymentsRead, orcus.Middleware.RequirePermission(store.PermissionPaymentsRead, orcus.TransactionHandler.HandleGetTransactionsByShopID)))
+		r.Get("/transactions/merchant/{id}", orcus.Middleware.RequireMerchantScope(tokens.ScopePaymentsRead, orcus.Middleware.RequirePermission(store.PermissionPaymentsRead, orcus.TransactionHandler.HandleGetTransactionsByMerchantID)))
+		r.Get("/transactions/{id}/proof", orcus.Middleware.RequireMerchantScope(tokens.ScopePaymentsRead, orcus.Middleware.RequirePermission(store.PermissionReportsRead, orcus.AnchorHandler.HandleGetTransactionProof)))
+		r.Get("/transactions/{id}/refunds", orcus.Middleware.RequireMerchantScope(tokens.ScopePaymentsRead, orcus.Middleware.RequirePermission(store.PermissionPaymentsRead, orcus.RefundHandler.HandleGetRefunds)))
+		r.Post("/transactions/{id}/refunds", orcus.Middleware.RequireMerchantScope(tokens.ScopePaymentsCreate, orcus.Middleware.RequirePermission(store.PermissionPaymentsCreate, orcus.RefundHandler.HandleCreateRefund)))
+		r.Post("/transactions/{id}/refunds/{refund_id}/submit", orcus.Middleware.RequireMerchantScope(tokens.ScopePaymentsCreate, orcus.Middleware.RequirePermission(store.PermissionPaymentsCreate, orcus.RefundHandler.HandleSubmitRefund)))
+		r.Post("/payment-requests", orcus.Middleware.RequireMerchantScope(tokens.ScopePaymentsCreate, orcus.Middleware.RequirePermission(store.PermissionPaymentsCreate, orcus.PaymentRequestHandler.HandleCreatePaymentRequest)))
+		r.Get("/payment-requests", orcus.Middleware.RequireMerchantScope(tokens.ScopePaymentsRead, orcus.Middleware.RequirePermission(store.PermissionPaymentsRead, orcus.PaymentRequestHandler.HandleGetPaymentRequests)))
+		r.Get("/payment-requests/{id}", orcus.Middleware.RequireMerchantScope(tokens.ScopePaymentsRead, orcus.Middleware.RequirePermission(store.PermissionPaymentsRead, orcus.PaymentRequestHandler.HandleGetPaymentRequest)))
+		r.Post("/payment-requests/{id}/cancel", orcus.Middleware.RequireMerchantScope(tokens.ScopePaymentsCreate, orcus.Middleware.RequirePermission(store.PermissionPaymentsCreate, orcus.PaymentRequestHandler.HandleCancelPaymentRequest)))
+		r.Get("/mandates", orcus.Middleware.RequireMerchantScope(tokens.ScopePaymentsRead, orcus.Middleware.RequirePermission(store.PermissionPaymentsRead, orcus.MandateHandler.HandleGetMandates)))
+		r.Get("/mandates/{id}", orcus.Middleware.RequireMerchantScope(tokens.ScopePaymentsRead, orcus.Middleware.RequirePermission(store.PermissionPaymentsRead, orcus.MandateHandler.HandleGetMandate)))
+		r.Post("/mandates/{id}/cancel", orcus.Middleware.RequireMerchantScope(tokens.ScopePaymentsCreate, orcus.Middleware.RequirePermission(store.PermissionPaymentsCreate, orcus.MandateHandler.HandleCancelMandate)))
 		r.Get("/my-campaigns/{id}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.ShopHandler.HandlerGetShopCampaigns))
 		r.Get("/shops/merchant/{id}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.ShopHandler.HandlerGetShopsByMerchantID))
 		r.Get("/shops/campaigns/{id}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.ShopHandler.HandlerGetShopCampaignsByShopID))
 		r.Get("/shops/campaigns/entries/{id}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.ShopHandler.HandlerGetUserCampaignsEntryByShopID))
 		r.Get("/shops/campaigns/participants/{id}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.ShopHandler.HandleGetCampaignParticipants))
-		r.Post("/shops/campaigns/end/{id}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.ShopHandler.HandlerEndCampaign))
-		r.Put("/shops/{id}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.ShopHandler.HandlerUpdateShop))
+		r.Post("/shops/campaigns/end/{id}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionCampaignsManage, orcus.ShopHandler.HandlerEndCampaign)))
+		r.Put("/shops/{id}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionShopsManage, orcus.ShopHandler.HandlerUpdateShop)))
+		r.Get("/shops/{id}/qr", orcus.Middleware.RequireMerchantScope(tokens.ScopePaymentsRead, orcus.Middleware.RequirePermission(store.PermissionPaymentsRead, orcus.QRHandler.HandleGetShopQR)))
+		r.Post("/shops/{id}/qr", orcus.Middleware.RequireMerchantScope(tokens.ScopePaymentsCreate, orcus.Middleware.RequirePermission(store.PermissionPaymentsCreate, orcus.QRHandler.HandleCreateShopQR)))
+
+		r.Post("/api-keys", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionSettingsManage, orcus.APIKeyHandler.HandleCreateAPIKey)))
+		r.Get("/api-keys", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionSettingsManage, orcus.APIKeyHandler.HandleGetAPIKeys)))
+		r.Post("/api-keys/{id}/rotate", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionSettingsManage, orcus.APIKeyHandler.HandleRotateAPIKey)))
+		r.Delete("/api-keys/{id}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionSettingsManage, orcus.APIKeyHandler.HandleRevokeAPIKey)))
+
+		r.Post("/webhooks", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionSettingsManage, orcus.WebhookHandler.HandleCreateWebhookEndpoint)))
+		r.Get("/webhooks", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionSettingsManage, orcus.WebhookHandler.HandleGetWebhookEndpoints)))
+		r.Delete("/webhooks/{id}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionSettingsManage, orcus.WebhookHandler.HandleDeleteWebhookEndpoint)))
+		r.Get("/webhooks/deliveries", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionSettingsManage, orcus.WebhookHandler.HandleGetWebhookDeliveries)))
+		r.Post("/webhooks/deliveries/{id}/replay", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionSettingsManage, orcus.WebhookHandler.HandleReplayWebhookDelivery)))
+
+		r.Get("/events/stream", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionPaymentsRead, orcus.EventHandler.HandleMerchantStream)))
+		r.Get("/balances", orcus.Middleware.RequireMerchantScope(tokens.ScopePaymentsRead, orcus.Middleware.RequirePermission(store.PermissionReportsRead, orcus.BalanceHandler.HandleGetMerchantBalances)))
+
+		r.Get("/team/me", orcus.Middleware.RequireAuthenticatedMerchant(orcus.TeamHandler.HandleGetCurrentMember))
+		r.Get("/team/members", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionTeamManage, orcus.TeamHandler.HandleGetMembers)))
+		r.Post("/team/invitations", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionTeamManage, orcus.TeamHandler.HandleCreateInvitation)))
+		r.Put("/team/members/{id}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionTeamManage, orcus.TeamHandler.HandleUpdateMember)))
+		r.Delete("/team/members/{id}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionTeamManage, orcus.TeamHandler.HandleDisableMember)))
+
+		r.Post("/shops/{id}/pos-devices", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionShopsManage, orcus.POSHandler.HandleRegisterDevice)))
+		r.Get("/shops/{id}/pos-devices", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionShopsManage, orcus.POSHandler.HandleGetDevices)))
+		r.Delete("/pos-devices/{id}", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionShopsManage, orcus.POSHandler.HandleRevokeDevice)))
+		r.Get("/shops/{id}/z-report", orcus.Middleware.RequireMerchantScope(tokens.ScopePaymentsRead, orcus.Middleware.RequirePermission(store.PermissionReportsRead, orcus.POSHandler.HandleGetShopZReport)))
+
+		r.Get("/pos/session", orcus.Middleware.RequirePOSDevice(orcus.POSHandler.HandleGetSession))
+		r.Get("/pos/transactions", orcus.Middleware.RequirePOSDevice(orcus.POSHandler.HandleGetTodayTransactions))
+		r.Get("/pos/z-report", orcus.Middleware.RequirePOSDevice(orcus.POSHandler.HandleGetDeviceZReport))
+		r.Post("/pos/payment-requests", orcus.Middleware.RequirePOSDevice(orcus.PaymentRequestHandler.HandleCreatePaymentRequest))
+		r.Get("/pos/payment-requests/{id}", orcus.Middleware.RequirePOSDevice(orcus.PaymentRequestHandler.HandleGetPaymentRequest))
+
+		r.Get("/reconciliation/report", orcus.Middleware.RequireMerchantScope(tokens.ScopePaymentsRead, orcus.Middleware.RequirePermission(store.PermissionReportsRead, orcus.ReconciliationHandler.HandleGetReconciliationReport)))
+		r.Get("/audit-events", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionAuditRead, orcus.AuditHandler.HandleGetMerchantAuditEvents)))
+
+		r.Get("/kyc", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionSettingsManage, orcus.KYCHandler.HandleGetMerchantKYC)))
+		r.Post("/kyc/submissions", orcus.Middleware.RequireAuthenticatedMerchant(orcus.Middleware.RequirePermission(store.PermissionSettingsManage, orcus.KYCHandler.HandleCreateMerchantSubmission)))
 	})
 
+
 	r.Group(func (r chi.Router) {
 		r.Use(orcus.Middleware.AuthenticateUser)
 
 		r.Get("/users/{username}", orcus.Middleware.RequireAuthenticatedUser(orcus.UserHandler.HandleGetUserByUsername))
+		r.Get("/users/notification-key", orcus.Middleware.RequireAuthenticatedUser(orcus.NotificationHandler.HandleGetUserNotificationKey))
 		r.Get("/users-id/{id}", orcus.Middleware.RequireAuthenticatedUser(orcus.UserHandler.HandleGetUserByID))
 		r.Get("/transactions/user/{id}", orcus.Middleware.RequireAuthenticatedUser(orcus.TransactionHandler.HandleGetTransactionsByUserID))
 		r.Get("/transactions/{id}", orcus.Middleware.RequireAuthenticatedUser(orcus.TransactionHandler.HandleGetTransactionByID))
 		r.Get("/purchases/{id}", orcus.Middleware.RequireAuthenticatedUser(orcus.UserHandler.HandleGetUserPurchases))
 		r.Get("/user/campaigns/{id}", orcus.Middleware.RequireAuthenticatedUser(orcus.UserHandler.HandleGetUserCampaigns))
 		r.Post("/campaigns", orcus.Middleware.RequireAuthenticatedUser(orcus.UserHandler.HandleJoinCampaign))
-		r.Get("/user/shops/{id}", orcus.Middleware.RequireAuthenticatedUser(orcus.ShopHandler.HandlerGetShopByID))
-		r.Get("/user/shops/campaigns/{id}", orcus.Middleware.RequireAuthenticatedUser(orcus.ShopHandler.HandlerGetShopCampaignsByShopID))
+		r.Get("/user/shops/{id}", orcus.Middleware.RequireAuthenticatedUser(orcus.ShopHandler.HandlerGetUserShopByID))
+		r.Get("/user/shops/campaigns/{id}", orcus.Middleware.RequireAuthenticatedUser(orcus.ShopHandler.HandlerGetUserShopCampaignsByShopID))
+		r.Get("/user/events/stream", orcus.Middleware.RequireAuthenticatedUser(orcus.EventHandler.HandleUserStream))
+		r.Get("/user/balances", orcus.Middleware.RequireAuthenticatedUser(orcus.BalanceHandler.HandleGetUserBalances))
+		r.Get("/user/activity", orcus.Middleware.RequireAuthenticatedUser(orcus.TransferHandler.HandleGetActivity))
+		r.Get("/pay/{payment_id}", orcus.Middleware.RequireAuthenticatedUser(orcus.QRHandler.HandleResolvePaymentID))
+		r.Post("/pay/resolve", orcus.Middleware.RequireAuthenticatedUser(orcus.QRHandler.HandleResolvePayload))
+		r.Get("/user/payment-requests", orcus.Middleware.RequireAuthenticatedUser(orcus.PaymentRequestHandler.HandleGetUserPaymentRequests))
+		r.Get("/user/payment-requests/{id}", orcus.Middleware.RequireAuthenticatedUser(orcus.PaymentRequestHandler.HandleGetUserPaymentRequest))
+		r.Post("/user/payment-requests/{id}/pay", orcus.Middleware.RequireAuthenticatedUser(orcus.PaymentRequestHandler.HandlePayPaymentRequest))
+		r.Post("/user/mandates", orcus.Middleware.RequireAuthenticatedUser(orcus.MandateHandler.HandleCreateMandate))
+		r.Get("/user/mandates", orcus.Middleware.RequireAuthenticatedUser(orcus.MandateHandler.HandleGetUserMandates))
+		r.Get("/user/mandates/{id}", orcus.Middleware.RequireAuthenticatedUser(orcus.MandateHandler.HandleGetUserMandate))
+		r.Post("/user/mandates/{id}/pause", orcus.Middleware.RequireAuthenticatedUser(orcus.MandateHandler.HandlePauseMandate))
+		r.Post("/user/mandates/{id}/resume", orcus.Middleware.RequireAuthenticatedUser(orcus.MandateHandler.HandleResumeMandate))
+		r.Post("/user/mandates/{id}/cancel", orcus.Middleware.RequireAuthenticatedUser(orcus.MandateHandler.HandleCancelUserMandate))
+		r.Get("/user/kyc", orcus.Middleware.RequireAuthenticatedUser(orcus.KYCHandler.HandleGetUserKYC))
+		r.Post("/user/kyc/submissions", orcus.Middleware.RequireAuthenticatedUser(orcus.KYCHandler.HandleCreateUserSubmission))
 
 		r.Post("/campaigns/is-participant", orcus.Middleware.RequireAuthenticatedUser(orcus.UserHandler.HandleIsParticipant))
 		r.Post("/campaigns/update", orcus.Middleware.RequireAuthenticatedUser(orcus.UserHandler.HandleUpdateCampaignEntry))
 		r.Get("/campaigns/{id}", orcus.Middleware.RequireAuthenticatedUser(orcus.ShopHandler.HandlerGetShopCampaignByCampaignID))
 		r.Post("/transactions", orcus.Middleware.RequireAuthenticatedUser(orcus.TransactionHandler.HandleCreateTransaction))
+		r.Post("/transactions/split", orcus.Middleware.RequireAuthenticatedUser(orcus.TransactionHandler.HandleCreateSplitTransaction))
+		r.Post("/transfers", orcus.Middleware.RequireAuthenticatedUser(orcus.TransferHandler.HandleCreateTransfer))
 		r.Post("/purchases", orcus.Middleware.RequireAuthenticatedUser(orcus.UserHandler.HandleBuyToken))
+		r.With(orcus.RateLimiter.LimitByIP("phone-verification", ratelimit.VerificationIPLimit)).Post("/users/phone-verification", orcus.Middleware.RequireAuthenticatedUser(orcus.VerificationHandler.HandleRequestPhoneVerification))
+		r.With(orcus.RateLimiter.LimitByIP("phone-verification", ratelimit.VerificationIPLimit)).Post("/users/phone-verification/confirm", orcus.Middleware.RequireAuthenticatedUser(orcus.VerificationHandler.HandleVerifyPhone))
+	})
+
+	r.Group(func (r chi.Router) {
+		r.Use(orcus.AdminMiddleware.RequireAdmin)
+
+		r.Get("/admin/fee-rules", orcus.FeeHandler.HandleGetFeeRules)
+		r.Post("/admin/fee-rules", orcus.FeeHandler.HandleCreateFeeRule)
+		r.Get("/admin/fee-rules/{id}", orcus.FeeHandler.HandleGetFeeRule)
+		r.Put("/admin/fee-rules/{id}", orcus.FeeHandler.HandleUpdateFeeRule)
+		r.Delete("/admin/fee-rules/{id}", orcus.FeeHandler.HandleDeleteFeeRule)
+
+		r.Get("/admin/me", orcus.AdminHandler.HandleGetCurrentAdmin)
+		r.Get("/admin/admins", orcus.AdminHandler.HandleGetAdmins)
+		r.Post("/admin/admins", orcus.AdminHandler.HandleCreateAdmin)
+		r.Delete("/admin/admins/{id}", orcus.AdminHandler.HandleDisableAdmin)
+		r.Get("/admin/actions", orcus.AdminHandler.HandleGetActions)
+		r.Get("/admin/audit-events", orcus.AuditHandler.HandleGetAuditEvents)
+		r.Get("/admin/audit-events/verify", orcus.AuditHandler.HandleVerifyAuditChain)
+
+		r.Get("/admin/merchants", orcus.AdminHandler.HandleSearchMerchants)
+		r.Get("/admin/merchants/{id}", orcus.AdminHandler.HandleGetMerchant)
+		r.Post("/admin/merchants/{id}/suspend", orcus.AdminHandler.HandleSuspend(store.AdminSubjectMerchant))
+		r.Post("/admin/merchants/{id}/unsuspend", orcus.AdminHandler.HandleUnsuspend(store.AdminSubjectMerchant))
+		r.Get("/admin/users", orcus.AdminHandler.HandleSearchUsers)
+		r.Get("/admin/users/{id}", orcus.AdminHandler.HandleGetUser)
+		r.Post("/admin/users/{id}/suspend", orcus.AdminHandler.HandleSuspend(store.AdminSubjectUser))
+		r.Post("/admin/users/{id}/unsuspend", orcus.AdminHandler.HandleUnsuspend(store.AdminSubjectUser))
+		r.Get("/admin/shops", orcus.AdminHandler.HandleSearchShops)
+		r.Get("/admin/shops/{id}", orcus.AdminHandler.HandleGetShop)
+		r.Post("/admin/shops/{id}/suspend", orcus.AdminHandler.HandleSuspend(store.AdminSubjectShop))
+		r.Post("/admin/shops/{id}/unsuspend", orcus.AdminHandler.HandleUnsuspend(store.AdminSubjectShop))
+
+		r.Get("/admin/kyc/submissions", orcus.KYCHandler.HandleGetSubmissions)
+		r.Post("/admin/kyc/submissions/{id}/approve", orcus.KYCHandler.HandleApproveSubmission)
+		r.Post("/admin/kyc/submissions/{id}/reject", orcus.KYCHandler.HandleRejectSubmission)
+		r.Get("/admin/kyc/limits", orcus.KYCHandler.HandleGetLimits)
+		r.Put("/admin/kyc/limits", orcus.KYCHandler.HandleUpdateLimit)
+		r.Get("/admin/fraud/rules", orcus.FraudHandler.HandleGetRules)
+		r.Post("/admin/fraud/rules", orcus.FraudHandler.HandleCreateRule)
+		r.Put("/admin/fraud/rules/{id}", orcus.FraudHandler.HandleUpdateRule)
+		r.Get("/admin/fraud/decisions", orcus.FraudHandler.HandleGetDecisions)
+		r.Get("/admin/fraud/decisions/{id}", orcus.FraudHandler.HandleGetDecision)
+		r.Post("/admin/fraud/decisions/{id}/review", orcus.FraudHandler.HandleReviewDecision)
+		r.Get("/admin/aml/cases", orcus.AMLHandler.HandleGetCases)
+		r.Get("/admin/aml/cases/export", orcus.AMLHandler.HandleExportCases)
+		r.Get("/admin/aml/cases/{id}", orcus.AMLHandler.HandleGetCase)
+		r.Post("/admin/aml/cases/{id}/report", orcus.AMLHandler.HandleReportCase)
+		r.Post("/admin/aml/cases/{id}/dismiss", orcus.AMLHandler.HandleDismissCase)
+
+		r.Get("/admin/transactions", orcus.AdminHandler.HandleSearchTransactions)
+		r.Get("/admin/transactions/{id}/proof", orcus.AnchorHandler.HandleGetAdminTransactionProof)
+		r.Get("/admin/anchors", orcus.AnchorHandler.HandleGetAnchors)
+		r.Get("/admin/failures", orcus.AdminHandler.HandleGetFailures)
+		r.Get("/admin/stats", orcus.AdminHandler.HandleGetStats)
 	})
 
 	r.Get("/health", orcus.HealthCheck)
+	r.Get("/notifications/schema", orcus.NotificationHandler.HandleGetSchema)
+
+	r.Group(func (r chi.Router) {
+		r.Use(orcus.RateLimiter.LimitByIP("register", ratelimit.RegisterIPLimit))
 
-	r.Post("/register", orcus.MerchantHandler.HandleCreateMerchant)
-	r.Post("/login", orcus.TokenHandler.HandleCreateToken)
+		r.Post("/register", orcus.MerchantHandler.HandleCreateMerchant)
+		r.Post("/register-user", orcus.UserHandler.HandleCreateUser)
+		r.Post("/team/invitations/accept", orcus.TeamHandler.HandleAcceptInvitation)
+	})
 
-	r.Post("/register-user", orcus.UserHandler.HandleCreateUser)
-	r.Post("/login-user", orcus.TokenHandler.HandleCreateUserToken)
+	r.Group(func (r chi.Router) {
+		r.Use(orcus.RateLimiter.LimitByIP("login", ratelimit.LoginIPLimit))
+		r.Use(orcus.RateLimiter.LimitByUsername("login", ratelimit.LoginUsernameLimit))
+
+		r.Post("/login", orcus.TokenHandler.HandleCreateToken)
+		r.Post("/login-user", orcus.TokenHandler.HandleCreateUserToken)
+		r.Post("/login-member", orcus.TokenHandler.HandleCreateMemberToken)
+		r.Post("/admin/login", orcus.TokenHandler.HandleCreateAdminToken)
+	})
+
+	r.Group(func (r chi.Router) {
+		r.Use(orcus.RateLimiter.LimitByIP("password-reset", ratelimit.VerificationIPLimit))
+		r.Use(orcus.RateLimiter.LimitByUsername("password-reset", ratelimit.LoginUsernameLimit))
+
+		r.Post("/password-reset", orcus.VerificationHandler.HandleRequestPasswordReset)
+		r.Post("/password-reset/confirm", orcus.VerificationHandler.HandleResetPassword)
+		r.Post("/password-reset-user", orcus.VerificationHandler.HandleRequestUserPasswordReset)
+		r.Post("/password-reset-user/confirm", orcus.VerificationHandler.HandleResetUserPassword)
+	})
 
 	return r
 }
@@ -0,0 +1,115 @@
+package routes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/divin3circle/orcus/backend/internals/api"
+	"github.com/divin3circle/orcus/backend/internals/app"
+	"github.com/divin3circle/orcus/backend/internals/middleware"
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+type fakeMerchantStore struct {
+	store.MerchantStore
+	merchants map[string]*store.Merchant
+}
+
+func (fs *fakeMerchantStore) GetMerchantToken(scope, tokenPlainText string) (*store.Merchant, error) {
+	return fs.merchants[tokenPlainText], nil
+}
+
+type fakeMemberStore struct {
+	store.MerchantMemberStore
+}
+
+func (fs *fakeMemberStore) GetMemberToken(scope string, tokenPlainText string) (*store.Merchant, *store.MerchantMember, error) {
+	return nil, nil, nil
+}
+
+type fakeUserStore struct {
+	store.UserStore
+	users map[string]*store.User
+}
+
+func (fs *fakeUserStore) GetUserToken(scope, tokenPlainText string) (*store.User, error) {
+	return fs.users[tokenPlainText], nil
+}
+
+type fakeTransactionStore struct {
+	store.TransactionStore
+	transactions map[string]*store.Transaction
+}
+
+func (fs *fakeTransactionStore) GetTransactionByID(id string) (*store.Transaction, error) {
+	transaction, ok := fs.transactions[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *transaction
+	return &copied, nil
+}
+
+func TestTransactionLookupRoutes(t *testing.T) {
+	merchantStore := &fakeMerchantStore{merchants: map[string]*store.Merchant{
+		"merchant-a-token": {ID: "merchant-a"},
+		"merchant-b-token": {ID: "merchant-b"},
+	}}
+	userStore := &fakeUserStore{users: map[string]*store.User{
+		"user-a-token": {ID: "user-a"},
+		"user-b-token": {ID: "user-b"},
+	}}
+	transactionStore := &fakeTransactionStore{transactions: map[string]*store.Transaction{
+		"txn-1": {ID: "txn-1", ShopID: "shop-a", UserID: "user-a", MerchantID: "merchant-a", Kind: store.TransactionKindPayment},
+	}}
+	orcus := &app.Application{
+		Logger:             log.New(io.Discard, "", 0),
+		Middleware:         middleware.NewMerchantMiddleware(merchantStore, userStore, nil, &fakeMemberStore{}, nil),
+		TransactionHandler: &api.TransactionHandler{TransactionStore: transactionStore, Logger: log.New(io.Discard, "", 0)},
+	}
+	router := SetUpRoutes(orcus)
+
+	get := func(target string, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		token  string
+		status int
+	}{
+		{"merchant reads own payment", "/merchant/transactions/txn-1", "merchant-a-token", http.StatusOK},
+		{"merchant reads another merchant's payment", "/merchant/transactions/txn-1", "merchant-b-token", http.StatusNotFound},
+		{"merchant reads missing payment", "/merchant/transactions/txn-2", "merchant-a-token", http.StatusNotFound},
+		{"user reads own payment", "/transactions/txn-1", "user-a-token", http.StatusOK},
+		{"user reads another user's payment", "/transactions/txn-1", "user-b-token", http.StatusNotFound},
+		{"merchant token on the user route", "/transactions/txn-1", "merchant-a-token", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(tt.target, tt.token)
+			require.Equal(t, tt.status, rec.Code, rec.Body.String())
+			if tt.status != http.StatusOK {
+				return
+			}
+			var body struct {
+				Transaction store.Transaction `json:"transaction"`
+			}
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+			assert.Equal(t, "txn-1", body.Transaction.ID)
+		})
+	}
+}
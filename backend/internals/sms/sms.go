@@ -0,0 +1,35 @@
+package sms
+
+import (
+	"log"
+	"os"
+)
+
+// Sender delivers a text message to a mobile number. Implementations wrap a
+// provider such as Africa's Talking; LogSender is used locally.
+type Sender interface {
+	Send(to string, message string) error
+}
+
+type LogSender struct {
+	Logger *log.Logger
+}
+
+func NewLogSender(logger *log.Logger) *LogSender {
+	return &LogSender{Logger: logger}
+}
+
+func (ls *LogSender) Send(to string, message string) error {
+	ls.Logger.Printf("SMS to %s: %s", to, message)
+	return nil
+}
+
+// NewSenderFromEnv picks a Sender based on SMS_PROVIDER. Only the log sender
+// ships with the backend, other providers plug in here.
+func NewSenderFromEnv(logger *log.Logger) Sender {
+	provider := os.Getenv("SMS_PROVIDER")
+	if provider != "" && provider != "log" {
+		logger.Printf("WARNING: unknown SMS_PROVIDER %q, falling back to log sender", provider)
+	}
+	return NewLogSender(logger)
+}
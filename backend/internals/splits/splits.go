@@ -0,0 +1,107 @@
+// Package splits divides one customer payment among several payees.
+//
+// Each share is either a fixed amount or a weight in basis points. Fixed
+// amounts are taken off the total first and the basis point shares divide
+// what is left, so a marketplace can pay a fixed delivery charge and split
+// the rest by commission rate. The parts always add up to the total.
+package splits
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+)
+
+const (
+	// WholeBasisPoints is 100%.
+	WholeBasisPoints = 10000
+
+	// MaxPayees keeps a split within one Hedera transfer, which allows ten
+	// token legs: the payer, the fee account and up to eight payees.
+	MaxPayees = 8
+)
+
+var (
+	ErrNoPayees       = errors.New("at least two payees are required")
+	ErrTooManyPayees  = fmt.Errorf("at most %d payees are allowed", MaxPayees)
+	ErrShareAmbiguous = errors.New("each payee needs exactly one of amount or basis_points")
+	ErrBasisPoints    = errors.New("basis_points must add up to 10000")
+	ErrTotalMismatch  = errors.New("payee amounts do not add up to the total")
+	ErrShareTooSmall  = errors.New("a payee's share rounds to zero")
+)
+
+// Share is one payee's part of a split. Exactly one field is set.
+type Share struct {
+	Amount      money.Amount
+	BasisPoints int64
+}
+
+// Allocate returns each share's amount and the total they add up to. A zero
+// total is allowed only when every share is fixed, and is then their sum.
+func Allocate(total money.Amount, shares []Share) ([]money.Amount, money.Amount, error) {
+	if len(shares) < 2 {
+		return nil, 0, ErrNoPayees
+	}
+	if len(shares) > MaxPayees {
+		return nil, 0, ErrTooManyPayees
+	}
+	if total < 0 {
+		return nil, 0, errors.New("total must not be negative")
+	}
+
+	parts := make([]money.Amount, len(shares))
+	var fixed money.Amount
+	var weighted []int
+	var weights []int64
+	var basisPoints int64
+	for i, share := range shares {
+		if (share.Amount > 0) == (share.BasisPoints > 0) || share.Amount < 0 || share.BasisPoints < 0 {
+			return nil, 0, ErrShareAmbiguous
+		}
+		if share.Amount > 0 {
+			var err error
+			fixed, err = fixed.Add(share.Amount)
+			if err != nil {
+				return nil, 0, err
+			}
+			parts[i] = share.Amount
+			continue
+		}
+		weighted = append(weighted, i)
+		weights = append(weights, share.BasisPoints)
+		basisPoints += share.BasisPoints
+	}
+
+	if len(weighted) == 0 {
+		if total != 0 && total != fixed {
+			return nil, 0, ErrTotalMismatch
+		}
+		return parts, fixed, nil
+	}
+	if basisPoints != WholeBasisPoints {
+		return nil, 0, ErrBasisPoints
+	}
+	if total == 0 {
+		return nil, 0, errors.New("a total is required when payees use basis_points")
+	}
+	rest, err := total.Sub(fixed)
+	if err != nil {
+		return nil, 0, err
+	}
+	if rest <= 0 {
+		return nil, 0, ErrTotalMismatch
+	}
+
+	allocated, err := rest.Allocate(weights...)
+	if err != nil {
+		return nil, 0, err
+	}
+	for j, i := range weighted {
+		if allocated[j] <= 0 {
+			return nil, 0, ErrShareTooSmall
+		}
+		parts[i] = allocated[j]
+	}
+	return parts, total, nil
+}
@@ -0,0 +1,87 @@
+package splits
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+)
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name      string
+		total     money.Amount
+		shares    []Share
+		want      []money.Amount
+		wantTotal money.Amount
+	}{
+		{
+			name:      "fixed amounts without total",
+			shares:    []Share{{Amount: 1500}, {Amount: 2500}},
+			want:      []money.Amount{1500, 2500},
+			wantTotal: 4000,
+		},
+		{
+			name:      "fixed amounts matching total",
+			total:     4000,
+			shares:    []Share{{Amount: 1500}, {Amount: 2500}},
+			want:      []money.Amount{1500, 2500},
+			wantTotal: 4000,
+		},
+		{
+			name:      "basis points",
+			total:     10000,
+			shares:    []Share{{BasisPoints: 7000}, {BasisPoints: 3000}},
+			want:      []money.Amount{7000, 3000},
+			wantTotal: 10000,
+		},
+		{
+			name:      "basis points leftover cent",
+			total:     100,
+			shares:    []Share{{BasisPoints: 3333}, {BasisPoints: 3333}, {BasisPoints: 3334}},
+			want:      []money.Amount{33, 33, 34},
+			wantTotal: 100,
+		},
+		{
+			name:      "fixed then basis points of the rest",
+			total:     10500,
+			shares:    []Share{{BasisPoints: 8000}, {Amount: 500}, {BasisPoints: 2000}},
+			want:      []money.Amount{8000, 500, 2000},
+			wantTotal: 10500,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total, err := Allocate(tt.total, tt.shares)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantTotal, total)
+		})
+	}
+}
+
+func TestAllocateErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		total  money.Amount
+		shares []Share
+		want   error
+	}{
+		{"single payee", 1000, []Share{{Amount: 1000}}, ErrNoPayees},
+		{"too many payees", 0, make([]Share, MaxPayees+1), ErrTooManyPayees},
+		{"both set", 1000, []Share{{Amount: 500, BasisPoints: 5000}, {Amount: 500}}, ErrShareAmbiguous},
+		{"neither set", 1000, []Share{{}, {Amount: 1000}}, ErrShareAmbiguous},
+		{"fixed over total", 1000, []Share{{Amount: 600}, {Amount: 600}}, ErrTotalMismatch},
+		{"basis points short", 1000, []Share{{BasisPoints: 5000}, {BasisPoints: 4000}}, ErrBasisPoints},
+		{"nothing left for basis points", 1000, []Share{{Amount: 1000}, {BasisPoints: 10000}}, ErrTotalMismatch},
+		{"share rounds to zero", 1, []Share{{BasisPoints: 5000}, {BasisPoints: 5000}}, ErrShareTooSmall},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Allocate(tt.total, tt.shares)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,652 @@
+package store
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+	"github.com/divin3circle/orcus/backend/internals/tokens"
+)
+
+const (
+	AdminStatusActive   = "active"
+	AdminStatusDisabled = "disabled"
+)
+
+// Subjects an admin can suspend.
+const (
+	AdminSubjectMerchant = "merchant"
+	AdminSubjectUser     = "user"
+	AdminSubjectShop     = "shop"
+)
+
+// Values of the status filter when searching accounts.
+const (
+	AccountStatusActive    = "active"
+	AccountStatusSuspended = "suspended"
+)
+
+const (
+	DefaultAdminPageSize = 50
+	MaxAdminPageSize     = 200
+)
+
+var ErrAdminUsernameTaken = errors.New("admin username is already taken")
+
+// suspendableTables maps a subject type to its table. Subject types come
+// from the URL, so they are never interpolated directly.
+var suspendableTables = map[string]string{
+	AdminSubjectMerchant: "merchants",
+	AdminSubjectUser:     "users",
+	AdminSubjectShop:     "shops",
+}
+
+// Admin is a platform operator. Operators act across every merchant and
+// user, so they sign in through their own login and token table.
+type Admin struct {
+	ID           string     `json:"id"`
+	Username     string     `json:"username"`
+	PasswordHash password   `json:"-"`
+	Status       string     `json:"status"`
+	LastLoginAt  *time.Time `json:"last_login_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// AdminAction is one entry in the admin audit trail. AdminID is empty for
+// actions taken with the shared ADMIN_API_TOKEN.
+type AdminAction struct {
+	ID          string          `json:"id"`
+	AdminID     string          `json:"admin_id"`
+	Actor       string          `json:"actor"`
+	Action      string          `json:"action"`
+	SubjectType string          `json:"subject_type"`
+	SubjectID   string          `json:"subject_id"`
+	Details     json.RawMessage `json:"details"`
+	IPAddress   string          `json:"ip_address"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// AdminSearch filters the merchant, user and shop listings. Query matches
+// usernames, names, mobile numbers, Hedera accounts and ids.
+type AdminSearch struct {
+	Query  string
+	Status string
+	Limit  int
+	Offset int
+}
+
+// AdminTransactionFilter narrows the system-wide transaction listing. Zero
+// values match everything.
+type AdminTransactionFilter struct {
+	MerchantID string
+	ShopID     string
+	UserID     string
+	Status     string
+	Kind       string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+}
+
+type AdminActionFilter struct {
+	AdminID     string
+	SubjectType string
+	SubjectID   string
+	Limit       int
+	Offset      int
+}
+
+// DailyVolume is one day of PlatformStats, in UTC.
+type DailyVolume struct {
+	Date         string       `json:"date"`
+	PaymentCount int          `json:"payment_count"`
+	Volume       money.Amount `json:"volume"`
+	PaymentFees  money.Amount `json:"payment_fees"`
+}
+
+// PlatformStats totals the platform's activity in [From, To). Volume counts
+// each merchant's part of a split payment once. FeeRevenue is what the
+// platform kept from payments, transfers and withdrawals.
+type PlatformStats struct {
+	From            time.Time     `json:"from"`
+	To              time.Time     `json:"to"`
+	PaymentCount    int           `json:"payment_count"`
+	Volume          money.Amount  `json:"volume"`
+	PaymentFees     money.Amount  `json:"payment_fees"`
+	TransferCount   int           `json:"transfer_count"`
+	TransferVolume  money.Amount  `json:"transfer_volume"`
+	TransferFees    money.Amount  `json:"transfer_fees"`
+	WithdrawalCount int           `json:"withdrawal_count"`
+	WithdrawalFees  money.Amount  `json:"withdrawal_fees"`
+	FeeRevenue      money.Amount  `json:"fee_revenue"`
+	RefundCount     int           `json:"refund_count"`
+	Refunded        money.Amount  `json:"refunded"`
+	Daily           []DailyVolume `json:"daily"`
+}
+
+type PostgresAdminStore struct {
+	db *sql.DB
+}
+
+func NewPostgresAdminStore(db *sql.DB) *PostgresAdminStore {
+	return &PostgresAdminStore{db: db}
+}
+
+type AdminStore interface {
+	CreateAdmin(admin *Admin) (*Admin, error)
+	GetAdmins() ([]*Admin, error)
+	GetAdminByID(id string) (*Admin, error)
+	GetAdminByUsername(username string) (*Admin, error)
+	DisableAdmin(id string) (*Admin, error)
+	CreateAdminToken(admin *Admin, ttl time.Duration) (*tokens.AdminToken, error)
+	GetAdminToken(tokenPlainText string) (*Admin, error)
+	RecordAdminAction(action *AdminAction) error
+	GetAdminActions(filter AdminActionFilter) ([]*AdminAction, error)
+	SearchMerchants(search AdminSearch) ([]*Merchant, error)
+	SearchUsers(search AdminSearch) ([]*User, error)
+	SearchShops(search AdminSearch) ([]*Shop, error)
+	SetSuspended(subjectType string, id string, suspended bool) (bool, error)
+	SearchTransactions(filter AdminTransactionFilter) ([]*Transaction, error)
+	GetFailedWithdrawals(limit int, offset int) ([]*Withdrawal, error)
+	GetOpenReconciliationIssues(limit int, offset int) ([]*ReconciliationIssue, error)
+	GetPlatformStats(from time.Time, to time.Time) (*PlatformStats, error)
+}
+
+// PageSize clamps a requested page size to (0, MaxAdminPageSize].
+func PageSize(limit int) int {
+	if limit <= 0 {
+		return DefaultAdminPageSize
+	}
+	if limit > MaxAdminPageSize {
+		return MaxAdminPageSize
+	}
+	return limit
+}
+
+const adminColumns = `id, username, password_hash, status, last_login_at, created_at`
+
+func scanAdmin(row interface{ Scan(...any) error }) (*Admin, error) {
+	admin := &Admin{PasswordHash: password{}}
+	err := row.Scan(&admin.ID, &admin.Username, &admin.PasswordHash.hash, &admin.Status, &admin.LastLoginAt, &admin.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return admin, nil
+}
+
+// scanOptionalAdmin maps a missing row to nil, nil.
+func scanOptionalAdmin(row *sql.Row) (*Admin, error) {
+	admin, err := scanAdmin(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return admin, err
+}
+
+func (pa *PostgresAdminStore) CreateAdmin(admin *Admin) (*Admin, error) {
+	query := `
+	INSERT INTO admins (username, password_hash, status)
+	VALUES ($1, $2, $3)
+	RETURNING ` + adminColumns
+
+	created, err := scanAdmin(pa.db.QueryRow(query, admin.Username, admin.PasswordHash.hash, AdminStatusActive))
+	if isUniqueViolation(err) {
+		return nil, ErrAdminUsernameTaken
+	}
+	return created, err
+}
+
+func (pa *PostgresAdminStore) GetAdmins() ([]*Admin, error) {
+	rows, err := pa.db.Query(`SELECT ` + adminColumns + ` FROM admins ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	admins := []*Admin{}
+	for rows.Next() {
+		admin, err := scanAdmin(rows)
+		if err != nil {
+			return nil, err
+		}
+		admins = append(admins, admin)
+	}
+	return admins, rows.Err()
+}
+
+func (pa *PostgresAdminStore) GetAdminByID(id string) (*Admin, error) {
+	return scanOptionalAdmin(pa.db.QueryRow(`SELECT `+adminColumns+` FROM admins WHERE id = $1`, id))
+}
+
+func (pa *PostgresAdminStore) GetAdminByUsername(username string) (*Admin, error) {
+	return scanOptionalAdmin(pa.db.QueryRow(`SELECT `+adminColumns+` FROM admins WHERE username = $1`, username))
+}
+
+// DisableAdmin blocks the admin from signing in and ends their sessions. It
+// returns nil, nil when there is no such active admin.
+func (pa *PostgresAdminStore) DisableAdmin(id string) (*Admin, error) {
+	tx, err := pa.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE admins
+	SET status = $2
+	WHERE id = $1 AND status <> $2
+	RETURNING ` + adminColumns
+
+	admin, err := scanAdmin(tx.QueryRow(query, id, AdminStatusDisabled))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM admin_tokens WHERE admin_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	return admin, tx.Commit()
+}
+
+// CreateAdminToken starts a session for admin and records the sign-in.
+func (pa *PostgresAdminStore) CreateAdminToken(admin *Admin, ttl time.Duration) (*tokens.AdminToken, error) {
+	token, err := tokens.GenerateAdminToken(admin.ID, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := pa.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO admin_tokens (hash, admin_id, expiry) VALUES ($1, $2, $3)`, token.Hash, token.AdminID, token.Expiry)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`UPDATE admins SET last_login_at = CURRENT_TIMESTAMP WHERE id = $1`, admin.ID)
+	if err != nil {
+		return nil, err
+	}
+	return token, tx.Commit()
+}
+
+// GetAdminToken resolves a session to its admin. It returns nil, nil for
+// unknown or expired tokens and for disabled admins.
+func (pa *PostgresAdminStore) GetAdminToken(tokenPlainText string) (*Admin, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlainText))
+
+	query := `
+	SELECT a.id, a.username, a.password_hash, a.status, a.last_login_at, a.created_at
+	FROM admins a
+	INNER JOIN admin_tokens t ON t.admin_id = a.id
+	WHERE t.hash = $1 AND t.expiry > $2 AND a.status = $3
+	`
+	return scanOptionalAdmin(pa.db.QueryRow(query, tokenHash[:], time.Now(), AdminStatusActive))
+}
+
+func (pa *PostgresAdminStore) RecordAdminAction(action *AdminAction) error {
+	details := action.Details
+	if len(details) == 0 {
+		details = json.RawMessage(`{}`)
+	}
+
+	query := `
+	INSERT INTO admin_actions (admin_id, actor, action, subject_type, subject_id, details, ip_address)
+	VALUES (NULLIF($1, '')::uuid, $2, $3, $4, $5, $6, $7)
+	RETURNING id, created_at
+	`
+	return pa.db.QueryRow(query, action.AdminID, action.Actor, action.Action, action.SubjectType, action.SubjectID, []byte(details), action.IPAddress).Scan(&action.ID, &action.CreatedAt)
+}
+
+// GetAdminActions lists the audit trail, newest first.
+func (pa *PostgresAdminStore) GetAdminActions(filter AdminActionFilter) ([]*AdminAction, error) {
+	query := `
+	SELECT id, COALESCE(admin_id::text, ''), actor, action, subject_type, subject_id, details, ip_address, created_at
+	FROM admin_actions
+	WHERE ($1::text = '' OR admin_id::text = $1::text)
+		AND ($2::text = '' OR subject_type = $2::text)
+		AND ($3::text = '' OR subject_id = $3::text)
+	ORDER BY created_at DESC
+	LIMIT $4 OFFSET $5
+	`
+	rows, err := pa.db.Query(query, filter.AdminID, filter.SubjectType, filter.SubjectID, PageSize(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []*AdminAction{}
+	for rows.Next() {
+		action := &AdminAction{}
+		var details []byte
+		err = rows.Scan(&action.ID, &action.AdminID, &action.Actor, &action.Action, &action.SubjectType, &action.SubjectID, &details, &action.IPAddress, &action.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		action.Details = details
+		actions = append(actions, action)
+	}
+	return actions, rows.Err()
+}
+
+// suspensionFilter is shared by the account searches. $2 is the status.
+const suspensionFilter = `($2::text = ''
+		OR ($2::text = 'active' AND suspended_at IS NULL)
+		OR ($2::text = 'suspended' AND suspended_at IS NOT NULL))`
+
+func (pa *PostgresAdminStore) SearchMerchants(search AdminSearch) ([]*Merchant, error) {
+	query := `
+	SELECT id, username, topic_id, mobile_number, account_id, profile_image_url, account_banner_image_url, auto_offramp, suspended_at, kyc_level, created_at, updated_at
+	FROM merchants
+	WHERE ($1::text = '' OR username ILIKE '%' || $1::text || '%' OR mobile_number ILIKE '%' || $1::text || '%'
+			OR account_id = $1::text OR id::text = $1::text)
+		AND ` + suspensionFilter + `
+	ORDER BY created_at DESC
+	LIMIT $3 OFFSET $4
+	`
+	rows, err := pa.db.Query(query, search.Query, search.Status, PageSize(search.Limit), search.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	merchants := []*Merchant{}
+	for rows.Next() {
+		merchant := &Merchant{PasswordHash: password{}}
+		err = rows.Scan(&merchant.ID, &merchant.Username, &merchant.TopicID, &merchant.MobileNumber, &merchant.AccountID, &merchant.ProfileImageUrl, &merchant.AccountBannerImageUrl, &merchant.AutoOfframp, &merchant.SuspendedAt, &merchant.KYCLevel, &merchant.CreatedAt, &merchant.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		merchants = append(merchants, merchant)
+	}
+	return merchants, rows.Err()
+}
+
+func (pa *PostgresAdminStore) SearchUsers(search AdminSearch) ([]*User, error) {
+	query := `
+	SELECT id, username, topic_id, mobile_number, phone_verified, account_id, profile_image_url, suspended_at, kyc_level, created_at, updated_at
+	FROM users
+	WHERE ($1::text = '' OR username ILIKE '%' || $1::text || '%' OR mobile_number ILIKE '%' || $1::text || '%'
+			OR account_id = $1::text OR id::text = $1::text)
+		AND ` + suspensionFilter + `
+	ORDER BY created_at DESC
+	LIMIT $3 OFFSET $4
+	`
+	rows, err := pa.db.Query(query, search.Query, search.Status, PageSize(search.Limit), search.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		user := &User{PasswordHash: password{}}
+		err = rows.Scan(&user.ID, &user.Username, &user.TopicID, &user.MobileNumber, &user.PhoneVerified, &user.AccountID, &user.ProfileImageUrl, &user.SuspendedAt, &user.KYCLevel, &user.CreatedAt, &user.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (pa *PostgresAdminStore) SearchShops(search AdminSearch) ([]*Shop, error) {
+	query := `
+	SELECT id, merchant_id, name, theme, payment_id, profile_image_url, suspended_at
+	FROM shops
+	WHERE ($1::text = '' OR name ILIKE '%' || $1::text || '%' OR payment_id::text = $1::text
+			OR id::text = $1::text OR merchant_id::text = $1::text)
+		AND ` + suspensionFilter + `
+	ORDER BY name, id
+	LIMIT $3 OFFSET $4
+	`
+	rows, err := pa.db.Query(query, search.Query, search.Status, PageSize(search.Limit), search.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shops := []*Shop{}
+	for rows.Next() {
+		shop := &Shop{Campaigns: []CampaignEntry{}}
+		err = rows.Scan(&shop.ID, &shop.MerchantID, &shop.Name, &shop.Theme, &shop.PaymentID, &shop.ProfileImageUrl, &shop.SuspendedAt)
+		if err != nil {
+			return nil, err
+		}
+		shops = append(shops, shop)
+	}
+	return shops, rows.Err()
+}
+
+// SetSuspended suspends or reinstates a merchant, user or shop. It returns
+// false when the subject does not exist or is already in that state.
+// Suspending a merchant or user also ends their sessions.
+func (pa *PostgresAdminStore) SetSuspended(subjectType string, id string, suspended bool) (bool, error) {
+	table, ok := suspendableTables[subjectType]
+	if !ok {
+		return false, errors.New("unknown subject type " + subjectType)
+	}
+
+	tx, err := pa.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var query string
+	if suspended {
+		query = `UPDATE ` + table + ` SET suspended_at = CURRENT_TIMESTAMP WHERE id = $1 AND suspended_at IS NULL`
+	} else {
+		query = `UPDATE ` + table + ` SET suspended_at = NULL WHERE id = $1 AND suspended_at IS NOT NULL`
+	}
+	result, err := tx.Exec(query, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	if suspended {
+		switch subjectType {
+		case AdminSubjectMerchant:
+			_, err = tx.Exec(`DELETE FROM tokens WHERE merchant_id = $1 AND scope = $2`, id, tokens.ScopeAuthentication)
+		case AdminSubjectUser:
+			_, err = tx.Exec(`DELETE FROM user_tokens WHERE user_id = $1 AND scope = $2`, id, tokens.ScopeAuthentication)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// SearchTransactions lists payments across every merchant, newest first.
+func (pa *PostgresAdminStore) SearchTransactions(filter AdminTransactionFilter) ([]*Transaction, error) {
+	query := `
+	SELECT ` + transactionColumns + `
+	FROM transactions
+	WHERE ($1::text = '' OR merchant_id::text = $1::text)
+		AND ($2::text = '' OR shop_id::text = $2::text)
+		AND ($3::text = '' OR user_id::text = $3::text)
+		AND ($4::text = '' OR status = $4::text)
+		AND ($5::text = '' OR kind = $5::text)
+		AND ($6::timestamptz IS NULL OR created_at >= $6::timestamptz)
+		AND ($7::timestamptz IS NULL OR created_at < $7::timestamptz)
+	ORDER BY created_at DESC
+	LIMIT $8 OFFSET $9
+	`
+	rows, err := pa.db.Query(query, filter.MerchantID, filter.ShopID, filter.UserID, filter.Status, filter.Kind, filter.From, filter.To, PageSize(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := scanTransactions(rows)
+	if err != nil {
+		return nil, err
+	}
+	if transactions == nil {
+		transactions = []*Transaction{}
+	}
+	return transactions, nil
+}
+
+// GetFailedWithdrawals lists withdrawals whose payout failed, newest first.
+func (pa *PostgresAdminStore) GetFailedWithdrawals(limit int, offset int) ([]*Withdrawal, error) {
+	query := `
+	SELECT ` + withdrawalColumns + `
+	FROM withdrawals
+	WHERE status = $1 AND deleted_at IS NULL
+	ORDER BY updated_at DESC
+	LIMIT $2 OFFSET $3
+	`
+	rows, err := pa.db.Query(query, WithdrawalStatusFailed, PageSize(limit), offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	withdrawals := []*Withdrawal{}
+	for rows.Next() {
+		withdrawal, err := scanWithdrawal(rows)
+		if err != nil {
+			return nil, err
+		}
+		withdrawals = append(withdrawals, withdrawal)
+	}
+	return withdrawals, rows.Err()
+}
+
+// GetOpenReconciliationIssues lists unresolved ledger mismatches across all
+// merchants, newest first.
+func (pa *PostgresAdminStore) GetOpenReconciliationIssues(limit int, offset int) ([]*ReconciliationIssue, error) {
+	query := `
+	SELECT id, kind, status, COALESCE(merchant_id::text, ''), COALESCE(transaction_id::text, ''), hedera_transaction_id,
+		COALESCE(account_id, ''), expected_amount, ledger_amount, details, detected_at, resolved_at
+	FROM reconciliation_issues
+	WHERE status = $1
+	ORDER BY detected_at DESC
+	LIMIT $2 OFFSET $3
+	`
+	rows, err := pa.db.Query(query, ReconciliationIssueOpen, PageSize(limit), offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	issues := []*ReconciliationIssue{}
+	for rows.Next() {
+		issue := &ReconciliationIssue{}
+		var expectedAmount, ledgerAmount sql.NullInt64
+		var resolvedAt sql.NullTime
+		err = rows.Scan(&issue.ID, &issue.Kind, &issue.Status, &issue.MerchantID, &issue.TransactionID, &issue.HederaTransactionID, &issue.AccountID, &expectedAmount, &ledgerAmount, &issue.Details, &issue.DetectedAt, &resolvedAt)
+		if err != nil {
+			return nil, err
+		}
+		if expectedAmount.Valid {
+			amount := money.Amount(expectedAmount.Int64)
+			issue.ExpectedAmount = &amount
+		}
+		if ledgerAmount.Valid {
+			amount := money.Amount(ledgerAmount.Int64)
+			issue.LedgerAmount = &amount
+		}
+		if resolvedAt.Valid {
+			issue.ResolvedAt = &resolvedAt.Time
+		}
+		issues = append(issues, issue)
+	}
+	return issues, rows.Err()
+}
+
+// GetPlatformStats totals activity in [from, to). Split parents are skipped
+// since their children carry each merchant's amount and fee.
+func (pa *PostgresAdminStore) GetPlatformStats(from time.Time, to time.Time) (*PlatformStats, error) {
+	stats := &PlatformStats{From: from, To: to, Daily: []DailyVolume{}}
+
+	query := `
+	SELECT COUNT(*), COALESCE(SUM(amount), 0), COALESCE(SUM(fee), 0)
+	FROM transactions
+	WHERE kind <> $1 AND created_at >= $2 AND created_at < $3
+	`
+	err := pa.db.QueryRow(query, TransactionKindSplit, from, to).Scan(&stats.PaymentCount, &stats.Volume, &stats.PaymentFees)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+	SELECT COUNT(*), COALESCE(SUM(amount), 0), COALESCE(SUM(fee), 0)
+	FROM transfers
+	WHERE status = $1 AND created_at >= $2 AND created_at < $3
+	`
+	err = pa.db.QueryRow(query, TransactionStatusCompleted, from, to).Scan(&stats.TransferCount, &stats.TransferVolume, &stats.TransferFees)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+	SELECT COUNT(*), COALESCE(SUM(fee), 0)
+	FROM withdrawals
+	WHERE status = $1 AND deleted_at IS NULL AND created_at >= $2 AND created_at < $3
+	`
+	err = pa.db.QueryRow(query, WithdrawalStatusCompleted, from, to).Scan(&stats.WithdrawalCount, &stats.WithdrawalFees)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+	SELECT COUNT(*), COALESCE(SUM(amount), 0)
+	FROM refunds
+	WHERE status = $1 AND updated_at >= $2 AND updated_at < $3
+	`
+	err = pa.db.QueryRow(query, RefundStatusCompleted, from, to).Scan(&stats.RefundCount, &stats.Refunded)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.FeeRevenue, err = stats.PaymentFees.Add(stats.TransferFees)
+	if err != nil {
+		return nil, err
+	}
+	stats.FeeRevenue, err = stats.FeeRevenue.Add(stats.WithdrawalFees)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+	SELECT to_char(date_trunc('day', created_at AT TIME ZONE 'UTC'), 'YYYY-MM-DD') AS day,
+		COUNT(*), COALESCE(SUM(amount), 0), COALESCE(SUM(fee), 0)
+	FROM transactions
+	WHERE kind <> $1 AND created_at >= $2 AND created_at < $3
+	GROUP BY day
+	ORDER BY day
+	`
+	rows, err := pa.db.Query(query, TransactionKindSplit, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		day := DailyVolume{}
+		err = rows.Scan(&day.Date, &day.PaymentCount, &day.Volume, &day.PaymentFees)
+		if err != nil {
+			return nil, err
+		}
+		stats.Daily = append(stats.Daily, day)
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,293 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+)
+
+// AML case kinds. Sanctions and PEP cases come from watchlist screening.
+const (
+	AMLCaseSanctions   = "sanctions"
+	AMLCasePEP         = "pep"
+	AMLCaseStructuring = "structuring"
+)
+
+const (
+	AMLSubjectUser     = "user"
+	AMLSubjectMerchant = "merchant"
+)
+
+const (
+	AMLCaseStatusOpen      = "open"
+	AMLCaseStatusReported  = "reported"
+	AMLCaseStatusDismissed = "dismissed"
+)
+
+// AMLCase is a suspicious activity case awaiting or past a compliance
+// officer's decision. Evidence is kind specific JSON; see the aml package.
+// ReportReference is the reference of the report filed with the regulator
+// for reported cases.
+type AMLCase struct {
+	ID              string          `json:"id"`
+	SubjectType     string          `json:"subject_type"`
+	SubjectID       string          `json:"subject_id"`
+	SubjectName     string          `json:"subject_name"`
+	Kind            string          `json:"kind"`
+	Fingerprint     string          `json:"-"`
+	Summary         string          `json:"summary"`
+	Evidence        json.RawMessage `json:"evidence"`
+	Status          string          `json:"status"`
+	ReportReference string          `json:"report_reference"`
+	ResolutionNote  string          `json:"resolution_note"`
+	ResolvedBy      string          `json:"resolved_by"`
+	ResolvedAt      *time.Time      `json:"resolved_at"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+// AMLCaseFilter narrows the cases listed or exported. Zero values match
+// everything; From and To bound created_at.
+type AMLCaseFilter struct {
+	Status      string
+	Kind        string
+	SubjectType string
+	SubjectID   string
+	From        *time.Time
+	To          *time.Time
+	Limit       int
+	Offset      int
+}
+
+// AMLSubject is a name to screen and the account it belongs to. Shop names
+// are screened as their merchant's, with ShopID saying which shop.
+type AMLSubject struct {
+	Type   string
+	ID     string
+	Name   string
+	ShopID string
+}
+
+// StructuringActivity is one user's payments and transfers that together
+// look like structuring over a period.
+type StructuringActivity struct {
+	UserID         string
+	Username       string
+	Count          int
+	Total          money.Amount
+	TransactionIDs []string
+	TransferIDs    []string
+	FirstAt        time.Time
+	LastAt         time.Time
+}
+
+type PostgresAMLStore struct {
+	db *sql.DB
+}
+
+func NewPostgresAMLStore(db *sql.DB) *PostgresAMLStore {
+	return &PostgresAMLStore{db: db}
+}
+
+type AMLStore interface {
+	GetScreeningSubjects(since time.Time) ([]*AMLSubject, error)
+	FindStructuring(from time.Time, to time.Time, minAmount money.Amount, threshold money.Amount, minCount int) ([]*StructuringActivity, error)
+	RaiseAMLCase(amlCase *AMLCase) (*AMLCase, bool, error)
+	GetAMLCaseByID(id string) (*AMLCase, error)
+	SearchAMLCases(filter AMLCaseFilter) ([]*AMLCase, error)
+	ResolveAMLCase(id string, status string, reference string, note string, resolvedBy string) (*AMLCase, error)
+}
+
+// GetScreeningSubjects returns the user, merchant and shop names added or
+// changed since since. The zero time returns every name.
+func (pa *PostgresAMLStore) GetScreeningSubjects(since time.Time) ([]*AMLSubject, error) {
+	query := `
+	SELECT $2::text, id::text, username, ''
+	FROM users
+	WHERE deleted_at IS NULL AND username IS NOT NULL AND COALESCE(updated_at, created_at) >= $1
+	UNION ALL
+	SELECT $3::text, id::text, username, ''
+	FROM merchants
+	WHERE deleted_at IS NULL AND COALESCE(updated_at, created_at) >= $1
+	UNION ALL
+	SELECT $3::text, merchant_id::text, name, id::text
+	FROM shops
+	WHERE COALESCE(updated_at, created_at) >= $1
+	`
+	rows, err := pa.db.Query(query, since, AMLSubjectUser, AMLSubjectMerchant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subjects := []*AMLSubject{}
+	for rows.Next() {
+		subject := &AMLSubject{}
+		err = rows.Scan(&subject.Type, &subject.ID, &subject.Name, &subject.ShopID)
+		if err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, subject)
+	}
+	return subjects, rows.Err()
+}
+
+// FindStructuring finds users who made at least minCount payments and
+// completed transfers between from and to, each of at least minAmount but
+// under threshold, that add up to threshold or more. Split payments count
+// once, at the parent.
+func (pa *PostgresAMLStore) FindStructuring(from time.Time, to time.Time, minAmount money.Amount, threshold money.Amount, minCount int) ([]*StructuringActivity, error) {
+	query := `
+	SELECT p.user_id::text, COALESCE(u.username, ''), COUNT(*), SUM(p.amount)::BIGINT,
+		COALESCE(string_agg(p.id::text, ',' ORDER BY p.created_at) FILTER (WHERE NOT p.transfer), ''),
+		COALESCE(string_agg(p.id::text, ',' ORDER BY p.created_at) FILTER (WHERE p.transfer), ''),
+		MIN(p.created_at), MAX(p.created_at)
+	FROM (
+		SELECT user_id, id, amount, created_at, FALSE AS transfer
+		FROM transactions
+		WHERE parent_id IS NULL AND created_at >= $1 AND created_at < $2 AND amount >= $3 AND amount < $4
+		UNION ALL
+		SELECT sender_id, id, amount, created_at, TRUE
+		FROM transfers
+		WHERE status = 'completed' AND created_at >= $1 AND created_at < $2 AND amount >= $3 AND amount < $4
+	) p
+	INNER JOIN users u ON u.id = p.user_id
+	GROUP BY p.user_id, u.username
+	HAVING COUNT(*) >= $5 AND SUM(p.amount) >= $4
+	ORDER BY p.user_id
+	`
+	rows, err := pa.db.Query(query, from, to, minAmount, threshold, minCount)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activities := []*StructuringActivity{}
+	for rows.Next() {
+		activity := &StructuringActivity{}
+		var transactionIDs, transferIDs string
+		err = rows.Scan(&activity.UserID, &activity.Username, &activity.Count, &activity.Total, &transactionIDs, &transferIDs, &activity.FirstAt, &activity.LastAt)
+		if err != nil {
+			return nil, err
+		}
+		activity.TransactionIDs = splitIDs(transactionIDs)
+		activity.TransferIDs = splitIDs(transferIDs)
+		activities = append(activities, activity)
+	}
+	return activities, rows.Err()
+}
+
+// splitIDs splits an aggregated id list, which is empty when there are none.
+func splitIDs(ids string) []string {
+	if ids == "" {
+		return []string{}
+	}
+	return strings.Split(ids, ",")
+}
+
+const amlCaseColumns = `id, subject_type, subject_id, subject_name, kind, fingerprint, summary, evidence, status, report_reference, resolution_note, resolved_by, resolved_at, created_at, updated_at`
+
+func scanAMLCase(row interface{ Scan(...any) error }) (*AMLCase, error) {
+	amlCase := &AMLCase{}
+	var evidence []byte
+	err := row.Scan(&amlCase.ID, &amlCase.SubjectType, &amlCase.SubjectID, &amlCase.SubjectName, &amlCase.Kind, &amlCase.Fingerprint, &amlCase.Summary, &evidence, &amlCase.Status, &amlCase.ReportReference, &amlCase.ResolutionNote, &amlCase.ResolvedBy, &amlCase.ResolvedAt, &amlCase.CreatedAt, &amlCase.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	amlCase.Evidence = json.RawMessage(evidence)
+	return amlCase, nil
+}
+
+// RaiseAMLCase opens a case, or refreshes the summary and evidence of the
+// open case with the same fingerprint. It reports whether a new case was
+// opened, and returns nil, false, nil when nothing changed or the matching
+// case has already been closed.
+func (pa *PostgresAMLStore) RaiseAMLCase(amlCase *AMLCase) (*AMLCase, bool, error) {
+	evidence := amlCase.Evidence
+	if len(evidence) == 0 {
+		evidence = json.RawMessage(`{}`)
+	}
+
+	query := `
+	INSERT INTO aml_cases (subject_type, subject_id, subject_name, kind, fingerprint, summary, evidence)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (subject_type, subject_id, kind, fingerprint) DO UPDATE
+	SET subject_name = EXCLUDED.subject_name, summary = EXCLUDED.summary, evidence = EXCLUDED.evidence,
+		updated_at = CURRENT_TIMESTAMP
+	WHERE aml_cases.status = $8 AND aml_cases.evidence IS DISTINCT FROM EXCLUDED.evidence
+	RETURNING ` + amlCaseColumns
+
+	raised, err := scanAMLCase(pa.db.QueryRow(query, amlCase.SubjectType, amlCase.SubjectID, amlCase.SubjectName, amlCase.Kind, amlCase.Fingerprint, amlCase.Summary, []byte(evidence), AMLCaseStatusOpen))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	// An update moves updated_at past created_at; both are set from the
+	// same transaction timestamp on insert.
+	return raised, raised.UpdatedAt.Equal(raised.CreatedAt), nil
+}
+
+func (pa *PostgresAMLStore) GetAMLCaseByID(id string) (*AMLCase, error) {
+	query := `SELECT ` + amlCaseColumns + `
+	FROM aml_cases
+	WHERE id = $1
+	`
+	amlCase, err := scanAMLCase(pa.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return amlCase, err
+}
+
+// SearchAMLCases lists cases newest first.
+func (pa *PostgresAMLStore) SearchAMLCases(filter AMLCaseFilter) ([]*AMLCase, error) {
+	query := `SELECT ` + amlCaseColumns + `
+	FROM aml_cases
+	WHERE ($1::text = '' OR status = $1::text)
+		AND ($2::text = '' OR kind = $2::text)
+		AND ($3::text = '' OR subject_type = $3::text)
+		AND ($4::text = '' OR subject_id::text = $4::text)
+		AND ($5::timestamptz IS NULL OR created_at >= $5::timestamptz)
+		AND ($6::timestamptz IS NULL OR created_at < $6::timestamptz)
+	ORDER BY created_at DESC, id
+	LIMIT $7 OFFSET $8
+	`
+	rows, err := pa.db.Query(query, filter.Status, filter.Kind, filter.SubjectType, filter.SubjectID, filter.From, filter.To, PageSize(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cases := []*AMLCase{}
+	for rows.Next() {
+		amlCase, err := scanAMLCase(rows)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, amlCase)
+	}
+	return cases, rows.Err()
+}
+
+// ResolveAMLCase closes an open case as reported or dismissed. It returns
+// nil, nil when there is no open case with that id.
+func (pa *PostgresAMLStore) ResolveAMLCase(id string, status string, reference string, note string, resolvedBy string) (*AMLCase, error) {
+	query := `
+	UPDATE aml_cases
+	SET status = $2, report_reference = $3, resolution_note = $4, resolved_by = $5,
+		resolved_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status = $6
+	RETURNING ` + amlCaseColumns
+
+	resolved, err := scanAMLCase(pa.db.QueryRow(query, id, status, reference, note, resolvedBy, AMLCaseStatusOpen))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return resolved, err
+}
@@ -0,0 +1,275 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+const (
+	AnchorStatusPending   = "pending"
+	AnchorStatusSubmitted = "submitted"
+)
+
+// Kinds of record an anchor covers.
+const (
+	AnchorSubjectTransaction = "transaction"
+	AnchorSubjectAuditEvent  = "audit_event"
+)
+
+// Anchor is a Merkle root over a batch of records. Once submitted, the root
+// sits on the anchoring topic at TopicSequenceNumber. RootHex is Root as it
+// appears in the topic message.
+type Anchor struct {
+	ID                  string     `json:"id"`
+	Root                []byte     `json:"-"`
+	RootHex             string     `json:"root"`
+	LeafCount           int        `json:"leaf_count"`
+	Status              string     `json:"status"`
+	TopicID             string     `json:"topic_id"`
+	TopicSequenceNumber int64      `json:"topic_sequence_number"`
+	HederaTransactionID string     `json:"hedera_transaction_id"`
+	Attempts            int        `json:"attempts"`
+	LastError           string     `json:"last_error"`
+	CreatedAt           time.Time  `json:"created_at"`
+	SubmittedAt         *time.Time `json:"submitted_at"`
+}
+
+// AnchorLeaf is one record's place in an anchor's tree.
+type AnchorLeaf struct {
+	AnchorID    string `json:"anchor_id"`
+	Position    int    `json:"position"`
+	SubjectType string `json:"subject_type"`
+	SubjectID   string `json:"subject_id"`
+	LeafHash    []byte `json:"leaf_hash"`
+}
+
+type PostgresAnchorStore struct {
+	db *sql.DB
+}
+
+func NewPostgresAnchorStore(db *sql.DB) *PostgresAnchorStore {
+	return &PostgresAnchorStore{db: db}
+}
+
+type AnchorStore interface {
+	GetUnanchoredTransactions(limit int) ([]*Transaction, error)
+	GetUnanchoredAuditEvents(limit int) ([]*AuditEvent, error)
+	CreateAnchor(anchor *Anchor, leaves []*AnchorLeaf) (*Anchor, error)
+	GetPendingAnchors(limit int) ([]*Anchor, error)
+	MarkAnchorSubmitted(id string, topicID string, sequenceNumber int64, hederaTransactionID string) error
+	MarkAnchorFailed(id string, message string) error
+	GetAnchors(limit int, offset int) ([]*Anchor, error)
+	GetAnchorByID(id string) (*Anchor, error)
+	GetAnchorLeaf(subjectType string, subjectID string) (*AnchorLeaf, error)
+	GetAnchorLeafHashes(anchorID string) ([][]byte, error)
+}
+
+const anchorColumns = `id, root, leaf_count, status, topic_id, topic_sequence_number, hedera_transaction_id, attempts, last_error, created_at, submitted_at`
+
+func scanAnchor(row interface{ Scan(...any) error }) (*Anchor, error) {
+	anchor := &Anchor{}
+	err := row.Scan(&anchor.ID, &anchor.Root, &anchor.LeafCount, &anchor.Status, &anchor.TopicID, &anchor.TopicSequenceNumber, &anchor.HederaTransactionID, &anchor.Attempts, &anchor.LastError, &anchor.CreatedAt, &anchor.SubmittedAt)
+	if err != nil {
+		return nil, err
+	}
+	anchor.RootHex = hex.EncodeToString(anchor.Root)
+	return anchor, nil
+}
+
+func scanAnchors(rows *sql.Rows) ([]*Anchor, error) {
+	defer rows.Close()
+
+	anchors := []*Anchor{}
+	for rows.Next() {
+		anchor, err := scanAnchor(rows)
+		if err != nil {
+			return nil, err
+		}
+		anchors = append(anchors, anchor)
+	}
+	return anchors, rows.Err()
+}
+
+// GetUnanchoredTransactions returns up to limit transactions not yet in an
+// anchor, oldest first.
+func (pa *PostgresAnchorStore) GetUnanchoredTransactions(limit int) ([]*Transaction, error) {
+	query := `
+	SELECT ` + transactionColumns + `
+	FROM transactions t
+	WHERE NOT EXISTS (
+		SELECT 1 FROM anchor_leaves l
+		WHERE l.subject_type = 'transaction' AND l.subject_id = t.id::text
+	)
+	ORDER BY t.created_at, t.id
+	LIMIT $1
+	`
+	rows, err := pa.db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := scanTransactions(rows)
+	if transactions == nil {
+		transactions = []*Transaction{}
+	}
+	return transactions, err
+}
+
+// GetUnanchoredAuditEvents returns up to limit audit events not yet in an
+// anchor, in chain order.
+func (pa *PostgresAnchorStore) GetUnanchoredAuditEvents(limit int) ([]*AuditEvent, error) {
+	query := `
+	SELECT ` + auditEventColumns + `
+	FROM audit_events e
+	WHERE NOT EXISTS (
+		SELECT 1 FROM anchor_leaves l
+		WHERE l.subject_type = 'audit_event' AND l.subject_id = e.id::text
+	)
+	ORDER BY e.seq
+	LIMIT $1
+	`
+	rows, err := pa.db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanAuditEvents(rows)
+}
+
+// CreateAnchor stores a pending anchor with its leaves. It returns nil when
+// another run has already anchored one of the records.
+func (pa *PostgresAnchorStore) CreateAnchor(anchor *Anchor, leaves []*AnchorLeaf) (*Anchor, error) {
+	tx, err := pa.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO anchors (root, leaf_count)
+	VALUES ($1, $2)
+	RETURNING ` + anchorColumns
+	created, err := scanAnchor(tx.QueryRow(query, anchor.Root, len(leaves)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, leaf := range leaves {
+		_, err = tx.Exec(`
+		INSERT INTO anchor_leaves (anchor_id, position, subject_type, subject_id, leaf_hash)
+		VALUES ($1, $2, $3, $4, $5)
+		`, created.ID, leaf.Position, leaf.SubjectType, leaf.SubjectID, leaf.LeafHash)
+		if isUniqueViolation(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		leaf.AnchorID = created.ID
+	}
+	return created, tx.Commit()
+}
+
+// GetPendingAnchors returns anchors still waiting to be submitted, oldest
+// first.
+func (pa *PostgresAnchorStore) GetPendingAnchors(limit int) ([]*Anchor, error) {
+	query := `
+	SELECT ` + anchorColumns + `
+	FROM anchors
+	WHERE status = 'pending'
+	ORDER BY created_at
+	LIMIT $1
+	`
+	rows, err := pa.db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanAnchors(rows)
+}
+
+func (pa *PostgresAnchorStore) MarkAnchorSubmitted(id string, topicID string, sequenceNumber int64, hederaTransactionID string) error {
+	query := `
+	UPDATE anchors
+	SET status = 'submitted', topic_id = $2, topic_sequence_number = $3, hedera_transaction_id = $4,
+		attempts = attempts + 1, last_error = '', submitted_at = CURRENT_TIMESTAMP
+	WHERE id = $1
+	`
+	_, err := pa.db.Exec(query, id, topicID, sequenceNumber, hederaTransactionID)
+	return err
+}
+
+func (pa *PostgresAnchorStore) MarkAnchorFailed(id string, message string) error {
+	query := `
+	UPDATE anchors
+	SET attempts = attempts + 1, last_error = $2
+	WHERE id = $1 AND status = 'pending'
+	`
+	_, err := pa.db.Exec(query, id, message)
+	return err
+}
+
+// GetAnchors lists anchors newest first.
+func (pa *PostgresAnchorStore) GetAnchors(limit int, offset int) ([]*Anchor, error) {
+	query := `
+	SELECT ` + anchorColumns + `
+	FROM anchors
+	ORDER BY created_at DESC, id
+	LIMIT $1 OFFSET $2
+	`
+	rows, err := pa.db.Query(query, PageSize(limit), offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanAnchors(rows)
+}
+
+func (pa *PostgresAnchorStore) GetAnchorByID(id string) (*Anchor, error) {
+	query := `
+	SELECT ` + anchorColumns + `
+	FROM anchors
+	WHERE id = $1
+	`
+	anchor, err := scanAnchor(pa.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return anchor, err
+}
+
+// GetAnchorLeaf finds the anchor a record went into, or nil if it has not
+// been anchored yet.
+func (pa *PostgresAnchorStore) GetAnchorLeaf(subjectType string, subjectID string) (*AnchorLeaf, error) {
+	query := `
+	SELECT anchor_id, position, subject_type, subject_id, leaf_hash
+	FROM anchor_leaves
+	WHERE subject_type = $1 AND subject_id = $2
+	`
+	leaf := &AnchorLeaf{}
+	err := pa.db.QueryRow(query, subjectType, subjectID).Scan(&leaf.AnchorID, &leaf.Position, &leaf.SubjectType, &leaf.SubjectID, &leaf.LeafHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return leaf, nil
+}
+
+// GetAnchorLeafHashes returns an anchor's leaf hashes in tree order.
+func (pa *PostgresAnchorStore) GetAnchorLeafHashes(anchorID string) ([][]byte, error) {
+	rows, err := pa.db.Query(`SELECT leaf_hash FROM anchor_leaves WHERE anchor_id = $1 ORDER BY position`, anchorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := [][]byte{}
+	for rows.Next() {
+		var hash []byte
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
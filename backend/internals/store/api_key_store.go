@@ -0,0 +1,232 @@
+package store
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/tokens"
+)
+
+type APIKey struct {
+	ID         string     `json:"id"`
+	MerchantID string     `json:"merchant_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type PostgresAPIKeyStore struct {
+	db *sql.DB
+}
+
+func NewPostgresAPIKeyStore(db *sql.DB) *PostgresAPIKeyStore {
+	return &PostgresAPIKeyStore{db: db}
+}
+
+type APIKeyStore interface {
+	CreateAPIKey(merchantID string, name string, scopes []string) (*APIKey, *tokens.APIKey, error)
+	GetAPIKeysByMerchantID(merchantID string) ([]*APIKey, error)
+	GetAPIKeyByID(merchantID string, id string) (*APIKey, error)
+	RotateAPIKey(merchantID string, id string) (*APIKey, *tokens.APIKey, error)
+	RevokeAPIKey(merchantID string, id string) error
+	GetMerchantByAPIKey(plaintext string) (*Merchant, *APIKey, error)
+}
+
+const apiKeyColumns = `id, merchant_id, name, prefix, scopes, last_used_at, revoked_at, created_at`
+
+func scanAPIKey(row interface{ Scan(...any) error }) (*APIKey, error) {
+	key := &APIKey{}
+	var scopes string
+	var lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&key.ID, &key.MerchantID, &key.Name, &key.Prefix, &scopes, &lastUsedAt, &revokedAt, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	key.Scopes = strings.Split(scopes, ",")
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return key, nil
+}
+
+func insertAPIKey(tx *sql.Tx, merchantID string, name string, scopes []string) (*APIKey, *tokens.APIKey, error) {
+	generated, err := tokens.GenerateAPIKey(merchantID, scopes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query := `
+	INSERT INTO api_keys (merchant_id, name, prefix, hash, scopes)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING ` + apiKeyColumns
+
+	key, err := scanAPIKey(tx.QueryRow(query, merchantID, name, generated.Prefix, generated.Hash, strings.Join(scopes, ",")))
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, generated, nil
+}
+
+func (pk *PostgresAPIKeyStore) CreateAPIKey(merchantID string, name string, scopes []string) (*APIKey, *tokens.APIKey, error) {
+	tx, err := pk.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	key, generated, err := insertAPIKey(tx, merchantID, name, scopes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, generated, tx.Commit()
+}
+
+func (pk *PostgresAPIKeyStore) GetAPIKeysByMerchantID(merchantID string) ([]*APIKey, error) {
+	query := `
+	SELECT ` + apiKeyColumns + `
+	FROM api_keys
+	WHERE merchant_id = $1
+	ORDER BY created_at DESC
+	`
+	rows, err := pk.db.Query(query, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (pk *PostgresAPIKeyStore) GetAPIKeyByID(merchantID string, id string) (*APIKey, error) {
+	query := `
+	SELECT ` + apiKeyColumns + `
+	FROM api_keys
+	WHERE id = $1 AND merchant_id = $2
+	`
+	key, err := scanAPIKey(pk.db.QueryRow(query, id, merchantID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// RotateAPIKey revokes the key and issues a replacement with the same name
+// and scopes in one transaction.
+func (pk *PostgresAPIKeyStore) RotateAPIKey(merchantID string, id string) (*APIKey, *tokens.APIKey, error) {
+	tx, err := pk.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE api_keys
+	SET revoked_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL
+	RETURNING ` + apiKeyColumns
+
+	old, err := scanAPIKey(tx.QueryRow(query, id, merchantID))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, generated, err := insertAPIKey(tx, merchantID, old.Name, old.Scopes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, generated, tx.Commit()
+}
+
+func (pk *PostgresAPIKeyStore) RevokeAPIKey(merchantID string, id string) error {
+	query := `
+	UPDATE api_keys
+	SET revoked_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL
+	`
+	result, err := pk.db.Exec(query, id, merchantID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetMerchantByAPIKey resolves an active key to its merchant. It returns
+// nil, nil, nil for unknown, malformed or revoked keys.
+func (pk *PostgresAPIKeyStore) GetMerchantByAPIKey(plaintext string) (*Merchant, *APIKey, error) {
+	prefix, ok := tokens.ParseAPIKeyPrefix(plaintext)
+	if !ok {
+		return nil, nil, nil
+	}
+
+	var id, merchantID string
+	var hash []byte
+	query := `
+	SELECT id, merchant_id, hash
+	FROM api_keys
+	WHERE prefix = $1 AND revoked_at IS NULL
+	`
+	err := pk.db.QueryRow(query, prefix).Scan(&id, &merchantID, &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if subtle.ConstantTimeCompare(hash, tokens.HashAPIKey(plaintext)) != 1 {
+		return nil, nil, nil
+	}
+
+	key, err := pk.GetAPIKeyByID(merchantID, id)
+	if err != nil || key == nil {
+		return nil, nil, err
+	}
+
+	_, err = pk.db.Exec(`UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1`, key.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	merchant, err := NewPostgresMerchantStore(pk.db).GetMerchantByID(key.MerchantID)
+	if err != nil || merchant == nil {
+		return nil, nil, err
+	}
+	return merchant, key, nil
+}
@@ -0,0 +1,235 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Who performed an audited action.
+const (
+	AuditActorMerchant  = "merchant"
+	AuditActorMember    = "member"
+	AuditActorAPIKey    = "api_key"
+	AuditActorPOSDevice = "pos_device"
+	AuditActorUser      = "user"
+	AuditActorAdmin     = "admin"
+	AuditActorAnonymous = "anonymous"
+	AuditActorSystem    = "system"
+)
+
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// auditChainLock serialises appends so each event links to the one before.
+const auditChainLock = 0x61756469
+
+// ErrAuditChainBroken is returned by VerifyAuditChain at the first event
+// whose hash or link does not match.
+var ErrAuditChainBroken = errors.New("audit chain is broken")
+
+// AuditEvent is one row of the append-only audit log. Hash is the SHA-256 of
+// PrevHash and the event's other fields, so the log cannot be edited without
+// recomputing every later hash.
+type AuditEvent struct {
+	Seq        int64           `json:"seq"`
+	ID         string          `json:"id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	ActorType  string          `json:"actor_type"`
+	ActorID    string          `json:"actor_id"`
+	MerchantID string          `json:"merchant_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	Outcome    string          `json:"outcome"`
+	IPAddress  string          `json:"ip_address"`
+	UserAgent  string          `json:"user_agent"`
+	Details    json.RawMessage `json:"details"`
+	PrevHash   []byte          `json:"prev_hash"`
+	Hash       []byte          `json:"hash"`
+}
+
+// AuditEventFilter narrows an audit feed. Zero values match everything.
+type AuditEventFilter struct {
+	MerchantID string
+	Action     string
+	ActorType  string
+	Limit      int
+	Offset     int
+}
+
+// HashAuditEvent computes event's hash from prevHash and its fields. Times
+// are hashed in UTC at microsecond precision, which is what Postgres keeps.
+func HashAuditEvent(prevHash []byte, event *AuditEvent) []byte {
+	canonical, _ := json.Marshal([]any{
+		event.Seq,
+		event.OccurredAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+		event.ActorType,
+		event.ActorID,
+		event.MerchantID,
+		event.Action,
+		event.TargetType,
+		event.TargetID,
+		event.Outcome,
+		event.IPAddress,
+		event.UserAgent,
+		string(event.Details),
+	})
+
+	h := sha256.New()
+	h.Write(prevHash)
+	h.Write(canonical)
+	return h.Sum(nil)
+}
+
+// VerifyAuditChain checks that events, in seq order, each link to the one
+// before and hash to their stored hash. prevHash is the hash of the event
+// before the first, or empty when the first event starts the log.
+func VerifyAuditChain(prevHash []byte, events []*AuditEvent) error {
+	for _, event := range events {
+		if !bytes.Equal(event.PrevHash, prevHash) {
+			return fmt.Errorf("%w: event %d does not link to the event before it", ErrAuditChainBroken, event.Seq)
+		}
+		if !bytes.Equal(event.Hash, HashAuditEvent(prevHash, event)) {
+			return fmt.Errorf("%w: event %d does not match its hash", ErrAuditChainBroken, event.Seq)
+		}
+		prevHash = event.Hash
+	}
+	return nil
+}
+
+type PostgresAuditStore struct {
+	db *sql.DB
+}
+
+func NewPostgresAuditStore(db *sql.DB) *PostgresAuditStore {
+	return &PostgresAuditStore{db: db}
+}
+
+type AuditStore interface {
+	AppendAuditEvent(event *AuditEvent) (*AuditEvent, error)
+	GetAuditEvents(filter AuditEventFilter) ([]*AuditEvent, error)
+	GetAuditEventsAfter(seq int64, limit int) ([]*AuditEvent, error)
+	GetAuditEventHash(seq int64) ([]byte, error)
+}
+
+const auditEventColumns = `seq, id, occurred_at, actor_type, actor_id, COALESCE(merchant_id::text, ''), action, target_type, target_id, outcome, ip_address, user_agent, details, prev_hash, hash`
+
+func scanAuditEvent(row interface{ Scan(...any) error }) (*AuditEvent, error) {
+	event := &AuditEvent{}
+	var details []byte
+	err := row.Scan(&event.Seq, &event.ID, &event.OccurredAt, &event.ActorType, &event.ActorID, &event.MerchantID, &event.Action, &event.TargetType, &event.TargetID, &event.Outcome, &event.IPAddress, &event.UserAgent, &details, &event.PrevHash, &event.Hash)
+	if err != nil {
+		return nil, err
+	}
+	event.Details = details
+	return event, nil
+}
+
+func scanAuditEvents(rows *sql.Rows) ([]*AuditEvent, error) {
+	defer rows.Close()
+
+	events := []*AuditEvent{}
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// AppendAuditEvent links event to the end of the chain and stores it.
+// Appends are serialised with an advisory lock held until commit.
+func (pa *PostgresAuditStore) AppendAuditEvent(event *AuditEvent) (*AuditEvent, error) {
+	if len(event.Details) == 0 {
+		event.Details = json.RawMessage(`{}`)
+	}
+	event.OccurredAt = event.OccurredAt.UTC().Truncate(time.Microsecond)
+
+	tx, err := pa.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`SELECT pg_advisory_xact_lock($1)`, auditChainLock)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastSeq int64
+	prevHash := []byte{}
+	err = tx.QueryRow(`SELECT seq, hash FROM audit_events ORDER BY seq DESC LIMIT 1`).Scan(&lastSeq, &prevHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	event.Seq = lastSeq + 1
+	event.PrevHash = prevHash
+	event.Hash = HashAuditEvent(prevHash, event)
+
+	query := `
+	INSERT INTO audit_events (seq, occurred_at, actor_type, actor_id, merchant_id, action, target_type, target_id, outcome, ip_address, user_agent, details, prev_hash, hash)
+	VALUES ($1, $2, $3, $4, NULLIF($5, '')::uuid, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	RETURNING id
+	`
+	err = tx.QueryRow(query, event.Seq, event.OccurredAt, event.ActorType, event.ActorID, event.MerchantID, event.Action, event.TargetType, event.TargetID, event.Outcome, event.IPAddress, event.UserAgent, string(event.Details), event.PrevHash, event.Hash).Scan(&event.ID)
+	if err != nil {
+		return nil, err
+	}
+	return event, tx.Commit()
+}
+
+// GetAuditEvents lists events newest first.
+func (pa *PostgresAuditStore) GetAuditEvents(filter AuditEventFilter) ([]*AuditEvent, error) {
+	query := `
+	SELECT ` + auditEventColumns + `
+	FROM audit_events
+	WHERE ($1::text = '' OR merchant_id::text = $1::text)
+		AND ($2::text = '' OR action = $2::text)
+		AND ($3::text = '' OR actor_type = $3::text)
+	ORDER BY seq DESC
+	LIMIT $4 OFFSET $5
+	`
+	rows, err := pa.db.Query(query, filter.MerchantID, filter.Action, filter.ActorType, PageSize(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanAuditEvents(rows)
+}
+
+// GetAuditEventsAfter returns up to limit events following seq, oldest
+// first, for walking the chain.
+func (pa *PostgresAuditStore) GetAuditEventsAfter(seq int64, limit int) ([]*AuditEvent, error) {
+	query := `
+	SELECT ` + auditEventColumns + `
+	FROM audit_events
+	WHERE seq > $1
+	ORDER BY seq
+	LIMIT $2
+	`
+	rows, err := pa.db.Query(query, seq, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanAuditEvents(rows)
+}
+
+// GetAuditEventHash returns the hash of event seq, or nil when there is no
+// such event.
+func (pa *PostgresAuditStore) GetAuditEventHash(seq int64) ([]byte, error) {
+	var hash []byte
+	err := pa.db.QueryRow(`SELECT hash FROM audit_events WHERE seq = $1`, seq).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return hash, err
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildAuditChain(t *testing.T, n int) []*AuditEvent {
+	t.Helper()
+	occurredAt := time.Date(2026, 10, 19, 9, 30, 0, 123456789, time.UTC)
+
+	events := make([]*AuditEvent, n)
+	prevHash := []byte{}
+	for i := range events {
+		event := &AuditEvent{
+			Seq:        int64(i + 1),
+			OccurredAt: occurredAt.Add(time.Duration(i) * time.Minute),
+			ActorType:  AuditActorMerchant,
+			ActorID:    "merchant-1",
+			MerchantID: "merchant-1",
+			Action:     "shop.update",
+			TargetType: "shop",
+			TargetID:   "shop-1",
+			Outcome:    AuditOutcomeSuccess,
+			IPAddress:  "10.0.0.1",
+			UserAgent:  "test",
+			Details:    json.RawMessage(`{"name":"Kibanda"}`),
+			PrevHash:   prevHash,
+		}
+		event.Hash = HashAuditEvent(prevHash, event)
+		prevHash = event.Hash
+		events[i] = event
+	}
+	return events
+}
+
+func TestVerifyAuditChain(t *testing.T) {
+	events := buildAuditChain(t, 3)
+	require.NoError(t, VerifyAuditChain([]byte{}, events))
+	require.NoError(t, VerifyAuditChain(events[0].Hash, events[1:]))
+
+	tampered := buildAuditChain(t, 3)
+	tampered[1].Details = json.RawMessage(`{"name":"Duka"}`)
+	assert.ErrorIs(t, VerifyAuditChain([]byte{}, tampered), ErrAuditChainBroken)
+
+	removed := buildAuditChain(t, 3)
+	removed = append(removed[:1], removed[2:]...)
+	assert.ErrorIs(t, VerifyAuditChain([]byte{}, removed), ErrAuditChainBroken)
+}
+
+func TestHashAuditEventIgnoresTimeZoneAndNanoseconds(t *testing.T) {
+	event := buildAuditChain(t, 1)[0]
+	hash := HashAuditEvent(event.PrevHash, event)
+
+	// Postgres hands back microseconds in the session's time zone.
+	event.OccurredAt = event.OccurredAt.Truncate(time.Microsecond).In(time.FixedZone("EAT", 3*60*60))
+	assert.Equal(t, hash, HashAuditEvent(event.PrevHash, event))
+}
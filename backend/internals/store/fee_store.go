@@ -0,0 +1,239 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+)
+
+const (
+	FeeTransactionPayment    = "payment"
+	FeeTransactionWithdrawal = "withdrawal"
+	FeeTransactionTransfer   = "transfer"
+)
+
+const (
+	FeeKindFree       = "free"
+	FeeKindFlat       = "flat"
+	FeeKindPercentage = "percentage"
+	FeeKindTiered     = "tiered"
+)
+
+// FeeTier applies to amounts up to and including UpTo. A nil UpTo covers
+// everything above the previous tier.
+type FeeTier struct {
+	UpTo        *money.Amount `json:"up_to"`
+	Flat        money.Amount  `json:"flat"`
+	BasisPoints int64         `json:"basis_points"`
+}
+
+// FeeRule prices one transaction type, either platform wide or for a single
+// merchant. Rules with StartsAt or EndsAt set are promotions that only apply
+// inside that window.
+type FeeRule struct {
+	ID              string        `json:"id"`
+	Name            string        `json:"name"`
+	TransactionType string        `json:"transaction_type"`
+	MerchantID      string        `json:"merchant_id,omitempty"`
+	Kind            string        `json:"kind"`
+	Flat            money.Amount  `json:"flat"`
+	BasisPoints     int64         `json:"basis_points"`
+	Tiers           []FeeTier     `json:"tiers"`
+	MinFee          money.Amount  `json:"min_fee"`
+	MaxFee          *money.Amount `json:"max_fee"`
+	Rounding        string        `json:"rounding"`
+	StartsAt        *time.Time    `json:"starts_at"`
+	EndsAt          *time.Time    `json:"ends_at"`
+	Active          bool          `json:"active"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
+// IsPromotion reports whether the rule only applies inside a time window.
+func (fr *FeeRule) IsPromotion() bool {
+	return fr.StartsAt != nil || fr.EndsAt != nil
+}
+
+// feeRuleSnapshot is what transactions and withdrawals keep of the rule that
+// priced them, so later edits to the rule do not rewrite history.
+func feeRuleSnapshot(rule *FeeRule) ([]byte, error) {
+	if rule == nil {
+		return nil, nil
+	}
+	return json.Marshal(rule)
+}
+
+func scanFeeRuleSnapshot(snapshot []byte) (*FeeRule, error) {
+	if len(snapshot) == 0 {
+		return nil, nil
+	}
+	rule := &FeeRule{}
+	return rule, json.Unmarshal(snapshot, rule)
+}
+
+type PostgresFeeStore struct {
+	db *sql.DB
+}
+
+func NewPostgresFeeStore(db *sql.DB) *PostgresFeeStore {
+	return &PostgresFeeStore{db: db}
+}
+
+type FeeStore interface {
+	CreateFeeRule(rule *FeeRule) (*FeeRule, error)
+	GetFeeRules() ([]*FeeRule, error)
+	GetFeeRuleByID(id string) (*FeeRule, error)
+	UpdateFeeRule(rule *FeeRule) (*FeeRule, error)
+	DeactivateFeeRule(id string) error
+	GetApplicableFeeRules(transactionType string, merchantID string, at time.Time) ([]*FeeRule, error)
+}
+
+const feeRuleColumns = `id, name, transaction_type, COALESCE(merchant_id::text, ''), kind, flat_fee, basis_points, tiers, min_fee, max_fee, rounding, starts_at, ends_at, active, created_at, updated_at`
+
+func scanFeeRule(row interface{ Scan(...any) error }) (*FeeRule, error) {
+	rule := &FeeRule{}
+	var tiers []byte
+	var maxFee sql.NullInt64
+	var startsAt, endsAt sql.NullTime
+	err := row.Scan(&rule.ID, &rule.Name, &rule.TransactionType, &rule.MerchantID, &rule.Kind, &rule.Flat, &rule.BasisPoints, &tiers, &rule.MinFee, &maxFee, &rule.Rounding, &startsAt, &endsAt, &rule.Active, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(tiers, &rule.Tiers); err != nil {
+		return nil, err
+	}
+	if maxFee.Valid {
+		amount := money.Amount(maxFee.Int64)
+		rule.MaxFee = &amount
+	}
+	if startsAt.Valid {
+		rule.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		rule.EndsAt = &endsAt.Time
+	}
+	return rule, nil
+}
+
+func scanFeeRules(rows *sql.Rows) ([]*FeeRule, error) {
+	defer rows.Close()
+
+	rules := []*FeeRule{}
+	for rows.Next() {
+		rule, err := scanFeeRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func feeRuleTiers(rule *FeeRule) ([]byte, error) {
+	if rule.Tiers == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(rule.Tiers)
+}
+
+func (pf *PostgresFeeStore) CreateFeeRule(rule *FeeRule) (*FeeRule, error) {
+	tiers, err := feeRuleTiers(rule)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+	INSERT INTO fee_rules (name, transaction_type, merchant_id, kind, flat_fee, basis_points, tiers, min_fee, max_fee, rounding, starts_at, ends_at, active)
+	VALUES ($1, $2, NULLIF($3, '')::uuid, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	RETURNING ` + feeRuleColumns
+
+	return scanFeeRule(pf.db.QueryRow(query, rule.Name, rule.TransactionType, rule.MerchantID, rule.Kind, rule.Flat, rule.BasisPoints, tiers, rule.MinFee, rule.MaxFee, rule.Rounding, rule.StartsAt, rule.EndsAt, rule.Active))
+}
+
+func (pf *PostgresFeeStore) GetFeeRules() ([]*FeeRule, error) {
+	query := `SELECT ` + feeRuleColumns + `
+	FROM fee_rules
+	ORDER BY transaction_type, created_at DESC
+	`
+	rows, err := pf.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	return scanFeeRules(rows)
+}
+
+func (pf *PostgresFeeStore) GetFeeRuleByID(id string) (*FeeRule, error) {
+	query := `SELECT ` + feeRuleColumns + `
+	FROM fee_rules
+	WHERE id = $1
+	`
+	rule, err := scanFeeRule(pf.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return rule, err
+}
+
+// UpdateFeeRule overwrites every editable field. It returns nil, nil when
+// the rule does not exist.
+func (pf *PostgresFeeStore) UpdateFeeRule(rule *FeeRule) (*FeeRule, error) {
+	tiers, err := feeRuleTiers(rule)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+	UPDATE fee_rules
+	SET name = $2, transaction_type = $3, merchant_id = NULLIF($4, '')::uuid, kind = $5, flat_fee = $6, basis_points = $7,
+		tiers = $8, min_fee = $9, max_fee = $10, rounding = $11, starts_at = $12, ends_at = $13, active = $14,
+		updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1
+	RETURNING ` + feeRuleColumns
+
+	updated, err := scanFeeRule(pf.db.QueryRow(query, rule.ID, rule.Name, rule.TransactionType, rule.MerchantID, rule.Kind, rule.Flat, rule.BasisPoints, tiers, rule.MinFee, rule.MaxFee, rule.Rounding, rule.StartsAt, rule.EndsAt, rule.Active))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return updated, err
+}
+
+// DeactivateFeeRule retires a rule. Rules are never deleted so transactions
+// keep pointing at the rule that priced them.
+func (pf *PostgresFeeStore) DeactivateFeeRule(id string) error {
+	query := `
+	UPDATE fee_rules
+	SET active = FALSE, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1
+	`
+	result, err := pf.db.Exec(query, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetApplicableFeeRules returns the active platform and merchant rules for
+// transactionType whose window, if any, contains at.
+func (pf *PostgresFeeStore) GetApplicableFeeRules(transactionType string, merchantID string, at time.Time) ([]*FeeRule, error) {
+	query := `SELECT ` + feeRuleColumns + `
+	FROM fee_rules
+	WHERE transaction_type = $1
+		AND active
+		AND (merchant_id IS NULL OR merchant_id = NULLIF($2, '')::uuid)
+		AND (starts_at IS NULL OR starts_at <= $3)
+		AND (ends_at IS NULL OR ends_at > $3)
+	`
+	rows, err := pf.db.Query(query, transactionType, merchantID, at)
+	if err != nil {
+		return nil, err
+	}
+	return scanFeeRules(rows)
+}
@@ -0,0 +1,442 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+)
+
+const (
+	FraudRuleDailyVolume            = "daily_volume"
+	FraudRuleVelocity               = "velocity"
+	FraudRuleNewAccountLargePayment = "new_account_large_payment"
+	FraudRuleUnusualShop            = "unusual_shop"
+)
+
+// Fraud outcomes, least severe first. A rule's action is one of these, and a
+// decision's outcome is the most severe action among the rules it hit.
+const (
+	FraudOutcomeAllow     = "allow"
+	FraudOutcomeChallenge = "challenge"
+	FraudOutcomeBlock     = "block"
+)
+
+const (
+	FraudChallengePending = "pending"
+	FraudChallengePassed  = "passed"
+)
+
+const (
+	FraudReviewFraud      = "fraud"
+	FraudReviewLegitimate = "legitimate"
+)
+
+// FraudRule is one check payments are screened against. Which thresholds it
+// uses depends on Kind; see the fraud_rules migration.
+type FraudRule struct {
+	ID              string       `json:"id"`
+	Name            string       `json:"name"`
+	Kind            string       `json:"kind"`
+	Action          string       `json:"action"`
+	AmountThreshold money.Amount `json:"amount_threshold"`
+	CountThreshold  int          `json:"count_threshold"`
+	WindowSeconds   int          `json:"window_seconds"`
+	Active          bool         `json:"active"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+}
+
+// Window is WindowSeconds as a duration.
+func (fr *FraudRule) Window() time.Duration {
+	return time.Duration(fr.WindowSeconds) * time.Second
+}
+
+// FraudRuleHit is a rule a payment tripped, as the rule stood at the time.
+type FraudRuleHit struct {
+	RuleID   string `json:"rule_id"`
+	RuleName string `json:"rule_name"`
+	Kind     string `json:"kind"`
+	Action   string `json:"action"`
+	Detail   string `json:"detail"`
+}
+
+// FraudDecision records a screened payment that hit at least one rule.
+// ShopID is empty for split payments, which go to several shops.
+type FraudDecision struct {
+	ID              string          `json:"id"`
+	UserID          string          `json:"user_id"`
+	ShopID          string          `json:"shop_id"`
+	Amount          money.Amount    `json:"amount"`
+	Outcome         string          `json:"outcome"`
+	ChallengeStatus string          `json:"challenge_status"`
+	TransactionID   string          `json:"transaction_id"`
+	ReviewOutcome   string          `json:"review_outcome"`
+	ReviewNote      string          `json:"review_note"`
+	ReviewedBy      string          `json:"reviewed_by"`
+	ReviewedAt      *time.Time      `json:"reviewed_at"`
+	CreatedAt       time.Time       `json:"created_at"`
+	Hits            []*FraudRuleHit `json:"hits"`
+}
+
+// FraudDecisionFilter narrows the decisions listed for review. Zero values
+// match everything; Unreviewed keeps only decisions nobody has reviewed.
+type FraudDecisionFilter struct {
+	UserID     string
+	Outcome    string
+	Unreviewed bool
+	Limit      int
+	Offset     int
+}
+
+type PostgresFraudStore struct {
+	db *sql.DB
+}
+
+func NewPostgresFraudStore(db *sql.DB) *PostgresFraudStore {
+	return &PostgresFraudStore{db: db}
+}
+
+type FraudStore interface {
+	CreateFraudRule(rule *FraudRule) (*FraudRule, error)
+	GetFraudRules() ([]*FraudRule, error)
+	GetFraudRuleByID(id string) (*FraudRule, error)
+	UpdateFraudRule(rule *FraudRule) (*FraudRule, error)
+	GetActiveFraudRules() ([]*FraudRule, error)
+	GetUserPaymentVolume(userID string, since time.Time) (money.Amount, error)
+	CountUserPayments(userID string, since time.Time) (int, error)
+	CountUserShopPayments(userID string, shopID string) (int, error)
+	CreateFraudDecision(decision *FraudDecision) (*FraudDecision, error)
+	GetFraudDecisionByID(id string) (*FraudDecision, error)
+	SearchFraudDecisions(filter FraudDecisionFilter) ([]*FraudDecision, error)
+	PassFraudChallenge(id string) (bool, error)
+	SetFraudDecisionTransaction(id string, transactionID string) error
+	ReviewFraudDecision(id string, outcome string, note string, reviewedBy string) (*FraudDecision, error)
+}
+
+const fraudRuleColumns = `id, name, kind, action, amount_threshold, count_threshold, window_seconds, active, created_at, updated_at`
+
+func scanFraudRule(row interface{ Scan(...any) error }) (*FraudRule, error) {
+	rule := &FraudRule{}
+	err := row.Scan(&rule.ID, &rule.Name, &rule.Kind, &rule.Action, &rule.AmountThreshold, &rule.CountThreshold, &rule.WindowSeconds, &rule.Active, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func scanFraudRules(rows *sql.Rows) ([]*FraudRule, error) {
+	defer rows.Close()
+
+	rules := []*FraudRule{}
+	for rows.Next() {
+		rule, err := scanFraudRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (pf *PostgresFraudStore) CreateFraudRule(rule *FraudRule) (*FraudRule, error) {
+	query := `
+	INSERT INTO fraud_rules (name, kind, action, amount_threshold, count_threshold, window_seconds, active)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING ` + fraudRuleColumns
+
+	return scanFraudRule(pf.db.QueryRow(query, rule.Name, rule.Kind, rule.Action, rule.AmountThreshold, rule.CountThreshold, rule.WindowSeconds, rule.Active))
+}
+
+func (pf *PostgresFraudStore) GetFraudRules() ([]*FraudRule, error) {
+	query := `SELECT ` + fraudRuleColumns + `
+	FROM fraud_rules
+	ORDER BY kind, created_at
+	`
+	rows, err := pf.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	return scanFraudRules(rows)
+}
+
+func (pf *PostgresFraudStore) GetFraudRuleByID(id string) (*FraudRule, error) {
+	query := `SELECT ` + fraudRuleColumns + `
+	FROM fraud_rules
+	WHERE id = $1
+	`
+	rule, err := scanFraudRule(pf.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return rule, err
+}
+
+// UpdateFraudRule overwrites every editable field. It returns nil, nil when
+// the rule does not exist.
+func (pf *PostgresFraudStore) UpdateFraudRule(rule *FraudRule) (*FraudRule, error) {
+	query := `
+	UPDATE fraud_rules
+	SET name = $2, kind = $3, action = $4, amount_threshold = $5, count_threshold = $6, window_seconds = $7, active = $8,
+		updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1
+	RETURNING ` + fraudRuleColumns
+
+	updated, err := scanFraudRule(pf.db.QueryRow(query, rule.ID, rule.Name, rule.Kind, rule.Action, rule.AmountThreshold, rule.CountThreshold, rule.WindowSeconds, rule.Active))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return updated, err
+}
+
+func (pf *PostgresFraudStore) GetActiveFraudRules() ([]*FraudRule, error) {
+	query := `SELECT ` + fraudRuleColumns + `
+	FROM fraud_rules
+	WHERE active
+	ORDER BY created_at
+	`
+	rows, err := pf.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	return scanFraudRules(rows)
+}
+
+// GetUserPaymentVolume sums what userID has paid, transfers included, since
+// since. Split payments count once, at the parent.
+func (pf *PostgresFraudStore) GetUserPaymentVolume(userID string, since time.Time) (money.Amount, error) {
+	query := `
+	SELECT COALESCE(SUM(amount), 0)::BIGINT
+	FROM (
+		SELECT amount FROM transactions WHERE user_id = $1 AND parent_id IS NULL AND created_at >= $2
+		UNION ALL
+		SELECT amount FROM transfers WHERE sender_id = $1 AND status = 'completed' AND created_at >= $2
+	) payments
+	`
+	var volume money.Amount
+	err := pf.db.QueryRow(query, userID, since).Scan(&volume)
+	return volume, err
+}
+
+// CountUserPayments counts the payments and transfers userID has made since
+// since. Split payments count once, at the parent.
+func (pf *PostgresFraudStore) CountUserPayments(userID string, since time.Time) (int, error) {
+	query := `
+	SELECT
+		(SELECT COUNT(*) FROM transactions WHERE user_id = $1 AND parent_id IS NULL AND created_at >= $2) +
+		(SELECT COUNT(*) FROM transfers WHERE sender_id = $1 AND status = 'completed' AND created_at >= $2)
+	`
+	var count int
+	err := pf.db.QueryRow(query, userID, since).Scan(&count)
+	return count, err
+}
+
+// CountUserShopPayments counts every payment userID has made to shopID,
+// including legs of split payments.
+func (pf *PostgresFraudStore) CountUserShopPayments(userID string, shopID string) (int, error) {
+	query := `
+	SELECT COUNT(*)
+	FROM transactions
+	WHERE user_id = $1 AND shop_id = $2
+	`
+	var count int
+	err := pf.db.QueryRow(query, userID, shopID).Scan(&count)
+	return count, err
+}
+
+const fraudDecisionColumns = `id, user_id, COALESCE(shop_id::text, ''), amount, outcome, challenge_status, COALESCE(transaction_id::text, ''), review_outcome, review_note, reviewed_by, reviewed_at, created_at`
+
+func scanFraudDecision(row interface{ Scan(...any) error }) (*FraudDecision, error) {
+	decision := &FraudDecision{}
+	err := row.Scan(&decision.ID, &decision.UserID, &decision.ShopID, &decision.Amount, &decision.Outcome, &decision.ChallengeStatus, &decision.TransactionID, &decision.ReviewOutcome, &decision.ReviewNote, &decision.ReviewedBy, &decision.ReviewedAt, &decision.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return decision, nil
+}
+
+// CreateFraudDecision saves a decision together with its rule hits.
+func (pf *PostgresFraudStore) CreateFraudDecision(decision *FraudDecision) (*FraudDecision, error) {
+	tx, err := pf.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO fraud_decisions (user_id, shop_id, amount, outcome, challenge_status)
+	VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5)
+	RETURNING ` + fraudDecisionColumns
+
+	created, err := scanFraudDecision(tx.QueryRow(query, decision.UserID, decision.ShopID, decision.Amount, decision.Outcome, decision.ChallengeStatus))
+	if err != nil {
+		return nil, err
+	}
+
+	hitQuery := `
+	INSERT INTO fraud_rule_hits (decision_id, rule_id, rule_name, kind, action, detail)
+	VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5, $6)
+	`
+	for _, hit := range decision.Hits {
+		_, err = tx.Exec(hitQuery, created.ID, hit.RuleID, hit.RuleName, hit.Kind, hit.Action, hit.Detail)
+		if err != nil {
+			return nil, err
+		}
+	}
+	created.Hits = decision.Hits
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// GetFraudDecisionByID returns the decision with its rule hits, or nil, nil
+// when there is none.
+func (pf *PostgresFraudStore) GetFraudDecisionByID(id string) (*FraudDecision, error) {
+	query := `SELECT ` + fraudDecisionColumns + `
+	FROM fraud_decisions
+	WHERE id = $1
+	`
+	decision, err := scanFraudDecision(pf.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = pf.loadFraudRuleHits([]*FraudDecision{decision})
+	if err != nil {
+		return nil, err
+	}
+	return decision, nil
+}
+
+// SearchFraudDecisions lists decisions newest first, each with its rule hits.
+func (pf *PostgresFraudStore) SearchFraudDecisions(filter FraudDecisionFilter) ([]*FraudDecision, error) {
+	query := `SELECT ` + fraudDecisionColumns + `
+	FROM fraud_decisions
+	WHERE ($1::text = '' OR user_id::text = $1::text)
+		AND ($2::text = '' OR outcome = $2::text)
+		AND (NOT $3::boolean OR review_outcome = '')
+	ORDER BY created_at DESC
+	LIMIT $4 OFFSET $5
+	`
+	rows, err := pf.db.Query(query, filter.UserID, filter.Outcome, filter.Unreviewed, PageSize(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	decisions := []*FraudDecision{}
+	for rows.Next() {
+		decision, err := scanFraudDecision(rows)
+		if err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, decision)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = pf.loadFraudRuleHits(decisions)
+	if err != nil {
+		return nil, err
+	}
+	return decisions, nil
+}
+
+func (pf *PostgresFraudStore) loadFraudRuleHits(decisions []*FraudDecision) error {
+	if len(decisions) == 0 {
+		return nil
+	}
+	byID := make(map[string]*FraudDecision, len(decisions))
+	ids := make([]string, len(decisions))
+	for i, decision := range decisions {
+		decision.Hits = []*FraudRuleHit{}
+		byID[decision.ID] = decision
+		ids[i] = decision.ID
+	}
+
+	query := `
+	SELECT decision_id, COALESCE(rule_id::text, ''), rule_name, kind, action, detail
+	FROM fraud_rule_hits
+	WHERE decision_id IN (SELECT unnest($1::text[])::uuid)
+	ORDER BY decision_id, id
+	`
+	rows, err := pf.db.Query(query, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var decisionID string
+		hit := &FraudRuleHit{}
+		err = rows.Scan(&decisionID, &hit.RuleID, &hit.RuleName, &hit.Kind, &hit.Action, &hit.Detail)
+		if err != nil {
+			return err
+		}
+		decision := byID[decisionID]
+		decision.Hits = append(decision.Hits, hit)
+	}
+	return rows.Err()
+}
+
+// PassFraudChallenge marks a pending challenge as answered. It reports false
+// when the challenge was already used, so one code confirms one payment.
+func (pf *PostgresFraudStore) PassFraudChallenge(id string) (bool, error) {
+	query := `
+	UPDATE fraud_decisions
+	SET challenge_status = $2
+	WHERE id = $1 AND challenge_status = $3
+	`
+	result, err := pf.db.Exec(query, id, FraudChallengePassed, FraudChallengePending)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (pf *PostgresFraudStore) SetFraudDecisionTransaction(id string, transactionID string) error {
+	query := `
+	UPDATE fraud_decisions
+	SET transaction_id = $2
+	WHERE id = $1
+	`
+	_, err := pf.db.Exec(query, id, transactionID)
+	return err
+}
+
+// ReviewFraudDecision records an admin's verdict on a decision. Decisions
+// may be reviewed again, which replaces the earlier verdict. It returns nil,
+// nil when the decision does not exist.
+func (pf *PostgresFraudStore) ReviewFraudDecision(id string, outcome string, note string, reviewedBy string) (*FraudDecision, error) {
+	query := `
+	UPDATE fraud_decisions
+	SET review_outcome = $2, review_note = $3, reviewed_by = $4, reviewed_at = CURRENT_TIMESTAMP
+	WHERE id = $1
+	RETURNING ` + fraudDecisionColumns
+
+	decision, err := scanFraudDecision(pf.db.QueryRow(query, id, outcome, note, reviewedBy))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = pf.loadFraudRuleHits([]*FraudDecision{decision})
+	if err != nil {
+		return nil, err
+	}
+	return decision, nil
+}
@@ -0,0 +1,346 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+)
+
+// KYC levels, lowest first.
+const (
+	KYCLevelNone     = "none"
+	KYCLevelPhone    = "phone_verified"
+	KYCLevelID       = "id_verified"
+	KYCLevelBusiness = "business_verified"
+)
+
+// KYCLevels lists the levels lowest first.
+var KYCLevels = []string{KYCLevelNone, KYCLevelPhone, KYCLevelID, KYCLevelBusiness}
+
+const (
+	KYCSubjectUser     = "user"
+	KYCSubjectMerchant = "merchant"
+)
+
+const (
+	KYCStatusPending  = "pending"
+	KYCStatusApproved = "approved"
+	KYCStatusRejected = "rejected"
+)
+
+// Operations that KYC limits apply to.
+const (
+	KYCOperationPayment    = "payment"
+	KYCOperationPurchase   = "purchase"
+	KYCOperationWithdrawal = "withdrawal"
+)
+
+var ErrKYCSubmissionPending = errors.New("a verification is already under review")
+
+// KYCRank orders levels so they can be compared. Unknown levels rank below
+// none.
+func KYCRank(level string) int {
+	for i, l := range KYCLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// KYCSubmission is a set of documents sent for a higher KYC level.
+type KYCSubmission struct {
+	ID                string     `json:"id"`
+	SubjectType       string     `json:"subject_type"`
+	SubjectID         string     `json:"subject_id"`
+	Level             string     `json:"level"`
+	DocumentType      string     `json:"document_type"`
+	DocumentNumber    string     `json:"document_number"`
+	DocumentURLs      []string   `json:"document_urls"`
+	Status            string     `json:"status"`
+	Provider          string     `json:"provider"`
+	ProviderReference string     `json:"provider_reference"`
+	Reason            string     `json:"reason"`
+	DecidedBy         string     `json:"decided_by"`
+	CreatedAt         time.Time  `json:"created_at"`
+	DecidedAt         *time.Time `json:"decided_at"`
+}
+
+// KYCLimit caps how much an account at Level may move through Operation.
+type KYCLimit struct {
+	Level        string       `json:"level"`
+	Operation    string       `json:"operation"`
+	DailyLimit   money.Amount `json:"daily_limit"`
+	MonthlyLimit money.Amount `json:"monthly_limit"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
+// KYCUsage is what an account has already moved through an operation in the
+// current day and month.
+type KYCUsage struct {
+	Daily   money.Amount `json:"daily"`
+	Monthly money.Amount `json:"monthly"`
+}
+
+type PostgresKYCStore struct {
+	db *sql.DB
+}
+
+func NewPostgresKYCStore(db *sql.DB) *PostgresKYCStore {
+	return &PostgresKYCStore{db: db}
+}
+
+type KYCStore interface {
+	CreateKYCSubmission(submission *KYCSubmission) (*KYCSubmission, error)
+	GetKYCSubmissionByID(id string) (*KYCSubmission, error)
+	GetKYCSubmissions(subjectType string, subjectID string) ([]*KYCSubmission, error)
+	SearchKYCSubmissions(status string, limit int, offset int) ([]*KYCSubmission, error)
+	SetKYCProviderReference(id string, reference string) error
+	DecideKYCSubmission(id string, status string, reason string, decidedBy string) (*KYCSubmission, error)
+	GetKYCLimits() ([]*KYCLimit, error)
+	GetKYCLimit(level string, operation string) (*KYCLimit, error)
+	UpsertKYCLimit(limit *KYCLimit) (*KYCLimit, error)
+	GetKYCUsage(subjectID string, operation string, dayStart time.Time, monthStart time.Time) (*KYCUsage, error)
+}
+
+const kycSubmissionColumns = `id, subject_type, subject_id, level, document_type, document_number, document_urls, status, provider, provider_reference, reason, decided_by, created_at, decided_at`
+
+func scanKYCSubmission(row interface{ Scan(...any) error }) (*KYCSubmission, error) {
+	submission := &KYCSubmission{}
+	var urls []byte
+	err := row.Scan(&submission.ID, &submission.SubjectType, &submission.SubjectID, &submission.Level, &submission.DocumentType, &submission.DocumentNumber, &urls, &submission.Status, &submission.Provider, &submission.ProviderReference, &submission.Reason, &submission.DecidedBy, &submission.CreatedAt, &submission.DecidedAt)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(urls, &submission.DocumentURLs)
+	if err != nil {
+		return nil, err
+	}
+	return submission, nil
+}
+
+func scanKYCSubmissions(rows *sql.Rows) ([]*KYCSubmission, error) {
+	defer rows.Close()
+
+	submissions := []*KYCSubmission{}
+	for rows.Next() {
+		submission, err := scanKYCSubmission(rows)
+		if err != nil {
+			return nil, err
+		}
+		submissions = append(submissions, submission)
+	}
+	return submissions, rows.Err()
+}
+
+func (pk *PostgresKYCStore) CreateKYCSubmission(submission *KYCSubmission) (*KYCSubmission, error) {
+	urls, err := json.Marshal(submission.DocumentURLs)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	INSERT INTO kyc_submissions (subject_type, subject_id, level, document_type, document_number, document_urls, provider)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING ` + kycSubmissionColumns
+	created, err := scanKYCSubmission(pk.db.QueryRow(query, submission.SubjectType, submission.SubjectID, submission.Level, submission.DocumentType, submission.DocumentNumber, urls, submission.Provider))
+	if isUniqueViolation(err) {
+		return nil, ErrKYCSubmissionPending
+	}
+	return created, err
+}
+
+func (pk *PostgresKYCStore) GetKYCSubmissionByID(id string) (*KYCSubmission, error) {
+	query := `
+	SELECT ` + kycSubmissionColumns + `
+	FROM kyc_submissions
+	WHERE id = $1
+	`
+	submission, err := scanKYCSubmission(pk.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return submission, err
+}
+
+// GetKYCSubmissions lists an account's submissions, newest first.
+func (pk *PostgresKYCStore) GetKYCSubmissions(subjectType string, subjectID string) ([]*KYCSubmission, error) {
+	query := `
+	SELECT ` + kycSubmissionColumns + `
+	FROM kyc_submissions
+	WHERE subject_type = $1 AND subject_id = $2
+	ORDER BY created_at DESC
+	`
+	rows, err := pk.db.Query(query, subjectType, subjectID)
+	if err != nil {
+		return nil, err
+	}
+	return scanKYCSubmissions(rows)
+}
+
+// SearchKYCSubmissions lists submissions with status, or all of them, oldest
+// first so the review queue is worked in order.
+func (pk *PostgresKYCStore) SearchKYCSubmissions(status string, limit int, offset int) ([]*KYCSubmission, error) {
+	query := `
+	SELECT ` + kycSubmissionColumns + `
+	FROM kyc_submissions
+	WHERE ($1::text = '' OR status = $1::text)
+	ORDER BY created_at
+	LIMIT $2 OFFSET $3
+	`
+	rows, err := pk.db.Query(query, status, PageSize(limit), offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanKYCSubmissions(rows)
+}
+
+// SetKYCProviderReference records the provider's id for a submission it is
+// still reviewing.
+func (pk *PostgresKYCStore) SetKYCProviderReference(id string, reference string) error {
+	_, err := pk.db.Exec(`UPDATE kyc_submissions SET provider_reference = $2 WHERE id = $1`, id, reference)
+	return err
+}
+
+// DecideKYCSubmission approves or rejects a pending submission. Approval
+// raises the account to the submission's level, never lowering it. It
+// returns nil when the submission is missing or already decided.
+func (pk *PostgresKYCStore) DecideKYCSubmission(id string, status string, reason string, decidedBy string) (*KYCSubmission, error) {
+	tx, err := pk.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE kyc_submissions
+	SET status = $2, reason = $3, decided_by = $4, decided_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status = 'pending'
+	RETURNING ` + kycSubmissionColumns
+	submission, err := scanKYCSubmission(tx.QueryRow(query, id, status, reason, decidedBy))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if status == KYCStatusApproved {
+		// The table comes from a fixed set, never from input.
+		table := "users"
+		if submission.SubjectType == KYCSubjectMerchant {
+			table = "merchants"
+		}
+		_, err = tx.Exec(`
+		UPDATE `+table+`
+		SET kyc_level = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND `+kycRankSQL+` < $3
+		`, submission.SubjectID, submission.Level, KYCRank(submission.Level))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return submission, tx.Commit()
+}
+
+// kycRankSQL is KYCRank of the kyc_level column.
+const kycRankSQL = `CASE kyc_level WHEN 'none' THEN 0 WHEN 'phone_verified' THEN 1 WHEN 'id_verified' THEN 2 WHEN 'business_verified' THEN 3 ELSE -1 END`
+
+func (pk *PostgresKYCStore) GetKYCLimits() ([]*KYCLimit, error) {
+	rows, err := pk.db.Query(`SELECT level, operation, daily_limit, monthly_limit, updated_at FROM kyc_limits ORDER BY operation, level`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	limits := []*KYCLimit{}
+	for rows.Next() {
+		limit := &KYCLimit{}
+		err = rows.Scan(&limit.Level, &limit.Operation, &limit.DailyLimit, &limit.MonthlyLimit, &limit.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		limits = append(limits, limit)
+	}
+	return limits, rows.Err()
+}
+
+// GetKYCLimit returns the limit for level and operation, or nil when the
+// operation is not limited at that level.
+func (pk *PostgresKYCStore) GetKYCLimit(level string, operation string) (*KYCLimit, error) {
+	query := `
+	SELECT level, operation, daily_limit, monthly_limit, updated_at
+	FROM kyc_limits
+	WHERE level = $1 AND operation = $2
+	`
+	limit := &KYCLimit{}
+	err := pk.db.QueryRow(query, level, operation).Scan(&limit.Level, &limit.Operation, &limit.DailyLimit, &limit.MonthlyLimit, &limit.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return limit, nil
+}
+
+func (pk *PostgresKYCStore) UpsertKYCLimit(limit *KYCLimit) (*KYCLimit, error) {
+	query := `
+	INSERT INTO kyc_limits (level, operation, daily_limit, monthly_limit)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (level, operation) DO UPDATE
+	SET daily_limit = EXCLUDED.daily_limit, monthly_limit = EXCLUDED.monthly_limit, updated_at = CURRENT_TIMESTAMP
+	RETURNING updated_at
+	`
+	err := pk.db.QueryRow(query, limit.Level, limit.Operation, limit.DailyLimit, limit.MonthlyLimit).Scan(&limit.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return limit, nil
+}
+
+// kycUsageQueries sum each operation for one account since $2 (the start of
+// the month) and $3 (the start of the day). Payments include transfers to
+// other users, and split payments count once, at the parent. Withdrawals
+// still awaiting approval count against the limit.
+var kycUsageQueries = map[string]string{
+	KYCOperationPayment: `
+	SELECT COALESCE(SUM(amount) FILTER (WHERE created_at >= $3), 0)::BIGINT, COALESCE(SUM(amount), 0)::BIGINT
+	FROM (
+		SELECT amount, created_at
+		FROM transactions
+		WHERE user_id = $1 AND parent_id IS NULL AND created_at >= $2
+		UNION ALL
+		SELECT amount, created_at
+		FROM transfers
+		WHERE sender_id = $1 AND status = 'completed' AND created_at >= $2
+	) payments
+	`,
+	KYCOperationPurchase: `
+	SELECT COALESCE(SUM(amount) FILTER (WHERE created_at >= $3), 0)::BIGINT, COALESCE(SUM(amount), 0)::BIGINT
+	FROM purchases
+	WHERE user_id = $1 AND created_at >= $2
+	`,
+	KYCOperationWithdrawal: `
+	SELECT COALESCE(SUM(amount) FILTER (WHERE created_at >= $3), 0)::BIGINT, COALESCE(SUM(amount), 0)::BIGINT
+	FROM withdrawals
+	WHERE merchant_id = $1 AND status IN ('completed', 'pending_approval') AND created_at >= $2
+	`,
+}
+
+func (pk *PostgresKYCStore) GetKYCUsage(subjectID string, operation string, dayStart time.Time, monthStart time.Time) (*KYCUsage, error) {
+	query, ok := kycUsageQueries[operation]
+	if !ok {
+		return nil, errors.New("unknown kyc operation " + operation)
+	}
+
+	usage := &KYCUsage{}
+	err := pk.db.QueryRow(query, subjectID, monthStart, dayStart).Scan(&usage.Daily, &usage.Monthly)
+	if err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
@@ -0,0 +1,295 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+)
+
+const (
+	MandateStatusActive    = "active"
+	MandateStatusPaused    = "paused"
+	MandateStatusCancelled = "cancelled"
+	MandateStatusCompleted = "completed"
+
+	MandateIntervalDay   = "day"
+	MandateIntervalWeek  = "week"
+	MandateIntervalMonth = "month"
+
+	MandateRunSucceeded = "succeeded"
+	MandateRunFailed    = "failed"
+)
+
+// Mandate is a user's standing instruction to pay a shop Amount every
+// IntervalCount IntervalUnits from StartsAt. It stops after MaxPayments
+// payments when that is set, or once a payment would fall after EndsAt.
+//
+// Cycle counts the periods that have been paid or given up on; Attempts
+// counts failed tries within the current one. NextRunAt is nil once the
+// mandate has finished.
+type Mandate struct {
+	ID            string       `json:"id"`
+	UserID        string       `json:"user_id"`
+	ShopID        string       `json:"shop_id"`
+	MerchantID    string       `json:"merchant_id"`
+	Amount        money.Amount `json:"amount"`
+	IntervalUnit  string       `json:"interval_unit"`
+	IntervalCount int          `json:"interval_count"`
+	Reference     string       `json:"reference"`
+	StartsAt      time.Time    `json:"starts_at"`
+	EndsAt        *time.Time   `json:"ends_at"`
+	MaxPayments   int          `json:"max_payments"`
+	PaymentsMade  int          `json:"payments_made"`
+	Cycle         int          `json:"cycle"`
+	Attempts      int          `json:"attempts"`
+	NextRunAt     *time.Time   `json:"next_run_at"`
+	LastError     string       `json:"last_error"`
+	Status        string       `json:"status"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+// MandateRun is one attempt to collect a mandate payment.
+type MandateRun struct {
+	ID            string    `json:"id"`
+	MandateID     string    `json:"mandate_id"`
+	Cycle         int       `json:"cycle"`
+	Attempt       int       `json:"attempt"`
+	Status        string    `json:"status"`
+	TransactionID string    `json:"transaction_id,omitempty"`
+	Error         string    `json:"error"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type PostgresMandateStore struct {
+	db *sql.DB
+}
+
+func NewPostgresMandateStore(db *sql.DB) *PostgresMandateStore {
+	return &PostgresMandateStore{db: db}
+}
+
+type MandateStore interface {
+	CreateMandate(mandate *Mandate) (*Mandate, error)
+	GetMandateByID(id string) (*Mandate, error)
+	GetMandatesByUserID(userID string) ([]*Mandate, error)
+	GetMandatesByMerchantID(merchantID string, status string) ([]*Mandate, error)
+	GetMandateRuns(mandateID string) ([]*MandateRun, error)
+	PauseMandate(id string) (*Mandate, error)
+	ResumeMandate(mandate *Mandate) (*Mandate, error)
+	CancelMandate(id string) (*Mandate, error)
+	ClaimDueMandates(limit int, lease time.Duration) ([]*Mandate, error)
+	RecordMandateRun(mandate *Mandate, run *MandateRun) error
+}
+
+const mandateColumns = `id, user_id, shop_id, merchant_id, amount, interval_unit, interval_count, reference, starts_at, ends_at, max_payments, payments_made, cycle, attempts, next_run_at, last_error, status, created_at, updated_at`
+
+func scanMandate(row interface{ Scan(...any) error }) (*Mandate, error) {
+	mandate := &Mandate{}
+	var endsAt, nextRunAt sql.NullTime
+	err := row.Scan(&mandate.ID, &mandate.UserID, &mandate.ShopID, &mandate.MerchantID, &mandate.Amount, &mandate.IntervalUnit, &mandate.IntervalCount, &mandate.Reference, &mandate.StartsAt, &endsAt, &mandate.MaxPayments, &mandate.PaymentsMade, &mandate.Cycle, &mandate.Attempts, &nextRunAt, &mandate.LastError, &mandate.Status, &mandate.CreatedAt, &mandate.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if endsAt.Valid {
+		mandate.EndsAt = &endsAt.Time
+	}
+	if nextRunAt.Valid {
+		mandate.NextRunAt = &nextRunAt.Time
+	}
+	return mandate, nil
+}
+
+func scanMandates(rows *sql.Rows) ([]*Mandate, error) {
+	defer rows.Close()
+
+	mandates := []*Mandate{}
+	for rows.Next() {
+		mandate, err := scanMandate(rows)
+		if err != nil {
+			return nil, err
+		}
+		mandates = append(mandates, mandate)
+	}
+	return mandates, rows.Err()
+}
+
+// scanOptionalMandate maps a missing row to nil, nil for the updates that
+// only apply in a given status.
+func scanOptionalMandate(row *sql.Row) (*Mandate, error) {
+	mandate, err := scanMandate(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return mandate, err
+}
+
+// CreateMandate stores an active mandate whose first payment is due at
+// StartsAt.
+func (pm *PostgresMandateStore) CreateMandate(mandate *Mandate) (*Mandate, error) {
+	query := `
+	INSERT INTO mandates (user_id, shop_id, merchant_id, amount, interval_unit, interval_count, reference, starts_at, ends_at, max_payments, next_run_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $8)
+	RETURNING ` + mandateColumns
+
+	return scanMandate(pm.db.QueryRow(query, mandate.UserID, mandate.ShopID, mandate.MerchantID, mandate.Amount, mandate.IntervalUnit, mandate.IntervalCount, mandate.Reference, mandate.StartsAt, mandate.EndsAt, mandate.MaxPayments))
+}
+
+func (pm *PostgresMandateStore) GetMandateByID(id string) (*Mandate, error) {
+	query := `SELECT ` + mandateColumns + `
+	FROM mandates
+	WHERE id = $1
+	`
+	return scanOptionalMandate(pm.db.QueryRow(query, id))
+}
+
+func (pm *PostgresMandateStore) GetMandatesByUserID(userID string) ([]*Mandate, error) {
+	query := `SELECT ` + mandateColumns + `
+	FROM mandates
+	WHERE user_id = $1
+	ORDER BY created_at DESC
+	`
+	rows, err := pm.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanMandates(rows)
+}
+
+// GetMandatesByMerchantID lists mandates paying the merchant's shops. An
+// empty status returns all of them.
+func (pm *PostgresMandateStore) GetMandatesByMerchantID(merchantID string, status string) ([]*Mandate, error) {
+	query := `SELECT ` + mandateColumns + `
+	FROM mandates
+	WHERE merchant_id = $1 AND ($2 = '' OR status = $2)
+	ORDER BY created_at DESC
+	`
+	rows, err := pm.db.Query(query, merchantID, status)
+	if err != nil {
+		return nil, err
+	}
+	return scanMandates(rows)
+}
+
+func (pm *PostgresMandateStore) GetMandateRuns(mandateID string) ([]*MandateRun, error) {
+	query := `
+	SELECT id, mandate_id, cycle, attempt, status, COALESCE(transaction_id::text, ''), error, created_at
+	FROM mandate_runs
+	WHERE mandate_id = $1
+	ORDER BY created_at DESC
+	`
+	rows, err := pm.db.Query(query, mandateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []*MandateRun{}
+	for rows.Next() {
+		run := &MandateRun{}
+		err = rows.Scan(&run.ID, &run.MandateID, &run.Cycle, &run.Attempt, &run.Status, &run.TransactionID, &run.Error, &run.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// PauseMandate stops an active mandate from being collected. It returns
+// nil, nil when the mandate is not active.
+func (pm *PostgresMandateStore) PauseMandate(id string) (*Mandate, error) {
+	query := `
+	UPDATE mandates
+	SET status = 'paused', updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status = 'active'
+	RETURNING ` + mandateColumns
+
+	return scanOptionalMandate(pm.db.QueryRow(query, id))
+}
+
+// ResumeMandate reactivates a paused mandate with the schedule the caller
+// worked out, or completes it when NextRunAt is nil. It returns nil, nil
+// when the mandate is not paused.
+func (pm *PostgresMandateStore) ResumeMandate(mandate *Mandate) (*Mandate, error) {
+	query := `
+	UPDATE mandates
+	SET status = CASE WHEN $2::timestamptz IS NULL THEN 'completed' ELSE 'active' END,
+		next_run_at = $2, cycle = $3, attempts = 0, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status = 'paused'
+	RETURNING ` + mandateColumns
+
+	return scanOptionalMandate(pm.db.QueryRow(query, mandate.ID, mandate.NextRunAt, mandate.Cycle))
+}
+
+// CancelMandate ends an active or paused mandate for good. It returns nil,
+// nil when the mandate has already finished.
+func (pm *PostgresMandateStore) CancelMandate(id string) (*Mandate, error) {
+	query := `
+	UPDATE mandates
+	SET status = 'cancelled', next_run_at = NULL, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status IN ('active', 'paused')
+	RETURNING ` + mandateColumns
+
+	return scanOptionalMandate(pm.db.QueryRow(query, id))
+}
+
+// ClaimDueMandates returns active mandates whose next run has come and
+// pushes that run back by lease, so other instances skip them until the
+// claimer records the outcome or the lease runs out.
+func (pm *PostgresMandateStore) ClaimDueMandates(limit int, lease time.Duration) ([]*Mandate, error) {
+	query := `
+	UPDATE mandates
+	SET next_run_at = $1, updated_at = CURRENT_TIMESTAMP
+	WHERE id IN (
+		SELECT id
+		FROM mandates
+		WHERE status = 'active' AND next_run_at <= CURRENT_TIMESTAMP
+		ORDER BY next_run_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING ` + mandateColumns
+
+	rows, err := pm.db.Query(query, time.Now().Add(lease), limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanMandates(rows)
+}
+
+// RecordMandateRun saves the outcome of a run together with the schedule the
+// scheduler moved the mandate to. A mandate paused or cancelled while it was
+// being collected keeps that status.
+func (pm *PostgresMandateStore) RecordMandateRun(mandate *Mandate, run *MandateRun) error {
+	tx, err := pm.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+	INSERT INTO mandate_runs (mandate_id, cycle, attempt, status, transaction_id, error)
+	VALUES ($1, $2, $3, $4, NULLIF($5, '')::uuid, $6)
+	RETURNING id, created_at
+	`, mandate.ID, run.Cycle, run.Attempt, run.Status, run.TransactionID, run.Error).Scan(&run.ID, &run.CreatedAt)
+	if err != nil {
+		return err
+	}
+	run.MandateID = mandate.ID
+
+	_, err = tx.Exec(`
+	UPDATE mandates
+	SET payments_made = $2, cycle = $3, attempts = $4, last_error = $5,
+		next_run_at = CASE WHEN status = 'cancelled' THEN NULL ELSE $6::timestamptz END,
+		status = CASE WHEN status = 'active' THEN $7 ELSE status END,
+		updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1
+	`, mandate.ID, mandate.PaymentsMade, mandate.Cycle, mandate.Attempts, mandate.LastError, mandate.NextRunAt, mandate.Status)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
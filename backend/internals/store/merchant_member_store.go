@@ -0,0 +1,447 @@
+package store
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/tokens"
+)
+
+const (
+	MemberRoleOwner      = "owner"
+	MemberRoleManager    = "manager"
+	MemberRoleCashier    = "cashier"
+	MemberRoleAccountant = "accountant"
+)
+
+var MemberRoles = []string{MemberRoleOwner, MemberRoleManager, MemberRoleCashier, MemberRoleAccountant}
+
+const (
+	MemberStatusInvited  = "invited"
+	MemberStatusActive   = "active"
+	MemberStatusDisabled = "disabled"
+)
+
+// Permissions checked on merchant routes for team members. The merchant's
+// own login holds all of them.
+const (
+	PermissionShopsManage       = "shops:manage"
+	PermissionCampaignsManage   = "campaigns:manage"
+	PermissionPaymentsRead      = "payments:read"
+	PermissionPaymentsCreate    = "payments:create"
+	PermissionReportsRead       = "reports:read"
+	PermissionWithdrawalsRead   = "withdrawals:read"
+	PermissionWithdrawalsCreate = "withdrawals:create"
+	PermissionTeamManage        = "team:manage"
+	PermissionSettingsManage    = "settings:manage"
+	PermissionAuditRead         = "audit:read"
+)
+
+var rolePermissions = map[string][]string{
+	MemberRoleOwner: {
+		PermissionShopsManage, PermissionCampaignsManage, PermissionPaymentsRead, PermissionPaymentsCreate,
+		PermissionReportsRead, PermissionWithdrawalsRead, PermissionWithdrawalsCreate, PermissionTeamManage,
+		PermissionSettingsManage, PermissionAuditRead,
+	},
+	MemberRoleManager: {
+		PermissionShopsManage, PermissionCampaignsManage, PermissionPaymentsRead, PermissionPaymentsCreate,
+		PermissionReportsRead, PermissionWithdrawalsRead, PermissionTeamManage, PermissionAuditRead,
+	},
+	MemberRoleCashier: {
+		PermissionPaymentsRead, PermissionPaymentsCreate,
+	},
+	MemberRoleAccountant: {
+		PermissionPaymentsRead, PermissionReportsRead, PermissionWithdrawalsRead, PermissionAuditRead,
+	},
+}
+
+var (
+	ErrMemberUsernameTaken = errors.New("username is already taken")
+	ErrInvitationNotFound  = errors.New("invitation is invalid or has expired")
+	ErrMemberShopNotFound  = errors.New("shop_ids must name shops of this merchant")
+)
+
+func IsMemberRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RolePermissions lists what role may do.
+func RolePermissions(role string) []string {
+	return rolePermissions[role]
+}
+
+// MerchantMember is a team account acting on behalf of a merchant. ShopIDs
+// limits the member to those shops; empty means every shop.
+type MerchantMember struct {
+	ID           string    `json:"id"`
+	MerchantID   string    `json:"merchant_id"`
+	Username     string    `json:"username"`
+	MobileNumber string    `json:"mobile_number"`
+	PasswordHash password  `json:"-"`
+	Role         string    `json:"role"`
+	Status       string    `json:"status"`
+	ShopIDs      []string  `json:"shop_ids"`
+	InvitedBy    string    `json:"invited_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (m *MerchantMember) Can(permission string) bool {
+	for _, p := range rolePermissions[m.Role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MerchantMember) CanAccessShop(shopID string) bool {
+	if len(m.ShopIDs) == 0 {
+		return true
+	}
+	for _, id := range m.ShopIDs {
+		if id == shopID {
+			return true
+		}
+	}
+	return false
+}
+
+type PostgresMerchantMemberStore struct {
+	db *sql.DB
+}
+
+func NewPostgresMerchantMemberStore(db *sql.DB) *PostgresMerchantMemberStore {
+	return &PostgresMerchantMemberStore{db: db}
+}
+
+type MerchantMemberStore interface {
+	CreateInvitation(member *MerchantMember, ttl time.Duration) (*MerchantMember, *tokens.Token, error)
+	AcceptInvitation(tokenPlainText string, username string, plainPassword string) (*MerchantMember, error)
+	GetMemberByID(id string) (*MerchantMember, error)
+	GetMemberByUsername(username string) (*MerchantMember, error)
+	GetMembersByMerchantID(merchantID string) ([]*MerchantMember, error)
+	UpdateMember(member *MerchantMember) (*MerchantMember, error)
+	DisableMember(id string, merchantID string) (*MerchantMember, error)
+	CreateMemberToken(member *MerchantMember, ttl time.Duration, scope string) (*tokens.Token, error)
+	GetMemberToken(scope string, tokenPlainText string) (*Merchant, *MerchantMember, error)
+}
+
+const merchantMemberColumns = `m.id, m.merchant_id, COALESCE(m.username, ''), m.mobile_number, m.password_hash, m.role, m.status, COALESCE(m.invited_by::text, ''), m.created_at, m.updated_at,
+	COALESCE((SELECT string_agg(ms.shop_id::text, ',' ORDER BY ms.shop_id) FROM merchant_member_shops ms WHERE ms.member_id = m.id), '')`
+
+// scanMerchantMember reads merchantMemberColumns followed by any extra
+// columns into extra.
+func scanMerchantMember(row interface{ Scan(...any) error }, extra ...any) (*MerchantMember, error) {
+	member := &MerchantMember{PasswordHash: password{}}
+	var shopIDs string
+	dest := []any{&member.ID, &member.MerchantID, &member.Username, &member.MobileNumber, &member.PasswordHash.hash, &member.Role, &member.Status, &member.InvitedBy, &member.CreatedAt, &member.UpdatedAt, &shopIDs}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	member.ShopIDs = []string{}
+	if shopIDs != "" {
+		member.ShopIDs = strings.Split(shopIDs, ",")
+	}
+	return member, nil
+}
+
+func scanOptionalMerchantMember(row *sql.Row) (*MerchantMember, error) {
+	member, err := scanMerchantMember(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return member, err
+}
+
+// setMemberShops replaces the member's shop assignments. Shops must belong to
+// the member's merchant.
+func setMemberShops(tx *sql.Tx, member *MerchantMember) error {
+	_, err := tx.Exec(`DELETE FROM merchant_member_shops WHERE member_id = $1`, member.ID)
+	if err != nil {
+		return err
+	}
+	if len(member.ShopIDs) == 0 {
+		return nil
+	}
+	result, err := tx.Exec(`
+	INSERT INTO merchant_member_shops (member_id, shop_id)
+	SELECT $1, id FROM shops WHERE merchant_id = $2 AND id::text = ANY($3)
+	`, member.ID, member.MerchantID, member.ShopIDs)
+	if err != nil {
+		return err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if int(inserted) != len(member.ShopIDs) {
+		return ErrMemberShopNotFound
+	}
+	return nil
+}
+
+// CreateInvitation records an invited member and issues the invitation
+// token they accept it with.
+func (pm *PostgresMerchantMemberStore) CreateInvitation(member *MerchantMember, ttl time.Duration) (*MerchantMember, *tokens.Token, error) {
+	tx, err := pm.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO merchant_members (merchant_id, mobile_number, role, status, invited_by)
+	VALUES ($1, $2, $3, $4, NULLIF($5, '')::uuid)
+	RETURNING id
+	`
+	err = tx.QueryRow(query, member.MerchantID, member.MobileNumber, member.Role, MemberStatusInvited, member.InvitedBy).Scan(&member.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = setMemberShops(tx, member)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	token, err := tokens.GenerateMerchantToken(member.MerchantID, ttl, tokens.ScopeTeamInvitation)
+	if err != nil {
+		return nil, nil, err
+	}
+	token.MemberID = member.ID
+	err = insertMemberToken(tx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	created, err := scanMerchantMember(tx.QueryRow(`SELECT `+merchantMemberColumns+` FROM merchant_members m WHERE m.id = $1`, member.ID))
+	if err != nil {
+		return nil, nil, err
+	}
+	return created, token, tx.Commit()
+}
+
+// AcceptInvitation sets the invited member's login and activates them. It
+// returns ErrInvitationNotFound for unknown, used or expired tokens.
+func (pm *PostgresMerchantMemberStore) AcceptInvitation(tokenPlainText string, username string, plainPassword string) (*MerchantMember, error) {
+	var passwordHash password
+	err := passwordHash.Set(plainPassword)
+	if err != nil {
+		return nil, err
+	}
+	tokenHash := sha256.Sum256([]byte(tokenPlainText))
+
+	tx, err := pm.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var memberID string
+	query := `
+	SELECT m.id
+	FROM merchant_members m
+	INNER JOIN tokens t ON t.member_id = m.id
+	WHERE t.hash = $1 AND t.scope = $2 AND t.expiry > $3 AND m.status = $4
+	FOR UPDATE OF m
+	`
+	err = tx.QueryRow(query, tokenHash[:], tokens.ScopeTeamInvitation, time.Now(), MemberStatusInvited).Scan(&memberID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var takenByMerchant bool
+	err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM merchants WHERE username = $1)`, username).Scan(&takenByMerchant)
+	if err != nil {
+		return nil, err
+	}
+	if takenByMerchant {
+		return nil, ErrMemberUsernameTaken
+	}
+	_, err = tx.Exec(`
+	UPDATE merchant_members
+	SET username = $2, password_hash = $3, status = $4, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1
+	`, memberID, username, passwordHash.hash, MemberStatusActive)
+	if isUniqueViolation(err) {
+		return nil, ErrMemberUsernameTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`DELETE FROM tokens WHERE member_id = $1 AND scope = $2`, memberID, tokens.ScopeTeamInvitation)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := scanMerchantMember(tx.QueryRow(`SELECT `+merchantMemberColumns+` FROM merchant_members m WHERE m.id = $1`, memberID))
+	if err != nil {
+		return nil, err
+	}
+	return member, tx.Commit()
+}
+
+func (pm *PostgresMerchantMemberStore) GetMemberByID(id string) (*MerchantMember, error) {
+	query := `SELECT ` + merchantMemberColumns + ` FROM merchant_members m WHERE m.id = $1`
+	return scanOptionalMerchantMember(pm.db.QueryRow(query, id))
+}
+
+func (pm *PostgresMerchantMemberStore) GetMemberByUsername(username string) (*MerchantMember, error) {
+	query := `SELECT ` + merchantMemberColumns + ` FROM merchant_members m WHERE m.username = $1`
+	return scanOptionalMerchantMember(pm.db.QueryRow(query, username))
+}
+
+func (pm *PostgresMerchantMemberStore) GetMembersByMerchantID(merchantID string) ([]*MerchantMember, error) {
+	query := `
+	SELECT ` + merchantMemberColumns + `
+	FROM merchant_members m
+	WHERE m.merchant_id = $1
+	ORDER BY m.created_at
+	`
+	rows, err := pm.db.Query(query, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*MerchantMember{}
+	for rows.Next() {
+		member, err := scanMerchantMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// UpdateMember changes the role and shop assignments of a member who has
+// not been disabled. It returns nil, nil when there is no such member.
+func (pm *PostgresMerchantMemberStore) UpdateMember(member *MerchantMember) (*MerchantMember, error) {
+	tx, err := pm.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+	UPDATE merchant_members
+	SET role = $3, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND merchant_id = $2 AND status <> $4
+	`, member.ID, member.MerchantID, member.Role, MemberStatusDisabled)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if updated == 0 {
+		return nil, nil
+	}
+	err = setMemberShops(tx, member)
+	if err != nil {
+		return nil, err
+	}
+
+	saved, err := scanMerchantMember(tx.QueryRow(`SELECT `+merchantMemberColumns+` FROM merchant_members m WHERE m.id = $1`, member.ID))
+	if err != nil {
+		return nil, err
+	}
+	return saved, tx.Commit()
+}
+
+// DisableMember blocks the member and ends their sessions. It returns nil,
+// nil when the merchant has no such member.
+func (pm *PostgresMerchantMemberStore) DisableMember(id string, merchantID string) (*MerchantMember, error) {
+	tx, err := pm.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+	UPDATE merchant_members
+	SET status = $3, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND merchant_id = $2
+	`, id, merchantID, MemberStatusDisabled)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if updated == 0 {
+		return nil, nil
+	}
+	_, err = tx.Exec(`DELETE FROM tokens WHERE member_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := scanMerchantMember(tx.QueryRow(`SELECT `+merchantMemberColumns+` FROM merchant_members m WHERE m.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	return member, tx.Commit()
+}
+
+func insertMemberToken(db interface {
+	Exec(string, ...any) (sql.Result, error)
+}, token *tokens.Token) error {
+	query := `
+	INSERT INTO tokens (hash, merchant_id, member_id, expiry, scope)
+	VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err := db.Exec(query, token.Hash, token.MerchantID, token.MemberID, token.Expiry, token.Scope)
+	return err
+}
+
+func (pm *PostgresMerchantMemberStore) CreateMemberToken(member *MerchantMember, ttl time.Duration, scope string) (*tokens.Token, error) {
+	token, err := tokens.GenerateMerchantToken(member.MerchantID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+	token.MemberID = member.ID
+
+	err = insertMemberToken(pm.db, token)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// GetMemberToken resolves a member session to the member and the merchant
+// they act for. It returns nil, nil, nil for unknown or expired tokens and
+// for members who are no longer active.
+func (pm *PostgresMerchantMemberStore) GetMemberToken(scope string, tokenPlainText string) (*Merchant, *MerchantMember, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlainText))
+
+	query := `
+	SELECT ` + merchantMemberColumns + `,
+		merchants.id, merchants.username, merchants.topic_id, merchants.mobile_number, merchants.account_id, merchants.profile_image_url, merchants.account_banner_image_url, merchants.auto_offramp, merchants.suspended_at, merchants.kyc_level, merchants.created_at, merchants.updated_at
+	FROM merchant_members m
+	INNER JOIN tokens ON tokens.member_id = m.id
+	INNER JOIN merchants ON merchants.id = m.merchant_id
+	WHERE tokens.hash = $1 AND tokens.scope = $2 AND tokens.expiry > $3 AND m.status = $4
+	`
+	merchant := &Merchant{PasswordHash: password{}}
+	member, err := scanMerchantMember(pm.db.QueryRow(query, tokenHash[:], scope, time.Now(), MemberStatusActive),
+		&merchant.ID, &merchant.Username, &merchant.TopicID, &merchant.MobileNumber, &merchant.AccountID, &merchant.ProfileImageUrl, &merchant.AccountBannerImageUrl, &merchant.AutoOfframp, &merchant.SuspendedAt, &merchant.KYCLevel, &merchant.CreatedAt, &merchant.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return merchant, member, nil
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerchantMemberPermissions(t *testing.T) {
+	tests := []struct {
+		role       string
+		permission string
+		want       bool
+	}{
+		{MemberRoleOwner, PermissionWithdrawalsCreate, true},
+		{MemberRoleManager, PermissionTeamManage, true},
+		{MemberRoleManager, PermissionWithdrawalsCreate, false},
+		{MemberRoleManager, PermissionSettingsManage, false},
+		{MemberRoleCashier, PermissionPaymentsCreate, true},
+		{MemberRoleCashier, PermissionReportsRead, false},
+		{MemberRoleAccountant, PermissionReportsRead, true},
+		{MemberRoleAccountant, PermissionPaymentsCreate, false},
+		{"janitor", PermissionPaymentsRead, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role+" "+tt.permission, func(t *testing.T) {
+			member := &MerchantMember{Role: tt.role}
+			assert.Equal(t, tt.want, member.Can(tt.permission))
+		})
+	}
+}
+
+func TestMerchantMemberCanAccessShop(t *testing.T) {
+	unrestricted := &MerchantMember{Role: MemberRoleManager}
+	assert.True(t, unrestricted.CanAccessShop("shop-a"))
+
+	cashier := &MerchantMember{Role: MemberRoleCashier, ShopIDs: []string{"shop-a"}}
+	assert.True(t, cashier.CanAccessShop("shop-a"))
+	assert.False(t, cashier.CanAccessShop("shop-b"))
+}
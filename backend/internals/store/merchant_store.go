@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
 )
 
 type password struct {
@@ -52,6 +54,8 @@ type Merchant struct {
 	ProfileImageUrl       string    `json:"profile_image_url"`
 	AccountBannerImageUrl string    `json:"account_banner_image_url"`
 	AutoOfframp           sql.NullBool `json:"auto_offramp"`
+	SuspendedAt           *time.Time `json:"suspended_at"`
+	KYCLevel              string    `json:"kyc_level"`
 	CreatedAt             time.Time `json:"created_at"`
 	UpdatedAt             time.Time `json:"updated_at"`
 	DeletedAt             time.Time `json:"deleted_at"`
@@ -60,10 +64,15 @@ type Merchant struct {
 type Withdrawal struct {
 	ID         string    `json:"id"`
 	MerchantID string    `json:"merchant_id"`
-	Amount     int64     `json:"amount"`
-	Fee        int64     `json:"fee"`
+	Amount     money.Amount `json:"amount"`
+	Fee        money.Amount `json:"fee"`
 	Receiver   string    `json:"receiver"`
+	FeeRuleID  string    `json:"fee_rule_id"`
+	FeeRule    *FeeRule  `json:"fee_rule"`
 	Status     string    `json:"status"`
+	ScheduleID string    `json:"schedule_id,omitempty"`
+	ScheduledTransactionID string `json:"scheduled_transaction_id,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
 	DeletedAt  time.Time `json:"deleted_at"`
@@ -75,6 +84,11 @@ func (m Merchant) IsAnonymous() bool {
 	return m.ID == AnonymousMerchant.ID
 }
 
+// IsSuspended reports whether an admin has suspended the merchant.
+func (m Merchant) IsSuspended() bool {
+	return m.SuspendedAt != nil
+}
+
 type PostgresMerchantStore struct {
 	db *sql.DB
 }
@@ -89,7 +103,7 @@ type MerchantStore interface {
 	GetMerchantByID(id string) (*Merchant, error)
 	UpdateMerchant(merchant *Merchant) error
 	GetMerchantToken(scope, tokenPlainText string) (*Merchant, error)
-	Withdraw(merchant *Merchant, amount int64, receiver string) (*Withdrawal, error)
+	Withdraw(merchant *Merchant, amount money.Amount, fee money.Amount, feeRule *FeeRule, receiver string) (*Withdrawal, error)
 	GetWithdrawals(merchant *Merchant) ([]*Withdrawal, error)
 }
 
@@ -97,10 +111,10 @@ func (pg *PostgresMerchantStore) CreateMerchant(merchant *Merchant) (*Merchant,
 	query := `
 	INSERT INTO merchants (username, mobile_number, password_hash, account_id, profile_image_url, account_banner_image_url, topic_id)
 	VALUES ($1, $2, $3, $4, $5, $6, $7)
-	RETURNING id, created_at, updated_at;
+	RETURNING id, kyc_level, created_at, updated_at;
 	`
 
-	err := pg.db.QueryRow(query, merchant.Username, merchant.MobileNumber, merchant.PasswordHash.hash, merchant.AccountID, merchant.ProfileImageUrl, merchant.AccountBannerImageUrl, merchant.TopicID).Scan(&merchant.ID, &merchant.CreatedAt, &merchant.UpdatedAt)
+	err := pg.db.QueryRow(query, merchant.Username, merchant.MobileNumber, merchant.PasswordHash.hash, merchant.AccountID, merchant.ProfileImageUrl, merchant.AccountBannerImageUrl, merchant.TopicID).Scan(&merchant.ID, &merchant.KYCLevel, &merchant.CreatedAt, &merchant.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -113,11 +127,11 @@ func (pg *PostgresMerchantStore) GetMerchantByUsername(username string) (*Mercha
 	}
 
 	query := `
-	SELECT id, username, topic_id, mobile_number, password_hash, account_id, profile_image_url, account_banner_image_url, auto_offramp, created_at, updated_at
+	SELECT id, username, topic_id, mobile_number, password_hash, account_id, profile_image_url, account_banner_image_url, auto_offramp, suspended_at, kyc_level, created_at, updated_at
     FROM merchants WHERE username = $1
 	`
 
-	err := pg.db.QueryRow(query, username).Scan(&merchant.ID, &merchant.Username, &merchant.TopicID, &merchant.MobileNumber, &merchant.PasswordHash.hash, &merchant.AccountID, &merchant.ProfileImageUrl, &merchant.AccountBannerImageUrl, &merchant.AutoOfframp, &merchant.CreatedAt, &merchant.UpdatedAt)
+	err := pg.db.QueryRow(query, username).Scan(&merchant.ID, &merchant.Username, &merchant.TopicID, &merchant.MobileNumber, &merchant.PasswordHash.hash, &merchant.AccountID, &merchant.ProfileImageUrl, &merchant.AccountBannerImageUrl, &merchant.AutoOfframp, &merchant.SuspendedAt, &merchant.KYCLevel, &merchant.CreatedAt, &merchant.UpdatedAt)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
@@ -171,17 +185,17 @@ func (pg *PostgresMerchantStore) GetMerchantToken(scope, tokenPlainText string)
 	tokenHash := sha256.Sum256([]byte(tokenPlainText))
 
 	query := `
-	SELECT merchants.id, merchants.username, merchants.topic_id, merchants.mobile_number, merchants.password_hash, merchants.account_id, merchants.profile_image_url, merchants.account_banner_image_url, merchants.auto_offramp, merchants.created_at, merchants.updated_at
+	SELECT merchants.id, merchants.username, merchants.topic_id, merchants.mobile_number, merchants.password_hash, merchants.account_id, merchants.profile_image_url, merchants.account_banner_image_url, merchants.auto_offramp, merchants.suspended_at, merchants.kyc_level, merchants.created_at, merchants.updated_at
 	FROM merchants
 	INNER JOIN tokens ON merchants.id = tokens.merchant_id
-	WHERE tokens.hash = $1 AND tokens.scope = $2 AND tokens.expiry > $3
+	WHERE tokens.hash = $1 AND tokens.scope = $2 AND tokens.expiry > $3 AND tokens.member_id IS NULL
 	`
 
 	merchant := &Merchant{
 		PasswordHash: password{},
 	}
 
-	err := pg.db.QueryRow(query, tokenHash[:], scope, time.Now()).Scan(&merchant.ID, &merchant.Username, &merchant.TopicID, &merchant.MobileNumber, &merchant.PasswordHash.hash, &merchant.AccountID, &merchant.ProfileImageUrl, &merchant.AccountBannerImageUrl, &merchant.AutoOfframp, &merchant.CreatedAt, &merchant.UpdatedAt)
+	err := pg.db.QueryRow(query, tokenHash[:], scope, time.Now()).Scan(&merchant.ID, &merchant.Username, &merchant.TopicID, &merchant.MobileNumber, &merchant.PasswordHash.hash, &merchant.AccountID, &merchant.ProfileImageUrl, &merchant.AccountBannerImageUrl, &merchant.AutoOfframp, &merchant.SuspendedAt, &merchant.KYCLevel, &merchant.CreatedAt, &merchant.UpdatedAt)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
@@ -192,15 +206,25 @@ func (pg *PostgresMerchantStore) GetMerchantToken(scope, tokenPlainText string)
 
 }
 
-func (pg *PostgresMerchantStore) Withdraw(merchant *Merchant, amount int64, receiver string) (*Withdrawal, error) {
-	var withdrawal = &Withdrawal{}
+// Withdraw records a withdrawal charged fee, as priced by feeRule. A nil
+// feeRule means no rule applied.
+func (pg *PostgresMerchantStore) Withdraw(merchant *Merchant, amount money.Amount, fee money.Amount, feeRule *FeeRule, receiver string) (*Withdrawal, error) {
+	var withdrawal = &Withdrawal{FeeRule: feeRule}
+	snapshot, err := feeRuleSnapshot(feeRule)
+	if err != nil {
+		return nil, err
+	}
+	feeRuleID := ""
+	if feeRule != nil {
+		feeRuleID = feeRule.ID
+	}
 	query := `
-	INSERT INTO withdrawals (merchant_id, amount, fee, receiver, status)
-	VALUES ($1, $2, $3, $4, $5)
-	RETURNING id, status, amount, fee, receiver, merchant_id, created_at, updated_at;
+	INSERT INTO withdrawals (merchant_id, amount, fee, receiver, status, fee_rule_id, fee_rule)
+	VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::uuid, $7)
+	RETURNING id, status, amount, fee, receiver, merchant_id, COALESCE(fee_rule_id::text, ''), created_at, updated_at;
 	`
 
-	err := pg.db.QueryRow(query, merchant.ID, amount, 0, receiver, "completed").Scan(&withdrawal.ID, &withdrawal.Status, &withdrawal.Amount, &withdrawal.Fee, &withdrawal.Receiver, &withdrawal.MerchantID, &withdrawal.CreatedAt, &withdrawal.UpdatedAt)
+	err = pg.db.QueryRow(query, merchant.ID, amount, fee, receiver, WithdrawalStatusCompleted, feeRuleID, snapshot).Scan(&withdrawal.ID, &withdrawal.Status, &withdrawal.Amount, &withdrawal.Fee, &withdrawal.Receiver, &withdrawal.MerchantID, &withdrawal.FeeRuleID, &withdrawal.CreatedAt, &withdrawal.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -209,7 +233,7 @@ func (pg *PostgresMerchantStore) Withdraw(merchant *Merchant, amount int64, rece
 
 func (pg *PostgresMerchantStore) GetWithdrawals(merchant *Merchant) ([]*Withdrawal, error) {
 	query := `
-	SELECT id, status, amount, fee, receiver, merchant_id, created_at, updated_at
+	SELECT ` + withdrawalColumns + `
 	FROM withdrawals
 	WHERE merchant_id = $1
 	`
@@ -222,8 +246,7 @@ func (pg *PostgresMerchantStore) GetWithdrawals(merchant *Merchant) ([]*Withdraw
 
 	var withdrawals []*Withdrawal
 	for rows.Next() {
-		var withdrawal = &Withdrawal{}
-		err := rows.Scan(&withdrawal.ID, &withdrawal.Status, &withdrawal.Amount, &withdrawal.Fee, &withdrawal.Receiver, &withdrawal.MerchantID, &withdrawal.CreatedAt, &withdrawal.UpdatedAt)
+		withdrawal, err := scanWithdrawal(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -237,12 +260,12 @@ func (pg *PostgresMerchantStore) GetMerchantByID(id string) (*Merchant, error) {
 		PasswordHash: password{},
 	}
 	query := `
-	SELECT id, username, topic_id, mobile_number, password_hash, account_id, profile_image_url, account_banner_image_url, auto_offramp, created_at, updated_at
+	SELECT id, username, topic_id, mobile_number, password_hash, account_id, profile_image_url, account_banner_image_url, auto_offramp, suspended_at, kyc_level, created_at, updated_at
 	FROM merchants 
 	WHERE id = $1
 	`
 
-	err := pg.db.QueryRow(query, id).Scan(&merchant.ID, &merchant.Username, &merchant.TopicID, &merchant.MobileNumber, &merchant.PasswordHash.hash, &merchant.AccountID, &merchant.ProfileImageUrl, &merchant.AccountBannerImageUrl, &merchant.AutoOfframp, &merchant.CreatedAt, &merchant.UpdatedAt)
+	err := pg.db.QueryRow(query, id).Scan(&merchant.ID, &merchant.Username, &merchant.TopicID, &merchant.MobileNumber, &merchant.PasswordHash.hash, &merchant.AccountID, &merchant.ProfileImageUrl, &merchant.AccountBannerImageUrl, &merchant.AutoOfframp, &merchant.SuspendedAt, &merchant.KYCLevel, &merchant.CreatedAt, &merchant.UpdatedAt)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
@@ -0,0 +1,221 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+)
+
+const (
+	PaymentRequestStatusOpen       = "open"
+	PaymentRequestStatusProcessing = "processing"
+	PaymentRequestStatusPaid       = "paid"
+	PaymentRequestStatusCancelled  = "cancelled"
+	PaymentRequestStatusExpired    = "expired"
+)
+
+// PaymentRequest is an invoice a merchant raises for a user to pay. Invoice
+// numbers run per shop. Without a CustomerID anyone holding the id can pay
+// it.
+type PaymentRequest struct {
+	ID            string       `json:"id"`
+	ShopID        string       `json:"shop_id"`
+	MerchantID    string       `json:"merchant_id"`
+	InvoiceNumber int64        `json:"invoice_number"`
+	Invoice       string       `json:"invoice"`
+	Amount        money.Amount `json:"amount"`
+	Description   string       `json:"description"`
+	CustomerID    string       `json:"customer_id,omitempty"`
+	Status        string       `json:"status"`
+	TransactionID string       `json:"transaction_id,omitempty"`
+	ExpiresAt     time.Time    `json:"expires_at"`
+	PaidAt        *time.Time   `json:"paid_at"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+// FormatInvoiceNumber is how invoice numbers are shown to users.
+func FormatInvoiceNumber(number int64) string {
+	return fmt.Sprintf("INV-%06d", number)
+}
+
+type PostgresPaymentRequestStore struct {
+	db *sql.DB
+}
+
+func NewPostgresPaymentRequestStore(db *sql.DB) *PostgresPaymentRequestStore {
+	return &PostgresPaymentRequestStore{db: db}
+}
+
+type PaymentRequestStore interface {
+	CreatePaymentRequest(request *PaymentRequest) (*PaymentRequest, error)
+	GetPaymentRequestByID(id string) (*PaymentRequest, error)
+	GetPaymentRequestsByMerchantID(merchantID string, status string) ([]*PaymentRequest, error)
+	GetOpenPaymentRequestsByCustomerID(customerID string) ([]*PaymentRequest, error)
+	ClaimPaymentRequest(id string) (*PaymentRequest, error)
+	ReleasePaymentRequest(id string) error
+	MarkPaymentRequestPaid(id string, transactionID string) (*PaymentRequest, error)
+	CancelPaymentRequest(id string) (*PaymentRequest, error)
+}
+
+// Open requests past their expiry are reported as expired without a job
+// having to update them.
+const paymentRequestStatus = `CASE WHEN status = 'open' AND expires_at <= CURRENT_TIMESTAMP THEN 'expired' ELSE status END`
+
+const paymentRequestColumns = `id, shop_id, merchant_id, invoice_number, amount, description, COALESCE(customer_id::text, ''), ` + paymentRequestStatus + `, COALESCE(transaction_id::text, ''), expires_at, paid_at, created_at, updated_at`
+
+func scanPaymentRequest(row interface{ Scan(...any) error }) (*PaymentRequest, error) {
+	request := &PaymentRequest{}
+	var paidAt sql.NullTime
+	err := row.Scan(&request.ID, &request.ShopID, &request.MerchantID, &request.InvoiceNumber, &request.Amount, &request.Description, &request.CustomerID, &request.Status, &request.TransactionID, &request.ExpiresAt, &paidAt, &request.CreatedAt, &request.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	request.Invoice = FormatInvoiceNumber(request.InvoiceNumber)
+	if paidAt.Valid {
+		request.PaidAt = &paidAt.Time
+	}
+	return request, nil
+}
+
+func scanPaymentRequests(rows *sql.Rows) ([]*PaymentRequest, error) {
+	defer rows.Close()
+
+	requests := []*PaymentRequest{}
+	for rows.Next() {
+		request, err := scanPaymentRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
+
+// scanOptionalPaymentRequest maps a missing row to nil, nil for the updates
+// that only apply in a given status.
+func scanOptionalPaymentRequest(row *sql.Row) (*PaymentRequest, error) {
+	request, err := scanPaymentRequest(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return request, err
+}
+
+// CreatePaymentRequest takes the shop's next invoice number. The shop row is
+// locked by the increment, so numbers are gap free and never reused.
+func (pp *PostgresPaymentRequestStore) CreatePaymentRequest(request *PaymentRequest) (*PaymentRequest, error) {
+	tx, err := pp.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var invoiceNumber int64
+	err = tx.QueryRow(`UPDATE shops SET invoice_sequence = invoice_sequence + 1 WHERE id = $1 RETURNING invoice_sequence`, request.ShopID).Scan(&invoiceNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	INSERT INTO payment_requests (shop_id, merchant_id, invoice_number, amount, description, customer_id, expires_at)
+	VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::uuid, $7)
+	RETURNING ` + paymentRequestColumns
+
+	created, err := scanPaymentRequest(tx.QueryRow(query, request.ShopID, request.MerchantID, invoiceNumber, request.Amount, request.Description, request.CustomerID, request.ExpiresAt))
+	if err != nil {
+		return nil, err
+	}
+	return created, tx.Commit()
+}
+
+func (pp *PostgresPaymentRequestStore) GetPaymentRequestByID(id string) (*PaymentRequest, error) {
+	query := `SELECT ` + paymentRequestColumns + `
+	FROM payment_requests
+	WHERE id = $1
+	`
+	request, err := scanPaymentRequest(pp.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return request, err
+}
+
+// GetPaymentRequestsByMerchantID lists the merchant's requests, newest
+// first, optionally only those in status.
+func (pp *PostgresPaymentRequestStore) GetPaymentRequestsByMerchantID(merchantID string, status string) ([]*PaymentRequest, error) {
+	query := `SELECT ` + paymentRequestColumns + `
+	FROM payment_requests
+	WHERE merchant_id = $1
+		AND ($2 = '' OR ` + paymentRequestStatus + ` = $2)
+	ORDER BY created_at DESC
+	`
+	rows, err := pp.db.Query(query, merchantID, status)
+	if err != nil {
+		return nil, err
+	}
+	return scanPaymentRequests(rows)
+}
+
+func (pp *PostgresPaymentRequestStore) GetOpenPaymentRequestsByCustomerID(customerID string) ([]*PaymentRequest, error) {
+	query := `SELECT ` + paymentRequestColumns + `
+	FROM payment_requests
+	WHERE customer_id = $1
+		AND status = 'open'
+		AND expires_at > CURRENT_TIMESTAMP
+	ORDER BY expires_at
+	`
+	rows, err := pp.db.Query(query, customerID)
+	if err != nil {
+		return nil, err
+	}
+	return scanPaymentRequests(rows)
+}
+
+// ClaimPaymentRequest moves an open, unexpired request to processing so it
+// can only be paid once. It returns nil, nil when the request is not open.
+func (pp *PostgresPaymentRequestStore) ClaimPaymentRequest(id string) (*PaymentRequest, error) {
+	query := `
+	UPDATE payment_requests
+	SET status = 'processing', updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status = 'open' AND expires_at > CURRENT_TIMESTAMP
+	RETURNING ` + paymentRequestColumns
+
+	return scanOptionalPaymentRequest(pp.db.QueryRow(query, id))
+}
+
+// ReleasePaymentRequest reopens a claimed request whose payment failed.
+func (pp *PostgresPaymentRequestStore) ReleasePaymentRequest(id string) error {
+	query := `
+	UPDATE payment_requests
+	SET status = 'open', updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status = 'processing'
+	`
+	_, err := pp.db.Exec(query, id)
+	return err
+}
+
+func (pp *PostgresPaymentRequestStore) MarkPaymentRequestPaid(id string, transactionID string) (*PaymentRequest, error) {
+	query := `
+	UPDATE payment_requests
+	SET status = 'paid', transaction_id = $2, paid_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status = 'processing'
+	RETURNING ` + paymentRequestColumns
+
+	return scanOptionalPaymentRequest(pp.db.QueryRow(query, id, transactionID))
+}
+
+// CancelPaymentRequest withdraws an open request. It returns nil, nil when
+// the request is not open.
+func (pp *PostgresPaymentRequestStore) CancelPaymentRequest(id string) (*PaymentRequest, error) {
+	query := `
+	UPDATE payment_requests
+	SET status = 'cancelled', updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status = 'open' AND expires_at > CURRENT_TIMESTAMP
+	RETURNING ` + paymentRequestColumns
+
+	return scanOptionalPaymentRequest(pp.db.QueryRow(query, id))
+}
@@ -0,0 +1,192 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/tokens"
+)
+
+// POSDevice is a till registered to one shop. Its token lets cashiers take
+// payments at that shop without signing in as the merchant.
+type POSDevice struct {
+	ID           string     `json:"id"`
+	MerchantID   string     `json:"merchant_id"`
+	ShopID       string     `json:"shop_id"`
+	Name         string     `json:"name"`
+	RegisteredBy string     `json:"registered_by,omitempty"`
+	LastSeenAt   *time.Time `json:"last_seen_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+type PostgresPOSDeviceStore struct {
+	db *sql.DB
+}
+
+func NewPostgresPOSDeviceStore(db *sql.DB) *PostgresPOSDeviceStore {
+	return &PostgresPOSDeviceStore{db: db}
+}
+
+type POSDeviceStore interface {
+	CreatePOSDevice(device *POSDevice) (*POSDevice, *tokens.POSDeviceToken, error)
+	GetPOSDevicesByShopID(shopID string) ([]*POSDevice, error)
+	GetPOSDeviceByID(id string) (*POSDevice, error)
+	RevokePOSDevice(id string, merchantID string) (*POSDevice, error)
+	GetMerchantByPOSDeviceToken(plaintext string) (*Merchant, *POSDevice, error)
+	GetShopActivity(shopID string, from time.Time, to time.Time) ([]*Transaction, []*Refund, error)
+}
+
+const posDeviceColumns = `id, merchant_id, shop_id, name, COALESCE(registered_by::text, ''), last_seen_at, revoked_at, created_at`
+
+func scanPOSDevice(row interface{ Scan(...any) error }) (*POSDevice, error) {
+	device := &POSDevice{}
+	var lastSeenAt, revokedAt sql.NullTime
+	err := row.Scan(&device.ID, &device.MerchantID, &device.ShopID, &device.Name, &device.RegisteredBy, &lastSeenAt, &revokedAt, &device.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if lastSeenAt.Valid {
+		device.LastSeenAt = &lastSeenAt.Time
+	}
+	if revokedAt.Valid {
+		device.RevokedAt = &revokedAt.Time
+	}
+	return device, nil
+}
+
+// scanOptionalPOSDevice maps a missing row to nil, nil.
+func scanOptionalPOSDevice(row *sql.Row) (*POSDevice, error) {
+	device, err := scanPOSDevice(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return device, err
+}
+
+// CreatePOSDevice registers a device and issues its token. The plaintext
+// token is only available from the return value.
+func (pd *PostgresPOSDeviceStore) CreatePOSDevice(device *POSDevice) (*POSDevice, *tokens.POSDeviceToken, error) {
+	token, err := tokens.GeneratePOSDeviceToken(device.MerchantID, device.ShopID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query := `
+	INSERT INTO pos_devices (merchant_id, shop_id, name, token_hash, registered_by)
+	VALUES ($1, $2, $3, $4, NULLIF($5, '')::uuid)
+	RETURNING ` + posDeviceColumns
+
+	created, err := scanPOSDevice(pd.db.QueryRow(query, device.MerchantID, device.ShopID, device.Name, token.Hash, device.RegisteredBy))
+	if err != nil {
+		return nil, nil, err
+	}
+	return created, token, nil
+}
+
+func (pd *PostgresPOSDeviceStore) GetPOSDevicesByShopID(shopID string) ([]*POSDevice, error) {
+	query := `
+	SELECT ` + posDeviceColumns + `
+	FROM pos_devices
+	WHERE shop_id = $1
+	ORDER BY created_at DESC
+	`
+	rows, err := pd.db.Query(query, shopID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	devices := []*POSDevice{}
+	for rows.Next() {
+		device, err := scanPOSDevice(rows)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+	return devices, rows.Err()
+}
+
+func (pd *PostgresPOSDeviceStore) GetPOSDeviceByID(id string) (*POSDevice, error) {
+	query := `SELECT ` + posDeviceColumns + ` FROM pos_devices WHERE id = $1`
+	return scanOptionalPOSDevice(pd.db.QueryRow(query, id))
+}
+
+// RevokePOSDevice signs the device out for good. It returns nil, nil when
+// the merchant has no such active device.
+func (pd *PostgresPOSDeviceStore) RevokePOSDevice(id string, merchantID string) (*POSDevice, error) {
+	query := `
+	UPDATE pos_devices
+	SET revoked_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL
+	RETURNING ` + posDeviceColumns
+
+	return scanOptionalPOSDevice(pd.db.QueryRow(query, id, merchantID))
+}
+
+// GetMerchantByPOSDeviceToken resolves an active device token to its
+// merchant. It returns nil, nil, nil for unknown or revoked tokens.
+func (pd *PostgresPOSDeviceStore) GetMerchantByPOSDeviceToken(plaintext string) (*Merchant, *POSDevice, error) {
+	query := `
+	UPDATE pos_devices
+	SET last_seen_at = CURRENT_TIMESTAMP
+	WHERE token_hash = $1 AND revoked_at IS NULL
+	RETURNING ` + posDeviceColumns
+
+	device, err := scanOptionalPOSDevice(pd.db.QueryRow(query, tokens.HashPOSDeviceToken(plaintext)))
+	if err != nil || device == nil {
+		return nil, nil, err
+	}
+
+	merchant, err := NewPostgresMerchantStore(pd.db).GetMerchantByID(device.MerchantID)
+	if err != nil || merchant == nil {
+		return nil, nil, err
+	}
+	return merchant, device, nil
+}
+
+const qualifiedRefundColumns = `rf.id, rf.transaction_id, rf.merchant_id, rf.user_id, rf.amount, rf.reason, rf.status, rf.hedera_transaction_id, rf.expires_at, rf.created_at, rf.updated_at`
+
+// GetShopActivity returns the shop's payments made in [from, to) and the
+// refunds of its payments completed in the same window.
+func (pd *PostgresPOSDeviceStore) GetShopActivity(shopID string, from time.Time, to time.Time) ([]*Transaction, []*Refund, error) {
+	query := `
+	SELECT ` + transactionColumns + `
+	FROM transactions
+	WHERE shop_id = $1 AND created_at >= $2 AND created_at < $3
+	ORDER BY created_at
+	`
+	rows, err := pd.db.Query(query, shopID, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	transactions, err := scanTransactions(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query = `
+	SELECT ` + qualifiedRefundColumns + `
+	FROM refunds rf
+	JOIN transactions t ON t.id = rf.transaction_id
+	WHERE t.shop_id = $1 AND rf.status = $2 AND rf.updated_at >= $3 AND rf.updated_at < $4
+	ORDER BY rf.updated_at
+	`
+	rows, err = pd.db.Query(query, shopID, RefundStatusCompleted, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	refunds := []*Refund{}
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		refunds = append(refunds, refund)
+	}
+	return transactions, refunds, rows.Err()
+}
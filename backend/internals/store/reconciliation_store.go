@@ -0,0 +1,199 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+)
+
+const (
+	ReconciliationMissingOnLedger = "missing_on_ledger"
+	ReconciliationFailedOnLedger  = "failed_on_ledger"
+	ReconciliationAmountMismatch  = "amount_mismatch"
+	ReconciliationOrphanTransfer  = "orphan_transfer"
+)
+
+const (
+	ReconciliationIssueOpen     = "open"
+	ReconciliationIssueResolved = "resolved"
+)
+
+type ReconciliationIssue struct {
+	ID                  string        `json:"id"`
+	Kind                string        `json:"kind"`
+	Status              string        `json:"status"`
+	MerchantID          string        `json:"merchant_id"`
+	TransactionID       string        `json:"transaction_id"`
+	HederaTransactionID string        `json:"hedera_transaction_id"`
+	AccountID           string        `json:"account_id"`
+	ExpectedAmount      *money.Amount `json:"expected_amount"`
+	LedgerAmount        *money.Amount `json:"ledger_amount"`
+	Details             string        `json:"details"`
+	DetectedAt          time.Time     `json:"detected_at"`
+	ResolvedAt          *time.Time    `json:"resolved_at"`
+}
+
+// ReconciliationTransaction is a payment row with the accounts its on-chain
+// transfers should have touched.
+type ReconciliationTransaction struct {
+	ID                     string
+	MerchantID             string
+	Amount                 money.Amount
+	Fee                    money.Amount
+	HederaTransactionID    string
+	HederaFeeTransactionID string
+	MerchantAccountID      string
+	UserAccountID          string
+	CreatedAt              time.Time
+}
+
+type MerchantAccount struct {
+	MerchantID string
+	AccountID  string
+}
+
+type PostgresReconciliationStore struct {
+	db *sql.DB
+}
+
+func NewPostgresReconciliationStore(db *sql.DB) *PostgresReconciliationStore {
+	return &PostgresReconciliationStore{db: db}
+}
+
+type ReconciliationStore interface {
+	GetTransactionsForReconciliation(from time.Time, to time.Time) ([]*ReconciliationTransaction, error)
+	GetMerchantAccounts() ([]*MerchantAccount, error)
+	TransactionExistsByHederaID(hederaTransactionID string) (bool, error)
+	CreateReconciliationIssue(issue *ReconciliationIssue) (bool, error)
+	GetReconciliationIssuesByMerchantID(merchantID string, status string) ([]*ReconciliationIssue, error)
+}
+
+// GetTransactionsForReconciliation returns payments created in [from, to)
+// that recorded their Hedera transaction ids. Older rows have none to match.
+func (pr *PostgresReconciliationStore) GetTransactionsForReconciliation(from time.Time, to time.Time) ([]*ReconciliationTransaction, error) {
+	query := `
+	SELECT transactions.id, transactions.merchant_id, transactions.amount, transactions.fee,
+		transactions.hedera_transaction_id, COALESCE(transactions.hedera_fee_transaction_id, ''),
+		merchants.account_id, users.account_id, transactions.created_at
+	FROM transactions
+	INNER JOIN merchants ON merchants.id = transactions.merchant_id
+	INNER JOIN users ON users.id = transactions.user_id
+	WHERE transactions.created_at >= $1 AND transactions.created_at < $2
+		AND transactions.hedera_transaction_id IS NOT NULL
+	ORDER BY transactions.created_at
+	`
+	rows, err := pr.db.Query(query, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []*ReconciliationTransaction{}
+	for rows.Next() {
+		transaction := &ReconciliationTransaction{}
+		err = rows.Scan(&transaction.ID, &transaction.MerchantID, &transaction.Amount, &transaction.Fee, &transaction.HederaTransactionID, &transaction.HederaFeeTransactionID, &transaction.MerchantAccountID, &transaction.UserAccountID, &transaction.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, rows.Err()
+}
+
+func (pr *PostgresReconciliationStore) GetMerchantAccounts() ([]*MerchantAccount, error) {
+	query := `
+	SELECT id, account_id
+	FROM merchants
+	WHERE account_id IS NOT NULL AND account_id <> ''
+	`
+	rows, err := pr.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []*MerchantAccount{}
+	for rows.Next() {
+		account := &MerchantAccount{}
+		err = rows.Scan(&account.MerchantID, &account.AccountID)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
+}
+
+func (pr *PostgresReconciliationStore) TransactionExistsByHederaID(hederaTransactionID string) (bool, error) {
+	var exists bool
+	query := `
+	SELECT EXISTS (
+		SELECT 1 FROM transactions
+		WHERE hedera_transaction_id = $1 OR hedera_fee_transaction_id = $1
+	)
+	`
+	err := pr.db.QueryRow(query, hederaTransactionID).Scan(&exists)
+	return exists, err
+}
+
+// CreateReconciliationIssue records an issue once per kind and Hedera
+// transaction. It reports false when the issue was already known.
+func (pr *PostgresReconciliationStore) CreateReconciliationIssue(issue *ReconciliationIssue) (bool, error) {
+	query := `
+	INSERT INTO reconciliation_issues (kind, merchant_id, transaction_id, hedera_transaction_id, account_id, expected_amount, ledger_amount, details)
+	VALUES ($1, NULLIF($2, '')::uuid, NULLIF($3, '')::uuid, $4, NULLIF($5, ''), $6, $7, $8)
+	ON CONFLICT (kind, hedera_transaction_id) DO NOTHING
+	RETURNING id, status, detected_at
+	`
+	err := pr.db.QueryRow(query, issue.Kind, issue.MerchantID, issue.TransactionID, issue.HederaTransactionID, issue.AccountID, issue.ExpectedAmount, issue.LedgerAmount, issue.Details).Scan(&issue.ID, &issue.Status, &issue.DetectedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetReconciliationIssuesByMerchantID lists the merchant's issues, newest
+// first. An empty status returns every status.
+func (pr *PostgresReconciliationStore) GetReconciliationIssuesByMerchantID(merchantID string, status string) ([]*ReconciliationIssue, error) {
+	query := `
+	SELECT id, kind, status, COALESCE(merchant_id::text, ''), COALESCE(transaction_id::text, ''), hedera_transaction_id,
+		COALESCE(account_id, ''), expected_amount, ledger_amount, details, detected_at, resolved_at
+	FROM reconciliation_issues
+	WHERE merchant_id = $1 AND ($2::text = '' OR status = $2::text)
+	ORDER BY detected_at DESC
+	`
+	rows, err := pr.db.Query(query, merchantID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	issues := []*ReconciliationIssue{}
+	for rows.Next() {
+		issue := &ReconciliationIssue{}
+		var expectedAmount, ledgerAmount sql.NullInt64
+		var resolvedAt sql.NullTime
+		err = rows.Scan(&issue.ID, &issue.Kind, &issue.Status, &issue.MerchantID, &issue.TransactionID, &issue.HederaTransactionID, &issue.AccountID, &expectedAmount, &ledgerAmount, &issue.Details, &issue.DetectedAt, &resolvedAt)
+		if err != nil {
+			return nil, err
+		}
+		if expectedAmount.Valid {
+			amount := money.Amount(expectedAmount.Int64)
+			issue.ExpectedAmount = &amount
+		}
+		if ledgerAmount.Valid {
+			amount := money.Amount(ledgerAmount.Int64)
+			issue.LedgerAmount = &amount
+		}
+		if resolvedAt.Valid {
+			issue.ResolvedAt = &resolvedAt.Time
+		}
+		issues = append(issues, issue)
+	}
+	return issues, rows.Err()
+}
@@ -0,0 +1,242 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+)
+
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusCompleted = "completed"
+	RefundStatusFailed    = "failed"
+)
+
+var (
+	ErrPaymentNotRefundable = errors.New("payment cannot be refunded")
+	ErrRefundExceedsPayment = errors.New("refund exceeds the amount left to refund")
+)
+
+// Refund returns all or part of a payment to the user. It is created pending
+// with the id of a transfer the merchant has to sign, and completes once that
+// transfer succeeds on the ledger, or fails if it does not. A pending refund that is not submitted by ExpiresAt
+// can no longer reach the ledger and stops counting against the payment.
+type Refund struct {
+	ID                  string       `json:"id"`
+	TransactionID       string       `json:"transaction_id"`
+	MerchantID          string       `json:"merchant_id"`
+	UserID              string       `json:"user_id"`
+	Amount              money.Amount `json:"amount"`
+	Reason              string       `json:"reason"`
+	Status              string       `json:"status"`
+	HederaTransactionID string       `json:"hedera_transaction_id"`
+	ExpiresAt           time.Time    `json:"expires_at"`
+	CreatedAt           time.Time    `json:"created_at"`
+	UpdatedAt           time.Time    `json:"updated_at"`
+}
+
+// IsExpired reports whether a pending refund's transfer can no longer be
+// submitted.
+func (rf *Refund) IsExpired(now time.Time) bool {
+	return rf.Status == RefundStatusPending && !now.Before(rf.ExpiresAt)
+}
+
+type PostgresRefundStore struct {
+	db *sql.DB
+}
+
+func NewPostgresRefundStore(db *sql.DB) *PostgresRefundStore {
+	return &PostgresRefundStore{db: db}
+}
+
+type RefundStore interface {
+	CreateRefund(refund *Refund) (*Refund, error)
+	GetRefundByID(id string) (*Refund, error)
+	GetRefundsByTransactionID(transactionID string) ([]*Refund, error)
+	CompleteRefund(id string) (*Refund, *Transaction, error)
+	FailRefund(id string) (*Refund, error)
+}
+
+const refundColumns = `id, transaction_id, merchant_id, user_id, amount, reason, status, hedera_transaction_id, expires_at, created_at, updated_at`
+
+func scanRefund(row interface{ Scan(...any) error }) (*Refund, error) {
+	refund := &Refund{}
+	err := row.Scan(&refund.ID, &refund.TransactionID, &refund.MerchantID, &refund.UserID, &refund.Amount, &refund.Reason, &refund.Status, &refund.HederaTransactionID, &refund.ExpiresAt, &refund.CreatedAt, &refund.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// refundedAmount sums the completed refunds of a payment and the pending ones
+// that can still be submitted.
+func refundedAmount(tx *sql.Tx, transactionID string) (money.Amount, error) {
+	query := `
+	SELECT COALESCE(SUM(amount), 0)
+	FROM refunds
+	WHERE transaction_id = $1
+		AND (status = 'completed' OR (status = 'pending' AND expires_at > CURRENT_TIMESTAMP))
+	`
+	var refunded money.Amount
+	err := tx.QueryRow(query, transactionID).Scan(&refunded)
+	return refunded, err
+}
+
+// CreateRefund reserves refund.Amount against the payment, or everything
+// left to refund when it is zero. The payment row is locked so concurrent
+// refunds cannot together exceed it.
+func (pr *PostgresRefundStore) CreateRefund(refund *Refund) (*Refund, error) {
+	tx, err := pr.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var paid money.Amount
+	var status string
+	err = tx.QueryRow(`SELECT amount, status FROM transactions WHERE id = $1 FOR UPDATE`, refund.TransactionID).Scan(&paid, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPaymentNotRefundable
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != TransactionStatusCompleted && status != TransactionStatusPartiallyRefunded {
+		return nil, ErrPaymentNotRefundable
+	}
+
+	refunded, err := refundedAmount(tx, refund.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+	remaining, err := paid.Sub(refunded)
+	if err != nil {
+		return nil, err
+	}
+	if refund.Amount == 0 {
+		refund.Amount = remaining
+	}
+	if refund.Amount <= 0 || refund.Amount > remaining {
+		return nil, ErrRefundExceedsPayment
+	}
+
+	query := `
+	INSERT INTO refunds (transaction_id, merchant_id, user_id, amount, reason, status, hedera_transaction_id, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING ` + refundColumns
+
+	created, err := scanRefund(tx.QueryRow(query, refund.TransactionID, refund.MerchantID, refund.UserID, refund.Amount, refund.Reason, RefundStatusPending, refund.HederaTransactionID, refund.ExpiresAt))
+	if err != nil {
+		return nil, err
+	}
+	return created, tx.Commit()
+}
+
+func (pr *PostgresRefundStore) GetRefundByID(id string) (*Refund, error) {
+	query := `SELECT ` + refundColumns + `
+	FROM refunds
+	WHERE id = $1
+	`
+	refund, err := scanRefund(pr.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return refund, err
+}
+
+func (pr *PostgresRefundStore) GetRefundsByTransactionID(transactionID string) ([]*Refund, error) {
+	query := `SELECT ` + refundColumns + `
+	FROM refunds
+	WHERE transaction_id = $1
+	ORDER BY created_at DESC
+	`
+	rows, err := pr.db.Query(query, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refunds := []*Refund{}
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, refund)
+	}
+	return refunds, rows.Err()
+}
+
+// CompleteRefund marks a pending refund completed once its transfer has been
+// executed, and moves the payment to refunded or partially_refunded. It
+// returns nil, nil, nil when the refund is not pending.
+func (pr *PostgresRefundStore) CompleteRefund(id string) (*Refund, *Transaction, error) {
+	tx, err := pr.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE refunds
+	SET status = $2, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status = $3
+	RETURNING ` + refundColumns
+
+	refund, err := scanRefund(tx.QueryRow(query, id, RefundStatusCompleted, RefundStatusPending))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var paid money.Amount
+	err = tx.QueryRow(`SELECT amount FROM transactions WHERE id = $1 FOR UPDATE`, refund.TransactionID).Scan(&paid)
+	if err != nil {
+		return nil, nil, err
+	}
+	var completed money.Amount
+	err = tx.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE transaction_id = $1 AND status = 'completed'`, refund.TransactionID).Scan(&completed)
+	if err != nil {
+		return nil, nil, err
+	}
+	status := TransactionStatusPartiallyRefunded
+	if completed >= paid {
+		status = TransactionStatusRefunded
+	}
+
+	query = `
+	UPDATE transactions
+	SET status = $2, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1
+	RETURNING ` + transactionColumns
+
+	transaction, err := scanTransaction(tx.QueryRow(query, refund.TransactionID, status))
+	if err != nil {
+		return nil, nil, err
+	}
+	return refund, transaction, tx.Commit()
+}
+
+// FailRefund marks a pending refund failed when its transfer did not succeed
+// on the ledger. A failed refund no longer counts against the payment. It
+// returns nil, nil when the refund is not pending.
+func (pr *PostgresRefundStore) FailRefund(id string) (*Refund, error) {
+	query := `
+	UPDATE refunds
+	SET status = $2, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status = $3
+	RETURNING ` + refundColumns
+
+	refund, err := scanRefund(pr.db.QueryRow(query, id, RefundStatusFailed, RefundStatusPending))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
@@ -3,8 +3,11 @@ package store
 import (
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
 )
 
 type Shop struct {
@@ -14,9 +17,16 @@ type Shop struct {
 	Theme           string          `json:"theme"`
 	PaymentID       string          `json:"payment_id"`
 	ProfileImageUrl string          `json:"profile_image_url"`
+	SuspendedAt     *time.Time      `json:"suspended_at"`
 	Campaigns       []CampaignEntry `json:"campaigns"`
 }
 
+// IsSuspended reports whether an admin has suspended the shop. Suspended
+// shops cannot take payments.
+func (s Shop) IsSuspended() bool {
+	return s.SuspendedAt != nil
+}
+
 type CampaignEntry struct {
 	ID             string `json:"id"`
 	ShopID         string `json:"shop_id"`
@@ -24,7 +34,7 @@ type CampaignEntry struct {
 	TokenID        string `json:"token_id"`
 	Description    string `json:"description"`
 	Target         int64  `json:"target"`
-	Distributed    int64  `json:"distributed"`
+	Distributed    money.Amount `json:"distributed"`
 	Ended          int64  `json:"ended"`
 	Icon           string `json:"icon"`
 	BannerImageUrl string `json:"banner_image_url"`
@@ -41,10 +51,12 @@ func NewPostgresShopStore(db *sql.DB) *PostgresShopStore {
 type ShopStore interface {
 	CreateShop(shop *Shop) (*Shop, error)
 	GetShopByID(id string) (*Shop, error)
+	GetShopByPaymentID(paymentID string) (*Shop, error)
 	UpdateShop(*Shop) error
 	GetShopOwner(id string) (string, error)
 	GetShopCampaigns(id string) ([]*CampaignEntry, error)
 	GetShopCampaignByCampaignID(campaignID string) (*CampaignEntry, error)
+	GetCampaignsByTokenIDs(tokenIDs []string) ([]*CampaignEntry, error)
 	GetShopsByMerchantID(merchantID string) ([]*Shop, error)
 	GetShopCampaignsByShopID(shopID string) ([]*CampaignEntry, error)
 	GetUserCampaignEntryByShopID(shopID string) ([]*UserCampaignEntry, error)
@@ -100,11 +112,11 @@ func (pg *PostgresShopStore) CreateShop(shop *Shop) (*Shop, error) {
 func (pg *PostgresShopStore) GetShopByID(id string) (*Shop, error) {
 	shop := &Shop{}
 	query := `
-	SELECT id, name, theme, payment_id, profile_image_url, merchant_id
+	SELECT id, name, theme, payment_id, profile_image_url, merchant_id, suspended_at
 	FROM shops
 	WHERE id = $1
 	`
-	err := pg.db.QueryRow(query, id).Scan(&shop.ID, &shop.Name, &shop.Theme, &shop.PaymentID, &shop.ProfileImageUrl, &shop.MerchantID)
+	err := pg.db.QueryRow(query, id).Scan(&shop.ID, &shop.Name, &shop.Theme, &shop.PaymentID, &shop.ProfileImageUrl, &shop.MerchantID, &shop.SuspendedAt)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
@@ -151,7 +163,7 @@ func (pg *PostgresShopStore) UpdateShop(shop *Shop) error {
 
 	query := `
 	UPDATE shops
-	SET name = $1, theme = $2, profile_image_url = $3
+	SET name = $1, theme = $2, profile_image_url = $3, updated_at = CURRENT_TIMESTAMP
 	WHERE id = $4
 	`
 
@@ -237,9 +249,28 @@ func (pg *PostgresShopStore) GetShopCampaigns(id string) ([]*CampaignEntry, erro
 	return result, nil
 }
 
+// GetShopByPaymentID resolves the payment id printed in a shop's QR code.
+// Campaigns are not loaded.
+func (pg *PostgresShopStore) GetShopByPaymentID(paymentID string) (*Shop, error) {
+	shop := &Shop{}
+	query := `
+	SELECT id, name, theme, payment_id, profile_image_url, merchant_id, suspended_at
+	FROM shops
+	WHERE payment_id = $1
+	`
+	err := pg.db.QueryRow(query, paymentID).Scan(&shop.ID, &shop.Name, &shop.Theme, &shop.PaymentID, &shop.ProfileImageUrl, &shop.MerchantID, &shop.SuspendedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return shop, nil
+}
+
 func (pg *PostgresShopStore) GetShopsByMerchantID(merchantID string) ([]*Shop, error) {
 	shopsQuery := `
-	SELECT id, merchant_id, name, theme, payment_id, profile_image_url
+	SELECT id, merchant_id, name, theme, payment_id, profile_image_url, suspended_at
 	FROM shops
 	WHERE merchant_id = $1
 	`
@@ -259,6 +290,7 @@ func (pg *PostgresShopStore) GetShopsByMerchantID(merchantID string) ([]*Shop, e
 			&shop.Theme,
 			&shop.PaymentID,
 			&shop.ProfileImageUrl,
+			&shop.SuspendedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -371,6 +403,30 @@ func (pg *PostgresShopStore) GetShopCampaignByCampaignID(campaignID string) (*Ca
 	return campaign, nil
 }
 
+func (pg *PostgresShopStore) GetCampaignsByTokenIDs(tokenIDs []string) ([]*CampaignEntry, error) {
+	query := `
+	SELECT id, name, token_id, description, target_tokens, distributed, ended, icon, banner_image_url, shop_id
+	FROM campaigns
+	WHERE token_id = ANY($1)
+	`
+	campaigns, err := pg.db.Query(query, tokenIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer campaigns.Close()
+
+	result := []*CampaignEntry{}
+	for campaigns.Next() {
+		var campaign CampaignEntry
+		err = campaigns.Scan(&campaign.ID, &campaign.Name, &campaign.TokenID, &campaign.Description, &campaign.Target, &campaign.Distributed, &campaign.Ended, &campaign.Icon, &campaign.BannerImageUrl, &campaign.ShopID)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, &campaign)
+	}
+	return result, campaigns.Err()
+}
+
 func (pg *PostgresShopStore) GetCampaignParticipants(campaignID string) ([]*UserCampaignEntry, error) {
 	query := `
 	SELECT id, user_id, campaign_id, token_balance
@@ -415,11 +471,11 @@ func (pg *PostgresShopStore) IsCampaignEnded(campaignID string) (bool, error) {
 	WHERE id = $1
 	`
 	var ended int64
-	var distributed int64
-	var target int64
+	var distributed money.Amount
+	var target money.Amount
 	err := pg.db.QueryRow(query, campaignID).Scan(&ended, &distributed, &target)
 	if err != nil {
 		return false, err
 	}
-	return ended == 1 && distributed == target / 100, nil
+	return ended == 1 && distributed == target, nil
 }
\ No newline at end of file
@@ -1,7 +1,9 @@
 package store
 
 import (
+	"crypto/subtle"
 	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/divin3circle/orcus/backend/internals/tokens"
@@ -20,6 +22,10 @@ type TokenStore interface {
 	Insert(token *tokens.Token) error
 	Create(merchantID string, ttl time.Duration, scope string) (*tokens.Token, error)
 	DeleteAllForUser(scope string, userID string) error
+	DeleteAllForMerchant(scope string, merchantID string) error
+	CreateOTP(merchantID string, ttl time.Duration, scope string) (*tokens.Token, error)
+	MatchOTP(merchantID string, scope string, code string) (bool, error)
+	HasRecentOTP(merchantID string, scope string, since time.Time) (bool, error)
 }
 
 func (pt *PostgresTokenStore) Insert(token *tokens.Token) error {
@@ -49,4 +55,86 @@ func (pt *PostgresTokenStore) DeleteAllForUser(scope string, userID string) erro
 	`
 	_, err := pt.db.Exec(query, userID, scope)
 	return err
-}
\ No newline at end of file
+}
+
+func (pt *PostgresTokenStore) DeleteAllForMerchant(scope string, merchantID string) error {
+	query := `
+	DELETE FROM tokens
+	WHERE merchant_id = $1 AND scope = $2
+	`
+	_, err := pt.db.Exec(query, merchantID, scope)
+	return err
+}
+
+func (pt *PostgresTokenStore) CreateOTP(merchantID string, ttl time.Duration, scope string) (*tokens.Token, error) {
+	token, err := tokens.GenerateMerchantOTP(merchantID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	err = pt.DeleteAllForMerchant(scope, merchantID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = pt.Insert(token)
+
+	return token, err
+}
+
+// MatchOTP checks a code against the merchant's outstanding OTP. A match
+// consumes the code; a miss counts towards tokens.MaxOTPAttempts after which
+// the code is discarded.
+func (pt *PostgresTokenStore) MatchOTP(merchantID string, scope string, code string) (bool, error) {
+	tx, err := pt.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var hash []byte
+	var attempts int
+	query := `
+	SELECT hash, attempts
+	FROM tokens
+	WHERE merchant_id = $1 AND scope = $2 AND expiry > $3
+	FOR UPDATE
+	`
+	err = tx.QueryRow(query, merchantID, scope, time.Now()).Scan(&hash, &attempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if subtle.ConstantTimeCompare(hash, tokens.OTPHash(merchantID, code)) == 1 {
+		_, err = tx.Exec(`DELETE FROM tokens WHERE merchant_id = $1 AND scope = $2`, merchantID, scope)
+		if err != nil {
+			return false, err
+		}
+		return true, tx.Commit()
+	}
+
+	if attempts+1 >= tokens.MaxOTPAttempts {
+		_, err = tx.Exec(`DELETE FROM tokens WHERE hash = $1`, hash)
+	} else {
+		_, err = tx.Exec(`UPDATE tokens SET attempts = attempts + 1 WHERE hash = $1`, hash)
+	}
+	if err != nil {
+		return false, err
+	}
+	return false, tx.Commit()
+}
+
+func (pt *PostgresTokenStore) HasRecentOTP(merchantID string, scope string, since time.Time) (bool, error) {
+	query := `
+	SELECT EXISTS(SELECT 1 FROM tokens WHERE merchant_id = $1 AND scope = $2 AND created_at > $3)
+	`
+	var exists bool
+	err := pt.db.QueryRow(query, merchantID, scope, since).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	return exists, nil
+}
@@ -3,6 +3,21 @@ package store
 import (
 	"database/sql"
 	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+)
+
+const (
+	TransactionStatusCompleted         = "completed"
+	TransactionStatusPartiallyRefunded = "partially_refunded"
+	TransactionStatusRefunded          = "refunded"
+)
+
+const (
+	TransactionKindPayment = "payment"
+	// TransactionKindSplit marks the parent of a split payment. It holds the
+	// totals paid by the user; each merchant's part is a child payment.
+	TransactionKindSplit = "split"
 )
 
 type Transaction struct {
@@ -10,9 +25,16 @@ type Transaction struct {
 	ShopID string `json:"shop_id"`
 	UserID string `json:"user_id"`
 	MerchantID string `json:"merchant_id"`
-	Amount int64 `json:"amount"`
-	Fee int64 `json:"fee"`
+	Amount money.Amount `json:"amount"`
+	Fee money.Amount `json:"fee"`
 	Status string `json:"status"`
+	HederaTransactionID string `json:"hedera_transaction_id"`
+	HederaFeeTransactionID string `json:"hedera_fee_transaction_id"`
+	FeeRuleID string `json:"fee_rule_id"`
+	FeeRule *FeeRule `json:"fee_rule"`
+	Kind string `json:"kind"`
+	ParentID string `json:"parent_id"`
+	Children []*Transaction `json:"children,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -31,40 +53,127 @@ type TransactionStore interface {
 	GetTransactionsByShopID(shopID string) ([]*Transaction, error)
 	GetTransactionsByUserID(userID string) ([]*Transaction, error)
 	GetTransactionsByMerchantID(merchantID string) ([]*Transaction, error)
+	CreateSplitTransaction(parent *Transaction, children []*Transaction) (*Transaction, error)
+	GetChildTransactions(parentID string) ([]*Transaction, error)
+}
+
+const transactionColumns = `id, COALESCE(shop_id::text, ''), user_id, COALESCE(merchant_id::text, ''), amount, fee, status, COALESCE(hedera_transaction_id, ''), COALESCE(hedera_fee_transaction_id, ''), COALESCE(fee_rule_id::text, ''), fee_rule, kind, COALESCE(parent_id::text, ''), created_at, updated_at`
+
+func scanTransaction(row interface{ Scan(...any) error }) (*Transaction, error) {
+	var transaction = &Transaction{}
+	var feeRule []byte
+	err := row.Scan(&transaction.ID, &transaction.ShopID, &transaction.UserID, &transaction.MerchantID, &transaction.Amount, &transaction.Fee, &transaction.Status, &transaction.HederaTransactionID, &transaction.HederaFeeTransactionID, &transaction.FeeRuleID, &feeRule, &transaction.Kind, &transaction.ParentID, &transaction.CreatedAt, &transaction.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	transaction.FeeRule, err = scanFeeRuleSnapshot(feeRule)
+	return transaction, err
+}
+
+func scanTransactions(rows *sql.Rows) ([]*Transaction, error) {
+	defer rows.Close()
+
+	var transactions []*Transaction
+	for rows.Next() {
+		transaction, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, rows.Err()
 }
 
 func (pt *PostgresTransactionStore) CreateTransaction(transaction *Transaction) (*Transaction, error) {
+	feeRule, err := feeRuleSnapshot(transaction.FeeRule)
+	if err != nil {
+		return nil, err
+	}
 	query := `
-	INSERT INTO transactions (shop_id, user_id, merchant_id, amount, fee, status)
-	VALUES ($1, $2, $3, $4, $5, $6)
-	RETURNING id, status, created_at, updated_at;
+	INSERT INTO transactions (shop_id, user_id, merchant_id, amount, fee, status, hedera_transaction_id, hedera_fee_transaction_id, fee_rule_id, fee_rule)
+	VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, '')::uuid, $10)
+	RETURNING id, status, kind, created_at, updated_at;
 	`
 
-	err := pt.db.QueryRow(query, transaction.ShopID, transaction.UserID, transaction.MerchantID, transaction.Amount, transaction.Fee, transaction.Status).Scan(&transaction.ID, &transaction.Status, &transaction.CreatedAt, &transaction.UpdatedAt)
+	err = pt.db.QueryRow(query, transaction.ShopID, transaction.UserID, transaction.MerchantID, transaction.Amount, transaction.Fee, transaction.Status, transaction.HederaTransactionID, transaction.HederaFeeTransactionID, transaction.FeeRuleID, feeRule).Scan(&transaction.ID, &transaction.Status, &transaction.Kind, &transaction.CreatedAt, &transaction.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return transaction, nil
 }
 
-func (pt *PostgresTransactionStore) GetTransactionByID(id string) (*Transaction, error) {
-	var transaction = &Transaction{}
+// CreateSplitTransaction records a split payment: parent carries the user's
+// totals and each child one merchant's part. All rows are written together.
+func (pt *PostgresTransactionStore) CreateSplitTransaction(parent *Transaction, children []*Transaction) (*Transaction, error) {
+	tx, err := pt.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO transactions (shop_id, user_id, merchant_id, amount, fee, status, hedera_transaction_id, hedera_fee_transaction_id, fee_rule_id, fee_rule, kind, parent_id)
+	VALUES (NULLIF($1, '')::uuid, $2, NULLIF($3, '')::uuid, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, '')::uuid, $10, $11, NULLIF($12, '')::uuid)
+	RETURNING id, created_at, updated_at
+	`
+	insert := func(transaction *Transaction) error {
+		feeRule, err := feeRuleSnapshot(transaction.FeeRule)
+		if err != nil {
+			return err
+		}
+		return tx.QueryRow(query, transaction.ShopID, transaction.UserID, transaction.MerchantID, transaction.Amount, transaction.Fee, transaction.Status, transaction.HederaTransactionID, transaction.HederaFeeTransactionID, transaction.FeeRuleID, feeRule, transaction.Kind, transaction.ParentID).Scan(&transaction.ID, &transaction.CreatedAt, &transaction.UpdatedAt)
+	}
+
+	parent.Kind = TransactionKindSplit
+	err = insert(parent)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		child.Kind = TransactionKindPayment
+		child.ParentID = parent.ID
+		err = insert(child)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	parent.Children = children
+	return parent, nil
+}
+
+func (pt *PostgresTransactionStore) GetChildTransactions(parentID string) ([]*Transaction, error) {
 	query := `
-	SELECT id, shop_id, user_id, merchant_id, amount, fee, status, created_at, updated_at
+	SELECT ` + transactionColumns + `
 	FROM transactions
-	WHERE id = $1
+	WHERE parent_id = $1
+	ORDER BY created_at, id
 	`
 
-	err := pt.db.QueryRow(query, id).Scan(&transaction.ID, &transaction.ShopID, &transaction.UserID, &transaction.MerchantID, &transaction.Amount, &transaction.Fee, &transaction.Status, &transaction.CreatedAt, &transaction.UpdatedAt)
+	rows, err := pt.db.Query(query, parentID)
 	if err != nil {
 		return nil, err
 	}
-	return transaction, nil
+	return scanTransactions(rows)
+}
+
+func (pt *PostgresTransactionStore) GetTransactionByID(id string) (*Transaction, error) {
+	query := `
+	SELECT ` + transactionColumns + `
+	FROM transactions
+	WHERE id = $1
+	`
+
+	return scanTransaction(pt.db.QueryRow(query, id))
 }
 
 func (pt *PostgresTransactionStore) GetTransactionsByShopID(shopID string) ([]*Transaction, error) {
 	query := `
-	SELECT id, shop_id, user_id, merchant_id, amount, fee, status, created_at, updated_at
+	SELECT ` + transactionColumns + `
 	FROM transactions
 	WHERE shop_id = $1
 	`
@@ -73,48 +182,28 @@ func (pt *PostgresTransactionStore) GetTransactionsByShopID(shopID string) ([]*T
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var transactions []*Transaction
-	for rows.Next() {
-		var transaction = &Transaction{}
-		err = rows.Scan(&transaction.ID, &transaction.ShopID, &transaction.UserID, &transaction.MerchantID, &transaction.Amount, &transaction.Fee, &transaction.Status, &transaction.CreatedAt, &transaction.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-		transactions = append(transactions, transaction)
-	}
-	return transactions, nil
+	return scanTransactions(rows)
 }
 
+// GetTransactionsByUserID returns what the user paid. A split payment is
+// listed once, as its parent, and its legs are left out.
 func (pt *PostgresTransactionStore) GetTransactionsByUserID(userID string) ([]*Transaction, error) {
 	query := `
-	SELECT id, shop_id, user_id, merchant_id, amount, fee, status, created_at, updated_at
+	SELECT ` + transactionColumns + `
 	FROM transactions
-	WHERE user_id = $1
+	WHERE user_id = $1 AND parent_id IS NULL
 	`
 
 	rows, err := pt.db.Query(query, userID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var transactions []*Transaction
-	for rows.Next() {
-		var transaction = &Transaction{}
-		err = rows.Scan(&transaction.ID, &transaction.ShopID, &transaction.UserID, &transaction.MerchantID, &transaction.Amount, &transaction.Fee, &transaction.Status, &transaction.CreatedAt, &transaction.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-		transactions = append(transactions, transaction)
-	}
-	return transactions, nil
+	return scanTransactions(rows)
 }
 
 func (pt *PostgresTransactionStore) GetTransactionsByMerchantID(merchantID string) ([]*Transaction, error) {
 	query := `
-	SELECT id, shop_id, user_id, merchant_id, amount, fee, status, created_at, updated_at
+	SELECT ` + transactionColumns + `
 	FROM transactions
 	WHERE merchant_id = $1
 	`
@@ -123,16 +212,5 @@ func (pt *PostgresTransactionStore) GetTransactionsByMerchantID(merchantID strin
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var transactions []*Transaction
-	for rows.Next() {
-		var transaction = &Transaction{}
-		err = rows.Scan(&transaction.ID, &transaction.ShopID, &transaction.UserID, &transaction.MerchantID, &transaction.Amount, &transaction.Fee, &transaction.Status, &transaction.CreatedAt, &transaction.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-		transactions = append(transactions, transaction)
-	}
-	return transactions, nil
+	return scanTransactions(rows)
 }
\ No newline at end of file
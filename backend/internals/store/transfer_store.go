@@ -0,0 +1,186 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+)
+
+var ErrAmbiguousMobileNumber = errors.New("several users have verified this mobile number")
+
+// Transfer is a peer-to-peer payment of KES tokens between two users.
+type Transfer struct {
+	ID                  string       `json:"id"`
+	SenderID            string       `json:"sender_id"`
+	RecipientID         string       `json:"recipient_id"`
+	Amount              money.Amount `json:"amount"`
+	Fee                 money.Amount `json:"fee"`
+	Note                string       `json:"note"`
+	Status              string       `json:"status"`
+	HederaTransactionID string       `json:"hedera_transaction_id"`
+	FeeRuleID           string       `json:"fee_rule_id"`
+	FeeRule             *FeeRule     `json:"fee_rule"`
+	CreatedAt           time.Time    `json:"created_at"`
+	UpdatedAt           time.Time    `json:"updated_at"`
+}
+
+const (
+	ActivityPayment  = "payment"
+	ActivityTransfer = "transfer"
+	ActivityRefund   = "refund"
+
+	ActivityIn  = "in"
+	ActivityOut = "out"
+)
+
+// Activity is one line of a user's feed: a shop payment, a transfer sent or
+// received, or a refund. Amount excludes the fee, which only outgoing
+// entries carry.
+type Activity struct {
+	ID                  string       `json:"id"`
+	Kind                string       `json:"kind"`
+	Direction           string       `json:"direction"`
+	Amount              money.Amount `json:"amount"`
+	Fee                 money.Amount `json:"fee"`
+	Status              string       `json:"status"`
+	CounterpartID       string       `json:"counterpart_id"`
+	CounterpartName     string       `json:"counterpart_name"`
+	HederaTransactionID string       `json:"hedera_transaction_id"`
+	CreatedAt           time.Time    `json:"created_at"`
+}
+
+type PostgresTransferStore struct {
+	db *sql.DB
+}
+
+func NewPostgresTransferStore(db *sql.DB) *PostgresTransferStore {
+	return &PostgresTransferStore{db: db}
+}
+
+type TransferStore interface {
+	CreateTransfer(transfer *Transfer) (*Transfer, error)
+	GetUserByVerifiedMobileNumber(mobileNumber string) (*User, error)
+	GetUserActivity(userID string, before time.Time, limit int) ([]*Activity, error)
+}
+
+func (pt *PostgresTransferStore) CreateTransfer(transfer *Transfer) (*Transfer, error) {
+	feeRule, err := feeRuleSnapshot(transfer.FeeRule)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+	INSERT INTO transfers (sender_id, recipient_id, amount, fee, note, status, hedera_transaction_id, fee_rule_id, fee_rule)
+	VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, '')::uuid, $9)
+	RETURNING id, created_at, updated_at
+	`
+
+	err = pt.db.QueryRow(query, transfer.SenderID, transfer.RecipientID, transfer.Amount, transfer.Fee, transfer.Note, transfer.Status, transfer.HederaTransactionID, transfer.FeeRuleID, feeRule).Scan(&transfer.ID, &transfer.CreatedAt, &transfer.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// GetUserByVerifiedMobileNumber finds the recipient of a transfer sent to a
+// phone number. Only verified numbers count, and since numbers are not
+// unique it returns ErrAmbiguousMobileNumber rather than guess.
+func (pt *PostgresTransferStore) GetUserByVerifiedMobileNumber(mobileNumber string) (*User, error) {
+	query := `
+	SELECT id, username, topic_id, mobile_number, phone_verified, account_id, profile_image_url, created_at, updated_at
+	FROM users
+	WHERE mobile_number = $1 AND phone_verified
+	LIMIT 2
+	`
+	rows, err := pt.db.Query(query, mobileNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		user := &User{}
+		err = rows.Scan(&user.ID, &user.Username, &user.TopicID, &user.MobileNumber, &user.PhoneVerified, &user.AccountID, &user.ProfileImageUrl, &user.CreatedAt, &user.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	switch len(users) {
+	case 0:
+		return nil, nil
+	case 1:
+		return users[0], nil
+	default:
+		return nil, ErrAmbiguousMobileNumber
+	}
+}
+
+// GetUserActivity returns up to limit feed entries created before the given
+// time, newest first. Passing the last entry's CreatedAt fetches the next
+// page.
+func (pt *PostgresTransferStore) GetUserActivity(userID string, before time.Time, limit int) ([]*Activity, error) {
+	query := `
+	SELECT id, kind, direction, amount, fee, status, counterpart_id, counterpart_name, hedera_transaction_id, created_at
+	FROM (
+		SELECT t.id, 'payment' AS kind, 'out' AS direction, t.amount, t.fee, t.status,
+			t.shop_id::text AS counterpart_id, s.name AS counterpart_name,
+			COALESCE(t.hedera_transaction_id, '') AS hedera_transaction_id, t.created_at
+		FROM transactions t
+		JOIN shops s ON s.id = t.shop_id
+		WHERE t.user_id = $1
+
+		UNION ALL
+
+		SELECT tr.id, 'transfer', 'out', tr.amount, tr.fee, tr.status,
+			tr.recipient_id::text, u.username,
+			COALESCE(tr.hedera_transaction_id, ''), tr.created_at
+		FROM transfers tr
+		JOIN users u ON u.id = tr.recipient_id
+		WHERE tr.sender_id = $1
+
+		UNION ALL
+
+		SELECT tr.id, 'transfer', 'in', tr.amount, 0, tr.status,
+			tr.sender_id::text, u.username,
+			COALESCE(tr.hedera_transaction_id, ''), tr.created_at
+		FROM transfers tr
+		JOIN users u ON u.id = tr.sender_id
+		WHERE tr.recipient_id = $1
+
+		UNION ALL
+
+		SELECT rf.id, 'refund', 'in', rf.amount, 0, rf.status,
+			t.shop_id::text, s.name,
+			rf.hedera_transaction_id, rf.updated_at
+		FROM refunds rf
+		JOIN transactions t ON t.id = rf.transaction_id
+		JOIN shops s ON s.id = t.shop_id
+		WHERE rf.user_id = $1 AND rf.status = 'completed'
+	) activity
+	WHERE created_at < $2
+	ORDER BY created_at DESC
+	LIMIT $3
+	`
+	rows, err := pt.db.Query(query, userID, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activity := []*Activity{}
+	for rows.Next() {
+		entry := &Activity{}
+		err = rows.Scan(&entry.ID, &entry.Kind, &entry.Direction, &entry.Amount, &entry.Fee, &entry.Status, &entry.CounterpartID, &entry.CounterpartName, &entry.HederaTransactionID, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		activity = append(activity, entry)
+	}
+	return activity, rows.Err()
+}
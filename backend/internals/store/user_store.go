@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
 )
 
 type User struct {
@@ -12,10 +14,13 @@ type User struct {
 	Username        string    `json:"username"`
 	TopicID         string    `json:"topic_id"`
 	MobileNumber    string    `json:"mobile_number"`
+	PhoneVerified   bool      `json:"phone_verified"`
 	PasswordHash    password  `json:"-"`
 	EncryptedKey    string    `json:"-"`
 	AccountID       string    `json:"account_id"`
 	ProfileImageUrl string    `json:"profile_image_url"`
+	SuspendedAt     *time.Time `json:"suspended_at"`
+	KYCLevel        string    `json:"kyc_level"`
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 	DeletedAt       time.Time `json:"deleted_at"`
@@ -24,7 +29,7 @@ type User struct {
 type Purchase struct {
 	ID string `json:"id"`
 	UserID string `json:"user_id"`
-	Amount int64 `json:"amount"`
+	Amount money.Amount `json:"amount"`
 	Status string `json:"status"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -35,7 +40,7 @@ type UserCampaignEntry struct {
 	ShopID sql.NullString `json:"shop_id"`
 	UserID string `json:"user_id"`
 	CampaignID string `json:"campaign_id"`
-	TokenBalance int64 `json:"token_balance"`
+	TokenBalance money.Amount `json:"token_balance"`
 }
 
 var AnonymousUser = &User{}
@@ -44,6 +49,11 @@ func (u User) IsAnonymous() bool {
 	return u.ID == AnonymousUser.ID
 }
 
+// IsSuspended reports whether an admin has suspended the user.
+func (u User) IsSuspended() bool {
+	return u.SuspendedAt != nil
+}
+
 type PostgresUserStore struct {
 	db *sql.DB
 }
@@ -57,11 +67,12 @@ type UserStore interface {
 	GetUserByUsername(username string) (*User, error)
 	GetUserByID(id string) (*User, error)
 	UpdateUser(user *User) error
+	SetPhoneVerified(userID string, verified bool) error
 	GetUserToken(scope, tokenPlainText string) (*User, error)
-	BuyToken(userID string, amount int64) error
+	BuyToken(userID string, amount money.Amount) error
 	GetUserPurchases(userID string) ([]*Purchase, error)
-	JoinCampaign(userID string, campaignID string, tokenBalance int64) error
-	UpdateCampaignEntry(userID string, campaignID string, tokenBalance int64) error
+	JoinCampaign(userID string, campaignID string, tokenBalance money.Amount) error
+	UpdateCampaignEntry(userID string, campaignID string, tokenBalance money.Amount) error
 	IsParticipant(userID string, campaignID string) (bool, error)
 	GetUserCampaigns(userID string) ([]*UserCampaignEntry, error)
 }
@@ -70,10 +81,10 @@ func (pu *PostgresUserStore) CreateUser(user *User) (*User, error) {
 	query := `
 	INSERT INTO users (username, topic_id, mobile_number, hashed_password, encrypted_key, account_id, profile_image_url)
 	VALUES ($1, $2, $3, $4, $5, $6, $7)
-	RETURNING id, created_at, updated_at;
+	RETURNING id, kyc_level, created_at, updated_at;
 	`
 
-	err := pu.db.QueryRow(query, user.Username, user.TopicID, user.MobileNumber, user.PasswordHash.hash, user.EncryptedKey, user.AccountID, user.ProfileImageUrl).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	err := pu.db.QueryRow(query, user.Username, user.TopicID, user.MobileNumber, user.PasswordHash.hash, user.EncryptedKey, user.AccountID, user.ProfileImageUrl).Scan(&user.ID, &user.KYCLevel, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -86,11 +97,11 @@ func (pu *PostgresUserStore) GetUserByUsername(username string) (*User, error) {
 	}
 
 	query := `
-	SELECT id, username, topic_id, mobile_number, hashed_password, encrypted_key, account_id, profile_image_url, created_at, updated_at
+	SELECT id, username, topic_id, mobile_number, phone_verified, hashed_password, encrypted_key, account_id, profile_image_url, suspended_at, kyc_level, created_at, updated_at
 	FROM users
 	WHERE username = $1
 	`
-	err := pu.db.QueryRow(query, username).Scan(&user.ID, &user.Username, &user.TopicID, &user.MobileNumber, &user.PasswordHash.hash, &user.EncryptedKey, &user.AccountID, &user.ProfileImageUrl, &user.CreatedAt, &user.UpdatedAt)
+	err := pu.db.QueryRow(query, username).Scan(&user.ID, &user.Username, &user.TopicID, &user.MobileNumber, &user.PhoneVerified, &user.PasswordHash.hash, &user.EncryptedKey, &user.AccountID, &user.ProfileImageUrl, &user.SuspendedAt, &user.KYCLevel, &user.CreatedAt, &user.UpdatedAt)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
@@ -106,11 +117,11 @@ func (pu *PostgresUserStore) GetUserByID(id string) (*User, error) {
 	}
 	
 	query := `
-	SELECT id, username, topic_id, mobile_number, hashed_password, encrypted_key, account_id, profile_image_url, created_at, updated_at
+	SELECT id, username, topic_id, mobile_number, phone_verified, hashed_password, encrypted_key, account_id, profile_image_url, suspended_at, kyc_level, created_at, updated_at
 	FROM users
 	WHERE id = $1
 	`
-	err := pu.db.QueryRow(query, id).Scan(&user.ID, &user.Username, &user.TopicID, &user.MobileNumber, &user.PasswordHash.hash, &user.EncryptedKey, &user.AccountID, &user.ProfileImageUrl, &user.CreatedAt, &user.UpdatedAt)
+	err := pu.db.QueryRow(query, id).Scan(&user.ID, &user.Username, &user.TopicID, &user.MobileNumber, &user.PhoneVerified, &user.PasswordHash.hash, &user.EncryptedKey, &user.AccountID, &user.ProfileImageUrl, &user.SuspendedAt, &user.KYCLevel, &user.CreatedAt, &user.UpdatedAt)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
@@ -123,14 +134,44 @@ func (pu *PostgresUserStore) GetUserByID(id string) (*User, error) {
 func (pu *PostgresUserStore) UpdateUser(user *User) error {
 	query := `
 	UPDATE users
-	SET username = $1, mobile_number = $2, hashed_password = $3, encrypted_key = $4, account_id = $5, profile_image_url = $6, updated_at = CURRENT_TIMESTAMP
+	SET username = $1, mobile_number = $2, hashed_password = $3, encrypted_key = $4, account_id = $5, profile_image_url = $6, phone_verified = (phone_verified AND mobile_number = $2),
+		kyc_level = CASE WHEN kyc_level = 'phone_verified' AND mobile_number <> $2 THEN 'none' ELSE kyc_level END, updated_at = CURRENT_TIMESTAMP
 	WHERE id = $7
-	RETURNING updated_at
+	RETURNING phone_verified, kyc_level, updated_at
+	`
+	err := pu.db.QueryRow(query, user.Username, user.MobileNumber, user.PasswordHash.hash, user.EncryptedKey, user.AccountID, user.ProfileImageUrl, user.ID).Scan(&user.PhoneVerified, &user.KYCLevel, &user.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetPhoneVerified also moves the user between the none and phone_verified
+// KYC levels. Higher levels are kept.
+func (pu *PostgresUserStore) SetPhoneVerified(userID string, verified bool) error {
+	query := `
+	UPDATE users
+	SET phone_verified = $1,
+		kyc_level = CASE
+			WHEN $1 AND kyc_level = 'none' THEN 'phone_verified'
+			WHEN NOT $1 AND kyc_level = 'phone_verified' THEN 'none'
+			ELSE kyc_level
+		END,
+		updated_at = CURRENT_TIMESTAMP
+	WHERE id = $2
 	`
-	err := pu.db.QueryRow(query, user.Username, user.MobileNumber, user.PasswordHash.hash, user.EncryptedKey, user.AccountID, user.ProfileImageUrl, user.ID).Scan(&user.UpdatedAt)
+	result, err := pu.db.Exec(query, verified, userID)
 	if err != nil {
 		return err
 	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
 	return nil
 }
 
@@ -138,7 +179,7 @@ func (pu *PostgresUserStore) GetUserToken(scope, tokenPlainText string) (*User,
 	tokenHash := sha256.Sum256([]byte(tokenPlainText))
 
 	query := `
-	SELECT users.id, users.username, users.mobile_number, users.hashed_password, users.encrypted_key, users.account_id, users.profile_image_url, users.created_at, users.updated_at
+	SELECT users.id, users.username, users.topic_id, users.mobile_number, users.phone_verified, users.hashed_password, users.encrypted_key, users.account_id, users.profile_image_url, users.suspended_at, users.kyc_level, users.created_at, users.updated_at
 	FROM users
 	INNER JOIN user_tokens ON users.id = user_tokens.user_id
 	WHERE user_tokens.hash = $1 AND user_tokens.scope = $2 AND user_tokens.expiry > $3
@@ -148,7 +189,7 @@ func (pu *PostgresUserStore) GetUserToken(scope, tokenPlainText string) (*User,
 		PasswordHash: password{},
 	}
 
-	err := pu.db.QueryRow(query, tokenHash[:], scope, time.Now()).Scan(&user.ID, &user.Username, &user.MobileNumber, &user.PasswordHash.hash, &user.EncryptedKey, &user.AccountID, &user.ProfileImageUrl, &user.CreatedAt, &user.UpdatedAt)
+	err := pu.db.QueryRow(query, tokenHash[:], scope, time.Now()).Scan(&user.ID, &user.Username, &user.TopicID, &user.MobileNumber, &user.PhoneVerified, &user.PasswordHash.hash, &user.EncryptedKey, &user.AccountID, &user.ProfileImageUrl, &user.SuspendedAt, &user.KYCLevel, &user.CreatedAt, &user.UpdatedAt)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
@@ -158,7 +199,7 @@ func (pu *PostgresUserStore) GetUserToken(scope, tokenPlainText string) (*User,
 	return user, nil
 }
 
-func (pu *PostgresUserStore) BuyToken(userID string, amount int64) error {
+func (pu *PostgresUserStore) BuyToken(userID string, amount money.Amount) error {
 	var purchase Purchase
 	query := `
 	INSERT INTO purchases (user_id, amount, status)
@@ -196,7 +237,7 @@ func (pu *PostgresUserStore) GetUserPurchases(userID string) ([]*Purchase, error
 	return purchases, nil
 }
 
-func (pu *PostgresUserStore) JoinCampaign(userID string, campaignID string, tokenBalance int64) error {
+func (pu *PostgresUserStore) JoinCampaign(userID string, campaignID string, tokenBalance money.Amount) error {
 	isParticipant, err := pu.IsParticipant(userID, campaignID)
 	if err != nil {
 		return err
@@ -234,7 +275,7 @@ func (pu *PostgresUserStore) JoinCampaign(userID string, campaignID string, toke
 	return tx.Commit()
 }
 
-func (pg *PostgresUserStore) UpdateCampaignEntry(userID string, campaignID string, tokenIncrement int64) error {
+func (pg *PostgresUserStore) UpdateCampaignEntry(userID string, campaignID string, tokenIncrement money.Amount) error {
     tx, err := pg.db.Begin()
     if err != nil {
         return err
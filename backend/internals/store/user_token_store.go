@@ -1,7 +1,9 @@
 package store
 
 import (
+	"crypto/subtle"
 	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/divin3circle/orcus/backend/internals/tokens"
@@ -19,6 +21,9 @@ type UserTokenStore interface {
 	Insert(token *tokens.UserToken) error
 	Create(userID string, ttl time.Duration, scope string) (*tokens.UserToken, error)
 	DeleteAllForUser(scope string, userID string) error
+	CreateOTP(userID string, ttl time.Duration, scope string) (*tokens.UserToken, error)
+	MatchOTP(userID string, scope string, code string) (bool, error)
+	HasRecentOTP(userID string, scope string, since time.Time) (bool, error)
 }
 
 func (pt *PostgresUserTokenStore) Insert(token *tokens.UserToken) error {
@@ -49,3 +54,74 @@ func (pt *PostgresUserTokenStore) DeleteAllForUser(scope string, userID string)
 	_, err := pt.db.Exec(query, userID, scope)
 	return err
 }
+
+func (pt *PostgresUserTokenStore) CreateOTP(userID string, ttl time.Duration, scope string) (*tokens.UserToken, error) {
+	token, err := tokens.GenerateUserOTP(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	err = pt.DeleteAllForUser(scope, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = pt.Insert(token)
+
+	return token, err
+}
+
+// MatchOTP mirrors PostgresTokenStore.MatchOTP for user_tokens.
+func (pt *PostgresUserTokenStore) MatchOTP(userID string, scope string, code string) (bool, error) {
+	tx, err := pt.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var hash []byte
+	var attempts int
+	query := `
+	SELECT hash, attempts
+	FROM user_tokens
+	WHERE user_id = $1 AND scope = $2 AND expiry > $3
+	FOR UPDATE
+	`
+	err = tx.QueryRow(query, userID, scope, time.Now()).Scan(&hash, &attempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if subtle.ConstantTimeCompare(hash, tokens.OTPHash(userID, code)) == 1 {
+		_, err = tx.Exec(`DELETE FROM user_tokens WHERE user_id = $1 AND scope = $2`, userID, scope)
+		if err != nil {
+			return false, err
+		}
+		return true, tx.Commit()
+	}
+
+	if attempts+1 >= tokens.MaxOTPAttempts {
+		_, err = tx.Exec(`DELETE FROM user_tokens WHERE hash = $1`, hash)
+	} else {
+		_, err = tx.Exec(`UPDATE user_tokens SET attempts = attempts + 1 WHERE hash = $1`, hash)
+	}
+	if err != nil {
+		return false, err
+	}
+	return false, tx.Commit()
+}
+
+func (pt *PostgresUserTokenStore) HasRecentOTP(userID string, scope string, since time.Time) (bool, error) {
+	query := `
+	SELECT EXISTS(SELECT 1 FROM user_tokens WHERE user_id = $1 AND scope = $2 AND created_at > $3)
+	`
+	var exists bool
+	err := pt.db.QueryRow(query, userID, scope, since).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	return exists, nil
+}
@@ -0,0 +1,294 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+type WebhookEndpoint struct {
+	ID         string    `json:"id"`
+	MerchantID string    `json:"merchant_id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"-"`
+	Events     []string  `json:"events"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	EndpointID     string          `json:"endpoint_id"`
+	MerchantID     string          `json:"merchant_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code"`
+	LastError      string          `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// DueWebhookDelivery is a claimed delivery together with where and how to
+// send it.
+type DueWebhookDelivery struct {
+	Delivery *WebhookDelivery
+	URL      string
+	Secret   string
+}
+
+type PostgresWebhookStore struct {
+	db *sql.DB
+}
+
+func NewPostgresWebhookStore(db *sql.DB) *PostgresWebhookStore {
+	return &PostgresWebhookStore{db: db}
+}
+
+type WebhookStore interface {
+	CreateWebhookEndpoint(endpoint *WebhookEndpoint) (*WebhookEndpoint, error)
+	GetWebhookEndpointsByMerchantID(merchantID string) ([]*WebhookEndpoint, error)
+	DeleteWebhookEndpoint(merchantID string, id string) error
+	CreateWebhookDeliveries(merchantID string, eventID string, eventType string, payload []byte) ([]*WebhookDelivery, error)
+	GetWebhookDeliveriesByMerchantID(merchantID string, limit int) ([]*WebhookDelivery, error)
+	ReplayWebhookDelivery(merchantID string, id string) (*WebhookDelivery, error)
+	ClaimDueWebhookDeliveries(limit int, lease time.Duration) ([]*DueWebhookDelivery, error)
+	UpdateWebhookDelivery(id string, status string, statusCode int, lastError string, nextAttemptAt *time.Time) error
+}
+
+const webhookEndpointColumns = `id, merchant_id, url, secret, events, active, created_at, updated_at`
+
+const webhookDeliveryColumns = `webhook_deliveries.id, webhook_deliveries.endpoint_id, webhook_deliveries.merchant_id, webhook_deliveries.event_id, webhook_deliveries.event_type, webhook_deliveries.payload, webhook_deliveries.status, webhook_deliveries.attempts, webhook_deliveries.next_attempt_at, webhook_deliveries.last_status_code, webhook_deliveries.last_error, webhook_deliveries.created_at, webhook_deliveries.updated_at`
+
+func scanWebhookEndpoint(row interface{ Scan(...any) error }) (*WebhookEndpoint, error) {
+	endpoint := &WebhookEndpoint{}
+	var events string
+	err := row.Scan(&endpoint.ID, &endpoint.MerchantID, &endpoint.URL, &endpoint.Secret, &events, &endpoint.Active, &endpoint.CreatedAt, &endpoint.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	endpoint.Events = strings.Split(events, ",")
+	return endpoint, nil
+}
+
+func scanWebhookDelivery(row interface{ Scan(...any) error }, extra ...any) (*WebhookDelivery, error) {
+	delivery := &WebhookDelivery{}
+	var payload string
+	var nextAttemptAt sql.NullTime
+	var statusCode sql.NullInt64
+	var lastError sql.NullString
+
+	dest := []any{&delivery.ID, &delivery.EndpointID, &delivery.MerchantID, &delivery.EventID, &delivery.EventType, &payload, &delivery.Status, &delivery.Attempts, &nextAttemptAt, &statusCode, &lastError, &delivery.CreatedAt, &delivery.UpdatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Payload = json.RawMessage(payload)
+	if nextAttemptAt.Valid {
+		delivery.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if statusCode.Valid {
+		code := int(statusCode.Int64)
+		delivery.LastStatusCode = &code
+	}
+	delivery.LastError = lastError.String
+	return delivery, nil
+}
+
+func (pw *PostgresWebhookStore) CreateWebhookEndpoint(endpoint *WebhookEndpoint) (*WebhookEndpoint, error) {
+	query := `
+	INSERT INTO webhook_endpoints (merchant_id, url, secret, events)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, active, created_at, updated_at
+	`
+	err := pw.db.QueryRow(query, endpoint.MerchantID, endpoint.URL, endpoint.Secret, strings.Join(endpoint.Events, ",")).Scan(&endpoint.ID, &endpoint.Active, &endpoint.CreatedAt, &endpoint.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func (pw *PostgresWebhookStore) GetWebhookEndpointsByMerchantID(merchantID string) ([]*WebhookEndpoint, error) {
+	query := `
+	SELECT ` + webhookEndpointColumns + `
+	FROM webhook_endpoints
+	WHERE merchant_id = $1 AND active = TRUE
+	ORDER BY created_at
+	`
+	rows, err := pw.db.Query(query, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	endpoints := []*WebhookEndpoint{}
+	for rows.Next() {
+		endpoint, err := scanWebhookEndpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, rows.Err()
+}
+
+// DeleteWebhookEndpoint deactivates rather than deletes so the delivery log
+// keeps its endpoint.
+func (pw *PostgresWebhookStore) DeleteWebhookEndpoint(merchantID string, id string) error {
+	query := `
+	UPDATE webhook_endpoints
+	SET active = FALSE, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND merchant_id = $2 AND active = TRUE
+	`
+	result, err := pw.db.Exec(query, id, merchantID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CreateWebhookDeliveries queues one pending delivery per active endpoint of
+// the merchant subscribed to eventType.
+func (pw *PostgresWebhookStore) CreateWebhookDeliveries(merchantID string, eventID string, eventType string, payload []byte) ([]*WebhookDelivery, error) {
+	query := `
+	INSERT INTO webhook_deliveries (endpoint_id, merchant_id, event_id, event_type, payload, status, next_attempt_at)
+	SELECT id, merchant_id, $2::uuid, $3::text, $4, $5, CURRENT_TIMESTAMP
+	FROM webhook_endpoints
+	WHERE merchant_id = $1 AND active = TRUE AND (',' || events || ',') LIKE ('%,' || $3::text || ',%')
+	RETURNING ` + webhookDeliveryColumns
+
+	rows, err := pw.db.Query(query, merchantID, eventID, eventType, string(payload), WebhookDeliveryPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+func (pw *PostgresWebhookStore) GetWebhookDeliveriesByMerchantID(merchantID string, limit int) ([]*WebhookDelivery, error) {
+	query := `
+	SELECT ` + webhookDeliveryColumns + `
+	FROM webhook_deliveries
+	WHERE merchant_id = $1
+	ORDER BY created_at DESC
+	LIMIT $2
+	`
+	rows, err := pw.db.Query(query, merchantID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// ReplayWebhookDelivery queues a fresh delivery of the same event to the same
+// endpoint, leaving the original row in the log.
+func (pw *PostgresWebhookStore) ReplayWebhookDelivery(merchantID string, id string) (*WebhookDelivery, error) {
+	query := `
+	INSERT INTO webhook_deliveries (endpoint_id, merchant_id, event_id, event_type, payload, status, next_attempt_at)
+	SELECT webhook_deliveries.endpoint_id, webhook_deliveries.merchant_id, webhook_deliveries.event_id, webhook_deliveries.event_type, webhook_deliveries.payload, $3, CURRENT_TIMESTAMP
+	FROM webhook_deliveries
+	INNER JOIN webhook_endpoints ON webhook_endpoints.id = webhook_deliveries.endpoint_id
+	WHERE webhook_deliveries.id = $1 AND webhook_deliveries.merchant_id = $2 AND webhook_endpoints.active = TRUE
+	RETURNING ` + webhookDeliveryColumns
+
+	delivery, err := scanWebhookDelivery(pw.db.QueryRow(query, id, merchantID, WebhookDeliveryPending))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// ClaimDueWebhookDeliveries leases up to limit pending deliveries by pushing
+// next_attempt_at forward, so other instances skip them while they're sent.
+// Deliveries to deleted endpoints are left pending and never sent.
+func (pw *PostgresWebhookStore) ClaimDueWebhookDeliveries(limit int, lease time.Duration) ([]*DueWebhookDelivery, error) {
+	query := `
+	UPDATE webhook_deliveries
+	SET attempts = webhook_deliveries.attempts + 1, next_attempt_at = $1, updated_at = CURRENT_TIMESTAMP
+	FROM webhook_endpoints
+	WHERE webhook_endpoints.id = webhook_deliveries.endpoint_id AND webhook_endpoints.active = TRUE AND webhook_deliveries.id IN (
+		SELECT d.id
+		FROM webhook_deliveries d
+		INNER JOIN webhook_endpoints e ON e.id = d.endpoint_id
+		WHERE d.status = $2 AND d.next_attempt_at <= CURRENT_TIMESTAMP AND e.active = TRUE
+		ORDER BY d.next_attempt_at
+		LIMIT $3
+		FOR UPDATE OF d SKIP LOCKED
+	)
+	RETURNING ` + webhookDeliveryColumns + `, webhook_endpoints.url, webhook_endpoints.secret`
+
+	rows, err := pw.db.Query(query, time.Now().Add(lease), WebhookDeliveryPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	due := []*DueWebhookDelivery{}
+	for rows.Next() {
+		item := &DueWebhookDelivery{}
+		item.Delivery, err = scanWebhookDelivery(rows, &item.URL, &item.Secret)
+		if err != nil {
+			return nil, err
+		}
+		due = append(due, item)
+	}
+	return due, rows.Err()
+}
+
+func (pw *PostgresWebhookStore) UpdateWebhookDelivery(id string, status string, statusCode int, lastError string, nextAttemptAt *time.Time) error {
+	var code sql.NullInt64
+	if statusCode > 0 {
+		code = sql.NullInt64{Int64: int64(statusCode), Valid: true}
+	}
+
+	query := `
+	UPDATE webhook_deliveries
+	SET status = $1, last_status_code = $2, last_error = $3, next_attempt_at = $4, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $5
+	`
+	_, err := pw.db.Exec(query, status, code, lastError, nextAttemptAt, id)
+	return err
+}
@@ -0,0 +1,274 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/divin3circle/orcus/backend/internals/money"
+)
+
+const (
+	WithdrawalStatusCompleted       = "completed"
+	WithdrawalStatusPendingApproval = "pending_approval"
+	WithdrawalStatusFailed          = "failed"
+	WithdrawalStatusCancelled       = "cancelled"
+	WithdrawalStatusExpired         = "expired"
+)
+
+var (
+	ErrAlreadyApproved = errors.New("approver has already signed this withdrawal")
+	ErrApproverExists  = errors.New("public key is already an approver")
+)
+
+// WithdrawalApprover is a key, usually held by a merchant team member, that
+// may sign scheduled withdrawals. For a signature to count on the ledger the
+// key has to be part of the merchant account's key.
+type WithdrawalApprover struct {
+	ID         string     `json:"id"`
+	MerchantID string     `json:"merchant_id"`
+	Name       string     `json:"name"`
+	PublicKey  string     `json:"public_key"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// WithdrawalApproval is an approver's signature on a scheduled withdrawal,
+// submitted to the ledger as HederaTransactionID.
+type WithdrawalApproval struct {
+	ID                  string    `json:"id"`
+	WithdrawalID        string    `json:"withdrawal_id"`
+	ApproverID          string    `json:"approver_id"`
+	ApproverName        string    `json:"approver_name"`
+	HederaTransactionID string    `json:"hedera_transaction_id"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+type PostgresWithdrawalApprovalStore struct {
+	db *sql.DB
+}
+
+func NewPostgresWithdrawalApprovalStore(db *sql.DB) *PostgresWithdrawalApprovalStore {
+	return &PostgresWithdrawalApprovalStore{db: db}
+}
+
+type WithdrawalApprovalStore interface {
+	CreateApprover(approver *WithdrawalApprover) (*WithdrawalApprover, error)
+	GetApproversByMerchantID(merchantID string) ([]*WithdrawalApprover, error)
+	RevokeApprover(id string, merchantID string) (*WithdrawalApprover, error)
+	CreateScheduledWithdrawal(withdrawal *Withdrawal) (*Withdrawal, error)
+	GetWithdrawalByID(id string) (*Withdrawal, error)
+	GetWithdrawalApprovals(withdrawalID string) ([]*WithdrawalApproval, error)
+	AddWithdrawalApproval(approval *WithdrawalApproval) (*WithdrawalApproval, error)
+	ResolveScheduledWithdrawal(id string, status string) (*Withdrawal, error)
+	GetWithdrawnSince(merchantID string, since time.Time) (money.Amount, error)
+}
+
+// Scheduled withdrawals past their expiry are reported as expired; the ledger
+// drops the schedule itself.
+const withdrawalStatus = `CASE WHEN status = 'pending_approval' AND expires_at <= CURRENT_TIMESTAMP THEN 'expired' ELSE status END`
+
+const withdrawalColumns = `id, ` + withdrawalStatus + `, amount, fee, COALESCE(receiver, ''), merchant_id, COALESCE(fee_rule_id::text, ''), fee_rule, COALESCE(schedule_id, ''), COALESCE(scheduled_transaction_id, ''), expires_at, created_at, updated_at`
+
+func scanWithdrawal(row interface{ Scan(...any) error }) (*Withdrawal, error) {
+	withdrawal := &Withdrawal{}
+	var feeRule []byte
+	var expiresAt sql.NullTime
+	err := row.Scan(&withdrawal.ID, &withdrawal.Status, &withdrawal.Amount, &withdrawal.Fee, &withdrawal.Receiver, &withdrawal.MerchantID, &withdrawal.FeeRuleID, &feeRule, &withdrawal.ScheduleID, &withdrawal.ScheduledTransactionID, &expiresAt, &withdrawal.CreatedAt, &withdrawal.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		withdrawal.ExpiresAt = &expiresAt.Time
+	}
+	withdrawal.FeeRule, err = scanFeeRuleSnapshot(feeRule)
+	return withdrawal, err
+}
+
+// scanOptionalWithdrawal maps a missing row to nil, nil.
+func scanOptionalWithdrawal(row *sql.Row) (*Withdrawal, error) {
+	withdrawal, err := scanWithdrawal(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return withdrawal, err
+}
+
+const withdrawalApproverColumns = `id, merchant_id, name, public_key, created_at, revoked_at`
+
+func scanWithdrawalApprover(row interface{ Scan(...any) error }) (*WithdrawalApprover, error) {
+	approver := &WithdrawalApprover{}
+	var revokedAt sql.NullTime
+	err := row.Scan(&approver.ID, &approver.MerchantID, &approver.Name, &approver.PublicKey, &approver.CreatedAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		approver.RevokedAt = &revokedAt.Time
+	}
+	return approver, nil
+}
+
+func (pw *PostgresWithdrawalApprovalStore) CreateApprover(approver *WithdrawalApprover) (*WithdrawalApprover, error) {
+	query := `
+	INSERT INTO withdrawal_approvers (merchant_id, name, public_key)
+	VALUES ($1, $2, $3)
+	RETURNING ` + withdrawalApproverColumns
+
+	created, err := scanWithdrawalApprover(pw.db.QueryRow(query, approver.MerchantID, approver.Name, approver.PublicKey))
+	if isUniqueViolation(err) {
+		return nil, ErrApproverExists
+	}
+	return created, err
+}
+
+// GetApproversByMerchantID lists the merchant's approvers that have not been
+// revoked.
+func (pw *PostgresWithdrawalApprovalStore) GetApproversByMerchantID(merchantID string) ([]*WithdrawalApprover, error) {
+	query := `
+	SELECT ` + withdrawalApproverColumns + `
+	FROM withdrawal_approvers
+	WHERE merchant_id = $1 AND revoked_at IS NULL
+	ORDER BY created_at
+	`
+	rows, err := pw.db.Query(query, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	approvers := []*WithdrawalApprover{}
+	for rows.Next() {
+		approver, err := scanWithdrawalApprover(rows)
+		if err != nil {
+			return nil, err
+		}
+		approvers = append(approvers, approver)
+	}
+	return approvers, rows.Err()
+}
+
+// RevokeApprover stops the key from approving new withdrawals. It returns
+// nil, nil when the merchant has no such active approver.
+func (pw *PostgresWithdrawalApprovalStore) RevokeApprover(id string, merchantID string) (*WithdrawalApprover, error) {
+	query := `
+	UPDATE withdrawal_approvers
+	SET revoked_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL
+	RETURNING ` + withdrawalApproverColumns
+
+	approver, err := scanWithdrawalApprover(pw.db.QueryRow(query, id, merchantID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return approver, err
+}
+
+// CreateScheduledWithdrawal records a withdrawal waiting on approvals for the
+// ledger schedule in ScheduleID.
+func (pw *PostgresWithdrawalApprovalStore) CreateScheduledWithdrawal(withdrawal *Withdrawal) (*Withdrawal, error) {
+	snapshot, err := feeRuleSnapshot(withdrawal.FeeRule)
+	if err != nil {
+		return nil, err
+	}
+	feeRuleID := ""
+	if withdrawal.FeeRule != nil {
+		feeRuleID = withdrawal.FeeRule.ID
+	}
+	query := `
+	INSERT INTO withdrawals (merchant_id, amount, fee, receiver, status, fee_rule_id, fee_rule, schedule_id, scheduled_transaction_id, expires_at)
+	VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::uuid, $7, $8, $9, $10)
+	RETURNING ` + withdrawalColumns
+
+	return scanWithdrawal(pw.db.QueryRow(query, withdrawal.MerchantID, withdrawal.Amount, withdrawal.Fee, withdrawal.Receiver, WithdrawalStatusPendingApproval, feeRuleID, snapshot, withdrawal.ScheduleID, withdrawal.ScheduledTransactionID, withdrawal.ExpiresAt))
+}
+
+func (pw *PostgresWithdrawalApprovalStore) GetWithdrawalByID(id string) (*Withdrawal, error) {
+	query := `
+	SELECT ` + withdrawalColumns + `
+	FROM withdrawals
+	WHERE id = $1
+	`
+	return scanOptionalWithdrawal(pw.db.QueryRow(query, id))
+}
+
+func (pw *PostgresWithdrawalApprovalStore) GetWithdrawalApprovals(withdrawalID string) ([]*WithdrawalApproval, error) {
+	query := `
+	SELECT wa.id, wa.withdrawal_id, wa.approver_id, ap.name, wa.hedera_transaction_id, wa.created_at
+	FROM withdrawal_approvals wa
+	JOIN withdrawal_approvers ap ON ap.id = wa.approver_id
+	WHERE wa.withdrawal_id = $1
+	ORDER BY wa.created_at
+	`
+	rows, err := pw.db.Query(query, withdrawalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	approvals := []*WithdrawalApproval{}
+	for rows.Next() {
+		approval := &WithdrawalApproval{}
+		err = rows.Scan(&approval.ID, &approval.WithdrawalID, &approval.ApproverID, &approval.ApproverName, &approval.HederaTransactionID, &approval.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, approval)
+	}
+	return approvals, rows.Err()
+}
+
+// AddWithdrawalApproval records a signature. It returns ErrAlreadyApproved
+// when the approver has signed the withdrawal before.
+func (pw *PostgresWithdrawalApprovalStore) AddWithdrawalApproval(approval *WithdrawalApproval) (*WithdrawalApproval, error) {
+	query := `
+	INSERT INTO withdrawal_approvals (withdrawal_id, approver_id, hedera_transaction_id)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at
+	`
+	err := pw.db.QueryRow(query, approval.WithdrawalID, approval.ApproverID, approval.HederaTransactionID).Scan(&approval.ID, &approval.CreatedAt)
+	if isUniqueViolation(err) {
+		return nil, ErrAlreadyApproved
+	}
+	if err != nil {
+		return nil, err
+	}
+	return approval, nil
+}
+
+// ResolveScheduledWithdrawal moves a withdrawal waiting on approvals to
+// status. It returns nil, nil when the withdrawal has already been resolved.
+// The expiry is not checked, since the ledger may have executed the schedule
+// just before it lapsed.
+func (pw *PostgresWithdrawalApprovalStore) ResolveScheduledWithdrawal(id string, status string) (*Withdrawal, error) {
+	query := `
+	UPDATE withdrawals
+	SET status = $2, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status = 'pending_approval'
+	RETURNING ` + withdrawalColumns
+
+	return scanOptionalWithdrawal(pw.db.QueryRow(query, id, status))
+}
+
+// GetWithdrawnSince returns how much the merchant has withdrawn since since,
+// counting withdrawals still waiting on approvals but not lapsed or failed
+// ones.
+func (pw *PostgresWithdrawalApprovalStore) GetWithdrawnSince(merchantID string, since time.Time) (money.Amount, error) {
+	query := `
+	SELECT COALESCE(SUM(amount), 0)::BIGINT
+	FROM withdrawals
+	WHERE merchant_id = $1 AND created_at >= $2
+	AND (status = 'completed' OR (status = 'pending_approval' AND expires_at > CURRENT_TIMESTAMP))
+	`
+
+	var total money.Amount
+	err := pw.db.QueryRow(query, merchantID, since).Scan(&total)
+	return total, err
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
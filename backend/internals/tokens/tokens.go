@@ -4,17 +4,50 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base32"
+	"fmt"
+	"math/big"
+	"strings"
 	"time"
 )
 
 const (
-	ScopeAuthentication = "authentication"
+	ScopeAuthentication    = "authentication"
+	ScopePasswordReset     = "password-reset"
+	ScopePhoneVerification = "phone-verification"
+	ScopeTeamInvitation    = "team-invitation"
+	ScopePaymentChallenge  = "payment-challenge"
+)
+
+// API key scopes granted to merchant integrations.
+const (
+	ScopePaymentsRead   = "payments:read"
+	ScopePaymentsCreate = "payments:create"
+)
+
+var APIKeyScopes = []string{ScopePaymentsRead, ScopePaymentsCreate}
+
+const (
+	APIKeyPrefix       = "orc_"
+	apiKeyPublicLength = 8
+)
+
+// POSDevicePrefix marks point-of-sale device tokens so they can be told
+// apart from session tokens and API keys.
+const POSDevicePrefix = "pos_"
+
+const (
+	// OTPLength is the number of digits in codes sent over SMS.
+	OTPLength = 6
+	// MaxOTPAttempts is how many wrong guesses burn an outstanding code.
+	MaxOTPAttempts = 5
 )
 
 type Token struct {
 	Plaintext string `json:"token"`
 	Hash []byte `json:"-"`
 	MerchantID string `json:"-"`
+	// MemberID is set for tokens issued to a merchant team member.
+	MemberID string `json:"-"`
 	Expiry time.Time `json:"expiry"`
 	Scope string `json:"-"`
 }
@@ -65,4 +98,176 @@ func GenerateUserToken(userID string, ttl time.Duration, scope string) (*UserTok
 	token.Hash = hash[:]
 
 	return token, nil
-}
\ No newline at end of file
+}
+
+// AdminToken is a platform operator's session.
+type AdminToken struct {
+	Plaintext string    `json:"token"`
+	Hash      []byte    `json:"-"`
+	AdminID   string    `json:"-"`
+	Expiry    time.Time `json:"expiry"`
+}
+
+func GenerateAdminToken(adminID string, ttl time.Duration) (*AdminToken, error) {
+	emptyBytes := make([]byte, 32)
+	_, err := rand.Read(emptyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &AdminToken{
+		Plaintext: base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(emptyBytes),
+		AdminID:   adminID,
+		Expiry:    time.Now().Add(ttl),
+	}
+	hash := sha256.Sum256([]byte(token.Plaintext))
+	token.Hash = hash[:]
+
+	return token, nil
+}
+
+// GenerateMerchantOTP issues a short numeric code for the given scope. OTP
+// hashes are salted with the owner ID since six digits alone would collide
+// across accounts in the tokens table.
+func GenerateMerchantOTP(merchantID string, ttl time.Duration, scope string) (*Token, error) {
+	code, err := generateOTPCode()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Token{
+		Plaintext:  code,
+		Hash:       OTPHash(merchantID, code),
+		MerchantID: merchantID,
+		Expiry:     time.Now().Add(ttl),
+		Scope:      scope,
+	}, nil
+}
+
+func GenerateUserOTP(userID string, ttl time.Duration, scope string) (*UserToken, error) {
+	code, err := generateOTPCode()
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserToken{
+		Plaintext: code,
+		Hash:      OTPHash(userID, code),
+		UserID:    userID,
+		Expiry:    time.Now().Add(ttl),
+		Scope:     scope,
+	}, nil
+}
+
+func OTPHash(ownerID string, code string) []byte {
+	hash := sha256.Sum256([]byte(ownerID + ":" + code))
+	return hash[:]
+}
+
+func generateOTPCode() (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(OTPLength), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", OTPLength, n.Int64()), nil
+}
+
+// APIKey is a long lived merchant credential of the form
+// orc_<prefix>_<secret>. The prefix is stored in clear for lookup, the full
+// key only as a hash.
+type APIKey struct {
+	Plaintext  string   `json:"key"`
+	Prefix     string   `json:"prefix"`
+	Hash       []byte   `json:"-"`
+	MerchantID string   `json:"-"`
+	Scopes     []string `json:"scopes"`
+}
+
+func GenerateAPIKey(merchantID string, scopes []string) (*APIKey, error) {
+	prefixBytes := make([]byte, 5)
+	_, err := rand.Read(prefixBytes)
+	if err != nil {
+		return nil, err
+	}
+	secretBytes := make([]byte, 32)
+	_, err = rand.Read(secretBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	prefix := strings.ToLower(encoding.EncodeToString(prefixBytes))[:apiKeyPublicLength]
+	secret := strings.ToLower(encoding.EncodeToString(secretBytes))
+
+	key := &APIKey{
+		Plaintext:  APIKeyPrefix + prefix + "_" + secret,
+		Prefix:     prefix,
+		MerchantID: merchantID,
+		Scopes:     scopes,
+	}
+	key.Hash = HashAPIKey(key.Plaintext)
+
+	return key, nil
+}
+
+func HashAPIKey(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+// ParseAPIKeyPrefix extracts the lookup prefix from a plaintext key.
+func ParseAPIKeyPrefix(plaintext string) (string, bool) {
+	if !strings.HasPrefix(plaintext, APIKeyPrefix) {
+		return "", false
+	}
+	parts := strings.Split(strings.TrimPrefix(plaintext, APIKeyPrefix), "_")
+	if len(parts) != 2 || len(parts[0]) != apiKeyPublicLength || parts[1] == "" {
+		return "", false
+	}
+	return parts[0], true
+}
+
+func IsAPIKeyScope(scope string) bool {
+	for _, s := range APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// POSDeviceToken is the credential of a registered point-of-sale device. It
+// only acts for ShopID and, like an API key, lasts until it is revoked.
+type POSDeviceToken struct {
+	Plaintext  string `json:"token"`
+	Hash       []byte `json:"-"`
+	MerchantID string `json:"-"`
+	ShopID     string `json:"shop_id"`
+}
+
+func GeneratePOSDeviceToken(merchantID string, shopID string) (*POSDeviceToken, error) {
+	secretBytes := make([]byte, 32)
+	_, err := rand.Read(secretBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &POSDeviceToken{
+		Plaintext:  POSDevicePrefix + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secretBytes)),
+		MerchantID: merchantID,
+		ShopID:     shopID,
+	}
+	token.Hash = HashPOSDeviceToken(token.Plaintext)
+
+	return token, nil
+}
+
+func HashPOSDeviceToken(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+func IsPOSDeviceToken(plaintext string) bool {
+	return strings.HasPrefix(plaintext, POSDevicePrefix)
+}
@@ -0,0 +1,65 @@
+package tokens
+
+import (
+	"crypto/sha256"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateUserOTP(t *testing.T) {
+	otp, err := GenerateUserOTP("user-1", time.Minute, ScopePhoneVerification)
+	require.NoError(t, err)
+
+	assert.Regexp(t, regexp.MustCompile(`^\d{6}$`), otp.Plaintext)
+	assert.Equal(t, OTPHash("user-1", otp.Plaintext), otp.Hash)
+	assert.NotEqual(t, OTPHash("user-2", otp.Plaintext), otp.Hash, "hash is salted with the owner")
+}
+
+func TestAPIKeyPrefix(t *testing.T) {
+	key, err := GenerateAPIKey("merchant-1", []string{ScopePaymentsRead})
+	require.NoError(t, err)
+
+	prefix, ok := ParseAPIKeyPrefix(key.Plaintext)
+	require.True(t, ok)
+	assert.Equal(t, key.Prefix, prefix)
+	assert.Equal(t, HashAPIKey(key.Plaintext), key.Hash)
+
+	tests := []string{
+		"",
+		"orc_",
+		"orc_short_secret",
+		"orc_abcdefgh_",
+		"xyz_abcdefgh_secret",
+		"orc_abcdefgh_secret_extra",
+	}
+	for _, plaintext := range tests {
+		_, ok := ParseAPIKeyPrefix(plaintext)
+		assert.False(t, ok, plaintext)
+	}
+}
+
+func TestPOSDeviceToken(t *testing.T) {
+	token, err := GeneratePOSDeviceToken("merchant-1", "shop-1")
+	require.NoError(t, err)
+
+	assert.True(t, IsPOSDeviceToken(token.Plaintext))
+	assert.False(t, IsPOSDeviceToken("orc_abcdefgh_secret"))
+	assert.Equal(t, HashPOSDeviceToken(token.Plaintext), token.Hash)
+	assert.Equal(t, "shop-1", token.ShopID)
+}
+
+func TestGenerateAdminToken(t *testing.T) {
+	token, err := GenerateAdminToken("admin-1", time.Hour)
+	require.NoError(t, err)
+
+	hash := sha256.Sum256([]byte(token.Plaintext))
+	assert.Equal(t, hash[:], token.Hash)
+	assert.Equal(t, "admin-1", token.AdminID)
+	assert.False(t, IsPOSDeviceToken(token.Plaintext))
+	_, isAPIKey := ParseAPIKeyPrefix(token.Plaintext)
+	assert.False(t, isAPIKey)
+}
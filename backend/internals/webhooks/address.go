@@ -0,0 +1,88 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned for an endpoint on a loopback, private or
+// link-local address. Webhooks are sent from inside the platform network, so
+// such an endpoint could reach internal services.
+var ErrPrivateAddress = errors.New("webhook endpoints must be on a public address")
+
+// sharedAddressSpace is carrier-grade NAT space, which is not public either.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// IsPublicAddress reports whether addr may receive webhooks.
+func IsPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() && addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// CheckURL rejects endpoint URLs that are not https or that name a host
+// which is never public. Names are only resolved when a delivery is sent,
+// where newClient checks every address it connects to.
+func CheckURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" || parsed.Scheme != "https" {
+		return errors.New("url must be an absolute https url")
+	}
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrPrivateAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !IsPublicAddress(addr) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// refusePrivateAddress is a dialer Control hook. It sees the address after
+// DNS resolution, so a name that resolves to a public address when the
+// endpoint is saved and to an internal one later is still refused.
+func refusePrivateAddress(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !IsPublicAddress(addr) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, addr)
+	}
+	return nil
+}
+
+// newClient returns the client deliveries are sent with. It ignores proxy
+// settings, only connects to public addresses and only follows redirects to
+// other https URLs.
+func newClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: refusePrivateAddress}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("stopped after 5 redirects")
+			}
+			if req.URL.Scheme != "https" {
+				return errors.New("redirect to a non-https url")
+			}
+			return nil
+		},
+	}
+}
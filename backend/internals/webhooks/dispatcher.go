@@ -0,0 +1,157 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/divin3circle/orcus/backend/internals/store"
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxAttempts is how many times a delivery is tried before it is marked
+	// failed. With BaseBackoff of 30s the last retry lands about an hour in.
+	MaxAttempts = 8
+	BaseBackoff = 30 * time.Second
+
+	pollInterval = 5 * time.Second
+	batchSize    = 20
+	leaseTime    = time.Minute
+)
+
+// Dispatcher queues webhook deliveries in the database and sends them from a
+// background loop, retrying failures with exponential backoff.
+type Dispatcher struct {
+	Store  store.WebhookStore
+	Client *http.Client
+	Logger *log.Logger
+	now    func() time.Time
+}
+
+func NewDispatcher(webhookStore store.WebhookStore, logger *log.Logger) *Dispatcher {
+	return &Dispatcher{
+		Store:  webhookStore,
+		Client: newClient(10 * time.Second),
+		Logger: logger,
+		now:    time.Now,
+	}
+}
+
+// Publish records the event for every endpoint the merchant subscribed to
+// eventType. Errors are logged since the triggering request already succeeded.
+func (d *Dispatcher) Publish(merchantID string, eventType string, data any) {
+	event := Event{
+		ID:        uuid.NewString(),
+		Type:      eventType,
+		CreatedAt: d.now().UTC(),
+		Data:      data,
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		d.Logger.Printf("ERROR: error marshalling webhook event %s: %v", eventType, err)
+		return
+	}
+
+	_, err = d.Store.CreateWebhookDeliveries(merchantID, event.ID, eventType, payload)
+	if err != nil {
+		d.Logger.Printf("ERROR: error queueing webhook deliveries at CreateWebhookDeliveries: %v", err)
+	}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.deliverDue()
+		}
+	}
+}
+
+func (d *Dispatcher) deliverDue() {
+	due, err := d.Store.ClaimDueWebhookDeliveries(batchSize, leaseTime)
+	if err != nil {
+		d.Logger.Printf("ERROR: error claiming webhook deliveries at ClaimDueWebhookDeliveries: %v", err)
+		return
+	}
+
+	for _, item := range due {
+		d.deliver(item)
+	}
+}
+
+func (d *Dispatcher) deliver(item *store.DueWebhookDelivery) {
+	delivery := item.Delivery
+	statusCode, err := d.send(item)
+
+	if err == nil {
+		err = d.Store.UpdateWebhookDelivery(delivery.ID, store.WebhookDeliverySucceeded, statusCode, "", nil)
+		if err != nil {
+			d.Logger.Printf("ERROR: error updating webhook delivery at UpdateWebhookDelivery: %v", err)
+		}
+		return
+	}
+
+	d.Logger.Printf("WARNING: webhook delivery %s attempt %d failed: %v", delivery.ID, delivery.Attempts, err)
+
+	status := store.WebhookDeliveryPending
+	var nextAttemptAt *time.Time
+	if delivery.Attempts >= MaxAttempts {
+		status = store.WebhookDeliveryFailed
+	} else {
+		next := d.now().Add(Backoff(delivery.Attempts))
+		nextAttemptAt = &next
+	}
+
+	err = d.Store.UpdateWebhookDelivery(delivery.ID, status, statusCode, err.Error(), nextAttemptAt)
+	if err != nil {
+		d.Logger.Printf("ERROR: error updating webhook delivery at UpdateWebhookDelivery: %v", err)
+	}
+}
+
+// send POSTs the payload and treats any 2xx response as delivered.
+func (d *Dispatcher) send(item *store.DueWebhookDelivery) (int, error) {
+	delivery := item.Delivery
+	body := []byte(delivery.Payload)
+
+	req, err := http.NewRequest(http.MethodPost, item.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Orcus-Webhooks/1.0")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(SignatureHeader, Sign(item.Secret, d.now().Unix(), body))
+
+	res, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("endpoint responded with status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// Backoff returns the wait after the given failed attempt: 30s, 1m, 2m, ...
+func Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	return BaseBackoff * time.Duration(1<<(attempt-1))
+}
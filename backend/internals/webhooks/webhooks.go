@@ -0,0 +1,128 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	EventPaymentCompleted    = "payment.completed"
+	EventWithdrawalCompleted = "withdrawal.completed"
+	EventCampaignJoined      = "campaign.joined"
+	EventShopCreated         = "shop.created"
+	EventCampaignCreated     = "campaign.created"
+	EventPaymentRefunded     = "payment.refunded"
+	EventPaymentRequestPaid  = "payment_request.paid"
+
+	EventWithdrawalPendingApproval = "withdrawal.pending_approval"
+	EventWithdrawalFailed          = "withdrawal.failed"
+)
+
+// EventTransferCompleted is only streamed to the two users involved.
+// Merchants cannot subscribe to it, so it is not in EventTypes.
+const EventTransferCompleted = "transfer.completed"
+
+var EventTypes = []string{
+	EventPaymentCompleted,
+	EventWithdrawalCompleted,
+	EventCampaignJoined,
+	EventShopCreated,
+	EventCampaignCreated,
+	EventPaymentRefunded,
+	EventPaymentRequestPaid,
+	EventWithdrawalPendingApproval,
+	EventWithdrawalFailed,
+}
+
+const (
+	SignatureHeader = "X-Orcus-Signature"
+	EventHeader     = "X-Orcus-Event"
+	DeliveryHeader  = "X-Orcus-Delivery"
+)
+
+// Event is the JSON body POSTed to merchant endpoints.
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// Publisher is implemented by Dispatcher; handlers depend on this so they can
+// run without webhook delivery wired up.
+type Publisher interface {
+	Publish(merchantID string, eventType string, data any)
+}
+
+func IsEventType(eventType string) bool {
+	for _, t := range EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func GenerateSecret() (string, error) {
+	secretBytes := make([]byte, 32)
+	_, err := rand.Read(secretBytes)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secretBytes)), nil
+}
+
+// Sign returns the X-Orcus-Signature value "t=<unix>,v1=<hex>" where v1 is
+// HMAC-SHA256 over "<unix>.<body>" keyed with the endpoint secret.
+func Sign(secret string, timestamp int64, body []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, computeSignature(secret, timestamp, body))
+}
+
+// Verify checks a signature header the way a receiving merchant should,
+// rejecting timestamps further than tolerance from now.
+func Verify(secret string, header string, body []byte, tolerance time.Duration, now time.Time) bool {
+	var timestamp int64
+	var signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return false
+		}
+		switch key {
+		case "t":
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return false
+			}
+			timestamp = parsed
+		case "v1":
+			signature = value
+		}
+	}
+	if timestamp == 0 || signature == "" {
+		return false
+	}
+
+	age := now.Sub(time.Unix(timestamp, 0))
+	if age > tolerance || age < -tolerance {
+		return false
+	}
+
+	expected := computeSignature(secret, timestamp, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+func computeSignature(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,72 @@
+package webhooks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/divin3circle/orcus/backend/internals/store"
+)
+
+func TestSignVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"type":"payment.completed"}`)
+	header := Sign("whsec_test", now.Unix(), body)
+
+	assert.True(t, Verify("whsec_test", header, body, 5*time.Minute, now))
+	assert.False(t, Verify("whsec_other", header, body, 5*time.Minute, now), "wrong secret")
+	assert.False(t, Verify("whsec_test", header, []byte(`{}`), 5*time.Minute, now), "tampered body")
+	assert.False(t, Verify("whsec_test", header, body, 5*time.Minute, now.Add(10*time.Minute)), "stale timestamp")
+	assert.False(t, Verify("whsec_test", "garbage", body, 5*time.Minute, now))
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, Backoff(1))
+	assert.Equal(t, time.Minute, Backoff(2))
+	assert.Equal(t, 4*time.Minute, Backoff(4))
+}
+
+func TestCheckURL(t *testing.T) {
+	assert.NoError(t, CheckURL("https://hooks.example.com/orcus"))
+	assert.NoError(t, CheckURL("https://8.8.8.8/orcus"))
+
+	for _, raw := range []string{
+		"http://hooks.example.com/orcus",
+		"ftp://hooks.example.com",
+		"/relative",
+		"https://localhost:8080/hook",
+		"https://api.localhost/hook",
+		"https://127.0.0.1/hook",
+		"https://10.0.0.5/hook",
+		"https://192.168.1.10/hook",
+		"https://169.254.169.254/latest/meta-data",
+		"https://100.64.0.1/hook",
+		"https://[::1]/hook",
+		"https://[fd00::1]/hook",
+		"https://[fe80::1]/hook",
+		"https://[::ffff:127.0.0.1]/hook",
+		"https://0.0.0.0/hook",
+	} {
+		assert.Error(t, CheckURL(raw), raw)
+	}
+}
+
+func TestDeliveriesRefusePrivateAddresses(t *testing.T) {
+	// The endpoint name is checked when it is saved, but it may resolve to an
+	// internal address by the time a delivery is sent.
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("delivery reached a loopback address")
+	}))
+	defer server.Close()
+
+	d := &Dispatcher{Client: newClient(time.Second), now: time.Now}
+	_, err := d.send(&store.DueWebhookDelivery{
+		Delivery: &store.WebhookDelivery{ID: "delivery-1", EventType: EventPaymentCompleted, Payload: []byte(`{}`)},
+		URL:      server.URL,
+		Secret:   "whsec_test",
+	})
+	assert.ErrorIs(t, err, ErrPrivateAddress)
+}
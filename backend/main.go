@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"flag"
 	"fmt"
@@ -32,6 +33,12 @@ func main() {
 
 	r := routes.SetUpRoutes(orcus)
 
+	go orcus.WebhookDispatcher.Run(context.Background())
+	go orcus.Reconciler.Run(context.Background())
+	go orcus.MandateScheduler.Run(context.Background())
+	go orcus.Anchorer.Run(context.Background())
+	go orcus.AMLMonitor.Run(context.Background())
+
 	server := &http.Server{
 		Addr:              fmt.Sprintf(":%d", port),
 		Handler:           r,
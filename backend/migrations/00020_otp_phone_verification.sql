@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN phone_verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE tokens ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tokens ADD COLUMN created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP;
+
+ALTER TABLE user_tokens ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE user_tokens ADD COLUMN created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_tokens DROP COLUMN created_at;
+ALTER TABLE user_tokens DROP COLUMN attempts;
+
+ALTER TABLE tokens DROP COLUMN created_at;
+ALTER TABLE tokens DROP COLUMN attempts;
+
+ALTER TABLE users DROP COLUMN phone_verified;
+-- +goose StatementEnd
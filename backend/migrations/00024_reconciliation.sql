@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS hedera_transaction_id VARCHAR(100);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS hedera_fee_transaction_id VARCHAR(100);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_hedera_transaction_id ON transactions(hedera_transaction_id);
+
+CREATE TABLE IF NOT EXISTS reconciliation_issues (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind VARCHAR(50) NOT NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'open',
+    merchant_id UUID REFERENCES merchants(id) ON DELETE CASCADE,
+    transaction_id UUID REFERENCES transactions(id) ON DELETE CASCADE,
+    hedera_transaction_id VARCHAR(100) NOT NULL,
+    account_id VARCHAR(50),
+    expected_amount BIGINT,
+    ledger_amount BIGINT,
+    details TEXT NOT NULL DEFAULT '',
+    detected_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMPTZ,
+    UNIQUE (kind, hedera_transaction_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconciliation_issues_merchant_id ON reconciliation_issues(merchant_id, status);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS reconciliation_issues;
+DROP INDEX IF EXISTS idx_transactions_hedera_transaction_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS hedera_fee_transaction_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS hedera_transaction_id;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Withdrawals and purchases recorded whole shillings while transactions
+-- recorded cents. Every amount column now holds cents.
+UPDATE withdrawals SET amount = amount * 100, fee = fee * 100;
+UPDATE purchases SET amount = amount * 100;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE withdrawals SET amount = amount / 100, fee = fee / 100;
+UPDATE purchases SET amount = amount / 100;
+-- +goose StatementEnd
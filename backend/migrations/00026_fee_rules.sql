@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS fee_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    transaction_type VARCHAR(20) NOT NULL,
+    merchant_id UUID REFERENCES merchants(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,
+    flat_fee BIGINT NOT NULL DEFAULT 0,
+    basis_points BIGINT NOT NULL DEFAULT 0,
+    tiers JSONB NOT NULL DEFAULT '[]',
+    min_fee BIGINT NOT NULL DEFAULT 0,
+    max_fee BIGINT,
+    rounding VARCHAR(20) NOT NULL DEFAULT 'down',
+    starts_at TIMESTAMPTZ,
+    ends_at TIMESTAMPTZ,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_fee_rules_transaction_type ON fee_rules(transaction_type, active);
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fee_rule_id UUID REFERENCES fee_rules(id) ON DELETE SET NULL;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fee_rule JSONB;
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS fee_rule_id UUID REFERENCES fee_rules(id) ON DELETE SET NULL;
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS fee_rule JSONB;
+
+-- The schedule that used to be hard coded: free up to KES 100, 0.5% above,
+-- rounded down to the cent. Withdrawals stay free until a rule is added.
+INSERT INTO fee_rules (name, transaction_type, kind, tiers, rounding)
+VALUES ('Standard payment fee', 'payment', 'tiered', '[{"up_to": 10000, "flat": 0, "basis_points": 0}, {"up_to": null, "flat": 0, "basis_points": 50}]', 'down');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE withdrawals DROP COLUMN IF EXISTS fee_rule;
+ALTER TABLE withdrawals DROP COLUMN IF EXISTS fee_rule_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS fee_rule;
+ALTER TABLE transactions DROP COLUMN IF EXISTS fee_rule_id;
+DROP TABLE IF EXISTS fee_rules;
+-- +goose StatementEnd
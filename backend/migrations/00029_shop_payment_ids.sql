@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Shops created before payment ids were resolvable may lack one.
+UPDATE shops SET payment_id = gen_random_uuid()::text WHERE payment_id IS NULL OR payment_id = '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shops_payment_id ON shops(payment_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_shops_payment_id;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS kind VARCHAR(20) NOT NULL DEFAULT 'payment';
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES transactions(id) ON DELETE CASCADE;
+
+-- A split parent is paid to several merchants, so it has no shop of its own.
+ALTER TABLE transactions ALTER COLUMN shop_id DROP NOT NULL;
+ALTER TABLE transactions ALTER COLUMN merchant_id DROP NOT NULL;
+ALTER TABLE transactions ADD CONSTRAINT transactions_payee_check
+    CHECK (kind = 'split' OR (shop_id IS NOT NULL AND merchant_id IS NOT NULL));
+
+CREATE INDEX IF NOT EXISTS idx_transactions_parent_id ON transactions(parent_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_transactions_parent_id;
+UPDATE transactions SET parent_id = NULL WHERE parent_id IS NOT NULL;
+DELETE FROM transactions WHERE kind = 'split';
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_payee_check;
+ALTER TABLE transactions ALTER COLUMN merchant_id SET NOT NULL;
+ALTER TABLE transactions ALTER COLUMN shop_id SET NOT NULL;
+ALTER TABLE transactions DROP COLUMN IF EXISTS parent_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS kind;
+-- +goose StatementEnd
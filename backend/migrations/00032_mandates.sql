@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS mandates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shop_id UUID NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+    merchant_id UUID NOT NULL REFERENCES merchants(id) ON DELETE CASCADE,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    interval_unit VARCHAR(10) NOT NULL CHECK (interval_unit IN ('day', 'week', 'month')),
+    interval_count INTEGER NOT NULL DEFAULT 1 CHECK (interval_count > 0),
+    reference TEXT NOT NULL DEFAULT '',
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ,
+    max_payments INTEGER NOT NULL DEFAULT 0 CHECK (max_payments >= 0),
+    payments_made INTEGER NOT NULL DEFAULT 0,
+    cycle INTEGER NOT NULL DEFAULT 0,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_run_at TIMESTAMPTZ,
+    last_error TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CHECK (ends_at IS NULL OR ends_at > starts_at)
+);
+
+CREATE TABLE IF NOT EXISTS mandate_runs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    mandate_id UUID NOT NULL REFERENCES mandates(id) ON DELETE CASCADE,
+    cycle INTEGER NOT NULL,
+    attempt INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_mandates_due ON mandates(next_run_at) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS idx_mandates_user_id ON mandates(user_id);
+CREATE INDEX IF NOT EXISTS idx_mandates_merchant_id ON mandates(merchant_id, status);
+CREATE INDEX IF NOT EXISTS idx_mandate_runs_mandate_id ON mandate_runs(mandate_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS mandate_runs;
+DROP TABLE IF EXISTS mandates;
+-- +goose StatementEnd
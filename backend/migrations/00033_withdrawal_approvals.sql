@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS withdrawal_approvers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    merchant_id UUID NOT NULL REFERENCES merchants(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    public_key VARCHAR(200) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_withdrawal_approvers_key ON withdrawal_approvers(merchant_id, public_key) WHERE revoked_at IS NULL;
+
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS schedule_id VARCHAR(50);
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS scheduled_transaction_id VARCHAR(100);
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS withdrawal_approvals (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    withdrawal_id UUID NOT NULL REFERENCES withdrawals(id) ON DELETE CASCADE,
+    approver_id UUID NOT NULL REFERENCES withdrawal_approvers(id) ON DELETE CASCADE,
+    hedera_transaction_id VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (withdrawal_id, approver_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS withdrawal_approvals;
+ALTER TABLE withdrawals DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE withdrawals DROP COLUMN IF EXISTS scheduled_transaction_id;
+ALTER TABLE withdrawals DROP COLUMN IF EXISTS schedule_id;
+DROP TABLE IF EXISTS withdrawal_approvers;
+-- +goose StatementEnd
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS merchant_members (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    merchant_id UUID NOT NULL REFERENCES merchants(id) ON DELETE CASCADE,
+    username VARCHAR(50) UNIQUE,
+    mobile_number VARCHAR(13) NOT NULL,
+    password_hash BYTEA,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'manager', 'cashier', 'accountant')),
+    status VARCHAR(20) NOT NULL DEFAULT 'invited' CHECK (status IN ('invited', 'active', 'disabled')),
+    invited_by UUID REFERENCES merchant_members(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_merchant_members_merchant ON merchant_members(merchant_id);
+
+CREATE TABLE IF NOT EXISTS merchant_member_shops (
+    member_id UUID NOT NULL REFERENCES merchant_members(id) ON DELETE CASCADE,
+    shop_id UUID NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+    PRIMARY KEY (member_id, shop_id)
+);
+
+-- Member sessions and invitations live alongside the owner's tokens. A NULL
+-- member_id is the owner's own login.
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS member_id UUID REFERENCES merchant_members(id) ON DELETE CASCADE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM tokens WHERE member_id IS NOT NULL;
+ALTER TABLE tokens DROP COLUMN IF EXISTS member_id;
+DROP TABLE IF EXISTS merchant_member_shops;
+DROP TABLE IF EXISTS merchant_members;
+-- +goose StatementEnd
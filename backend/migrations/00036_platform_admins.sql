@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS admins (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    username VARCHAR(50) NOT NULL UNIQUE,
+    password_hash BYTEA NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'disabled')),
+    last_login_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS admin_tokens (
+    hash BYTEA PRIMARY KEY,
+    admin_id UUID NOT NULL REFERENCES admins(id) ON DELETE CASCADE,
+    expiry TIMESTAMPTZ NOT NULL
+);
+
+-- Actions taken through the admin API. actor is kept as text so the record
+-- survives the admin being removed and covers the ADMIN_API_TOKEN login.
+CREATE TABLE IF NOT EXISTS admin_actions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    admin_id UUID REFERENCES admins(id) ON DELETE SET NULL,
+    actor VARCHAR(50) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    subject_type VARCHAR(20) NOT NULL DEFAULT '',
+    subject_id VARCHAR(100) NOT NULL DEFAULT '',
+    details JSONB NOT NULL DEFAULT '{}',
+    ip_address VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_actions_subject ON admin_actions(subject_type, subject_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_admin_actions_created ON admin_actions(created_at);
+
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS suspended_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_at TIMESTAMPTZ;
+ALTER TABLE shops ADD COLUMN IF NOT EXISTS suspended_at TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE shops DROP COLUMN IF EXISTS suspended_at;
+ALTER TABLE users DROP COLUMN IF EXISTS suspended_at;
+ALTER TABLE merchants DROP COLUMN IF EXISTS suspended_at;
+DROP TABLE IF EXISTS admin_actions;
+DROP TABLE IF EXISTS admin_tokens;
+DROP TABLE IF EXISTS admins;
+-- +goose StatementEnd
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Merkle roots of transactions and audit events, submitted to the anchoring
+-- HCS topic. An anchor stays pending until the network returns a receipt.
+CREATE TABLE IF NOT EXISTS anchors (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    root BYTEA NOT NULL,
+    leaf_count INTEGER NOT NULL CHECK (leaf_count > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'submitted')),
+    topic_id VARCHAR(50) NOT NULL DEFAULT '',
+    topic_sequence_number BIGINT NOT NULL DEFAULT 0,
+    hedera_transaction_id VARCHAR(100) NOT NULL DEFAULT '',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    submitted_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_anchors_pending ON anchors(created_at) WHERE status = 'pending';
+
+-- The leaves of each anchor in tree order. A record is anchored once.
+CREATE TABLE IF NOT EXISTS anchor_leaves (
+    anchor_id UUID NOT NULL REFERENCES anchors(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    subject_type VARCHAR(20) NOT NULL CHECK (subject_type IN ('transaction', 'audit_event')),
+    subject_id VARCHAR(100) NOT NULL,
+    leaf_hash BYTEA NOT NULL,
+    PRIMARY KEY (anchor_id, position),
+    UNIQUE (subject_type, subject_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS anchor_leaves;
+DROP TABLE IF EXISTS anchors;
+-- +goose StatementEnd
@@ -0,0 +1,65 @@
+-- +goose Up
+-- +goose StatementBegin
+-- KYC levels, lowest first: none, phone_verified, id_verified,
+-- business_verified. Users reach phone_verified through the OTP flow; the
+-- higher levels come from approved document submissions.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS kyc_level VARCHAR(30) NOT NULL DEFAULT 'none';
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS kyc_level VARCHAR(30) NOT NULL DEFAULT 'none';
+UPDATE users SET kyc_level = 'phone_verified' WHERE phone_verified;
+
+CREATE TABLE IF NOT EXISTS kyc_submissions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    subject_type VARCHAR(20) NOT NULL CHECK (subject_type IN ('user', 'merchant')),
+    subject_id UUID NOT NULL,
+    level VARCHAR(30) NOT NULL,
+    document_type VARCHAR(30) NOT NULL,
+    document_number VARCHAR(50) NOT NULL,
+    document_urls JSONB NOT NULL DEFAULT '[]',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    provider VARCHAR(30) NOT NULL,
+    provider_reference VARCHAR(100) NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    decided_by VARCHAR(50) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    decided_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_kyc_submissions_subject ON kyc_submissions(subject_type, subject_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_kyc_submissions_status ON kyc_submissions(status, created_at);
+-- One submission under review per account at a time.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_kyc_submissions_pending ON kyc_submissions(subject_type, subject_id) WHERE status = 'pending';
+
+-- Daily and monthly caps per level, in cents. An operation with no row for a
+-- level is not limited.
+CREATE TABLE IF NOT EXISTS kyc_limits (
+    level VARCHAR(30) NOT NULL,
+    operation VARCHAR(20) NOT NULL CHECK (operation IN ('payment', 'purchase', 'withdrawal')),
+    daily_limit BIGINT NOT NULL CHECK (daily_limit >= 0),
+    monthly_limit BIGINT NOT NULL CHECK (monthly_limit >= daily_limit),
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (level, operation)
+);
+
+INSERT INTO kyc_limits (level, operation, daily_limit, monthly_limit) VALUES
+    ('none', 'payment', 100000, 500000),
+    ('none', 'purchase', 100000, 500000),
+    ('none', 'withdrawal', 1000000, 5000000),
+    ('phone_verified', 'payment', 2000000, 10000000),
+    ('phone_verified', 'purchase', 2000000, 10000000),
+    ('phone_verified', 'withdrawal', 1000000, 5000000),
+    ('id_verified', 'payment', 15000000, 100000000),
+    ('id_verified', 'purchase', 15000000, 100000000),
+    ('id_verified', 'withdrawal', 15000000, 100000000),
+    ('business_verified', 'payment', 50000000, 500000000),
+    ('business_verified', 'purchase', 50000000, 500000000),
+    ('business_verified', 'withdrawal', 100000000, 2000000000)
+ON CONFLICT (level, operation) DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS kyc_limits;
+DROP TABLE IF EXISTS kyc_submissions;
+ALTER TABLE merchants DROP COLUMN IF EXISTS kyc_level;
+ALTER TABLE users DROP COLUMN IF EXISTS kyc_level;
+-- +goose StatementEnd
@@ -0,0 +1,72 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Rules every payment is screened against. Which thresholds apply depends on
+-- the kind:
+--   daily_volume               amount_threshold: most a user may pay per day
+--   velocity                   count_threshold payments per window_seconds
+--   new_account_large_payment  amount_threshold, for accounts younger than
+--                              window_seconds
+--   unusual_shop               amount_threshold, for a first payment to a shop
+-- A hit on an allow rule is recorded without holding up the payment.
+CREATE TABLE IF NOT EXISTS fraud_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    kind VARCHAR(40) NOT NULL CHECK (kind IN ('daily_volume', 'velocity', 'new_account_large_payment', 'unusual_shop')),
+    action VARCHAR(20) NOT NULL CHECK (action IN ('allow', 'challenge', 'block')),
+    amount_threshold BIGINT NOT NULL DEFAULT 0,
+    count_threshold INT NOT NULL DEFAULT 0,
+    window_seconds INT NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Only screenings that hit at least one rule are kept. A challenged payment
+-- keeps its decision once the customer confirms it, linked to the payment.
+CREATE TABLE IF NOT EXISTS fraud_decisions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shop_id UUID REFERENCES shops(id) ON DELETE SET NULL,
+    amount BIGINT NOT NULL,
+    outcome VARCHAR(20) NOT NULL CHECK (outcome IN ('allow', 'challenge', 'block')),
+    challenge_status VARCHAR(20) NOT NULL DEFAULT '' CHECK (challenge_status IN ('', 'pending', 'passed')),
+    transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL,
+    review_outcome VARCHAR(20) NOT NULL DEFAULT '' CHECK (review_outcome IN ('', 'fraud', 'legitimate')),
+    review_note TEXT NOT NULL DEFAULT '',
+    reviewed_by VARCHAR(50) NOT NULL DEFAULT '',
+    reviewed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_fraud_decisions_created ON fraud_decisions(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_fraud_decisions_user ON fraud_decisions(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_fraud_decisions_unreviewed ON fraud_decisions(created_at DESC) WHERE review_outcome = '';
+
+-- Rule hits copy the rule's name and action so later edits to the rule do
+-- not rewrite why a payment was stopped.
+CREATE TABLE IF NOT EXISTS fraud_rule_hits (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    decision_id UUID NOT NULL REFERENCES fraud_decisions(id) ON DELETE CASCADE,
+    rule_id UUID REFERENCES fraud_rules(id) ON DELETE SET NULL,
+    rule_name VARCHAR(100) NOT NULL,
+    kind VARCHAR(40) NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    detail TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_fraud_rule_hits_decision ON fraud_rule_hits(decision_id);
+
+INSERT INTO fraud_rules (name, kind, action, amount_threshold, count_threshold, window_seconds) VALUES
+    ('Daily volume above KES 100,000', 'daily_volume', 'challenge', 10000000, 0, 0),
+    ('Daily volume above KES 300,000', 'daily_volume', 'block', 30000000, 0, 0),
+    ('More than 5 payments a minute', 'velocity', 'block', 0, 5, 60),
+    ('Account under 7 days old paying over KES 20,000', 'new_account_large_payment', 'challenge', 2000000, 0, 604800),
+    ('First payment to a shop over KES 50,000', 'unusual_shop', 'challenge', 5000000, 0, 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS fraud_rule_hits;
+DROP TABLE IF EXISTS fraud_decisions;
+DROP TABLE IF EXISTS fraud_rules;
+-- +goose StatementEnd
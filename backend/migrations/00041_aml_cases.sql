@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Suspicious activity cases for compliance officers, raised by screening
+-- account and shop names against the watchlist and by structuring detection.
+-- fingerprint identifies what raised a case, so a later sweep that finds the
+-- same thing updates the open case rather than opening another, and does not
+-- reopen one an officer has closed:
+--   sanctions, pep  the list entry and the name that matched it
+--   structuring     the day the payments were made on
+-- subject_name is the name as screened, which for a merchant may be one of
+-- its shops.
+CREATE TABLE IF NOT EXISTS aml_cases (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    subject_type VARCHAR(20) NOT NULL CHECK (subject_type IN ('user', 'merchant')),
+    subject_id UUID NOT NULL,
+    subject_name VARCHAR(255) NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('sanctions', 'pep', 'structuring')),
+    fingerprint VARCHAR(255) NOT NULL,
+    summary TEXT NOT NULL,
+    evidence JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'reported', 'dismissed')),
+    report_reference VARCHAR(100) NOT NULL DEFAULT '',
+    resolution_note TEXT NOT NULL DEFAULT '',
+    resolved_by VARCHAR(50) NOT NULL DEFAULT '',
+    resolved_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_aml_cases_fingerprint ON aml_cases(subject_type, subject_id, kind, fingerprint);
+CREATE INDEX IF NOT EXISTS idx_aml_cases_status ON aml_cases(status, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_aml_cases_created ON aml_cases(created_at DESC);
+
+-- Structuring detection scans a day of payments across all users.
+CREATE INDEX IF NOT EXISTS idx_transactions_created ON transactions(created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_transactions_created;
+DROP TABLE IF EXISTS aml_cases;
+-- +goose StatementEnd
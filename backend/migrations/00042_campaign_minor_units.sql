@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Campaign entries and the distributed total recorded whole tokens while
+-- target_tokens, like the ledger, counts the token's two decimals. They now
+-- hold minor units too.
+UPDATE campaigns_entry SET token_balance = token_balance * 100;
+UPDATE campaigns SET distributed = distributed * 100;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE campaigns_entry SET token_balance = token_balance / 100;
+UPDATE campaigns SET distributed = distributed / 100;
+-- +goose StatementEnd
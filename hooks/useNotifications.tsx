@@ -4,10 +4,30 @@ import { useQuery } from "@tanstack/react-query";
 
 const BASE_TOPIC_URL = "https://testnet.mirrornode.hedera.com/api/v1/topics/";
 
+// Mirrors backend/internals/notifications/schema.json (version 1).
+export interface NotificationData {
+  transaction_id?: string;
+  hedera_transaction_id?: string;
+  amount?: number;
+  fee?: number;
+  token_id?: string;
+  account_id?: string;
+  shop_id?: string;
+  shop_name?: string;
+  counterpart_id?: string;
+  counterpart_name?: string;
+  campaign_id?: string;
+  campaign_name?: string;
+  receiver?: string;
+}
+
 export interface Notification {
+  version?: number;
   type: string;
   message_content: string;
   timestamp: number;
+  data?: NotificationData;
+  encrypted?: { alg: string; nonce: string; ciphertext: string };
   consensus_timestamp?: string;
 }
 